@@ -2,8 +2,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 
 	"audio-labeler/internal/api"
 	"audio-labeler/internal/config"
@@ -12,6 +15,7 @@ import (
 
 func main() {
 	envFile := flag.String("env", ".env", "path to .env file")
+	flag.Usage = usage
 	flag.Parse()
 
 	// Load config
@@ -21,18 +25,31 @@ func main() {
 	}
 
 	// Database
-	database, err := db.New(
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Name,
-	)
+	database, err := openDatabase(cfg.Database)
 	if err != nil {
 		log.Fatalf("DB error: %v", err)
 	}
 	defer database.Close()
-	log.Println("✓ Connected to MariaDB")
+
+	// Subcommands
+	if flag.NArg() > 0 {
+		switch flag.Arg(0) {
+		case "migrate":
+			if err := runMigrate(database, flag.Args()[1:]); err != nil {
+				log.Fatalf("Migrate error: %v", err)
+			}
+			return
+		default:
+			usage()
+			os.Exit(2)
+		}
+	}
+
+	if pending, err := database.PendingMigrations(); err != nil {
+		log.Printf("⚠ Migrations status error: %v", err)
+	} else if pending > 0 {
+		log.Printf("⚠ %d pending schema migrations, run: %s migrate up", pending, os.Args[0])
+	}
 
 	// Router (создаёт все сервисы внутри)
 	router := api.NewRouter(cfg, database)
@@ -47,14 +64,120 @@ func main() {
 	}
 }
 
+// openDatabase выбирает хранилище по DB_DRIVER
+func openDatabase(cfg config.DatabaseConfig) (db.Store, error) {
+	switch cfg.Driver {
+	case db.DriverMySQL, "mariadb":
+		database, err := db.New(cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.Name)
+		if err != nil {
+			return nil, err
+		}
+		log.Println("✓ Connected to MariaDB")
+		return database, nil
+
+	case db.DriverSQLite, "sqlite":
+		database, err := db.NewSQLite(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("✓ Opened SQLite: %s", cfg.Path)
+		return database, nil
+
+	default:
+		return nil, fmt.Errorf("unknown DB_DRIVER %q (mysql|sqlite)", cfg.Driver)
+	}
+}
+
 func printEndpoints() {
 	log.Println("\nEndpoints:")
 	log.Println("  POST /api/scan/start")
-	log.Println("  POST /api/asr/start")
-	log.Println("  POST /api/whisper-local/start")
-	log.Println("  POST /api/whisper-openai/start")
-	log.Println("  POST /api/whisper-openai/start-forced")
+	log.Println("  GET  /api/engines")
+	log.Println("  POST /api/engines/{name}/start")
+	log.Println("  GET  /api/engines/{name}/status")
+	log.Println("  POST /api/engines/{name}/stop")
+	log.Println("  POST /api/engines/{name}/retry-errors")
 	log.Println("  GET  /api/stats")
 	log.Println("  GET  /api/files")
+	log.Println("  GET  /api/files/{id}/asr-segments")
+	log.Println("  GET  /api/files/{id}/words")
+	log.Println("  GET  /api/files/{id}/hypotheses")
+	log.Println("  POST /api/sweep/start")
+	log.Println("  GET  /api/sweep/results")
+	log.Println("  GET  /api/jobs")
+	log.Println("  GET  /api/jobs/{id}")
 	log.Println("  GET  /")
 }
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [flags] [command]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  (none)                 start HTTP server")
+	fmt.Fprintln(out, "  migrate up [version]   apply pending migrations (up to version)")
+	fmt.Fprintln(out, "  migrate down [steps]   roll back last applied migrations (default 1)")
+	fmt.Fprintln(out, "  migrate status         list migrations")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// runMigrate — подкоманда migrate up|down|status
+func runMigrate(database db.MigrationRepository, args []string) error {
+	cmd := "up"
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+
+	var n int64
+	if len(args) > 1 {
+		var err error
+		if n, err = strconv.ParseInt(args[1], 10, 64); err != nil {
+			return fmt.Errorf("bad number %q", args[1])
+		}
+	}
+
+	switch cmd {
+	case "up":
+		done, err := database.MigrateUp(n)
+		for _, m := range done {
+			log.Printf("✓ Applied %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			log.Println("✓ Schema is up to date")
+		}
+
+	case "down":
+		if n <= 0 {
+			n = 1
+		}
+		done, err := database.MigrateDown(int(n))
+		for _, m := range done {
+			log.Printf("✓ Rolled back %04d_%s", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(done) == 0 {
+			log.Println("Nothing to roll back")
+		}
+
+	case "status":
+		states, err := database.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		for _, st := range states {
+			if st.Applied {
+				fmt.Printf("  ✓ %04d_%s  (%s)\n", st.Version, st.Name, st.AppliedAt.Format("2006-01-02 15:04:05"))
+			} else {
+				fmt.Printf("  · %04d_%s  pending\n", st.Version, st.Name)
+			}
+		}
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (up|down|status)", cmd)
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ go 1.24.11
 require (
 	github.com/go-sql-driver/mysql v1.9.3
 	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.33
 )
 
 require filippo.io/edwards25519 v1.1.0 // indirect
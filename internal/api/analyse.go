@@ -2,14 +2,13 @@ package api
 
 import (
 	"audio-labeler/internal/audio"
-	"log"
+	"audio-labeler/internal/service"
 	"net/http"
 	"strconv"
-	"sync"
 )
 
 // AnalyzeFile - POST /api/files/{id}/analyze
-// Запускает анализ аудио (SNR, RMS, noise level)
+// Запускает анализ аудио (SNR, RMS, noise level) и поиск дефектов
 func (h *Handlers) AnalyzeFile(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -23,8 +22,15 @@ func (h *Handlers) AnalyzeFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
 	// Запускаем анализ
-	stats, err := audio.GetStats(file.FilePath)
+	stats, err := audio.GetStats(path)
 	if err != nil {
 		h.error(w, http.StatusInternalServerError, "analyze error: "+err.Error())
 		return
@@ -37,31 +43,33 @@ func (h *Handlers) AnalyzeFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Дефекты записи
+	defects, err := audio.DetectDefects(path)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "defects error: "+err.Error())
+		return
+	}
+	if err := h.db.SaveDefects(id, defects); err != nil {
+		h.error(w, http.StatusInternalServerError, "db update error: "+err.Error())
+		return
+	}
+
 	h.success(w, map[string]interface{}{
 		"snr_db":       stats.SNREstimate,
 		"snr_sox":      stats.SNRSox,
 		"snr_wada":     stats.SNRWada,
 		"snr_spectral": stats.SNRSpectral,
+		"snr_band":     stats.SNRBand,
+		"snr_vad":      stats.SNRVad,
 		"noise_level":  stats.NoiseLevel,
 		"rms_db":       stats.RMSLevDB,
+		"loudness":     stats.Loudness,
+		"defects":      defects,
 	})
 }
 
-var analyzeRunning bool
-var analyzeProcessed int
-var analyzeTotal int
-var analyzeMu sync.Mutex
-
-// AnalyzeStart - POST /api/analyze/start
+// AnalyzeStart - POST /api/analyze/start?limit=&force=1
 func (h *Handlers) AnalyzeStart(w http.ResponseWriter, r *http.Request) {
-	analyzeMu.Lock()
-	if analyzeRunning {
-		analyzeMu.Unlock()
-		h.error(w, http.StatusConflict, "Analyze already running")
-		return
-	}
-	analyzeMu.Unlock()
-
 	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
 	if limit <= 0 {
 		limit = 100
@@ -69,13 +77,13 @@ func (h *Handlers) AnalyzeStart(w http.ResponseWriter, r *http.Request) {
 
 	force := r.URL.Query().Get("force") == "1"
 
-	files, err := h.db.GetFilesForAnalyze(limit, force)
+	queued, err := h.analyzer.Start(limit, force, startedBy(r))
 	if err != nil {
-		h.error(w, http.StatusInternalServerError, err.Error())
+		h.error(w, http.StatusConflict, err.Error())
 		return
 	}
 
-	if len(files) == 0 {
+	if queued == 0 {
 		h.success(w, map[string]interface{}{
 			"message": "No files to analyze",
 			"queued":  0,
@@ -83,54 +91,19 @@ func (h *Handlers) AnalyzeStart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	analyzeMu.Lock()
-	analyzeRunning = true
-	analyzeProcessed = 0
-	analyzeTotal = len(files)
-	analyzeMu.Unlock()
-
-	// Запускаем в фоне
-	go func() {
-		for _, file := range files {
-			stats, err := audio.GetStats(file.FilePath)
-			if err != nil {
-				log.Printf("Analyze error for %d: %v", file.ID, err)
-			} else {
-				h.db.UpdateAudioStats(file.ID, stats)
-				log.Printf("Analyzed file %d: SNR=%.1f, Noise=%s", file.ID, stats.SNREstimate, stats.NoiseLevel)
-			}
-
-			analyzeMu.Lock()
-			analyzeProcessed++
-			analyzeMu.Unlock()
-		}
-
-		analyzeMu.Lock()
-		analyzeRunning = false
-		analyzeMu.Unlock()
-		log.Printf("Analyze complete: %d files", len(files))
-	}()
-
 	h.success(w, map[string]interface{}{
 		"message": "Analyze started",
-		"queued":  len(files),
+		"queued":  queued,
 	})
 }
 
 // AnalyzeStatus - GET /api/analyze/status
 func (h *Handlers) AnalyzeStatus(w http.ResponseWriter, r *http.Request) {
-	analyzeMu.Lock()
-	defer analyzeMu.Unlock()
-
-	var percent float64
-	if analyzeTotal > 0 {
-		percent = float64(analyzeProcessed) / float64(analyzeTotal) * 100
-	}
+	h.success(w, h.analyzer.Status())
+}
 
-	h.success(w, map[string]interface{}{
-		"running":   analyzeRunning,
-		"processed": analyzeProcessed,
-		"total":     analyzeTotal,
-		"percent":   percent,
-	})
+// AnalyzeStop - POST /api/analyze/stop
+func (h *Handlers) AnalyzeStop(w http.ResponseWriter, r *http.Request) {
+	h.analyzer.Stop()
+	h.success(w, "Analyze stopped")
 }
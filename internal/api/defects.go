@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/service"
+)
+
+// validDefectFilter — any | none | unchecked | тип дефекта (см. audio.DefectTypes)
+func validDefectFilter(defect string) bool {
+	switch defect {
+	case "", "any", "none", "unchecked":
+		return true
+	}
+	return slices.Contains(audio.DefectTypes, defect)
+}
+
+// FileDefects - GET /api/files/{id}/defects
+// Сохранённые дефекты; defect_count = null — файл не проверялся
+func (h *Handlers) FileDefects(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	file, err := h.db.GetFile(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	defects := file.Defects
+	if defects == nil {
+		defects = []audio.Defect{}
+	}
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"defect_count":  file.DefectCount,
+		"defects":       defects,
+	})
+}
+
+// DetectFileDefects - POST /api/files/{id}/defects
+// Проверяет запись на клиппинг, DC offset, выпадения, сетевой фон и узкую полосу и сохраняет результат
+func (h *Handlers) DetectFileDefects(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	file, err := h.db.GetFile(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	defects, err := audio.DetectDefects(path)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "defects error: "+err.Error())
+		return
+	}
+	if err := h.db.SaveDefects(id, defects); err != nil {
+		h.error(w, http.StatusInternalServerError, "db update error: "+err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"defect_count":  len(defects),
+		"defects":       defects,
+	})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// Duplicates - GET /api/duplicates?threshold=&cross=&limit=
+// Группы почти-дубликатов: одна запись после перекодирования, ресемплинга, обрезки тишины
+// или повторной поставки. threshold — доля совпавших битов отпечатка (по умолчанию
+// DUPLICATE_THRESHOLD), cross=speaker|chapter — только группы через нескольких дикторов /
+// несколько глав: такие копии протекают между train и test
+func (h *Handlers) Duplicates(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var threshold float64
+	if v := q.Get("threshold"); v != "" {
+		var err error
+		if threshold, err = strconv.ParseFloat(v, 64); err != nil || threshold <= 0 {
+			h.error(w, http.StatusBadRequest, "invalid threshold")
+			return
+		}
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	report, err := h.duplicates.Report(threshold, q.Get("cross"), limit)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.success(w, report)
+}
+
+// FingerprintStart - POST /api/duplicates/fingerprint/start?limit=
+// Отпечатки файлов, импортированных до появления отпечатков (новые считает скан)
+func (h *Handlers) FingerprintStart(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 {
+		limit = 1000
+	}
+
+	queued, err := h.duplicates.Start(limit, startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if queued == 0 {
+		h.success(w, map[string]interface{}{
+			"message": "No files without fingerprint",
+			"queued":  0,
+		})
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message": "Fingerprinting started",
+		"queued":  queued,
+	})
+}
+
+// FingerprintStatus - GET /api/duplicates/fingerprint/status
+func (h *Handlers) FingerprintStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.duplicates.Status())
+}
+
+// FingerprintStop - POST /api/duplicates/fingerprint/stop
+func (h *Handlers) FingerprintStop(w http.ResponseWriter, r *http.Request) {
+	h.duplicates.Stop()
+	h.success(w, "Fingerprinting stopped")
+}
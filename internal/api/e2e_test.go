@@ -0,0 +1,849 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"audio-labeler/internal/api"
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/service"
+	"audio-labeler/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+// harness — приложение целиком (NewRouter на SQLite) поверх заглушек Kaldi, sox/ffmpeg,
+// Whisper, OpenAI и pyannote
+type harness struct {
+	t   *testing.T
+	dir string
+	srv *httptest.Server
+
+	whisper  *testutil.Server
+	openai   *testutil.Server
+	pyannote *testutil.Server
+}
+
+var corpus = []testutil.Utterance{
+	{Speaker: "1001", Chapter: "2001", Text: "the quick brown fox"},
+	{Speaker: "1001", Chapter: "2001", Text: "jumps over the lazy dog"},
+	{Speaker: "1001", Chapter: "2001", Text: "a stitch in time saves nine"},        // stitch нет в графе Kaldi
+	{Speaker: "1001", Chapter: "2001", Text: "hello wrold", Spoken: "hello world"}, // опечатка в эталоне
+}
+
+func newHarness(t *testing.T) *harness {
+	t.Helper()
+	h := &harness{t: t, dir: t.TempDir()}
+
+	dataDir := filepath.Join(h.dir, "data")
+	testutil.Corpus(t, dataDir, 16000, corpus...)
+	testutil.AudioTools(t)
+
+	var spoken []string
+	for _, u := range corpus {
+		if u.Spoken == "" {
+			u.Spoken = u.Text
+		}
+		spoken = append(spoken, strings.ReplaceAll(u.Spoken, "stitch", ""))
+	}
+	kaldi := testutil.NewKaldi(t, testutil.KaldiOptions{
+		Vocabulary: testutil.Vocabulary(spoken...),
+		LoadDelay:  50 * time.Millisecond,
+		CrashOn:    "lazy", // первый процесс декодера падает: проверка перезапуска и retry
+	})
+
+	h.whisper = testutil.NewWhisperLocal(t)
+	h.openai = testutil.NewOpenAI(t, "sk-test")
+	h.pyannote = testutil.NewPyannote(t)
+
+	env := map[string]string{
+		"DB_DRIVER":                 "sqlite",
+		"DB_PATH":                   filepath.Join(h.dir, "labeler.db"),
+		"DATA_DIR":                  dataDir,
+		"MERGED_DIR":                filepath.Join(h.dir, "merged"),
+		"SPLIT_DIR":                 filepath.Join(h.dir, "split"),
+		"KALDI_SERVER":              "true",
+		"KALDI_LATTICE_DIR":         filepath.Join(h.dir, "lattices"),
+		"WHISPER_LOCAL_URL":         h.whisper.URL,
+		"WHISPER_OPENAI_KEY":        "sk-test",
+		"WHISPER_OPENAI_URL":        h.openai.URL,
+		"WHISPER_OPENAI_FORMAT":     "verbose_json",
+		"WHISPER_OPENAI_TIMESTAMPS": "segment,word",
+		"PYANNOTE_URL":              h.pyannote.URL,
+		"SCAN_WORKERS":              "2",
+		"ASR_WORKERS":               "2",
+		"ASR_RETRY_BASE_DELAY_MS":   "10",
+		"ASR_RETRY_MAX_DELAY_MS":    "50",
+	}
+	for key, val := range kaldi.Env() {
+		env[key] = val
+	}
+	for key, val := range env {
+		t.Setenv(key, val)
+	}
+
+	cfg, err := config.Load(filepath.Join(h.dir, ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	database, err := db.NewSQLite(cfg.Database.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+
+	h.srv = httptest.NewServer(api.NewRouter(cfg, database))
+	t.Cleanup(h.srv.Close)
+	return h
+}
+
+// call — запрос к API; ответ с success=false валит тест. out — куда разобрать data
+func (h *harness) call(method, path string, body, out interface{}) {
+	h.t.Helper()
+
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req, err := http.NewRequest(method, h.srv.URL+path, reader)
+	if err != nil {
+		h.t.Fatal(err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		h.t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   string          `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		h.t.Fatalf("%s %s: decode: %v", method, path, err)
+	}
+	if !envelope.Success {
+		h.t.Fatalf("%s %s: %d %s", method, path, resp.StatusCode, envelope.Error)
+	}
+	if out != nil {
+		if err := json.Unmarshal(envelope.Data, out); err != nil {
+			h.t.Fatalf("%s %s: data: %v", method, path, err)
+		}
+	}
+}
+
+// wait опрашивает status endpoint, пока задача не закончится; возвращает последний статус
+func (h *harness) wait(path string) map[string]interface{} {
+	h.t.Helper()
+	deadline := time.Now().Add(60 * time.Second)
+	for {
+		var st map[string]interface{}
+		h.call("GET", path, nil, &st)
+		if st["running"] != true {
+			return st
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("%s: still running: %v", path, st)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// num — числовое поле статуса; omitempty поля отсутствуют при 0
+func num(st map[string]interface{}, key string) float64 {
+	v, _ := st[key].(float64)
+	return v
+}
+
+func (h *harness) runEngine(name, query string) map[string]interface{} {
+	h.t.Helper()
+	h.call("POST", "/api/engines/"+name+"/start"+query, nil, nil)
+	st := h.wait("/api/engines/" + name + "/status")
+	if num(st, "errors") != 0 {
+		h.t.Fatalf("%s: %v errors, last: %v", name, st["errors"], st["last_error"])
+	}
+	return st
+}
+
+// files — активные файлы по эталонной транскрипции
+func (h *harness) files() map[string]db.AudioFile {
+	h.t.Helper()
+	var list db.FileListResult
+	h.call("GET", "/api/files?limit=100&active=1", nil, &list)
+
+	files := make(map[string]db.AudioFile)
+	for _, f := range list.Files {
+		var full db.AudioFile
+		h.call("GET", fmt.Sprintf("/api/files/%d", f.ID), nil, &full)
+		files[full.TranscriptionOriginal] = full
+	}
+	return files
+}
+
+func transcription(f db.AudioFile, engine string) *db.Transcription {
+	for _, tr := range f.Transcriptions {
+		if tr.Engine == engine && tr.ModelVersion == "" {
+			return tr
+		}
+	}
+	return nil
+}
+
+func checkWER(t *testing.T, files map[string]db.AudioFile, engine string, want map[string]float64) {
+	t.Helper()
+	for text, wer := range want {
+		tr := transcription(files[text], engine)
+		if tr == nil || tr.Status != "processed" {
+			t.Errorf("%s %q: not processed: %+v", engine, text, tr)
+			continue
+		}
+		if math.Abs(tr.WER-wer) > 1e-6 {
+			t.Errorf("%s %q: WER = %.4f (%q), want %.4f", engine, text, tr.WER, tr.Text, wer)
+		}
+	}
+}
+
+// TestEndToEnd — scan → Kaldi (server mode, падение декодера) → Whisper → OpenAI →
+// LM sweep → merge → диаризация → экспорт сегментов
+func TestEndToEnd(t *testing.T) {
+	h := newHarness(t)
+
+	// Scan
+	h.call("POST", "/api/scan/start", nil, nil)
+	if st := h.wait("/api/scan/status"); num(st, "processed") != float64(len(corpus)) {
+		t.Fatalf("scan: %v", st)
+	}
+	files := h.files()
+	if len(files) != len(corpus) {
+		t.Fatalf("scanned %d files, want %d", len(files), len(corpus))
+	}
+	for text, f := range files {
+		if f.SampleRate != 16000 || f.DurationSec <= 0 || f.FileHash == "" {
+			t.Errorf("%q: metadata %+v", text, f)
+		}
+		if f.DefectCount == nil || *f.DefectCount != 0 {
+			t.Errorf("%q: defects %v %+v", text, f.DefectCount, f.Defects)
+		}
+	}
+
+	// Kaldi: один процесс на воркер, первый падает на "lazy" и перезапускается
+	st := h.runEngine("kaldi", "?workers=1")
+	if num(st, "decoder_restarts") < 1 {
+		t.Errorf("kaldi: decoder was not restarted: %v", st)
+	}
+	kaldiWER := map[string]float64{
+		"the quick brown fox":         0,
+		"jumps over the lazy dog":     0,
+		"a stitch in time saves nine": 1.0 / 6,
+		"hello wrold":                 0.5,
+	}
+	files = h.files()
+	checkWER(t, files, db.EngineKaldi, kaldiWER)
+	if tr := transcription(files["a stitch in time saves nine"], db.EngineKaldi); tr != nil && tr.Text != "a <UNK> in time saves nine" {
+		t.Errorf("kaldi OOV: %q", tr.Text)
+	}
+
+	// Kaldi без LM: lattice-scale | lattice-best-path | int2sym.pl
+	h.runEngine("kaldi-nolm", "")
+	checkWER(t, h.files(), db.EngineKaldiNoLM, kaldiWER)
+
+	// Whisper: первый запрос — 503, файл повторяется
+	h.whisper.FailNext(1, http.StatusServiceUnavailable)
+	h.runEngine("whisper-local", "")
+	files = h.files()
+	checkWER(t, files, db.EngineWhisperLocal, map[string]float64{
+		"the quick brown fox":         0,
+		"jumps over the lazy dog":     0,
+		"a stitch in time saves nine": 0,
+		"hello wrold":                 0.5,
+	})
+	if got := h.whisper.Requests(); got != int64(len(corpus))+1 {
+		t.Errorf("whisper requests = %d, want %d", got, len(corpus)+1)
+	}
+
+	var words struct {
+		Words []struct {
+			Word  string  `json:"word"`
+			Start float64 `json:"start"`
+			End   float64 `json:"end"`
+		} `json:"words"`
+	}
+	h.call("GET", fmt.Sprintf("/api/files/%d/words?engine=%s", files["the quick brown fox"].ID, db.EngineWhisperLocal), nil, &words)
+	if len(words.Words) != 4 || words.Words[3].Word != "fox" || words.Words[3].End <= words.Words[0].Start {
+		t.Errorf("whisper words: %+v", words.Words)
+	}
+
+	// OpenAI — только файлы, где Whisper local ошибся (min_wer по умолчанию 0)
+	h.runEngine("whisper-openai", "")
+	files = h.files()
+	checkWER(t, files, db.EngineWhisperOpenAI, map[string]float64{"hello wrold": 0.5})
+	if tr := transcription(files["the quick brown fox"], db.EngineWhisperOpenAI); tr != nil && tr.Status == "processed" {
+		t.Errorf("whisper-openai processed a file with Whisper local WER 0")
+	}
+	if got := h.openai.Requests(); got != 1 {
+		t.Errorf("openai requests = %d, want 1", got)
+	}
+
+	// LM sweep по сохранённым lattice: WER корпуса = 2 ошибки на 17 слов
+	h.call("POST", "/api/sweep/start?lm_scales=0.5,1&wips=0", nil, nil)
+	h.wait("/api/sweep/status")
+	var sweep struct {
+		Results []db.SweepResult `json:"results"`
+	}
+	h.call("GET", "/api/sweep/results", nil, &sweep)
+	if len(sweep.Results) != 2 {
+		t.Fatalf("sweep results: %+v", sweep.Results)
+	}
+	if r := sweep.Results[0]; !r.Best || r.WordErrors != 2 || r.RefWords != 17 {
+		t.Errorf("sweep best: %+v", r)
+	}
+
+	// Merge двух файлов: тишина в конце добавляется, аудио и эталоны склеиваются
+	first, second := files["the quick brown fox"], files["jumps over the lazy dog"]
+	var merged struct {
+		NewID      int64   `json:"new_id"`
+		OutputPath string  `json:"output_path"`
+		Duration   float64 `json:"duration"`
+	}
+	h.call("POST", "/api/merge/now", map[string]string{"ids": fmt.Sprintf("%d|%d", first.ID, second.ID)}, &merged)
+	if merged.Duration <= first.DurationSec+second.DurationSec {
+		t.Errorf("merged duration %.3f: no pause between %.3f and %.3f", merged.Duration, first.DurationSec, second.DurationSec)
+	}
+	mergedAudio, err := testutil.ReadWAV(merged.OutputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const mergedText = "the quick brown fox jumps over the lazy dog"
+	if mergedAudio.Text() != mergedText {
+		t.Errorf("merged audio: %q", mergedAudio.Text())
+	}
+	files = h.files()
+	if _, ok := files[first.TranscriptionOriginal]; ok {
+		t.Errorf("merged source is still active")
+	}
+	if f, ok := files[mergedText]; !ok || f.ID != merged.NewID {
+		t.Fatalf("merged file not found: %+v", f)
+	}
+
+	// Пауза склейки — цифровая тишина, но не выпадение: вокруг неё тихо
+	var defects struct {
+		DefectCount int `json:"defect_count"`
+	}
+	h.call("POST", fmt.Sprintf("/api/files/%d/defects", merged.NewID), nil, &defects)
+	var clean db.FileListResult
+	h.call("GET", "/api/files?defect=none", nil, &clean)
+	if defects.DefectCount != 0 || clean.Total != int64(len(files)) {
+		t.Errorf("defects: merged %d, clean files %d of %d", defects.DefectCount, clean.Total, len(files))
+	}
+
+	// Диаризация: пауза между исходными файлами делит запись на два сегмента
+	var diarized struct {
+		Segments    int `json:"segments"`
+		NumSpeakers int `json:"num_speakers"`
+	}
+	h.call("POST", fmt.Sprintf("/api/files/%d/diarize", merged.NewID), nil, &diarized)
+	if diarized.Segments != 2 || diarized.NumSpeakers != 2 {
+		t.Errorf("diarize: %+v", diarized)
+	}
+	var segments []struct {
+		Start float64 `json:"start"`
+		End   float64 `json:"end"`
+	}
+	h.call("GET", fmt.Sprintf("/api/files/%d/segments", merged.NewID), nil, &segments)
+	if len(segments) != 2 {
+		t.Fatalf("segments: %+v", segments)
+	}
+
+	// Экспорт сегментов: ffmpeg режет, файлы 8 kHz в LibriSpeech структуре
+	texts := []string{first.TranscriptionOriginal, second.TranscriptionOriginal}
+	var groups []map[string]interface{}
+	for i, seg := range segments {
+		groups = append(groups, map[string]interface{}{"start": seg.Start, "end": seg.End, "transcript": texts[i]})
+	}
+	var exported struct {
+		Created   int     `json:"created"`
+		FileIDs   []int64 `json:"file_ids"`
+		OutputDir string  `json:"output_dir"`
+	}
+	h.call("POST", fmt.Sprintf("/api/files/%d/segments/export", merged.NewID), map[string]interface{}{"groups": groups}, &exported)
+	if exported.Created != 2 || !strings.HasPrefix(exported.OutputDir, filepath.Join(h.dir, "split")) {
+		t.Fatalf("export: %+v", exported)
+	}
+	for i, id := range exported.FileIDs {
+		var f db.AudioFile
+		h.call("GET", fmt.Sprintf("/api/files/%d", id), nil, &f)
+		a, err := testutil.ReadWAV(f.FilePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Rate != 8000 || a.Text() != texts[i] || f.TranscriptionOriginal != texts[i] {
+			t.Errorf("split %d: rate %d, audio %q, reference %q", id, a.Rate, a.Text(), f.TranscriptionOriginal)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(exported.OutputDir, fmt.Sprintf("1001-%s.trans.txt", filepath.Base(exported.OutputDir)))); err != nil {
+		t.Errorf("split trans.txt: %v", err)
+	}
+
+	// Нормализация тишины: речь начинается через 100ms и без паузы в конце — обе стороны до 150ms
+	h.call("POST", "/api/silence/normalize/start?min_ms=150&max_ms=300", nil, nil)
+	if st := h.wait("/api/silence/normalize/status"); num(st, "errors") != 0 {
+		t.Errorf("silence: %v", st)
+	}
+	stitch := h.files()["a stitch in time saves nine"]
+	if !strings.HasSuffix(stitch.FilePath, "_norm.wav") || stitch.LeadingSilenceMs == nil || stitch.TrailingSilenceMs == nil ||
+		math.Abs(*stitch.LeadingSilenceMs-150) > 1 || math.Abs(*stitch.TrailingSilenceMs-150) > 1 {
+		t.Fatalf("silence normalized: %+v", stitch)
+	}
+	a, err := testutil.ReadWAV(stitch.FilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Text() != stitch.TranscriptionOriginal || math.Abs(a.Duration()-stitch.DurationSec) > 0.001 {
+		t.Errorf("silence normalized audio: %q, %.3fs", a.Text(), a.Duration())
+	}
+
+	// Нормализация громкости к -20 LUFS и откат на исходник
+	h.call("POST", "/api/loudness/normalize/start?target=-20&peak=-1", nil, nil)
+	if st := h.wait("/api/loudness/normalize/status"); num(st, "errors") != 0 {
+		t.Errorf("loudness: %v", st)
+	}
+	loud := h.files()["a stitch in time saves nine"]
+	if loud.FilePath != strings.TrimSuffix(stitch.FilePath, ".wav")+"_loud.wav" || loud.LoudnessLUFS == nil || loud.LoudnessGainDB == nil ||
+		*loud.LoudnessLUFS > -19.9 || *loud.LoudnessLUFS < -22 || *loud.TruePeakDB > -0.9 {
+		t.Fatalf("loudness normalized: %+v", loud)
+	}
+	if a, err := testutil.ReadWAV(loud.FilePath); err != nil || a.Text() != loud.TranscriptionOriginal {
+		t.Errorf("loudness normalized audio: %v %v", a, err)
+	}
+	var reverted struct {
+		FilePath string `json:"file_path"`
+	}
+	h.call("POST", fmt.Sprintf("/api/files/%d/loudness/revert", loud.ID), nil, &reverted)
+	if f := h.files()["a stitch in time saves nine"]; reverted.FilePath != stitch.FilePath || f.FilePath != stitch.FilePath ||
+		f.FileHash != stitch.FileHash || f.LoudnessGainDB != nil {
+		t.Errorf("loudness revert: %q, %+v", reverted.FilePath, f)
+	}
+}
+
+// TestScanKaldiSegments — импорт Kaldi data dir: две фразы как отрезки одной длинной записи
+func TestScanKaldiSegments(t *testing.T) {
+	h := newHarness(t)
+
+	texts := []string{"the quick brown fox", "jumps over the lazy dog"}
+	kaldiDir := filepath.Join(h.dir, "data", "vendor", "train")
+	long := &testutil.Audio{Rate: 16000}
+	var segments, text, utt2spk strings.Builder
+	for i, s := range texts {
+		start := long.Duration()
+		long.Append(testutil.Speech(s, 16000))
+		utt := fmt.Sprintf("spk9-utt%d", i)
+		fmt.Fprintf(&segments, "%s rec1 %.3f %.3f\n", utt, start, long.Duration())
+		fmt.Fprintf(&text, "%s %s\n", utt, s)
+		fmt.Fprintf(&utt2spk, "%s spk9\n", utt)
+	}
+	if err := os.MkdirAll(filepath.Join(kaldiDir, "wav"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.WriteWAV(filepath.Join(kaldiDir, "wav", "rec1.wav"), long); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"wav.scp":  "rec1 wav/rec1.wav\n",
+		"segments": segments.String(),
+		"text":     text.String(),
+		"utt2spk":  utt2spk.String(),
+	} {
+		if err := os.WriteFile(filepath.Join(kaldiDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.call("POST", "/api/scan/start?format=kaldi&dir=vendor/train", nil, nil)
+	if st := h.wait("/api/scan/status"); num(st, "processed") != float64(len(texts)) || num(st, "errors") != 0 {
+		t.Fatalf("scan: %v", st)
+	}
+	files := h.files()
+	if len(files) != len(texts) {
+		t.Fatalf("scanned %d files, want %d", len(files), len(texts))
+	}
+
+	// Отрезки виртуальные: file_path — запись, звук режется на лету
+	recPath := filepath.Join(kaldiDir, "wav", "rec1.wav")
+	for _, s := range texts {
+		f := files[s]
+		if f.UserID != "spk9" || f.ChapterID != "rec1" || f.FilePath != recPath || f.SegmentStart == nil || f.SegmentEnd == nil {
+			t.Fatalf("%q: %s/%s %s [%v, %v]", s, f.UserID, f.ChapterID, f.FilePath, f.SegmentStart, f.SegmentEnd)
+		}
+		if d := *f.SegmentEnd - *f.SegmentStart; math.Abs(f.DurationSec-d) > 1e-6 || f.SNRDB == 0 {
+			t.Errorf("%q: duration %.3f, segment %.3f, SNR %.1f", s, f.DurationSec, d, f.SNRDB)
+		}
+
+		resp, err := http.Get(fmt.Sprintf("%s/api/audio/%d", h.srv.URL, f.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		a, err := testutil.DecodeWAV(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Text() != s {
+			t.Errorf("%q: served audio %q", s, a.Text())
+		}
+	}
+
+	// ASR и анализ читают только свой отрезок
+	h.runEngine("kaldi", "?workers=1")
+	h.runEngine("whisper-local", "")
+	files = h.files()
+	for _, engine := range []string{db.EngineKaldi, db.EngineWhisperLocal} {
+		checkWER(t, files, engine, map[string]float64{texts[0]: 0, texts[1]: 0})
+	}
+	f := files[texts[1]]
+	h.call("POST", fmt.Sprintf("/api/files/%d/analyze", f.ID), nil, nil)
+
+	// Обрезка отрезка сдвигает границы в записи, файл не пишется
+	var trim map[string]interface{}
+	h.call("POST", fmt.Sprintf("/api/files/%d/trim", f.ID), map[string]float64{"start": 0.1, "end": 0.6}, &trim)
+	if got := num(trim, "segment_start"); math.Abs(got-(*f.SegmentStart+0.1)) > 1e-6 {
+		t.Errorf("trim: %v", trim)
+	}
+	var trimmed db.AudioFile
+	h.call("GET", fmt.Sprintf("/api/files/%d", f.ID), nil, &trimmed)
+	if trimmed.FilePath != recPath || math.Abs(trimmed.DurationSec-0.5) > 1e-6 || trimmed.FileHash == f.FileHash {
+		t.Errorf("trimmed: %s %.3f %s", trimmed.FilePath, trimmed.DurationSec, trimmed.FileHash)
+	}
+
+	h.call("POST", fmt.Sprintf("/api/files/%d/diarize", f.ID), nil, nil)
+	if _, err := os.Stat(filepath.Join(kaldiDir, "wav", "segments_wav")); !os.IsNotExist(err) {
+		t.Errorf("segments were materialized: %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Setenv("WATCH_AUTO_ENGINES", "whisper-local")
+	h := newHarness(t)
+	dataDir := filepath.Join(h.dir, "data")
+
+	var pass map[string]interface{}
+	h.call("POST", "/api/watch/run?force=1", nil, &pass)
+	if num(pass, "added") != float64(len(corpus)) {
+		t.Fatalf("first pass: %v", pass)
+	}
+	h.wait("/api/engines/whisper-local/status")
+
+	// Без изменений на диске проход не сверяет файлы
+	h.call("POST", "/api/watch/run", nil, &pass)
+	if pass["changed"] == true {
+		t.Fatalf("idle pass: %v", pass)
+	}
+
+	files := h.files()
+	edited := files["the quick brown fox"]
+	h.call("PUT", fmt.Sprintf("/api/files/%d/transcription", edited.ID),
+		map[string]string{"transcription": "the quick brown fox!"}, nil)
+	gone := files["a stitch in time saves nine"]
+
+	// Новая глава, правка trans.txt (в том числе строки, которую правил оператор) и удалённый WAV
+	testutil.Corpus(t, dataDir, 16000, testutil.Utterance{Speaker: "1001", Chapter: "2002", Text: "the lazy fox"})
+	trans := filepath.Join(dataDir, "1001", "2001", "1001-2001.trans.txt")
+	data, err := os.ReadFile(trans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.NewReplacer("jumps over the lazy dog", "jumps over a lazy dog", "quick brown", "quick red").Replace(string(data))
+	if err := os.WriteFile(trans, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(gone.FilePath); err != nil {
+		t.Fatal(err)
+	}
+
+	h.call("POST", "/api/watch/run", nil, &pass)
+	if pass["changed"] != true || num(pass, "added") != 1 || num(pass, "updated") != 1 || num(pass, "kept") != 1 ||
+		num(pass, "deactivated") != 1 || num(pass, "errors") != 0 {
+		t.Fatalf("pass: %v", pass)
+	}
+	if q, _ := pass["queued"].([]interface{}); len(q) != 1 || q[0] != "whisper-local" {
+		t.Errorf("queued: %v", pass["queued"])
+	}
+	h.wait("/api/engines/whisper-local/status")
+
+	files = h.files()
+	if _, ok := files["jumps over a lazy dog"]; !ok {
+		t.Errorf("disk transcription not updated")
+	}
+	if _, ok := files["the quick brown fox!"]; !ok {
+		t.Errorf("operator edit overwritten")
+	}
+	if _, ok := files[gone.TranscriptionOriginal]; ok {
+		t.Errorf("deleted file still active")
+	}
+	if f, ok := files["the lazy fox"]; !ok || transcription(f, db.EngineWhisperLocal) == nil {
+		t.Errorf("new file not imported or not transcribed: %v", ok)
+	}
+
+	var st map[string]interface{}
+	h.call("GET", "/api/watch/status", nil, &st)
+	if num(st, "passes") != 3 || st["last_change"] == nil {
+		t.Errorf("status: %v", st)
+	}
+
+	// Деактивированный файл не попадает в очередь движков (runEngine валит тест на ошибках)
+	if st := h.runEngine("kaldi-nolm", ""); num(st, "total") != float64(len(files)) {
+		t.Errorf("kaldi-nolm queued %v files, active %d", st["total"], len(files))
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	h := newHarness(t)
+	chapterDir := filepath.Join(h.dir, "data", "1001", "2001")
+	trans := filepath.Join(chapterDir, "1001-2001.trans.txt")
+
+	h.call("POST", "/api/scan/start", nil, nil)
+	h.wait("/api/scan/status")
+	files := h.files()
+
+	// Оператор правит два эталона, поставщик — два других и один из правленых;
+	// один WAV удалён, другой перезаписан
+	edit := func(text, fixed string) {
+		h.call("PUT", fmt.Sprintf("/api/files/%d/transcription", files[text].ID),
+			map[string]string{"transcription": fixed}, nil)
+	}
+	edit("the quick brown fox", "the quick brown fox!")
+	edit("jumps over the lazy dog", "jumps over the lazy dogs")
+	data, err := os.ReadFile(trans)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.NewReplacer("jumps over the lazy dog", "jumps over a lazy dog", "hello wrold", "hello world").Replace(string(data))
+	if err := os.WriteFile(trans, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(files["a stitch in time saves nine"].FilePath); err != nil {
+		t.Fatal(err)
+	}
+	if err := testutil.WriteWAV(files["hello wrold"].FilePath, testutil.Speech("hello world world", 16000)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]float64{
+		db.ConflictDBEdited:    1,
+		db.ConflictBothChanged: 1,
+		db.ConflictDiskChanged: 1,
+		db.ConflictFileMissing: 1,
+		db.ConflictHashChanged: 1,
+	}
+	reconcile := func() map[string]interface{} {
+		t.Helper()
+		h.call("POST", "/api/reconcile/start", nil, nil)
+		st := h.wait("/api/reconcile/status")
+		if num(st, "errors") != 0 {
+			t.Fatalf("reconcile: %v", st)
+		}
+		pending, _ := st["pending"].(map[string]interface{})
+		return pending
+	}
+	for run := 0; run < 2; run++ { // повторная сверка не дублирует очередь
+		pending := reconcile()
+		if len(pending) != len(want) {
+			t.Fatalf("run %d: pending %v", run, pending)
+		}
+		for kind, n := range want {
+			if num(pending, kind) != n {
+				t.Errorf("run %d: %s = %v", run, kind, pending[kind])
+			}
+		}
+	}
+
+	var queue struct {
+		Conflicts []db.ReconcileConflict `json:"conflicts"`
+	}
+	h.call("GET", "/api/reconcile/conflicts", nil, &queue)
+	conflicts := make(map[string]db.ReconcileConflict)
+	for _, c := range queue.Conflicts {
+		conflicts[c.Kind] = c
+	}
+	if c := conflicts[db.ConflictBothChanged]; c.DiskText != "jumps over a lazy dog" || c.DBText != "jumps over the lazy dogs" ||
+		c.BaseText != "jumps over the lazy dog" {
+		t.Errorf("both_changed: %+v", c)
+	}
+
+	// База побеждает: правка записывается в trans.txt
+	var res service.ResolveResult
+	h.call("POST", fmt.Sprintf("/api/reconcile/conflicts/%d/resolve", conflicts[db.ConflictDBEdited].ID),
+		map[string]string{"winner": "db"}, &res)
+	if !res.Written {
+		t.Errorf("db_edited: not written: %+v", res)
+	}
+	data, _ = os.ReadFile(trans)
+	if !strings.Contains(string(data), "1001-2001-0000 the quick brown fox!\n") {
+		t.Errorf("trans.txt:\n%s", data)
+	}
+
+	// Диск побеждает: по одному и массово по виду
+	h.call("POST", fmt.Sprintf("/api/reconcile/conflicts/%d/resolve", conflicts[db.ConflictBothChanged].ID),
+		map[string]string{"winner": "disk"}, nil)
+	var bulk map[string]interface{}
+	h.call("POST", "/api/reconcile/resolve?kind="+db.ConflictDiskChanged, map[string]string{"winner": "disk"}, &bulk)
+	h.call("POST", "/api/reconcile/resolve", map[string]interface{}{"winner": "disk",
+		"ids": []int64{conflicts[db.ConflictFileMissing].ID, conflicts[db.ConflictHashChanged].ID}}, nil)
+	if r, _ := bulk["resolved"].([]interface{}); len(r) != 1 {
+		t.Errorf("bulk: %v", bulk)
+	}
+
+	after := h.files()
+	for _, text := range []string{"the quick brown fox!", "jumps over a lazy dog", "hello world"} {
+		if _, ok := after[text]; !ok {
+			t.Errorf("%q not found after resolve", text)
+		}
+	}
+	if _, ok := after["a stitch in time saves nine"]; ok {
+		t.Error("missing file still active")
+	}
+	if f := after["hello world"]; f.FileHash == files["hello wrold"].FileHash || f.OriginalEdited {
+		t.Errorf("disk side not accepted: %+v", f)
+	}
+
+	if pending := reconcile(); len(pending) != 0 {
+		t.Errorf("conflicts after resolve: %v", pending)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	h := newHarness(t)
+	dataDir := filepath.Join(h.dir, "data")
+
+	h.call("POST", "/api/scan/start", nil, nil)
+	h.wait("/api/scan/status")
+
+	var report service.DuplicateReport
+	h.call("GET", "/api/duplicates", nil, &report)
+	if report.Files != len(corpus) || report.Unfingerprinted != 0 || report.TotalGroups != 0 {
+		t.Fatalf("clean corpus: %+v", report)
+	}
+
+	// Та же запись у другого диктора, пересэмплированная в 8kHz: md5 другой
+	testutil.Corpus(t, dataDir, 8000, testutil.Utterance{Speaker: "1002", Chapter: "3001",
+		Text: "the quick brown fox again", Spoken: "the quick brown fox"})
+	h.call("POST", "/api/scan/start?duplicates=flag", nil, nil)
+	if st := h.wait("/api/scan/status"); num(st, "processed") != 1 {
+		t.Fatalf("flag scan: %v", st)
+	}
+
+	files := h.files()
+	orig, dup := files["the quick brown fox"], files["the quick brown fox again"]
+	if dup.DuplicateOf == nil || *dup.DuplicateOf != orig.ID || *dup.DuplicateScore < 0.8 {
+		t.Fatalf("duplicate not flagged: of=%v score=%v", dup.DuplicateOf, dup.DuplicateScore)
+	}
+	if orig.DuplicateOf != nil {
+		t.Errorf("original flagged as duplicate of %d", *orig.DuplicateOf)
+	}
+
+	h.call("GET", "/api/duplicates?cross=speaker", nil, &report)
+	if report.TotalGroups != 1 || report.DuplicateFiles != 2 {
+		t.Fatalf("report: %+v", report)
+	}
+	g := report.Groups[0]
+	if g.Speakers != 2 || len(g.Pairs) != 1 || g.Pairs[0].A != orig.ID || g.Pairs[0].B != dup.ID || g.MaxSimilarity < 0.8 {
+		t.Errorf("group: %+v", g)
+	}
+	h.call("GET", "/api/duplicates?threshold=0.999", nil, &report)
+	if report.TotalGroups != 0 {
+		t.Errorf("groups above 0.999: %+v", report.Groups)
+	}
+
+	// skip: копия с другим эталоном не импортируется; из двух копий новой фразы
+	// в одном скане импортируется одна
+	testutil.Corpus(t, dataDir, 22050, testutil.Utterance{Speaker: "1002", Chapter: "3002",
+		Text: "jumps over the dog", Spoken: "jumps over the lazy dog"})
+	testutil.Corpus(t, dataDir, 16000, testutil.Utterance{Speaker: "1003", Chapter: "4001", Text: "pack my box with jugs"})
+	testutil.Corpus(t, dataDir, 8000, testutil.Utterance{Speaker: "1003", Chapter: "4002",
+		Text: "pack my box with jugs again", Spoken: "pack my box with jugs"})
+	h.call("POST", "/api/scan/start?duplicates=skip", nil, nil)
+	if st := h.wait("/api/scan/status"); num(st, "processed") != 1 || num(st, "skipped") != 7 {
+		t.Errorf("skip scan: %v", st)
+	}
+	files = h.files()
+	if _, ok := files["jumps over the dog"]; ok {
+		t.Error("duplicate imported in skip mode")
+	}
+	_, first := files["pack my box with jugs"]
+	_, second := files["pack my box with jugs again"]
+	if first == second {
+		t.Errorf("copies in one scan: imported %v and %v", first, second)
+	}
+
+	var fp map[string]interface{}
+	h.call("POST", "/api/duplicates/fingerprint/start", nil, &fp)
+	if num(fp, "queued") != 0 {
+		t.Errorf("fingerprint backfill: %v", fp)
+	}
+
+	// Обрезка меняет звук: старый отпечаток удаляется, backfill считает новый
+	h.call("POST", fmt.Sprintf("/api/files/%d/trim", orig.ID), map[string]float64{"start": 0, "end": 0.5}, nil)
+	h.call("GET", "/api/duplicates/fingerprint/status", nil, &fp)
+	if num(fp, "missing") != 1 {
+		t.Errorf("after trim: %v", fp)
+	}
+	h.call("POST", "/api/duplicates/fingerprint/start", nil, &fp)
+	if num(fp, "queued") != 1 {
+		t.Fatalf("backfill after trim: %v", fp)
+	}
+	if st := h.wait("/api/duplicates/fingerprint/status"); num(st, "processed") != 1 || num(st, "missing") != 0 {
+		t.Errorf("backfill: %v", st)
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	h := newHarness(t)
+	h.call("POST", "/api/scan/start", nil, nil)
+	h.wait("/api/scan/status")
+
+	// 401 не зависит от файла: задача останавливается после первого запроса, файлы остаются pending
+	h.whisper.FailNext(1, http.StatusUnauthorized)
+	h.call("POST", "/api/engines/whisper-local/start?workers=1", nil, nil)
+	st := h.wait("/api/engines/whisper-local/status")
+	if num(st, "processed") != 0 || num(st, "errors") != 0 || !strings.Contains(fmt.Sprint(st["last_error"]), "status 401") {
+		t.Fatalf("status: %v", st)
+	}
+	if got := h.whisper.Requests(); got != 1 {
+		t.Errorf("whisper requests = %d, want 1", got)
+	}
+	var job db.Job
+	h.call("GET", fmt.Sprintf("/api/jobs/%d", int64(num(st, "job_id"))), nil, &job)
+	if job.Status != db.JobFailed {
+		t.Errorf("job status = %s, want %s", job.Status, db.JobFailed)
+	}
+	for text, f := range h.files() {
+		if tr := transcription(f, db.EngineWhisperLocal); tr != nil && tr.Status == "error" {
+			t.Errorf("%q: %s after auth failure", text, tr.Status)
+		}
+	}
+
+	st = h.runEngine("whisper-local", "")
+	if num(st, "processed") != float64(len(corpus)) {
+		t.Errorf("rerun: %v", st)
+	}
+}
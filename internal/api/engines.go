@@ -0,0 +1,117 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"audio-labeler/internal/service"
+)
+
+// ============================================================
+// ENGINES — общие handlers для всех ASR движков
+// ============================================================
+
+// engine достаёт движок по {name}, при ошибке сам пишет ответ
+func (h *Handlers) engine(w http.ResponseWriter, r *http.Request) *service.EngineService {
+	name := r.PathValue("name")
+	svc := h.engines.Get(name)
+	if svc == nil {
+		h.error(w, http.StatusNotFound, "engine not available: "+name)
+		return nil
+	}
+	return svc
+}
+
+// EnginesList - GET /api/engines
+func (h *Handlers) EnginesList(w http.ResponseWriter, r *http.Request) {
+	var list []map[string]interface{}
+	for _, svc := range h.engines.All() {
+		list = append(list, map[string]interface{}{
+			"name":        svc.Name(),
+			"description": svc.Description(),
+			"profiles":    svc.Profiles(),
+			"status":      svc.Status(),
+		})
+	}
+	h.success(w, list)
+}
+
+// EngineStart - POST /api/engines/{name}/start?limit=&workers=&batch_size=&...
+// Остальные query параметры передаются движку как есть (min_wer, forced, budget, ...);
+// profile — именованный профиль декодирования (Kaldi), результаты с model_version = профиль
+func (h *Handlers) EngineStart(w http.ResponseWriter, r *http.Request) {
+	svc := h.engine(w, r)
+	if svc == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	opts := service.StartOptions{Params: make(map[string]string), StartedBy: startedBy(r)}
+	opts.Limit, _ = strconv.Atoi(q.Get("limit"))
+	opts.Workers, _ = strconv.Atoi(q.Get("workers"))
+	opts.BatchSize, _ = strconv.Atoi(q.Get("batch_size"))
+
+	for key := range q {
+		switch key {
+		case "limit", "workers", "batch_size":
+		default:
+			opts.Params[key] = q.Get(key)
+		}
+	}
+
+	if err := svc.Start(opts); err != nil {
+		h.error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message": svc.Description() + " started",
+		"engine":  svc.Name(),
+		"limit":   opts.Limit,
+		"workers": opts.Workers,
+		"params":  opts.Params,
+	})
+}
+
+// EngineStatus - GET /api/engines/{name}/status
+func (h *Handlers) EngineStatus(w http.ResponseWriter, r *http.Request) {
+	svc := h.engine(w, r)
+	if svc == nil {
+		return
+	}
+	h.success(w, svc.Status())
+}
+
+// EngineStop - POST /api/engines/{name}/stop
+func (h *Handlers) EngineStop(w http.ResponseWriter, r *http.Request) {
+	svc := h.engine(w, r)
+	if svc == nil {
+		return
+	}
+	svc.Stop()
+	h.success(w, svc.Description()+" stopped")
+}
+
+// EngineRetryErrors - POST /api/engines/{name}/retry-errors?max_attempts=&unclassified=1&profile=
+// Возвращает в pending только transient ошибки движка; permanent (битый WAV и т.п.) остаются
+func (h *Handlers) EngineRetryErrors(w http.ResponseWriter, r *http.Request) {
+	svc := h.engine(w, r)
+	if svc == nil {
+		return
+	}
+
+	q := r.URL.Query()
+	maxAttempts, _ := strconv.Atoi(q.Get("max_attempts"))
+	unclassified := q.Get("unclassified") == "1"
+
+	requeued, err := svc.RetryErrors(q.Get("profile"), maxAttempts, unclassified)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"engine":   svc.Name(),
+		"requeued": requeued,
+	})
+}
@@ -3,10 +3,14 @@ package api
 import (
 	"log"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
 )
 
 // === Files handlers ===
@@ -22,25 +26,32 @@ func (h *Handlers) FilesList(w http.ResponseWriter, r *http.Request) {
 	}
 
 	speaker := r.URL.Query().Get("speaker")
+	werEngine := r.URL.Query().Get("wer_engine")
 	werOp := r.URL.Query().Get("wer_op")
 	werValue, _ := strconv.ParseFloat(r.URL.Query().Get("wer_value"), 64)
 	durOp := r.URL.Query().Get("dur_op")
 	durValue, _ := strconv.ParseFloat(r.URL.Query().Get("dur_value"), 64)
 
-	asrStatus := r.URL.Query().Get("asr_status")
-	asrNoLMStatus := r.URL.Query().Get("asr_nolm_status") // <-- НОВЫЙ
-	whisperLocalStatus := r.URL.Query().Get("whisper_local_status")
-	whisperOpenaiStatus := r.URL.Query().Get("whisper_openai_status")
+	engineStatus := parseEngineStatus(r.URL.Query())
 	verified := r.URL.Query().Get("verified") // <-- НОВЫЙ
 	merged := r.URL.Query().Get("merged")
 	active := r.URL.Query().Get("active")
 	noiseLevel := r.URL.Query().Get("noise_level")
 	textSearch := r.URL.Query().Get("text")
 	chapter := r.URL.Query().Get("chapter")
+	confEngine := r.URL.Query().Get("conf_engine")
+	confMax, _ := strconv.ParseFloat(r.URL.Query().Get("conf_max"), 64)
+	sortBy := r.URL.Query().Get("sort") // confidence — сначала наименее уверенные
+	defect := r.URL.Query().Get("defect")
+	defectSeverity := r.URL.Query().Get("defect_severity")
+	if !validDefectFilter(defect) || (defectSeverity != "" && audio.SeverityRank(defectSeverity) < 0) {
+		h.error(w, http.StatusBadRequest, "invalid defect filter")
+		return
+	}
 
-	result, err := h.db.GetFilesFiltered(page, limit, speaker, werOp, werValue, durOp, durValue,
-		asrStatus, asrNoLMStatus, whisperLocalStatus, whisperOpenaiStatus,
-		verified, merged, active, noiseLevel, textSearch, chapter)
+	result, err := h.db.GetFilesFiltered(page, limit, speaker, werEngine, werOp, werValue, durOp, durValue,
+		engineStatus, verified, merged, active, noiseLevel, textSearch, chapter, confEngine, confMax, sortBy,
+		defect, defectSeverity)
 	if err != nil {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -49,6 +60,31 @@ func (h *Handlers) FilesList(w http.ResponseWriter, r *http.Request) {
 	h.success(w, result)
 }
 
+// legacyStatusParams — старые имена фильтров статуса (до таблицы transcriptions)
+var legacyStatusParams = map[string]string{
+	"asr_status":            db.EngineKaldi,
+	"asr_nolm_status":       db.EngineKaldiNoLM,
+	"whisper_local_status":  db.EngineWhisperLocal,
+	"whisper_openai_status": db.EngineWhisperOpenAI,
+}
+
+// parseEngineStatus собирает фильтры статуса по движкам:
+// status_<engine>=pending|processed|error (например status_kaldi-nolm=pending)
+func parseEngineStatus(q url.Values) map[string]string {
+	engineStatus := make(map[string]string)
+	for key := range q {
+		if engine, ok := strings.CutPrefix(key, "status_"); ok && engine != "" {
+			engineStatus[engine] = q.Get(key)
+		}
+	}
+	for param, engine := range legacyStatusParams {
+		if v := q.Get(param); v != "" {
+			engineStatus[engine] = v
+		}
+	}
+	return engineStatus
+}
+
 func (h *Handlers) FilesGet(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -65,6 +101,100 @@ func (h *Handlers) FilesGet(w http.ResponseWriter, r *http.Request) {
 	h.success(w, file)
 }
 
+// FileASRSegments - GET /api/files/{id}/asr-segments?engine=&model_version=
+// Сегменты распознавания с таймингом и уверенностью (Whisper verbose_json)
+func (h *Handlers) FileASRSegments(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	engine := r.URL.Query().Get("engine")
+	if engine == "" {
+		engine = db.EngineWhisperOpenAI
+	}
+	version := r.URL.Query().Get("model_version")
+
+	segments, err := h.db.GetTranscriptionSegments(id, engine, version)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"engine":        engine,
+		"model_version": version,
+		"segments":      segments,
+	})
+}
+
+// FileWords - GET /api/files/{id}/words?engine=&model_version=
+// Слова с таймингом и уверенностью — для подсветки при воспроизведении
+func (h *Handlers) FileWords(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	engine := r.URL.Query().Get("engine")
+	if engine == "" {
+		engine = db.PrimaryEngine
+	}
+	version := r.URL.Query().Get("model_version")
+
+	words, err := h.db.GetTranscriptionWords(id, engine, version)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"engine":        engine,
+		"model_version": version,
+		"words":         words,
+	})
+}
+
+// FileHypotheses - GET /api/files/{id}/hypotheses?engine=&model_version=
+// N-best и MBR уверенности Kaldi — помогают найти опечатки в эталонной транскрипции
+func (h *Handlers) FileHypotheses(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	engine := r.URL.Query().Get("engine")
+	if engine == "" {
+		engine = db.PrimaryEngine
+	}
+	version := r.URL.Query().Get("model_version")
+
+	hyps, err := h.db.GetTranscriptionHypotheses(id, engine, version)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if hyps == nil {
+		h.error(w, http.StatusNotFound, "transcription not found")
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"engine":        engine,
+		"model_version": version,
+		"confidence":    hyps.Confidence,
+		"lattice_path":  hyps.LatticePath,
+		"nbest":         hyps.NBest,
+		"mbr":           hyps.MBR,
+	})
+}
+
 // GetShortFiles возвращает короткие файлы сгруппированные по спикеру
 func (h *Handlers) GetShortFiles(w http.ResponseWriter, r *http.Request) {
 	maxDur, _ := strconv.ParseFloat(r.URL.Query().Get("max_duration"), 64)
@@ -142,6 +272,20 @@ func (h *Handlers) DeleteFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Виртуальный отрезок: запись общая с другими отрезками, удаляется только строка в БД
+	if file.IsSegment() {
+		if err := h.db.DeleteFile(id); err != nil {
+			h.error(w, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+		h.success(w, map[string]interface{}{
+			"message":   "Segment deleted",
+			"id":        id,
+			"file_path": file.FilePath,
+		})
+		return
+	}
+
 	// Получаем директорию и имя файла
 	dir := filepath.Dir(file.FilePath)
 	baseName := strings.TrimSuffix(filepath.Base(file.FilePath), ".wav") // 1001217-920379637-0002
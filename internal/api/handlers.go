@@ -2,46 +2,59 @@ package api
 
 import (
 	"encoding/json"
-	"fmt"
+	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"audio-labeler/internal/audio"
+	"audio-labeler/internal/config"
 	"audio-labeler/internal/db"
 	"audio-labeler/internal/metrics"
+	"audio-labeler/internal/scanner"
 	"audio-labeler/internal/service"
 )
 
 type Handlers struct {
-	db              *db.DB
+	db              db.Store
+	jobs            *service.JobManager
 	scanner         *service.Scanner
-	asr             *service.ASRService
-	asrNoLM         *service.ASRNoLMService
-	asrGPU          *service.ASRGPUService
-	asrGPUNoLM      *service.ASRGPUNoLMService
-	whisperLocal    *service.WhisperLocalService
-	whisperOpenAI   *service.WhisperOpenAIService
+	engines         *service.Registry
 	mergeService    *service.MergeService
+	analyzer        *service.AnalyzeService
+	sweep           *service.SweepService
+	silence         *service.SilenceService
+	silenceTarget   config.SilenceConfig
+	loudness        *service.LoudnessService
+	loudnessTarget  config.LoudnessConfig
+	watch           *service.WatchService
+	reconcile       *service.ReconcileService
+	duplicates      *service.DuplicateService
 	segmentHandlers *SegmentHandlers
 }
 
-func NewHandlers(db *db.DB, scanner *service.Scanner, asr *service.ASRService, asrNoLM *service.ASRNoLMService,
-	asrGPU *service.ASRGPUService, asrGPUNoLM *service.ASRGPUNoLMService,
-	whisperLocal *service.WhisperLocalService, whisperOpenAI *service.WhisperOpenAIService, mergeService *service.MergeService) *Handlers {
+func NewHandlers(db db.Store, jobs *service.JobManager, scanner *service.Scanner, engines *service.Registry,
+	mergeService *service.MergeService, analyzer *service.AnalyzeService, sweep *service.SweepService,
+	silence *service.SilenceService, silenceTarget config.SilenceConfig,
+	loudness *service.LoudnessService, loudnessTarget config.LoudnessConfig, watch *service.WatchService,
+	reconcile *service.ReconcileService, duplicates *service.DuplicateService) *Handlers {
 	return &Handlers{
-		db:            db,
-		scanner:       scanner,
-		asr:           asr,
-		asrNoLM:       asrNoLM,
-		asrGPU:        asrGPU,
-		asrGPUNoLM:    asrGPUNoLM,
-		whisperLocal:  whisperLocal,
-		whisperOpenAI: whisperOpenAI,
-		mergeService:  mergeService,
+		db:             db,
+		jobs:           jobs,
+		scanner:        scanner,
+		engines:        engines,
+		mergeService:   mergeService,
+		analyzer:       analyzer,
+		sweep:          sweep,
+		silence:        silence,
+		silenceTarget:  silenceTarget,
+		loudness:       loudness,
+		loudnessTarget: loudnessTarget,
+		watch:          watch,
+		reconcile:      reconcile,
+		duplicates:     duplicates,
 	}
 }
 
@@ -68,29 +81,49 @@ func (h *Handlers) error(w http.ResponseWriter, status int, msg string) {
 // === Health handler ===
 
 func (h *Handlers) Health(w http.ResponseWriter, r *http.Request) {
-	asrOK := h.asr != nil
+	asrOK := h.engines.Get("kaldi") != nil
 	h.success(w, map[string]interface{}{
-		"status": "ok",
-		"asr":    asrOK,
+		"status":  "ok",
+		"asr":     asrOK,
+		"engines": h.engines.Names(),
 	})
 }
 
 // === Scan handlers ===
 
+// ScanStart - POST /api/scan/start?limit=&workers=&format=&dir=&duplicates=
+// format — формат корпуса (librispeech по умолчанию, см. GET /api/scan/formats),
+// dir — подкаталог DATA_DIR с корпусом, duplicates — off | flag | skip для почти-дубликатов
+// уже импортированных файлов (по умолчанию SCAN_DUPLICATES)
 func (h *Handlers) ScanStart(w http.ResponseWriter, r *http.Request) {
-	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
-	workers, _ := strconv.Atoi(r.URL.Query().Get("workers"))
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	workers, _ := strconv.Atoi(q.Get("workers"))
+	format := q.Get("format")
 
-	err := h.scanner.Start(limit, workers)
+	err := h.scanner.Start(limit, workers, format, q.Get("dir"), q.Get("duplicates"), startedBy(r))
 	if err != nil {
 		h.error(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
+	if format == "" {
+		format = scanner.DefaultFormat
+	}
 	h.success(w, map[string]interface{}{
 		"message": "Scan started",
 		"limit":   limit,
 		"workers": workers,
+		"format":  format,
+		"dir":     q.Get("dir"),
+	})
+}
+
+// ScanFormats - GET /api/scan/formats
+func (h *Handlers) ScanFormats(w http.ResponseWriter, r *http.Request) {
+	h.success(w, map[string]interface{}{
+		"formats": scanner.Formats(),
+		"default": scanner.DefaultFormat,
 	})
 }
 
@@ -111,32 +144,7 @@ func (h *Handlers) Stats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	avgMetrics, _ := h.db.AvgMetricsAll()
-
-	result := map[string]interface{}{
-		"total":                    extended["total"],
-		"pending":                  extended["pending"],
-		"processed":                extended["processed"],
-		"errors":                   extended["errors"],
-		"verified":                 extended["verified"],
-		"needs_review":             extended["needs_review"],
-		"pending_nolm":             extended["pending_nolm"],
-		"processed_nolm":           extended["processed_nolm"],
-		"pending_whisper_local":    extended["pending_whisper_local"],
-		"processed_whisper_local":  extended["processed_whisper_local"],
-		"pending_whisper_openai":   extended["pending_whisper_openai"],
-		"processed_whisper_openai": extended["processed_whisper_openai"],
-		"kaldi_wer":                avgMetrics["kaldi_wer"],
-		"kaldi_cer":                avgMetrics["kaldi_cer"],
-		"kaldi_nolm_wer":           avgMetrics["kaldi_nolm_wer"],
-		"kaldi_nolm_cer":           avgMetrics["kaldi_nolm_cer"],
-		"whisper_local_wer":        avgMetrics["whisper_local_wer"],
-		"whisper_local_cer":        avgMetrics["whisper_local_cer"],
-		"whisper_openai_wer":       avgMetrics["whisper_openai_wer"],
-		"whisper_openai_cer":       avgMetrics["whisper_openai_cer"],
-	}
-
-	h.success(w, result)
+	h.success(w, extended)
 }
 
 // === Test/Debug handlers ===
@@ -161,6 +169,7 @@ func (h *Handlers) TestAudioStats(w http.ResponseWriter, r *http.Request) {
 		"snr": map[string]float64{
 			"sox":      stats.SNRSox,
 			"spectral": stats.SNRSpectral,
+			"band":     stats.SNRBand,
 			"vad":      stats.SNRVad,
 			"wada":     stats.SNRWada,
 			"estimate": stats.SNREstimate,
@@ -172,7 +181,7 @@ func (h *Handlers) TestAudioStats(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// ServeAudio отдает аудио файл
+// ServeAudio отдает аудио файл; виртуальный отрезок вырезается из записи на лету
 func (h *Handlers) ServeAudio(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
@@ -187,9 +196,16 @@ func (h *Handlers) ServeAudio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
 	w.Header().Set("Content-Type", "audio/wav")
 	w.Header().Set("Accept-Ranges", "bytes")
-	http.ServeFile(w, r, file.FilePath)
+	http.ServeFile(w, r, path)
 }
 
 // ServeWeb отдает веб интерфейс
@@ -221,47 +237,19 @@ func (h *Handlers) ProcessFile(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var result interface{}
-
-	switch target {
-	case "kaldi":
-		if h.asr == nil {
-			h.error(w, http.StatusServiceUnavailable, "Kaldi not configured")
-			return
-		}
-		go h.processFileKaldi(file)
-		result = "Kaldi processing started"
-
-	case "kaldi-nolm":
-		if h.asrNoLM == nil {
-			h.error(w, http.StatusServiceUnavailable, "Kaldi NoLM not configured")
-			return
-		}
-		go h.processFileKaldiNoLM(file)
-		result = "Kaldi NoLM processing started"
-
-	case "whisper-local":
-		if h.whisperLocal == nil {
-			h.error(w, http.StatusServiceUnavailable, "Whisper Local not configured")
-			return
-		}
-		go h.processFileWhisperLocal(file)
-		result = "Whisper Local processing started"
-
-	case "whisper-openai":
-		if h.whisperOpenAI == nil {
-			h.error(w, http.StatusServiceUnavailable, "Whisper OpenAI not configured")
-			return
-		}
-		go h.processFileWhisperOpenAI(file)
-		result = "Whisper OpenAI processing started"
-
-	default:
-		h.error(w, http.StatusBadRequest, "invalid target: kaldi, whisper-local, whisper-openai")
+	svc := h.engines.Get(target)
+	if svc == nil {
+		h.error(w, http.StatusBadRequest, "invalid target, available: "+strings.Join(h.engines.Names(), ", "))
 		return
 	}
 
-	h.success(w, result)
+	go func() {
+		if err := svc.ProcessFile(file); err != nil {
+			log.Printf("%s ERROR ID=%d: %v", svc.Description(), file.ID, err)
+		}
+	}()
+
+	h.success(w, svc.Description()+" processing started")
 }
 
 func (h *Handlers) RecalcWER(w http.ResponseWriter, r *http.Request) {
@@ -271,75 +259,48 @@ func (h *Handlers) RecalcWER(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	file, err := h.db.GetFileForRecalc(id)
+	list, err := h.db.GetTranscriptionsForRecalc(id)
 	if err != nil {
-		h.error(w, http.StatusNotFound, "file not found")
+		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	updated := h.recalcFile(file)
+	updated := h.recalcTranscriptions(list)
 
 	h.success(w, map[string]interface{}{
 		"id":      id,
-		"updated": updated,
+		"updated": updated > 0,
 	})
 }
 
 func (h *Handlers) RecalcAll(w http.ResponseWriter, r *http.Request) {
-	files, err := h.db.GetAllForRecalc()
+	list, err := h.db.GetTranscriptionsForRecalc(0)
 	if err != nil {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	count := 0
-	for _, file := range files {
-		if h.recalcFile(&file) {
-			count++
-		}
-	}
-
 	h.success(w, map[string]interface{}{
-		"count": count,
+		"count": h.recalcTranscriptions(list),
 	})
 }
 
-func (h *Handlers) recalcFile(file *db.AudioFileRecalc) bool {
-	updated := false
-
-	// Kaldi
-	if file.TranscriptionASR != "" {
-		wer := metrics.WER(file.TranscriptionOriginal, file.TranscriptionASR)
-		cer := metrics.CER(file.TranscriptionOriginal, file.TranscriptionASR)
-		h.db.UpdateASRMetrics(file.ID, wer, cer)
-		updated = true
-	}
-
-	// Kaldi NoLM  <-- ДОБАВИТЬ ЭТО!
-	if file.TranscriptionASRNoLM != "" {
-		wer := metrics.WER(file.TranscriptionOriginal, file.TranscriptionASRNoLM)
-		cer := metrics.CER(file.TranscriptionOriginal, file.TranscriptionASRNoLM)
-		h.db.UpdateASRNoLMMetrics(file.ID, wer, cer)
-		updated = true
-	}
-
-	// Whisper Local
-	if file.TranscriptionWhisperLocal != "" {
-		wer := metrics.WER(file.TranscriptionOriginal, file.TranscriptionWhisperLocal)
-		cer := metrics.CER(file.TranscriptionOriginal, file.TranscriptionWhisperLocal)
-		h.db.UpdateWhisperLocalMetrics(file.ID, wer, cer)
-		updated = true
-	}
-
-	// Whisper OpenAI
-	if file.TranscriptionWhisperOpenAI != "" {
-		wer := metrics.WER(file.TranscriptionOriginal, file.TranscriptionWhisperOpenAI)
-		cer := metrics.CER(file.TranscriptionOriginal, file.TranscriptionWhisperOpenAI)
-		h.db.UpdateWhisperOpenAIMetrics(file.ID, wer, cer)
-		updated = true
+// recalcTranscriptions пересчитывает WER/CER всех движков, возвращает число обновлённых
+func (h *Handlers) recalcTranscriptions(list []db.TranscriptionRecalc) int {
+	count := 0
+	for _, t := range list {
+		if t.Text == "" {
+			continue
+		}
+		wer := metrics.WER(t.TranscriptionOriginal, t.Text)
+		cer := metrics.CER(t.TranscriptionOriginal, t.Text)
+		if err := h.db.UpdateTranscriptionMetrics(t.ID, wer, cer); err != nil {
+			log.Printf("Recalc error ID=%d %s: %v", t.AudioFileID, t.Engine, err)
+			continue
+		}
+		count++
 	}
-
-	return updated
+	return count
 }
 
 // ============================================================
@@ -361,9 +322,7 @@ func (h *Handlers) UpdateTranscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Получаем файл для пересчёта WER
-	file, err := h.db.GetFile(id)
-	if err != nil {
+	if _, err := h.db.GetFile(id); err != nil {
 		h.error(w, http.StatusNotFound, "file not found")
 		return
 	}
@@ -375,30 +334,13 @@ func (h *Handlers) UpdateTranscription(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Пересчитываем WER для всех ASR систем
-	if file.TranscriptionASR != "" {
-		wer := metrics.WER(req.Transcription, file.TranscriptionASR)
-		cer := metrics.CER(req.Transcription, file.TranscriptionASR)
-		h.db.UpdateASRMetrics(id, wer, cer)
-	}
-
-	if file.TranscriptionASRNoLM != "" {
-		wer := metrics.WER(req.Transcription, file.TranscriptionASRNoLM)
-		cer := metrics.CER(req.Transcription, file.TranscriptionASRNoLM)
-		h.db.UpdateASRNoLMMetrics(id, wer, cer)
-	}
-
-	if file.TranscriptionWhisperLocal != "" {
-		wer := metrics.WER(req.Transcription, file.TranscriptionWhisperLocal)
-		cer := metrics.CER(req.Transcription, file.TranscriptionWhisperLocal)
-		h.db.UpdateWhisperLocalMetrics(id, wer, cer)
-	}
-
-	if file.TranscriptionWhisperOpenAI != "" {
-		wer := metrics.WER(req.Transcription, file.TranscriptionWhisperOpenAI)
-		cer := metrics.CER(req.Transcription, file.TranscriptionWhisperOpenAI)
-		h.db.UpdateWhisperOpenAIMetrics(id, wer, cer)
+	// Пересчитываем WER для всех ASR движков
+	list, err := h.db.GetTranscriptionsForRecalc(id)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
 	}
+	h.recalcTranscriptions(list)
 
 	h.success(w, map[string]interface{}{
 		"id":          id,
@@ -423,7 +365,14 @@ func (h *Handlers) CheckSilence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	info, err := audio.DetectTrailingSilence(file.FilePath, 100) // 100ms minimum
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	info, err := audio.DetectTrailingSilence(path, 100) // 100ms minimum
 	if err != nil {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
@@ -431,10 +380,59 @@ func (h *Handlers) CheckSilence(w http.ResponseWriter, r *http.Request) {
 
 	// Обновляем статус в БД
 	h.db.UpdateSilenceStatus(id, info.HasTrailingSilence, false)
+	h.db.UpdateSilenceEdges(id, info.LeadingSilence, info.SilenceDuration)
 
 	h.success(w, info)
 }
 
+// FileVAD - GET /api/files/{id}/vad
+// Участки речи и пауз; frame_ms, aggressiveness, hangover_ms переопределяют настройки
+func (h *Handlers) FileVAD(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	file, err := h.db.GetFile(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	opts := audio.CurrentVADOptions()
+	q := r.URL.Query()
+	if v, err := strconv.Atoi(q.Get("frame_ms")); err == nil {
+		opts.FrameMs = v
+	}
+	if v, err := strconv.Atoi(q.Get("aggressiveness")); err == nil {
+		opts.Aggressiveness = v
+	}
+	if v, err := strconv.Atoi(q.Get("hangover_ms")); err == nil {
+		opts.HangoverMs = v
+	}
+
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	vad, err := audio.VADFileWith(path, opts)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"vad":                 vad,
+		"speech_duration":     vad.SpeechDuration(),
+		"leading_silence_ms":  vad.LeadingSilence() * 1000,
+		"trailing_silence_ms": vad.TrailingSilence() * 1000,
+	})
+}
+
 func (h *Handlers) AddSilence(w http.ResponseWriter, r *http.Request) {
 	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
 	if err != nil {
@@ -448,12 +446,24 @@ func (h *Handlers) AddSilence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Создаём новый файл с тишиной
-	ext := filepath.Ext(file.FilePath)
-	base := strings.TrimSuffix(file.FilePath, ext)
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	// Создаём новый файл с тишиной (виртуальный отрезок — в segments_wav)
+	editPath := service.EditPath(file)
+	ext := filepath.Ext(editPath)
+	base := strings.TrimSuffix(editPath, ext)
 	newPath := base + "_sil" + ext
 
-	if err := audio.AddTrailingSilence(file.FilePath, newPath, 100); err != nil {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := audio.AddTrailingSilence(path, newPath, 100); err != nil {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -485,14 +495,26 @@ func (h *Handlers) RemoveSilence(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Создаём файл без тишины
-	ext := filepath.Ext(file.FilePath)
-	base := strings.TrimSuffix(file.FilePath, ext)
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	// Создаём файл без тишины (виртуальный отрезок — в segments_wav)
+	editPath := service.EditPath(file)
+	ext := filepath.Ext(editPath)
+	base := strings.TrimSuffix(editPath, ext)
 	// Убираем _sil если есть
 	base = strings.TrimSuffix(base, "_sil")
 	newPath := base + "_trimmed" + ext
 
-	if err := audio.RemoveTrailingSilence(file.FilePath, newPath); err != nil {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if err := audio.RemoveTrailingSilence(path, newPath); err != nil {
 		h.error(w, http.StatusInternalServerError, err.Error())
 		return
 	}
@@ -538,21 +560,40 @@ func (h *Handlers) TrimAudio(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Виртуальный отрезок: двигаем границы внутри записи, файл не пишется
+	if file.IsSegment() {
+		start := *file.SegmentStart + req.Start
+		end := min(*file.SegmentStart+req.End, *file.SegmentEnd)
+		if end <= start {
+			h.error(w, http.StatusBadRequest, "range is outside the segment")
+			return
+		}
+		recHash, err := audio.RecordingHash(file.FilePath)
+		if err != nil {
+			h.error(w, http.StatusInternalServerError, "hash error: "+err.Error())
+			return
+		}
+		if err := h.db.UpdateSegmentRange(id, start, end, audio.SegmentHash(recHash, start, end)); err != nil {
+			h.error(w, http.StatusInternalServerError, "db error: "+err.Error())
+			return
+		}
+		h.success(w, map[string]interface{}{
+			"message":       "Segment trimmed",
+			"new_duration":  end - start,
+			"start":         req.Start,
+			"end":           req.End,
+			"segment_start": start,
+			"segment_end":   end,
+		})
+		return
+	}
+
 	// Создаём временный файл
 	tmpPath := file.FilePath + ".tmp.wav"
 	duration := req.End - req.Start
 
-	cmd := exec.Command("ffmpeg", "-y",
-		"-i", file.FilePath,
-		"-ss", fmt.Sprintf("%.3f", req.Start),
-		"-t", fmt.Sprintf("%.3f", duration),
-		"-c:a", "pcm_s16le",
-		"-ar", fmt.Sprintf("%d", file.SampleRate),
-		"-ac", fmt.Sprintf("%d", file.Channels),
-		tmpPath,
-	)
-	if output, err := cmd.CombinedOutput(); err != nil {
-		h.error(w, http.StatusInternalServerError, "ffmpeg error: "+string(output))
+	if err := audio.CutAudio(file.FilePath, tmpPath, req.Start, duration, file.SampleRate, file.Channels); err != nil {
+		h.error(w, http.StatusInternalServerError, "cut error: "+err.Error())
 		return
 	}
 
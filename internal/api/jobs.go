@@ -0,0 +1,87 @@
+package api
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+)
+
+// ============================================================
+// JOBS — журнал пакетных задач (scan, asr, merge, analyze)
+// ============================================================
+
+// startedBy — кто запустил задачу: заголовок X-Operator или адрес клиента
+func startedBy(r *http.Request) string {
+	if op := r.Header.Get("X-Operator"); op != "" {
+		return op
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// JobsList - GET /api/jobs?type=&name=&status=&page=&limit=
+func (h *Handlers) JobsList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	page, _ := strconv.Atoi(q.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	jobs, total, err := h.jobs.List(q.Get("type"), q.Get("name"), q.Get("status"), page, limit)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"jobs":  jobs,
+		"total": total,
+		"page":  page,
+		"limit": limit,
+	})
+}
+
+// JobGet - GET /api/jobs/{id}
+func (h *Handlers) JobGet(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	job, err := h.jobs.Get(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "job not found")
+		return
+	}
+
+	h.success(w, job)
+}
+
+// JobResume - POST /api/jobs/{id}/resume
+// Продолжает прерванную (interrupted/stopped/failed) задачу с сохранёнными параметрами
+func (h *Handlers) JobResume(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	if err := h.jobs.Resume(id, startedBy(r)); err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message": "Job resumed",
+		"id":      id,
+	})
+}
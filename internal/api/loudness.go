@@ -0,0 +1,164 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/service"
+)
+
+// FileLoudness - GET /api/files/{id}/loudness
+// Измеряет громкость текущего файла (EBU R128) и сохраняет; loudness = null — тишина
+func (h *Handlers) FileLoudness(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	file, err := h.db.GetFile(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "file not found")
+		return
+	}
+
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	l, err := audio.LoudnessFile(path)
+	if err != nil && !errors.Is(err, audio.ErrNoLoudness) {
+		h.error(w, http.StatusInternalServerError, "loudness error: "+err.Error())
+		return
+	}
+	if err := h.db.UpdateLoudness(id, l); err != nil {
+		h.error(w, http.StatusInternalServerError, "db update error: "+err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id":        id,
+		"loudness":             l,
+		"loudness_gain_db":     file.LoudnessGainDB,
+		"loudness_source_path": file.LoudnessSourcePath,
+	})
+}
+
+// RevertLoudness - POST /api/files/{id}/loudness/revert
+// Возвращает файл на исходник до нормализации громкости; копия _loud остаётся на диске
+func (h *Handlers) RevertLoudness(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	file, err := h.db.GetFile(id)
+	if err != nil {
+		h.error(w, http.StatusNotFound, "file not found")
+		return
+	}
+	if file.LoudnessSourcePath == "" {
+		h.error(w, http.StatusBadRequest, "file is not loudness-normalized")
+		return
+	}
+	// после нормализации файл правили (например, тишину) — откат потерял бы правку
+	if file.FilePath != service.LoudnessPath(file.LoudnessSourcePath) {
+		h.error(w, http.StatusConflict, "file changed after loudness normalization")
+		return
+	}
+
+	hash, err := audio.MD5File(file.LoudnessSourcePath)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "source error: "+err.Error())
+		return
+	}
+	l, err := audio.LoudnessFile(file.LoudnessSourcePath)
+	if err != nil && !errors.Is(err, audio.ErrNoLoudness) {
+		h.error(w, http.StatusInternalServerError, "loudness error: "+err.Error())
+		return
+	}
+	if err := h.db.RevertLoudness(id, hash, l); err != nil {
+		h.error(w, http.StatusInternalServerError, "db update error: "+err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"audio_file_id": id,
+		"file_path":     file.LoudnessSourcePath,
+		"gain_db":       file.LoudnessGainDB,
+		"loudness":      l,
+	})
+}
+
+// LoudnessNormalizeStart - POST /api/loudness/normalize/start?target=&peak=&limit=&speaker=&chapter=&out_of_range=1&tolerance=
+// Громкость приводится к target LUFS с потолком true peak peak dBTP (по умолчанию
+// LOUDNESS_TARGET_LUFS/LOUDNESS_TRUE_PEAK_DB). out_of_range=1 — только файлы дальше
+// tolerance LU от цели (по умолчанию 1), с пиком выше потолка или ещё не измеренные
+func (h *Handlers) LoudnessNormalizeStart(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	target, peak := h.loudnessTarget.TargetLUFS, h.loudnessTarget.PeakDB
+	if v, err := strconv.ParseFloat(q.Get("target"), 64); err == nil {
+		target = v
+	}
+	if v, err := strconv.ParseFloat(q.Get("peak"), 64); err == nil {
+		peak = v
+	}
+	if target >= 0 || target < -70 || peak > 0 {
+		h.error(w, http.StatusBadRequest, "invalid target/peak")
+		return
+	}
+
+	filter := db.LoudnessFilter{
+		Speaker:    q.Get("speaker"),
+		Chapter:    q.Get("chapter"),
+		OutOfRange: q.Get("out_of_range") == "1",
+		Tolerance:  1,
+	}
+	if v, err := strconv.ParseFloat(q.Get("tolerance"), 64); err == nil && v >= 0 {
+		filter.Tolerance = v
+	}
+
+	queued, err := h.loudness.Start(limit, target, peak, filter, startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if queued == 0 {
+		h.success(w, map[string]interface{}{
+			"message": "No files to normalize",
+			"queued":  0,
+		})
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message":     "Loudness normalization started",
+		"queued":      queued,
+		"target_lufs": target,
+		"peak_db":     peak,
+	})
+}
+
+// LoudnessNormalizeStatus - GET /api/loudness/normalize/status
+func (h *Handlers) LoudnessNormalizeStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.loudness.Status())
+}
+
+// LoudnessNormalizeStop - POST /api/loudness/normalize/stop
+func (h *Handlers) LoudnessNormalizeStop(w http.ResponseWriter, r *http.Request) {
+	h.loudness.Stop()
+	h.success(w, "Loudness normalization stopped")
+}
@@ -96,7 +96,7 @@ func (h *Handlers) ProcessMergeQueue(w http.ResponseWriter, r *http.Request) {
 		limit = 100
 	}
 
-	err := h.mergeService.ProcessMergeQueue(limit)
+	err := h.mergeService.ProcessMergeQueue(limit, startedBy(r))
 	if err != nil {
 		h.error(w, http.StatusConflict, err.Error())
 		return
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/service"
+)
+
+// ReconcileStart - POST /api/reconcile/start?format=&dir=&hashes=0
+// Сверка эталонов и аудио на диске с базой; расхождения (disk_changed, db_edited,
+// both_changed, file_missing, hash_changed) попадают в очередь на разбор.
+// hashes=0 — без сверки MD5 аудио (быстро, только тексты и наличие файлов)
+func (h *Handlers) ReconcileStart(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := service.ReconcileParams{
+		Format: q.Get("format"),
+		Dir:    q.Get("dir"),
+		Hashes: q.Get("hashes") != "0",
+	}
+
+	if err := h.reconcile.Start(params, startedBy(r)); err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.success(w, map[string]interface{}{
+		"message": "Reconcile started",
+		"params":  params,
+	})
+}
+
+// ReconcileStatus - GET /api/reconcile/status
+func (h *Handlers) ReconcileStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.reconcile.Status())
+}
+
+// ReconcileStop - POST /api/reconcile/stop
+func (h *Handlers) ReconcileStop(w http.ResponseWriter, r *http.Request) {
+	h.reconcile.Stop()
+	h.success(w, "Reconcile stopped")
+}
+
+// ReconcileConflicts - GET /api/reconcile/conflicts?status=pending&kind=&job_id=&page=&limit=
+// Очередь расхождений; status=all — включая решённые
+func (h *Handlers) ReconcileConflicts(w http.ResponseWriter, r *http.Request) {
+	f, ok := h.conflictFilter(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	page, _ := strconv.Atoi(q.Get("page"))
+	limit, _ := strconv.Atoi(q.Get("limit"))
+
+	conflicts, total, err := h.db.GetReconcileConflicts(f, page, limit)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	h.success(w, map[string]interface{}{
+		"conflicts": conflicts,
+		"total":     total,
+	})
+}
+
+// ReconcileResolve - POST /api/reconcile/conflicts/{id}/resolve {"winner": "disk"|"db"}
+func (h *Handlers) ReconcileResolve(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		h.error(w, http.StatusBadRequest, "invalid id")
+		return
+	}
+
+	var req struct {
+		Winner string `json:"winner"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+
+	result, err := h.reconcile.Resolve(id, req.Winner, startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.success(w, result)
+}
+
+// ReconcileResolveBulk - POST /api/reconcile/resolve?kind=&job_id= {"winner": "disk"|"db", "ids": [...]}
+// Без ids — все нерешённые расхождения под фильтром
+func (h *Handlers) ReconcileResolveBulk(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Winner string  `json:"winner"`
+		IDs    []int64 `json:"ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.error(w, http.StatusBadRequest, "invalid json")
+		return
+	}
+	if req.Winner != db.WinnerDisk && req.Winner != db.WinnerDB {
+		h.error(w, http.StatusBadRequest, "winner must be disk or db")
+		return
+	}
+
+	ids := req.IDs
+	if len(ids) == 0 {
+		f, ok := h.conflictFilter(w, r)
+		if !ok {
+			return
+		}
+		f.Status = db.ConflictPending
+		var err error
+		if ids, err = h.db.GetReconcileConflictIDs(f); err != nil {
+			h.error(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	}
+
+	results, errs := h.reconcile.ResolveMany(ids, req.Winner, startedBy(r))
+	h.success(w, map[string]interface{}{
+		"resolved": results,
+		"errors":   errs,
+	})
+}
+
+// conflictFilter — фильтр очереди из query; по умолчанию только нерешённые
+func (h *Handlers) conflictFilter(w http.ResponseWriter, r *http.Request) (db.ConflictFilter, bool) {
+	q := r.URL.Query()
+	f := db.ConflictFilter{Kind: q.Get("kind"), Status: q.Get("status")}
+	switch f.Status {
+	case "":
+		f.Status = db.ConflictPending
+	case "all":
+		f.Status = ""
+	case db.ConflictPending, db.ConflictResolved:
+	default:
+		h.error(w, http.StatusBadRequest, "invalid status")
+		return f, false
+	}
+	f.JobID, _ = strconv.ParseInt(q.Get("job_id"), 10, 64)
+	return f, true
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"audio-labeler/internal/audio"
 	"audio-labeler/internal/config"
 	"audio-labeler/internal/db"
 	"audio-labeler/internal/segment"
@@ -16,97 +17,70 @@ type Router struct {
 	handlers *Handlers
 }
 
-func NewRouter(cfg *config.Config, database *db.DB) *Router {
+func NewRouter(cfg *config.Config, database db.Store) *Router {
+	// VAD: тишина, SNR, границы экспорта сегментов
+	audio.SetVADOptions(audio.VADOptions{
+		FrameMs:        cfg.VAD.FrameMs,
+		Aggressiveness: cfg.VAD.Aggressiveness,
+		HangoverMs:     cfg.VAD.HangoverMs,
+	})
+	vad := audio.CurrentVADOptions()
+	log.Printf("✓ VAD: frame=%dms, aggressiveness=%d, hangover=%dms", vad.FrameMs, vad.Aggressiveness, vad.HangoverMs)
+
+	// Журнал задач (до сервисов: они регистрируют в нём resume)
+	jobs := service.NewJobManager(database)
+
 	// Scanner
-	scanner := service.NewScanner(database, cfg.Data.Dir, cfg.Workers.Scan)
+	scanner := service.NewScanner(database, jobs, cfg.Data.Dir, cfg.Workers.Scan, cfg.Dedup)
 	log.Printf("✓ Scanner: %s (workers=%d)", cfg.Data.Dir, cfg.Workers.Scan)
 
-	// Kaldi ASR
-	var asrService *service.ASRService
-	if cfg.Kaldi.ModelDir != "" {
-		var err error
-		asrService, err = service.NewASRService(database, cfg.Kaldi.ModelDir, cfg.Workers.ASR)
-		if err != nil {
-			log.Printf("⚠ Kaldi ASR error: %v", err)
-		} else {
-			log.Printf("✓ Kaldi ASR: %s (workers=%d)", cfg.Kaldi.ModelDir, cfg.Workers.ASR)
-		}
-	}
+	// ASR движки (Kaldi, Whisper, ...) — см. service.RegisterEngine
+	engines := service.BuildEngines(cfg, database, jobs)
 
-	// Kaldi ASR NoLM (без LM, lm-scale=0)
-	var asrNoLMService *service.ASRNoLMService
-	if cfg.Kaldi.ModelDir != "" {
-		var err error
-		asrNoLMService, err = service.NewASRNoLMService(database, cfg.Kaldi.ModelDir, cfg.Workers.ASR)
-		if err != nil {
-			log.Printf("⚠ Kaldi ASR NoLM error: %v", err)
-		} else {
-			log.Printf("✓ Kaldi ASR NoLM: %s (lm-scale=0)", cfg.Kaldi.ModelDir)
-		}
-	}
+	// Merge Service
+	mergeService := service.NewMergeService(database, jobs, cfg.Data.MergedDir)
+	log.Printf("✓ Merge Service: output to %s", cfg.Data.MergedDir)
 
-	// Kaldi ASR GPU
-	var asrGPUService *service.ASRGPUService
-	if cfg.Kaldi.ModelDir != "" {
-		var err error
-		asrGPUService, err = service.NewASRGPUService(database, cfg.Kaldi.ModelDir, 32)
-		if err != nil {
-			log.Printf("⚠ Kaldi ASR GPU error: %v", err)
-		} else {
-			log.Printf("✓ Kaldi ASR GPU: %s (batch_size=32)", cfg.Kaldi.ModelDir)
-		}
-	}
+	analyzer := service.NewAnalyzeService(database, jobs)
+
+	// Перебор веса LM по сохранённым Kaldi lattice
+	sweep := service.NewSweepService(database, jobs, cfg)
+
+	// Нормализация тишины в начале и в конце файлов
+	silence := service.NewSilenceService(database, jobs)
+	log.Printf("✓ Silence: target %.0f-%.0fms", cfg.Silence.MinMs, cfg.Silence.MaxMs)
 
-	// Kaldi ASR GPU NoLM
-	var asrGPUNoLMService *service.ASRGPUNoLMService
-	if cfg.Kaldi.ModelDir != "" {
-		var err error
-		asrGPUNoLMService, err = service.NewASRGPUNoLMService(database, cfg.Kaldi.ModelDir, 32)
-		if err != nil {
-			log.Printf("⚠ Kaldi ASR GPU NoLM error: %v", err)
+	// Нормализация громкости (EBU R128)
+	loudness := service.NewLoudnessService(database, jobs)
+	log.Printf("✓ Loudness: target %.1f LUFS, true peak %.1f dBTP", cfg.Loudness.TargetLUFS, cfg.Loudness.PeakDB)
+
+	// Слежение за каталогом данных: инкрементальный импорт новых и изменённых файлов
+	watch := service.NewWatchService(database, jobs, scanner, engines, cfg.Data.Dir, cfg.Watch)
+	if cfg.Watch.Enabled {
+		if err := watch.Start(0, "watch"); err != nil {
+			log.Printf("⚠ Watch: %v", err)
 		} else {
-			log.Printf("✓ Kaldi ASR GPU NoLM: %s (batch_size=32, lm-scale=0)", cfg.Kaldi.ModelDir)
+			log.Printf("✓ Watch: every %ds, auto engines %v", cfg.Watch.IntervalSec, cfg.Watch.AutoEngines)
 		}
 	}
 
-	// Whisper Local
-	var whisperLocal *service.WhisperLocalService
-	if cfg.Whisper.LocalURL != "" {
-		whisperLocal = service.NewWhisperLocalService(database, cfg.Whisper.LocalURL, cfg.Whisper.Lang, 3)
-		log.Printf("✓ Whisper Local: %s", cfg.Whisper.LocalURL)
-	}
-
-	// Whisper OpenAI
-	var whisperOpenAI *service.WhisperOpenAIService
-	if cfg.Whisper.OpenAIKey != "" {
-		whisperOpenAI = service.NewWhisperOpenAIService(database, cfg.Whisper.OpenAIKey, cfg.Whisper.OpenAIModel, cfg.Whisper.Lang, 3)
-		log.Println("✓ Whisper OpenAI configured")
-	}
+	// Сверка эталонов на диске и в базе
+	reconcile := service.NewReconcileService(database, jobs, cfg.Data.Dir)
 
-	// Merge Service
-	mergeOutputDir := "/data/processed_labeler/merged" // или cfg.Data.Dir + "/merged"
-	mergeService := service.NewMergeService(database, mergeOutputDir)
-	log.Printf("✓ Merge Service: output to %s", mergeOutputDir)
+	// Почти-дубликаты по акустическому отпечатку
+	duplicates := service.NewDuplicateService(database, jobs, cfg.Dedup)
+	log.Printf("✓ Dedup: threshold %.2f, scan mode %s", cfg.Dedup.Threshold, cfg.Dedup.ScanMode)
 
 	r := &Router{
 		mux:      http.NewServeMux(),
-		handlers: NewHandlers(database, scanner, asrService, asrNoLMService, asrGPUService, asrGPUNoLMService, whisperLocal, whisperOpenAI, mergeService),
+		handlers: NewHandlers(database, jobs, scanner, engines, mergeService, analyzer, sweep, silence, cfg.Silence, loudness, cfg.Loudness, watch, reconcile, duplicates),
 	}
 
 	// Pyannote Segment Service
-	var segmentHandlers *SegmentHandlers
-	pyannoteURL := "http://127.0.0.1:8087"
-	segmentRepo := segment.NewRepository(database.DB())
-	segmentClient := segment.NewClient(pyannoteURL)
-
-	if err := segmentRepo.CreateTable(); err != nil {
-		log.Printf("⚠ Segment table error: %v", err)
-	} else {
-		segmentHandlers = NewSegmentHandlers(segmentRepo, segmentClient)
-		log.Printf("✓ Pyannote Segments: %s", pyannoteURL)
-	}
-
-	r.handlers.segmentHandlers = segmentHandlers
+	segmentRepo := database.Segments()
+	segmentClient := segment.NewClient(cfg.Pyannote.URL)
+	r.handlers.segmentHandlers = NewSegmentHandlers(segmentRepo, segmentClient, cfg.Data.SplitDir)
+	log.Printf("✓ Pyannote Segments: %s", cfg.Pyannote.URL)
 
 	r.setupRoutes()
 	return r
@@ -126,42 +100,22 @@ func (r *Router) setupRoutes() {
 	// Scan
 	r.mux.HandleFunc("POST /api/scan/start", r.handlers.ScanStart)
 	r.mux.HandleFunc("GET /api/scan/status", r.handlers.ScanStatus)
+	r.mux.HandleFunc("GET /api/scan/formats", r.handlers.ScanFormats)
 	r.mux.HandleFunc("POST /api/scan/stop", r.handlers.ScanStop)
 
-	// ASR
-	r.mux.HandleFunc("POST /api/asr/start", r.handlers.ASRStart)
-	r.mux.HandleFunc("GET /api/asr/status", r.handlers.ASRStatus)
-	r.mux.HandleFunc("POST /api/asr/stop", r.handlers.ASRStop)
-
-	// ASR NoLM (Kaldi без LM)
-	r.mux.HandleFunc("POST /api/asr-nolm/start", r.handlers.ASRNoLMStart)
-	r.mux.HandleFunc("GET /api/asr-nolm/status", r.handlers.ASRNoLMStatus)
-	r.mux.HandleFunc("POST /api/asr-nolm/stop", r.handlers.ASRNoLMStop)
-
-	// ASR GPU
-	r.mux.HandleFunc("POST /api/asr-gpu/start", r.handlers.ASRGPUStart)
-	r.mux.HandleFunc("GET /api/asr-gpu/status", r.handlers.ASRGPUStatus)
-	r.mux.HandleFunc("POST /api/asr-gpu/stop", r.handlers.ASRGPUStop)
-
-	// ASR GPU NoLM
-	r.mux.HandleFunc("POST /api/asr-gpu-nolm/start", r.handlers.ASRGPUNoLMStart)
-	r.mux.HandleFunc("GET /api/asr-gpu-nolm/status", r.handlers.ASRGPUNoLMStatus)
-	r.mux.HandleFunc("POST /api/asr-gpu-nolm/stop", r.handlers.ASRGPUNoLMStop)
-
-	// Whisper Local
-	r.mux.HandleFunc("POST /api/whisper-local/start", r.handlers.WhisperLocalStart)
-	r.mux.HandleFunc("GET /api/whisper-local/status", r.handlers.WhisperLocalStatus)
-	r.mux.HandleFunc("POST /api/whisper-local/stop", r.handlers.WhisperLocalStop)
-
-	// Whisper OpenAI
-	r.mux.HandleFunc("POST /api/whisper-openai/start", r.handlers.WhisperOpenAIStart)
-	r.mux.HandleFunc("POST /api/whisper-openai/start-forced", r.handlers.WhisperOpenAIStartForced)
-	r.mux.HandleFunc("GET /api/whisper-openai/status", r.handlers.WhisperOpenAIStatus)
-	r.mux.HandleFunc("POST /api/whisper-openai/stop", r.handlers.WhisperOpenAIStop)
+	// ASR engines (kaldi, kaldi-nolm, kaldi-gpu, kaldi-gpu-nolm, whisper-local, whisper-openai)
+	r.mux.HandleFunc("GET /api/engines", r.handlers.EnginesList)
+	r.mux.HandleFunc("POST /api/engines/{name}/start", r.handlers.EngineStart)
+	r.mux.HandleFunc("GET /api/engines/{name}/status", r.handlers.EngineStatus)
+	r.mux.HandleFunc("POST /api/engines/{name}/stop", r.handlers.EngineStop)
+	r.mux.HandleFunc("POST /api/engines/{name}/retry-errors", r.handlers.EngineRetryErrors)
 
 	// Data files
 	r.mux.HandleFunc("GET /api/files", r.handlers.FilesList)
 	r.mux.HandleFunc("GET /api/files/{id}", r.handlers.FilesGet)
+	r.mux.HandleFunc("GET /api/files/{id}/asr-segments", r.handlers.FileASRSegments)
+	r.mux.HandleFunc("GET /api/files/{id}/words", r.handlers.FileWords)
+	r.mux.HandleFunc("GET /api/files/{id}/hypotheses", r.handlers.FileHypotheses)
 	r.mux.HandleFunc("GET /api/audio/{id}", r.handlers.ServeAudio)
 
 	// Edit transcription (редактирование оригинала)
@@ -176,9 +130,12 @@ func (r *Router) setupRoutes() {
 
 	// Silence
 	r.mux.HandleFunc("GET /api/files/{id}/silence", r.handlers.CheckSilence)
+	r.mux.HandleFunc("GET /api/files/{id}/vad", r.handlers.FileVAD)
 	r.mux.HandleFunc("POST /api/files/{id}/add-silence", r.handlers.AddSilence)
 	r.mux.HandleFunc("POST /api/files/{id}/remove-silence", r.handlers.RemoveSilence)
 	r.mux.HandleFunc("POST /api/files/{id}/analyze", r.handlers.AnalyzeFile)
+	r.mux.HandleFunc("GET /api/files/{id}/defects", r.handlers.FileDefects)
+	r.mux.HandleFunc("POST /api/files/{id}/defects", r.handlers.DetectFileDefects)
 
 	// Process single file
 	r.mux.HandleFunc("POST /api/process/{id}", r.handlers.ProcessFile)
@@ -203,6 +160,50 @@ func (r *Router) setupRoutes() {
 
 	r.mux.HandleFunc("POST /api/analyze/start", r.handlers.AnalyzeStart)
 	r.mux.HandleFunc("GET /api/analyze/status", r.handlers.AnalyzeStatus)
+	r.mux.HandleFunc("POST /api/analyze/stop", r.handlers.AnalyzeStop)
+
+	// Silence normalization (leading/trailing)
+	r.mux.HandleFunc("POST /api/silence/normalize/start", r.handlers.SilenceNormalizeStart)
+	r.mux.HandleFunc("GET /api/silence/normalize/status", r.handlers.SilenceNormalizeStatus)
+	r.mux.HandleFunc("POST /api/silence/normalize/stop", r.handlers.SilenceNormalizeStop)
+
+	// Loudness (EBU R128)
+	r.mux.HandleFunc("GET /api/files/{id}/loudness", r.handlers.FileLoudness)
+	r.mux.HandleFunc("POST /api/files/{id}/loudness/revert", r.handlers.RevertLoudness)
+	r.mux.HandleFunc("POST /api/loudness/normalize/start", r.handlers.LoudnessNormalizeStart)
+	r.mux.HandleFunc("GET /api/loudness/normalize/status", r.handlers.LoudnessNormalizeStatus)
+	r.mux.HandleFunc("POST /api/loudness/normalize/stop", r.handlers.LoudnessNormalizeStop)
+
+	// Слежение за каталогом данных
+	r.mux.HandleFunc("POST /api/watch/start", r.handlers.WatchStart)
+	r.mux.HandleFunc("GET /api/watch/status", r.handlers.WatchStatus)
+	r.mux.HandleFunc("POST /api/watch/stop", r.handlers.WatchStop)
+	r.mux.HandleFunc("POST /api/watch/run", r.handlers.WatchRun)
+
+	// Сверка эталонов на диске и в базе, очередь расхождений
+	r.mux.HandleFunc("POST /api/reconcile/start", r.handlers.ReconcileStart)
+	r.mux.HandleFunc("GET /api/reconcile/status", r.handlers.ReconcileStatus)
+	r.mux.HandleFunc("POST /api/reconcile/stop", r.handlers.ReconcileStop)
+	r.mux.HandleFunc("GET /api/reconcile/conflicts", r.handlers.ReconcileConflicts)
+	r.mux.HandleFunc("POST /api/reconcile/conflicts/{id}/resolve", r.handlers.ReconcileResolve)
+	r.mux.HandleFunc("POST /api/reconcile/resolve", r.handlers.ReconcileResolveBulk)
+
+	// Почти-дубликаты по акустическому отпечатку
+	r.mux.HandleFunc("GET /api/duplicates", r.handlers.Duplicates)
+	r.mux.HandleFunc("POST /api/duplicates/fingerprint/start", r.handlers.FingerprintStart)
+	r.mux.HandleFunc("GET /api/duplicates/fingerprint/status", r.handlers.FingerprintStatus)
+	r.mux.HandleFunc("POST /api/duplicates/fingerprint/stop", r.handlers.FingerprintStop)
+
+	// LM-weight sweep (Kaldi lattices)
+	r.mux.HandleFunc("POST /api/sweep/start", r.handlers.SweepStart)
+	r.mux.HandleFunc("GET /api/sweep/status", r.handlers.SweepStatus)
+	r.mux.HandleFunc("POST /api/sweep/stop", r.handlers.SweepStop)
+	r.mux.HandleFunc("GET /api/sweep/results", r.handlers.SweepResults)
+
+	// Jobs (история и resume пакетных задач)
+	r.mux.HandleFunc("GET /api/jobs", r.handlers.JobsList)
+	r.mux.HandleFunc("GET /api/jobs/{id}", r.handlers.JobGet)
+	r.mux.HandleFunc("POST /api/jobs/{id}/resume", r.handlers.JobResume)
 
 	// Segments (Pyannote)
 	if r.handlers.segmentHandlers != nil {
@@ -6,25 +6,27 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
 
 	"audio-labeler/internal/audio"
 	"audio-labeler/internal/segment"
+	"audio-labeler/internal/service"
 )
 
 // SegmentHandlers - handlers для работы с сегментами
 type SegmentHandlers struct {
-	repo   *segment.Repository
-	client *segment.Client
+	repo     segment.Store
+	client   *segment.Client
+	splitDir string
 }
 
-func NewSegmentHandlers(repo *segment.Repository, client *segment.Client) *SegmentHandlers {
+func NewSegmentHandlers(repo segment.Store, client *segment.Client, splitDir string) *SegmentHandlers {
 	return &SegmentHandlers{
-		repo:   repo,
-		client: client,
+		repo:     repo,
+		client:   client,
+		splitDir: splitDir,
 	}
 }
 
@@ -59,9 +61,18 @@ func (sh *SegmentHandlers) DiarizeFile(w http.ResponseWriter, r *http.Request, h
 		return
 	}
 
-	log.Printf("Diarize file ID=%d path=%s", id, file.FilePath)
+	// pyannote читает файл по пути сам: виртуальный отрезок вырезается во временный WAV
+	// (TMPDIR должен быть виден pyannote) и удаляется после ответа
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
 
-	result, err := sh.client.Diarize(file.FilePath, minSpeakers, maxSpeakers)
+	log.Printf("Diarize file ID=%d path=%s", id, path)
+
+	result, err := sh.client.Diarize(path, minSpeakers, maxSpeakers)
 	if err != nil {
 		log.Printf("Diarize error ID=%d: %v", id, err)
 		h.error(w, http.StatusInternalServerError, "diarize failed: "+err.Error())
@@ -242,6 +253,30 @@ type ExportRequest struct {
 	Groups []ExportGroup `json:"groups"`
 }
 
+// Сдвиг границы группы к краю речи VAD и захват паузы, секунды
+const (
+	exportSnapShift  = 0.3
+	exportSnapMargin = 0.1
+)
+
+// exportBounds — до каких пор можно расширить группу i, не заходя в соседние
+func exportBounds(groups []ExportGroup, i int, duration float64) (lo, hi float64) {
+	lo, hi = 0, duration
+	g := groups[i]
+	for j, o := range groups {
+		if j == i {
+			continue
+		}
+		if o.End <= g.Start && o.End > lo {
+			lo = o.End
+		}
+		if o.Start >= g.End && o.Start < hi {
+			hi = o.Start
+		}
+	}
+	return lo, hi
+}
+
 // ExportSegments - POST /api/files/{id}/segments/export
 // Split audio by boundaries and save in LibriSpeech structure
 // ExportSegments - POST /api/files/{id}/segments/export
@@ -269,7 +304,7 @@ func (sh *SegmentHandlers) ExportSegments(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	baseDir := "/data/processed_labeler/split"
+	baseDir := sh.splitDir
 	speaker := file.UserID
 	if speaker == "" {
 		speaker = "unknown"
@@ -305,24 +340,32 @@ func (sh *SegmentHandlers) ExportSegments(w http.ResponseWriter, r *http.Request
 	var createdFiles []int64
 	var transLines []string
 
+	// Виртуальный отрезок вырезается из записи во временный WAV
+	path, release, err := service.LocalAudio(file)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, "audio error: "+err.Error())
+		return
+	}
+	defer release()
+
+	// VAD: границы групп не режут слова и захватывают немного паузы
+	vad, err := audio.VADFile(path)
+	if err != nil {
+		log.Printf("⚠ VAD %s: %v (cut at group boundaries)", file.FilePath, err)
+	}
+
 	for i, group := range req.Groups {
 		fileIdx := startIdx + i
 		outName := fmt.Sprintf("%s-%s-%04d.wav", speaker, chapter, fileIdx)
 		outPath := filepath.Join(outDir, outName)
 
+		if vad != nil {
+			lo, hi := exportBounds(req.Groups, i, vad.Duration)
+			group.Start, group.End = vad.SnapToPause(group.Start, group.End, exportSnapShift, exportSnapMargin, lo, hi)
+		}
 		duration := group.End - group.Start
-		cmd := exec.Command("ffmpeg", "-y",
-			"-i", file.FilePath,
-			"-ss", fmt.Sprintf("%.3f", group.Start),
-			"-t", fmt.Sprintf("%.3f", duration),
-			"-c:a", "pcm_s16le",
-			"-ar", "8000",
-			"-ac", "1",
-			outPath,
-		)
-
-		if output, err := cmd.CombinedOutput(); err != nil {
-			log.Printf("ffmpeg error for group %d: %v\n%s", i, err, string(output))
+		if err := audio.CutAudio(path, outPath, group.Start, duration, 8000, 1); err != nil {
+			log.Printf("cut error for group %d: %v", i, err)
 			continue
 		}
 
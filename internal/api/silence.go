@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"audio-labeler/internal/db"
+)
+
+// SilenceNormalizeStart - POST /api/silence/normalize/start?min_ms=&max_ms=&limit=&speaker=&chapter=&noise_level=&out_of_range=1
+// Тишина в начале и в конце приводится к [min_ms, max_ms] (по умолчанию SILENCE_MIN_MS/SILENCE_MAX_MS).
+// out_of_range=1 — только файлы, у которых сохранённая тишина вне диапазона или ещё не измерена
+func (h *Handlers) SilenceNormalizeStart(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+
+	minMs, maxMs := h.silenceTarget.MinMs, h.silenceTarget.MaxMs
+	if v, err := strconv.ParseFloat(q.Get("min_ms"), 64); err == nil {
+		minMs = v
+	}
+	if v, err := strconv.ParseFloat(q.Get("max_ms"), 64); err == nil {
+		maxMs = v
+	}
+	if minMs < 0 || maxMs < minMs {
+		h.error(w, http.StatusBadRequest, "invalid min_ms/max_ms")
+		return
+	}
+
+	filter := db.SilenceFilter{
+		Speaker:    q.Get("speaker"),
+		Chapter:    q.Get("chapter"),
+		NoiseLevel: q.Get("noise_level"),
+		OutOfRange: q.Get("out_of_range") == "1",
+	}
+
+	queued, err := h.silence.Start(limit, minMs, maxMs, filter, startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if queued == 0 {
+		h.success(w, map[string]interface{}{
+			"message": "No files to normalize",
+			"queued":  0,
+		})
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message": "Silence normalization started",
+		"queued":  queued,
+		"min_ms":  minMs,
+		"max_ms":  maxMs,
+	})
+}
+
+// SilenceNormalizeStatus - GET /api/silence/normalize/status
+func (h *Handlers) SilenceNormalizeStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.silence.Status())
+}
+
+// SilenceNormalizeStop - POST /api/silence/normalize/stop
+func (h *Handlers) SilenceNormalizeStop(w http.ResponseWriter, r *http.Request) {
+	h.silence.Stop()
+	h.success(w, "Silence normalization stopped")
+}
@@ -0,0 +1,94 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"audio-labeler/internal/service"
+)
+
+// SweepStart - POST /api/sweep/start?engine=kaldi&profile=&lm_scales=0.5,0.7,1&wips=0,0.5&limit=
+// Перебор веса LM и word insertion penalty по сохранённым lattice (KALDI_LATTICE_DIR)
+func (h *Handlers) SweepStart(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	params := service.SweepParams{
+		Engine:  q.Get("engine"),
+		Profile: q.Get("profile"),
+	}
+	params.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	var err error
+	if params.LMScales, err = parseFloats(q.Get("lm_scales")); err != nil {
+		h.error(w, http.StatusBadRequest, "lm_scales: "+err.Error())
+		return
+	}
+	if params.WIPs, err = parseFloats(q.Get("wips")); err != nil {
+		h.error(w, http.StatusBadRequest, "wips: "+err.Error())
+		return
+	}
+
+	files, err := h.sweep.Start(params, startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+
+	if files == 0 {
+		h.success(w, map[string]interface{}{
+			"message": "No files with kept lattices and reference text",
+			"files":   0,
+		})
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"message": "Sweep started",
+		"files":   files,
+	})
+}
+
+// SweepStatus - GET /api/sweep/status
+func (h *Handlers) SweepStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.sweep.Status())
+}
+
+// SweepStop - POST /api/sweep/stop
+func (h *Handlers) SweepStop(w http.ResponseWriter, r *http.Request) {
+	h.sweep.Stop()
+	h.success(w, "Sweep stopped")
+}
+
+// SweepResults - GET /api/sweep/results?job_id= (по умолчанию — последний перебор)
+// Точки по возрастанию WER, лучшая помечена best
+func (h *Handlers) SweepResults(w http.ResponseWriter, r *http.Request) {
+	jobID, _ := strconv.ParseInt(r.URL.Query().Get("job_id"), 10, 64)
+
+	jobID, results, err := h.sweep.Results(jobID)
+	if err != nil {
+		h.error(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	h.success(w, map[string]interface{}{
+		"job_id":  jobID,
+		"results": results,
+	})
+}
+
+// parseFloats — "0.5,0.7,1" -> []float64; пусто — nil (значения по умолчанию)
+func parseFloats(s string) ([]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	var values []float64
+	for _, part := range strings.Split(s, ",") {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", part)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
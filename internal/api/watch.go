@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// WatchStart - POST /api/watch/start?interval=
+// Периодически пересканирует WATCH_DIR: новые файлы импортируются, изменённые
+// эталоны обновляются, удалённые WAV деактивируются. interval — секунды
+// (по умолчанию WATCH_INTERVAL_SEC)
+func (h *Handlers) WatchStart(w http.ResponseWriter, r *http.Request) {
+	interval, _ := strconv.Atoi(r.URL.Query().Get("interval"))
+	if err := h.watch.Start(interval, startedBy(r)); err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.success(w, h.watch.Status())
+}
+
+// WatchStatus - GET /api/watch/status
+func (h *Handlers) WatchStatus(w http.ResponseWriter, r *http.Request) {
+	h.success(w, h.watch.Status())
+}
+
+// WatchStop - POST /api/watch/stop
+func (h *Handlers) WatchStop(w http.ResponseWriter, r *http.Request) {
+	h.watch.Stop()
+	h.success(w, "Watch stopped")
+}
+
+// WatchRun - POST /api/watch/run?force=1
+// Один проход синхронно; без force сверка с базой только при изменении каталога
+func (h *Handlers) WatchRun(w http.ResponseWriter, r *http.Request) {
+	pass, err := h.watch.Pass(r.URL.Query().Get("force") == "1", startedBy(r))
+	if err != nil {
+		h.error(w, http.StatusConflict, err.Error())
+		return
+	}
+	h.success(w, pass)
+}
@@ -0,0 +1,140 @@
+package asr
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Классы ошибок распознавания
+const (
+	// ErrorTransient — 429/5xx, таймауты, сеть, падение процесса Kaldi: повтор может помочь
+	ErrorTransient = "transient"
+	// ErrorPermanent — битый или неподдерживаемый WAV, отказ API по самому файлу: повтор не поможет
+	ErrorPermanent = "permanent"
+	// ErrorFatal — 401/403: ключ API неверен или отозван. От файла не зависит —
+	// файл не помечается ошибкой, задача останавливается
+	ErrorFatal = "fatal"
+)
+
+// Error — ошибка движка с классом (и паузой, которую попросил сервер)
+type Error struct {
+	Class      string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Permanent помечает ошибку как неповторяемую
+func Permanent(err error) error {
+	return &Error{Class: ErrorPermanent, Err: err}
+}
+
+// Classify — класс ошибки, которую вернул Transcribe.
+// Неизвестное (сеть, таймауты, ...) считается transient — число попыток всё равно ограничено
+func Classify(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Class
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrorPermanent
+	}
+	return ErrorTransient
+}
+
+// Failure — текст, класс и пауза сервера для неудачного вызова Transcribe
+// (ошибка вызова или DecodeResult с Success=false)
+func Failure(result *DecodeResult, err error) (msg, class string, retryAfter time.Duration) {
+	if err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			retryAfter = e.RetryAfter
+		}
+		return err.Error(), Classify(err), retryAfter
+	}
+	if result == nil {
+		return "no result", ErrorTransient, 0
+	}
+
+	class = result.ErrorClass
+	if class == "" {
+		class = ErrorTransient
+	}
+	return result.Error, class, result.RetryAfter
+}
+
+// ClassifyHTTPStatus — класс ответа HTTP API: 408/425/429 и 5xx — transient,
+// 401/403 — fatal, остальные 4xx — permanent
+func ClassifyHTTPStatus(code int) string {
+	switch {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return ErrorFatal
+	case code >= 500,
+		code == http.StatusRequestTimeout,
+		code == http.StatusTooEarly,
+		code == http.StatusTooManyRequests:
+		return ErrorTransient
+	case code >= 400:
+		return ErrorPermanent
+	}
+	return ErrorTransient
+}
+
+// httpFailure — DecodeResult для не-200 ответа
+func httpFailure(resp *http.Response, body []byte) *DecodeResult {
+	return &DecodeResult{
+		Success:    false,
+		Error:      "status " + strconv.Itoa(resp.StatusCode) + ": " + string(body),
+		ErrorClass: ClassifyHTTPStatus(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+// parseRetryAfter — заголовок Retry-After: секунды или HTTP дата
+func parseRetryAfter(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil && sec > 0 {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// kaldiPermanentMarkers — сообщения Kaldi о битом/неподдерживаемом входе
+var kaldiPermanentMarkers = []string{
+	"wavedata",
+	"riff",
+	"wave file",
+	"bits per sample",
+	"unsupported",
+	"could not read",
+}
+
+// classifyKaldiFailure — упавший процесс Kaldi повторяем, если вывод не говорит о плохом WAV
+func classifyKaldiFailure(output []byte) string {
+	out := strings.ToLower(string(output))
+	for _, marker := range kaldiPermanentMarkers {
+		if strings.Contains(out, marker) {
+			return ErrorPermanent
+		}
+	}
+	return ErrorTransient
+}
@@ -0,0 +1,112 @@
+package asr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestClassifyHTTPStatus(t *testing.T) {
+	tests := []struct {
+		code int
+		want string
+	}{
+		{400, ErrorPermanent},
+		{401, ErrorFatal},
+		{403, ErrorFatal},
+		{404, ErrorPermanent},
+		{408, ErrorTransient},
+		{413, ErrorPermanent},
+		{425, ErrorTransient},
+		{429, ErrorTransient},
+		{500, ErrorTransient},
+		{503, ErrorTransient},
+	}
+	for _, tt := range tests {
+		if got := ClassifyHTTPStatus(tt.code); got != tt.want {
+			t.Errorf("ClassifyHTTPStatus(%d) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"permanent", Permanent(errors.New("bad wav")), ErrorPermanent},
+		{"wrapped", fmt.Errorf("decode: %w", &Error{Class: ErrorFatal, Err: errors.New("401")}), ErrorFatal},
+		{"missing file", fmt.Errorf("open: %w", os.ErrNotExist), ErrorPermanent},
+		{"network", errors.New("connection reset"), ErrorTransient},
+	}
+	for _, tt := range tests {
+		if got := Classify(tt.err); got != tt.want {
+			t.Errorf("%s: Classify = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestFailure(t *testing.T) {
+	tests := []struct {
+		name       string
+		result     *DecodeResult
+		err        error
+		class      string
+		retryAfter time.Duration
+	}{
+		{"call error", nil, &Error{Class: ErrorTransient, RetryAfter: time.Second, Err: errors.New("429")}, ErrorTransient, time.Second},
+		{"no result", nil, nil, ErrorTransient, 0},
+		{"unclassified result", &DecodeResult{Error: "boom"}, nil, ErrorTransient, 0},
+		{"classified result", &DecodeResult{Error: "bad", ErrorClass: ErrorPermanent}, nil, ErrorPermanent, 0},
+	}
+	for _, tt := range tests {
+		_, class, retryAfter := Failure(tt.result, tt.err)
+		if class != tt.class || retryAfter != tt.retryAfter {
+			t.Errorf("%s: Failure = %s %s, want %s %s", tt.name, class, retryAfter, tt.class, tt.retryAfter)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"", 0},
+		{"7", 7 * time.Second},
+		{"-1", 0},
+		{"soon", 0},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+
+	date := time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(date); got <= 0 || got > time.Minute {
+		t.Errorf("parseRetryAfter(%q) = %s", date, got)
+	}
+}
+
+func TestClassifyKaldiFailure(t *testing.T) {
+	tests := []struct {
+		output string
+		want   string
+	}{
+		{"ERROR: WaveData: expected 16 bits per sample", ErrorPermanent},
+		{"ERROR: could not read from stream", ErrorPermanent},
+		{"Killed", ErrorTransient},
+		{"", ErrorTransient},
+	}
+	for _, tt := range tests {
+		if got := classifyKaldiFailure([]byte(tt.output)); got != tt.want {
+			t.Errorf("classifyKaldiFailure(%q) = %s, want %s", tt.output, got, tt.want)
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -19,6 +20,21 @@ type KaldiDecoder struct {
 	hclgFst    string
 	onlineConf string
 	lmScale    float64
+
+	// Параметры декодирования (KaldiParams)
+	beam             float64
+	maxActive        int
+	latticeBeam      float64
+	acousticScale    float64
+	frameSubsampling int
+
+	// Тайминги слов: пусто — у графа нет phones/word_boundary.int
+	wordBoundary string
+	symbolsOnce  sync.Once
+	symbols      map[int]string
+	symbolsErr   error
+
+	latticeOpts LatticeOptions
 }
 
 type DecodeResult struct {
@@ -28,19 +44,81 @@ type DecodeResult struct {
 	RTF            float64
 	Success        bool
 	Error          string
+	ErrorClass     string        // ErrorTransient / ErrorPermanent (пусто — transient)
+	RetryAfter     time.Duration // пауза, которую попросил сервер (429)
+	Segments       []Segment     // nil — движок не отдаёт тайминги
+	Words          []WordTiming  // nil — тайминги слов недоступны
+
+	// Из lattice Kaldi (LatticeOptions): сохранённая lattice, N-best, MBR уверенности
+	Lattice string
+	NBest   []Hypothesis
+	MBR     *MBRResult
+}
+
+// KaldiParams — параметры декодирования (профиль). Пустые поля — значения по умолчанию
+type KaldiParams struct {
+	KaldiRoot        string  // /opt/kaldi
+	ModelDir         string  // model/final.mdl, conf/online.conf
+	GraphDir         string  // HCLG.fst, words.txt; пусто — ModelDir/graph
+	Beam             float64 // 15.0
+	MaxActive        int     // 7000
+	LatticeBeam      float64 // 8.0
+	AcousticScale    float64 // 1.0
+	LMScale          float64 // 1.0 — без rescoring; другое значение — lattice rescoring
+	FrameSubsampling int     // 3 (chain модели)
+}
+
+// withDefaults заполняет пустые поля значениями по умолчанию
+func (p KaldiParams) withDefaults() KaldiParams {
+	if p.KaldiRoot == "" {
+		p.KaldiRoot = "/opt/kaldi"
+	}
+	if p.GraphDir == "" {
+		p.GraphDir = filepath.Join(p.ModelDir, "graph")
+	}
+	if p.Beam <= 0 {
+		p.Beam = 15.0
+	}
+	if p.MaxActive <= 0 {
+		p.MaxActive = 7000
+	}
+	if p.LatticeBeam <= 0 {
+		p.LatticeBeam = 8.0
+	}
+	if p.AcousticScale <= 0 {
+		p.AcousticScale = 1.0
+	}
+	if p.LMScale < 0 {
+		p.LMScale = 1.0
+	}
+	if p.FrameSubsampling <= 0 {
+		p.FrameSubsampling = 3
+	}
+	return p
 }
 
 func NewKaldiDecoder(modelDir string) (*KaldiDecoder, error) {
-	kaldiRoot := "/opt/kaldi"
+	return NewKaldiDecoderWithParams(KaldiParams{ModelDir: modelDir, LMScale: 1.0})
+}
+
+// NewKaldiDecoderWithParams — декодер по профилю: свои модель, граф, beam'ы и веса
+func NewKaldiDecoderWithParams(p KaldiParams) (*KaldiDecoder, error) {
+	p = p.withDefaults()
 
 	d := &KaldiDecoder{
-		kaldiRoot:  kaldiRoot,
-		modelPath:  filepath.Join(modelDir, "model/final.mdl"),
-		graphDir:   filepath.Join(modelDir, "graph"),
-		wordsTxt:   filepath.Join(modelDir, "graph/words.txt"),
-		hclgFst:    filepath.Join(modelDir, "graph/HCLG.fst"),
-		onlineConf: filepath.Join(modelDir, "conf/online.conf"),
-		lmScale:    1.0,
+		kaldiRoot:  p.KaldiRoot,
+		modelPath:  filepath.Join(p.ModelDir, "model/final.mdl"),
+		graphDir:   p.GraphDir,
+		wordsTxt:   filepath.Join(p.GraphDir, "words.txt"),
+		hclgFst:    filepath.Join(p.GraphDir, "HCLG.fst"),
+		onlineConf: filepath.Join(p.ModelDir, "conf/online.conf"),
+		lmScale:    p.LMScale,
+
+		beam:             p.Beam,
+		maxActive:        p.MaxActive,
+		latticeBeam:      p.LatticeBeam,
+		acousticScale:    p.AcousticScale,
+		frameSubsampling: p.FrameSubsampling,
 	}
 
 	files := []string{d.modelPath, d.wordsTxt, d.hclgFst, d.onlineConf}
@@ -50,6 +128,10 @@ func NewKaldiDecoder(modelDir string) (*KaldiDecoder, error) {
 		}
 	}
 
+	if wb := filepath.Join(p.GraphDir, "phones/word_boundary.int"); fileExists(wb) {
+		d.wordBoundary = wb
+	}
+
 	return d, nil
 }
 
@@ -76,18 +158,18 @@ func (d *KaldiDecoder) GetLMScale() float64 {
 
 func (d *KaldiDecoder) Decode(wavPath string) (*DecodeResult, error) {
 	if _, err := os.Stat(wavPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("audio file not found: %s", wavPath)
+		return nil, Permanent(fmt.Errorf("audio file not found: %s", wavPath))
 	}
 
 	duration, err := audio.GetAudioDuration(wavPath)
 	if err != nil {
-		return nil, fmt.Errorf("get duration: %w", err)
+		return nil, Permanent(fmt.Errorf("get duration: %w", err))
 	}
 
 	uttID := fmt.Sprintf("utt_%d", time.Now().UnixNano())
 
-	// NoLM режим — используем lattice rescoring
-	if d.lmScale == 0 {
+	// LM scale ≠ 1 (NoLM: 0) — lattice rescoring
+	if d.lmScale != 1 {
 		return d.decodeWithRescoring(wavPath, uttID, duration)
 	}
 
@@ -99,30 +181,30 @@ func (d *KaldiDecoder) Decode(wavPath string) (*DecodeResult, error) {
 func (d *KaldiDecoder) decodeDirect(wavPath, uttID string, duration float64) (*DecodeResult, error) {
 	start := time.Now()
 
+	tmpDir, err := os.MkdirTemp("", "kaldi_")
+	if err != nil {
+		return nil, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	latticePath := filepath.Join(tmpDir, "lat.ark")
+
 	decoderBin := filepath.Join(d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
 
-	cmd := exec.Command(decoderBin,
-		"--config="+d.onlineConf,
-		"--frame-subsampling-factor=3",
-		"--max-active=7000",
-		"--beam=15.0",
-		"--lattice-beam=8.0",
-		"--acoustic-scale=1.0",
-		"--word-symbol-table="+d.wordsTxt,
-		d.modelPath,
-		d.hclgFst,
+	cmd := exec.Command(decoderBin, d.decoderArgs(
 		fmt.Sprintf("ark:echo %s %s |", uttID, uttID),
 		fmt.Sprintf("scp:echo %s %s |", uttID, wavPath),
-		"ark:/dev/null",
-	)
+		"ark:"+latticePath,
+	)...)
 
 	output, err := cmd.CombinedOutput()
 	elapsed := time.Since(start).Seconds()
 
 	if err != nil {
 		return &DecodeResult{
-			Success: false,
-			Error:   fmt.Sprintf("decode error: %v, output: %s", err, string(output)),
+			Success:    false,
+			Error:      fmt.Sprintf("decode error: %v, output: %s", err, string(output)),
+			ErrorClass: classifyKaldiFailure(output),
 		}, nil
 	}
 
@@ -133,16 +215,18 @@ func (d *KaldiDecoder) decodeDirect(wavPath, uttID string, duration float64) (*D
 		rtf = elapsed / duration
 	}
 
-	return &DecodeResult{
+	result := &DecodeResult{
 		Text:           text,
 		Duration:       duration,
 		ProcessingTime: elapsed,
 		RTF:            rtf,
 		Success:        true,
-	}, nil
+	}
+	d.attachLattice(latticePath, map[string]string{uttID: wavPath}, map[string]*DecodeResult{wavPath: result})
+	return result, nil
 }
 
-// decodeWithRescoring — декодирование с lattice rescoring (lm-scale=0)
+// decodeWithRescoring — декодирование с lattice rescoring (lm-scale из профиля, NoLM — 0)
 func (d *KaldiDecoder) decodeWithRescoring(wavPath, uttID string, duration float64) (*DecodeResult, error) {
 	start := time.Now()
 
@@ -157,25 +241,17 @@ func (d *KaldiDecoder) decodeWithRescoring(wavPath, uttID string, duration float
 	// Шаг 1: Декодируем в lattice
 	decoderBin := filepath.Join(d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
 
-	cmd1 := exec.Command(decoderBin,
-		"--config="+d.onlineConf,
-		"--frame-subsampling-factor=3",
-		"--max-active=7000",
-		"--beam=15.0",
-		"--lattice-beam=8.0",
-		"--acoustic-scale=1.0",
-		"--word-symbol-table="+d.wordsTxt,
-		d.modelPath,
-		d.hclgFst,
+	cmd1 := exec.Command(decoderBin, d.decoderArgs(
 		fmt.Sprintf("ark:echo %s %s |", uttID, uttID),
 		fmt.Sprintf("scp:echo %s %s |", uttID, wavPath),
 		"ark:"+latticePath,
-	)
+	)...)
 
 	if output, err := cmd1.CombinedOutput(); err != nil {
 		return &DecodeResult{
-			Success: false,
-			Error:   fmt.Sprintf("decode step failed: %v, output: %s", err, string(output)),
+			Success:    false,
+			Error:      fmt.Sprintf("decode step failed: %v, output: %s", err, string(output)),
+			ErrorClass: classifyKaldiFailure(output),
 		}, nil
 	}
 
@@ -188,28 +264,14 @@ func (d *KaldiDecoder) decodeWithRescoring(wavPath, uttID string, duration float
 	}
 
 	// Шаг 2: Rescoring + Best Path + Convert to words
-	rescoreBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-scale")
-	bestPathBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-best-path")
-	int2symPl := filepath.Join(d.kaldiRoot, "egs/wsj/s5/utils/int2sym.pl")
-
-	// Проверяем наличие int2sym.pl
-	if _, err := os.Stat(int2symPl); os.IsNotExist(err) {
-		// Пробуем альтернативный путь
-		int2symPl = filepath.Join(d.kaldiRoot, "egs/work_3/s5/utils/int2sym.pl")
-	}
-
-	cmd2 := exec.Command("bash", "-c", fmt.Sprintf(
-		"%s --lm-scale=0.0 --acoustic-scale=1.0 'ark:%s' ark:- | %s ark:- ark,t:- | %s -f 2- %s",
-		rescoreBin, latticePath, bestPathBin, int2symPl, d.wordsTxt,
-	))
-
-	output, err := cmd2.CombinedOutput()
+	output, err := d.rescoreBestPath(latticePath)
 	elapsed := time.Since(start).Seconds()
 
 	if err != nil {
 		return &DecodeResult{
-			Success: false,
-			Error:   fmt.Sprintf("rescore step failed: %v, output: %s", err, string(output)),
+			Success:    false,
+			Error:      fmt.Sprintf("rescore step failed: %v, output: %s", err, string(output)),
+			ErrorClass: classifyKaldiFailure(output),
 		}, nil
 	}
 
@@ -220,13 +282,15 @@ func (d *KaldiDecoder) decodeWithRescoring(wavPath, uttID string, duration float
 		rtf = elapsed / duration
 	}
 
-	return &DecodeResult{
+	result := &DecodeResult{
 		Text:           text,
 		Duration:       duration,
 		ProcessingTime: elapsed,
 		RTF:            rtf,
 		Success:        true,
-	}, nil
+	}
+	d.attachLattice(latticePath, map[string]string{uttID: wavPath}, map[string]*DecodeResult{wavPath: result})
+	return result, nil
 }
 
 // ============================================================
@@ -238,8 +302,8 @@ func (d *KaldiDecoder) DecodeBatch(wavPaths []string) (map[string]*DecodeResult,
 		return nil, nil
 	}
 
-	// NoLM режим — используем batch lattice rescoring
-	if d.lmScale == 0 {
+	// LM scale ≠ 1 (NoLM: 0) — batch lattice rescoring
+	if d.lmScale != 1 {
 		return d.decodeBatchWithRescoring(wavPaths)
 	}
 
@@ -259,6 +323,7 @@ func (d *KaldiDecoder) decodeBatchDirect(wavPaths []string) (map[string]*DecodeR
 
 	wavScp := filepath.Join(tmpDir, "wav.scp")
 	spk2utt := filepath.Join(tmpDir, "spk2utt")
+	latticePath := filepath.Join(tmpDir, "lat.ark")
 
 	// Создаём wav.scp
 	wavScpFile, err := os.Create(wavScp)
@@ -289,20 +354,11 @@ func (d *KaldiDecoder) decodeBatchDirect(wavPaths []string) (map[string]*DecodeR
 
 	decoderBin := filepath.Join(d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
 
-	cmd := exec.Command(decoderBin,
-		"--config="+d.onlineConf,
-		"--frame-subsampling-factor=3",
-		"--max-active=7000",
-		"--beam=15.0",
-		"--lattice-beam=8.0",
-		"--acoustic-scale=1.0",
-		"--word-symbol-table="+d.wordsTxt,
-		d.modelPath,
-		d.hclgFst,
+	cmd := exec.Command(decoderBin, d.decoderArgs(
 		"ark:"+spk2utt,
 		"scp:"+wavScp,
-		"ark:/dev/null",
-	)
+		"ark:"+latticePath,
+	)...)
 
 	output, err := cmd.CombinedOutput()
 	totalElapsed := time.Since(start).Seconds()
@@ -340,6 +396,7 @@ func (d *KaldiDecoder) decodeBatchDirect(wavPaths []string) (map[string]*DecodeR
 		}
 	}
 
+	d.attachLattice(latticePath, uttIDs, results)
 	return results, nil
 }
 
@@ -387,20 +444,11 @@ func (d *KaldiDecoder) decodeBatchWithRescoring(wavPaths []string) (map[string]*
 	// Шаг 1: Batch декодирование в lattice
 	decoderBin := filepath.Join(d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
 
-	cmd1 := exec.Command(decoderBin,
-		"--config="+d.onlineConf,
-		"--frame-subsampling-factor=3",
-		"--max-active=7000",
-		"--beam=15.0",
-		"--lattice-beam=8.0",
-		"--acoustic-scale=1.0",
-		"--word-symbol-table="+d.wordsTxt,
-		d.modelPath,
-		d.hclgFst,
+	cmd1 := exec.Command(decoderBin, d.decoderArgs(
 		"ark:"+spk2utt,
 		"scp:"+wavScp,
 		"ark:"+latticePath,
-	)
+	)...)
 
 	if output, err := cmd1.CombinedOutput(); err != nil {
 		for _, path := range wavPaths {
@@ -424,20 +472,7 @@ func (d *KaldiDecoder) decodeBatchWithRescoring(wavPaths []string) (map[string]*
 	}
 
 	// Шаг 2: Batch rescoring + best path
-	rescoreBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-scale")
-	bestPathBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-best-path")
-
-	int2symPl := filepath.Join(d.kaldiRoot, "egs/wsj/s5/utils/int2sym.pl")
-	if _, err := os.Stat(int2symPl); os.IsNotExist(err) {
-		int2symPl = filepath.Join(d.kaldiRoot, "egs/work_3/s5/utils/int2sym.pl")
-	}
-
-	cmd2 := exec.Command("bash", "-c", fmt.Sprintf(
-		"%s --lm-scale=0.0 --acoustic-scale=1.0 'ark:%s' ark:- | %s ark:- ark,t:- | %s -f 2- %s",
-		rescoreBin, latticePath, bestPathBin, int2symPl, d.wordsTxt,
-	))
-
-	output, err := cmd2.CombinedOutput()
+	output, err := d.rescoreBestPath(latticePath)
 	totalElapsed := time.Since(start).Seconds()
 
 	if err != nil {
@@ -473,6 +508,7 @@ func (d *KaldiDecoder) decodeBatchWithRescoring(wavPaths []string) (map[string]*
 		}
 	}
 
+	d.attachLattice(latticePath, uttIDs, results)
 	return results, nil
 }
 
@@ -480,6 +516,53 @@ func (d *KaldiDecoder) decodeBatchWithRescoring(wavPaths []string) (map[string]*
 // Helper functions
 // ============================================================
 
+// decoderArgs — аргументы online2-wav-nnet3-latgen-faster с параметрами профиля
+func (d *KaldiDecoder) decoderArgs(spk2utt, wavScp, lattice string) []string {
+	return []string{
+		"--config=" + d.onlineConf,
+		fmt.Sprintf("--frame-subsampling-factor=%d", d.frameSubsampling),
+		fmt.Sprintf("--max-active=%d", d.maxActive),
+		fmt.Sprintf("--beam=%g", d.beam),
+		fmt.Sprintf("--lattice-beam=%g", d.latticeBeam),
+		fmt.Sprintf("--acoustic-scale=%g", d.acousticScale),
+		"--word-symbol-table=" + d.wordsTxt,
+		d.modelPath,
+		d.hclgFst,
+		spk2utt,
+		wavScp,
+		lattice,
+	}
+}
+
+// rescoreBestPath — lattice-scale с весом LM профиля + best path: строки "uttID слова"
+func (d *KaldiDecoder) rescoreBestPath(latticePath string) ([]byte, error) {
+	rescoreBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-scale")
+	bestPathBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-best-path")
+	int2symPl := filepath.Join(d.kaldiRoot, "egs/wsj/s5/utils/int2sym.pl")
+
+	// Проверяем наличие int2sym.pl
+	if _, err := os.Stat(int2symPl); os.IsNotExist(err) {
+		// Пробуем альтернативный путь
+		int2symPl = filepath.Join(d.kaldiRoot, "egs/work_3/s5/utils/int2sym.pl")
+	}
+
+	cmd := exec.Command("bash", "-c", fmt.Sprintf(
+		"%s --lm-scale=%g --acoustic-scale=%g 'ark:%s' ark:- | %s ark:- ark,t:- | %s -f 2- %s",
+		rescoreBin, d.lmScale, d.acousticScale, latticePath, bestPathBin, int2symPl, d.wordsTxt,
+	))
+	return cmd.CombinedOutput()
+}
+
+// frameShift — шаг кадра на выходе модели: 10ms × frame-subsampling-factor
+func (d *KaldiDecoder) frameShift() float64 {
+	return 0.01 * float64(d.frameSubsampling)
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
 // parseOutput извлекает текст для одного utterance
 func (d *KaldiDecoder) parseOutput(output, uttID string) string {
 	scanner := bufio.NewScanner(strings.NewReader(output))
@@ -549,8 +632,8 @@ func (d *KaldiDecoder) DecodeBatchGPU(wavPaths []string) (map[string]*DecodeResu
 		return nil, nil
 	}
 
-	// NoLM режим — GPU + lattice rescoring
-	if d.lmScale == 0 {
+	// LM scale ≠ 1 (NoLM: 0) — GPU + lattice rescoring
+	if d.lmScale != 1 {
 		return d.decodeBatchGPUWithRescoring(wavPaths)
 	}
 
@@ -570,6 +653,7 @@ func (d *KaldiDecoder) decodeBatchGPUDirect(wavPaths []string) (map[string]*Deco
 
 	wavScp := filepath.Join(tmpDir, "wav.scp")
 	spk2utt := filepath.Join(tmpDir, "spk2utt")
+	latticePath := filepath.Join(tmpDir, "lat.ark")
 
 	// Создаём wav.scp
 	wavScpFile, err := os.Create(wavScp)
@@ -607,12 +691,17 @@ func (d *KaldiDecoder) decodeBatchGPUDirect(wavPaths []string) (map[string]*Deco
 		"--cuda-worker-threads=4",
 		"--max-batch-size="+fmt.Sprintf("%d", len(wavPaths)),
 		"--num-channels="+fmt.Sprintf("%d", len(wavPaths)),
+		fmt.Sprintf("--frame-subsampling-factor=%d", d.frameSubsampling),
+		fmt.Sprintf("--max-active=%d", d.maxActive),
+		fmt.Sprintf("--beam=%g", d.beam),
+		fmt.Sprintf("--lattice-beam=%g", d.latticeBeam),
+		fmt.Sprintf("--acoustic-scale=%g", d.acousticScale),
 		"--word-symbol-table="+d.wordsTxt,
 		d.modelPath,
 		d.hclgFst,
 		"ark:"+spk2utt,
 		"scp:"+wavScp,
-		"ark,t:-",
+		"ark:"+latticePath,
 	)
 
 	output, err := cmd.CombinedOutput()
@@ -650,6 +739,7 @@ func (d *KaldiDecoder) decodeBatchGPUDirect(wavPaths []string) (map[string]*Deco
 		}
 	}
 
+	d.attachLattice(latticePath, uttIDs, results)
 	return results, nil
 }
 
@@ -698,20 +788,11 @@ func (d *KaldiDecoder) decodeBatchGPUWithRescoring(wavPaths []string) (map[strin
 	// Используем CPU декодер для lattice output (GPU decoder не поддерживает lattice output напрямую)
 	decoderBin := filepath.Join(d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
 
-	cmd1 := exec.Command(decoderBin,
-		"--config="+d.onlineConf,
-		"--frame-subsampling-factor=3",
-		"--max-active=7000",
-		"--beam=15.0",
-		"--lattice-beam=8.0",
-		"--acoustic-scale=1.0",
-		"--word-symbol-table="+d.wordsTxt,
-		d.modelPath,
-		d.hclgFst,
+	cmd1 := exec.Command(decoderBin, d.decoderArgs(
 		"ark:"+spk2utt,
 		"scp:"+wavScp,
 		"ark:"+latticePath,
-	)
+	)...)
 
 	if output, err := cmd1.CombinedOutput(); err != nil {
 		for _, path := range wavPaths {
@@ -729,8 +810,8 @@ func (d *KaldiDecoder) decodeBatchGPUWithRescoring(wavPaths []string) (map[strin
 	int2symPl := filepath.Join(d.kaldiRoot, "egs/work_3/s5/utils/int2sym.pl")
 
 	cmd2 := exec.Command("bash", "-c", fmt.Sprintf(
-		"%s --lm-scale=0.0 --acoustic-scale=1.0 'ark:%s' ark:- | %s ark:- ark,t:- | %s -f 2- %s",
-		rescoreBin, latticePath, bestPathBin, int2symPl, d.wordsTxt,
+		"%s --lm-scale=%g --acoustic-scale=%g 'ark:%s' ark:- | %s ark:- ark,t:- | %s -f 2- %s",
+		rescoreBin, d.lmScale, d.acousticScale, latticePath, bestPathBin, int2symPl, d.wordsTxt,
 	))
 
 	output, err := cmd2.CombinedOutput()
@@ -768,5 +849,6 @@ func (d *KaldiDecoder) decodeBatchGPUWithRescoring(wavPaths []string) (map[strin
 		}
 	}
 
+	d.attachLattice(latticePath, uttIDs, results)
 	return results, nil
 }
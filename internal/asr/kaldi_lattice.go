@@ -0,0 +1,376 @@
+package asr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LatticeOptions — что ещё извлекать из lattice после декодирования (по умолчанию ничего)
+type LatticeOptions struct {
+	Dir   string // сохранять lattice в Dir (ark + смещение в DecodeResult.Lattice); пусто — удалять
+	NBest int    // гипотез в N-best списке (lattice-to-nbest); 0 — не строить
+	MBR   bool   // MBR уверенности (lattice-mbr-decode)
+}
+
+// Hypothesis — гипотеза N-best, по возрастанию стоимости (первая — best path)
+type Hypothesis struct {
+	Text         string  `json:"text"`
+	LMCost       float64 `json:"lm_cost"`
+	AcousticCost float64 `json:"acoustic_cost"`
+}
+
+// MBRResult — MBR декодирование: гипотеза с минимальным ожидаемым числом ошибок
+type MBRResult struct {
+	Text       string           `json:"text"`
+	BayesRisk  float64          `json:"bayes_risk"` // ожидаемое число ошибок в словах
+	Confidence float64          `json:"confidence"` // средняя уверенность слов
+	Words      []WordConfidence `json:"words"`
+}
+
+// WordConfidence — апостериорная вероятность слова MBR гипотезы (бин sausage)
+type WordConfidence struct {
+	Word       string  `json:"word"`
+	Confidence float64 `json:"confidence"`
+}
+
+// SetLatticeOptions включает сохранение lattice, N-best и MBR уверенности
+func (d *KaldiDecoder) SetLatticeOptions(opts LatticeOptions) {
+	d.latticeOpts = opts
+}
+
+// attachLattice — всё, что берётся из lattice декодирования: слова с таймингом,
+// сохранённая копия lattice, N-best и MBR. Ошибки только логируются
+func (d *KaldiDecoder) attachLattice(latticePath string, uttIDs map[string]string, results map[string]*DecodeResult) {
+	d.attachWords(latticePath, uttIDs, results)
+
+	opts := d.latticeOpts
+	if opts.Dir != "" {
+		kept, err := d.keepLattice(latticePath)
+		if err != nil {
+			log.Printf("⚠ Kaldi: keep lattice: %v", err)
+		}
+		forEachResult(uttIDs, results, func(uttID string, r *DecodeResult) {
+			r.Lattice = kept[uttID]
+		})
+	}
+
+	if opts.NBest > 0 {
+		nbest, err := d.nbest(latticePath, opts.NBest)
+		if err != nil {
+			log.Printf("⚠ Kaldi: n-best: %v", err)
+		} else {
+			forEachResult(uttIDs, results, func(uttID string, r *DecodeResult) {
+				r.NBest = append([]Hypothesis{}, nbest[uttID]...)
+			})
+		}
+	}
+
+	if opts.MBR {
+		mbr, err := d.mbrDecode(latticePath)
+		if err != nil {
+			log.Printf("⚠ Kaldi: mbr: %v", err)
+		} else {
+			forEachResult(uttIDs, results, func(uttID string, r *DecodeResult) {
+				r.MBR = mbr[uttID]
+			})
+		}
+	}
+}
+
+// forEachResult — успешные результаты по uttID
+func forEachResult(uttIDs map[string]string, results map[string]*DecodeResult, fn func(uttID string, r *DecodeResult)) {
+	for uttID, path := range uttIDs {
+		if r := results[path]; r != nil && r.Success {
+			fn(uttID, r)
+		}
+	}
+}
+
+// keepLattice копирует lattice в LatticeOptions.Dir; uttID -> rxfilename "file.ark:offset",
+// который читается любым инструментом Kaldi (lattice-copy "ark:file.ark:offset" ...)
+func (d *KaldiDecoder) keepLattice(latticePath string) (map[string]string, error) {
+	if err := os.MkdirAll(d.latticeOpts.Dir, 0755); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf("lat_%d", time.Now().UnixNano())
+	arkPath := filepath.Join(d.latticeOpts.Dir, name+".ark")
+	scpPath := filepath.Join(d.latticeOpts.Dir, name+".scp")
+
+	copyBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-copy")
+	cmd := exec.Command(copyBin, "ark:"+latticePath, fmt.Sprintf("ark,scp:%s,%s", arkPath, scpPath))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%v, output: %s", err, string(output))
+	}
+
+	data, err := os.ReadFile(scpPath)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			kept[fields[0]] = fields[1]
+		}
+	}
+	return kept, nil
+}
+
+// nbest — N лучших гипотез: lattice-to-nbest + nbest-to-linear.
+// Ключи на выходе "<uttID>-<n>", n с 1 по возрастанию стоимости
+func (d *KaldiDecoder) nbest(latticePath string, n int) (map[string][]Hypothesis, error) {
+	symbols, err := d.wordSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kaldi_nbest_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	traPath := filepath.Join(tmpDir, "tra")
+	lmPath := filepath.Join(tmpDir, "lm_cost")
+	acPath := filepath.Join(tmpDir, "ac_cost")
+
+	nbestBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-to-nbest")
+	linearBin := filepath.Join(d.kaldiRoot, "src/latbin/nbest-to-linear")
+
+	cmd := exec.Command("bash", "-c", fmt.Sprintf(
+		"set -o pipefail; %s --acoustic-scale=%g --lm-scale=%g --n=%d 'ark:%s' ark:- | "+
+			"%s ark:- ark:/dev/null 'ark,t:%s' 'ark,t:%s' 'ark,t:%s'",
+		nbestBin, d.acousticScale, d.lmScale, n, latticePath, linearBin, traPath, lmPath, acPath,
+	))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%v, output: %s", err, string(output))
+	}
+
+	tra, err := readKaldiTable(traPath)
+	if err != nil {
+		return nil, err
+	}
+	lmCosts, err := readKaldiTable(lmPath)
+	if err != nil {
+		return nil, err
+	}
+	acCosts, err := readKaldiTable(acPath)
+	if err != nil {
+		return nil, err
+	}
+
+	type ranked struct {
+		rank int
+		hyp  Hypothesis
+	}
+	byUtt := make(map[string][]ranked)
+	for key, ids := range tra {
+		i := strings.LastIndex(key, "-")
+		if i < 0 {
+			continue
+		}
+		rank, err := strconv.Atoi(key[i+1:])
+		if err != nil {
+			continue
+		}
+		hyp := Hypothesis{Text: idsToText(ids, symbols)}
+		hyp.LMCost, _ = firstFloat(lmCosts[key])
+		hyp.AcousticCost, _ = firstFloat(acCosts[key])
+		byUtt[key[:i]] = append(byUtt[key[:i]], ranked{rank, hyp})
+	}
+
+	nbest := make(map[string][]Hypothesis, len(byUtt))
+	for uttID, list := range byUtt {
+		sort.Slice(list, func(a, b int) bool { return list[a].rank < list[b].rank })
+		hyps := make([]Hypothesis, len(list))
+		for i, r := range list {
+			hyps[i] = r.hyp
+		}
+		nbest[uttID] = hyps
+	}
+	return nbest, nil
+}
+
+// mbrDecode — lattice-mbr-decode: MBR гипотеза, Bayes risk и уверенность каждого слова
+func (d *KaldiDecoder) mbrDecode(latticePath string) (map[string]*MBRResult, error) {
+	symbols, err := d.wordSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kaldi_mbr_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	traPath := filepath.Join(tmpDir, "tra")
+	riskPath := filepath.Join(tmpDir, "risk")
+	confPath := filepath.Join(tmpDir, "conf")
+
+	mbrBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-mbr-decode")
+	cmd := exec.Command(mbrBin,
+		fmt.Sprintf("--acoustic-scale=%g", d.acousticScale),
+		fmt.Sprintf("--lm-scale=%g", d.lmScale),
+		"ark:"+latticePath,
+		"ark,t:"+traPath,
+		"ark,t:"+riskPath,
+		"ark,t:"+confPath,
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("%v, output: %s", err, string(output))
+	}
+
+	tra, err := readKaldiTable(traPath)
+	if err != nil {
+		return nil, err
+	}
+	risks, err := readKaldiTable(riskPath)
+	if err != nil {
+		return nil, err
+	}
+	confs, err := readKaldiTable(confPath)
+	if err != nil {
+		return nil, err
+	}
+
+	mbr := make(map[string]*MBRResult, len(tra))
+	for uttID, ids := range tra {
+		r := &MBRResult{Text: idsToText(ids, symbols), Words: []WordConfidence{}}
+		r.BayesRisk, _ = firstFloat(risks[uttID])
+
+		// вектор уверенностей в тексте: "[ 0.98 0.71 ]"
+		var conf []float64
+		for _, f := range confs[uttID] {
+			if v, err := strconv.ParseFloat(f, 64); err == nil {
+				conf = append(conf, v)
+			}
+		}
+
+		sum := 0.0
+		for i, id := range ids {
+			w := WordConfidence{Word: idsToText([]string{id}, symbols)}
+			if i < len(conf) {
+				w.Confidence = conf[i]
+			}
+			sum += w.Confidence
+			r.Words = append(r.Words, w)
+		}
+
+		// пустая гипотеза: уверенность по ожидаемому числу ошибок
+		if len(r.Words) > 0 {
+			r.Confidence = sum / float64(len(r.Words))
+		} else {
+			r.Confidence = max(0, 1-r.BayesRisk)
+		}
+		mbr[uttID] = r
+	}
+	return mbr, nil
+}
+
+// Rescore — best path по сохранённым lattice (uttID -> rxfilename из DecodeResult.Lattice)
+// с другим весом LM и word insertion penalty, как в Kaldi score.sh: uttID -> текст
+func (d *KaldiDecoder) Rescore(lattices map[string]string, lmScale, wip float64) (map[string]string, error) {
+	symbols, err := d.wordSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpDir, err := os.MkdirTemp("", "kaldi_rescore_")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	scpPath := filepath.Join(tmpDir, "lat.scp")
+	var scp strings.Builder
+	for uttID, rx := range lattices {
+		fmt.Fprintf(&scp, "%s %s\n", uttID, rx)
+	}
+	if err := os.WriteFile(scpPath, []byte(scp.String()), 0644); err != nil {
+		return nil, err
+	}
+
+	scaleBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-scale")
+	penaltyBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-add-penalty")
+	bestPathBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-best-path")
+
+	cmd := exec.Command("bash", "-c", fmt.Sprintf(
+		"set -o pipefail; %s --lm-scale=%g --acoustic-scale=%g 'scp:%s' ark:- | "+
+			"%s --word-ins-penalty=%g ark:- ark:- | %s ark:- ark,t:-",
+		scaleBin, lmScale, d.acousticScale, scpPath, penaltyBin, wip, bestPathBin,
+	))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("rescore: %v, output: %s", err, stderr.String())
+	}
+
+	texts := make(map[string]string, len(lattices))
+	for uttID, ids := range parseKaldiTable(output) {
+		texts[uttID] = idsToText(ids, symbols)
+	}
+	return texts, nil
+}
+
+// readKaldiTable читает text archive "key field field ..." в key -> поля
+func readKaldiTable(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseKaldiTable(data), nil
+}
+
+func parseKaldiTable(data []byte) map[string][]string {
+	table := make(map[string][]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		var values []string
+		for _, f := range fields[1:] {
+			if f != "[" && f != "]" {
+				values = append(values, f)
+			}
+		}
+		table[fields[0]] = values
+	}
+	return table
+}
+
+// idsToText — id слов -> текст через words.txt
+func idsToText(ids []string, symbols map[int]string) string {
+	words := make([]string, 0, len(ids))
+	for _, s := range ids {
+		if id, err := strconv.Atoi(s); err == nil {
+			if sym, ok := symbols[id]; ok {
+				s = sym
+			}
+		}
+		words = append(words, s)
+	}
+	return strings.Join(words, " ")
+}
+
+func firstFloat(fields []string) (float64, error) {
+	if len(fields) == 0 {
+		return 0, fmt.Errorf("empty")
+	}
+	return strconv.ParseFloat(fields[0], 64)
+}
@@ -0,0 +1,61 @@
+package asr
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKaldiTable(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want map[string][]string
+	}{
+		{"transcripts", "utt1-1 4 5 6\nutt1-2 4 6\n", map[string][]string{"utt1-1": {"4", "5", "6"}, "utt1-2": {"4", "6"}}},
+		{"costs", "utt1 12.5\n", map[string][]string{"utt1": {"12.5"}}},
+		{"confidence vector", "utt1 [ 0.98 0.71 ]\n", map[string][]string{"utt1": {"0.98", "0.71"}}},
+		{"empty hypothesis", "utt1 \n\n", map[string][]string{"utt1": nil}},
+	}
+	for _, tt := range tests {
+		if got := parseKaldiTable([]byte(tt.data)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIDsToText(t *testing.T) {
+	symbols := map[int]string{4: "one", 5: "two", 6: "<UNK>"}
+	tests := []struct {
+		ids  []string
+		want string
+	}{
+		{[]string{"4", "5"}, "one two"},
+		{[]string{"6"}, "<UNK>"},
+		{[]string{"99", "word"}, "99 word"}, // нет в words.txt — как есть
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		if got := idsToText(tt.ids, symbols); got != tt.want {
+			t.Errorf("idsToText(%v) = %q, want %q", tt.ids, got, tt.want)
+		}
+	}
+}
+
+func TestFirstFloat(t *testing.T) {
+	tests := []struct {
+		fields  []string
+		want    float64
+		wantErr bool
+	}{
+		{[]string{"1.25", "3"}, 1.25, false},
+		{[]string{"-7"}, -7, false},
+		{[]string{"x"}, 0, true},
+		{nil, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := firstFloat(tt.fields)
+		if (err != nil) != tt.wantErr || (err == nil && got != tt.want) {
+			t.Errorf("firstFloat(%v) = %v %v", tt.fields, got, err)
+		}
+	}
+}
@@ -0,0 +1,534 @@
+package asr
+
+import (
+	"audio-labeler/internal/audio"
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ============================================================
+// Server mode: один процесс декодера на воркер
+// ============================================================
+
+// Таймаут utterance (запись в FIFO и ожидание результата): базовый + кратный
+// длительности (RTF 20 — декодер завис); у первого utterance процесса — ещё и загрузка модели
+const (
+	kaldiServerTimeout    = 60 * time.Second
+	kaldiServerTimeoutRTF = 20
+	kaldiServerStartup    = 5 * time.Minute
+	kaldiServerFIFOPoll   = 20 * time.Millisecond
+	kaldiServerTailLines  = 20
+)
+
+// uttID server mode: ключ строки с текстом и упоминание в логах Kaldi
+var (
+	kaldiUttKey     = regexp.MustCompile(`^srv\d{12}$`)
+	kaldiUttPattern = regexp.MustCompile(`srv\d{12}`)
+)
+
+// KaldiServerDecoder — KaldiDecoder, у которого каждый воркер держит свой
+// долгоживущий процесс (NewWorker). Transcribe без воркера — обычный Decode
+type KaldiServerDecoder struct {
+	*KaldiDecoder
+}
+
+func NewKaldiServerDecoder(d *KaldiDecoder) *KaldiServerDecoder {
+	return &KaldiServerDecoder{KaldiDecoder: d}
+}
+
+// NewWorker реализует WorkerTranscriber: процесс стартует при первом Transcribe
+func (s *KaldiServerDecoder) NewWorker() TranscriberWorker {
+	return &KaldiServer{d: s.KaldiDecoder}
+}
+
+// KaldiServer — долгоживущий online2-wav-nnet3-latgen-faster: модель и HCLG грузятся
+// один раз, utterance подаются потоком (spk2utt — stdin, wav archive — FIFO).
+// Текст (stderr) и lattice (stdout, text archive) сопоставляются по uttID.
+// Упавший или зависший процесс убивается, следующий Transcribe запускает новый
+type KaldiServer struct {
+	d     *KaldiDecoder
+	mu    sync.Mutex
+	dir   string
+	proc  *kaldiProcess
+	seq   int64
+	stats WorkerStats
+}
+
+// kaldiProcess — один запуск декодера
+type kaldiProcess struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	fifo    string
+	wav     *os.File // открывается при первом utterance: open ждёт загрузки модели
+	started time.Time
+	events  chan kaldiEvent
+	quit    chan struct{} // процесс больше не нужен: читатели не ждут получателя
+	done    chan struct{} // процесс завершился, exitErr заполнен
+	exitErr error
+
+	tailMu sync.Mutex
+	tail   []string // последние строки stderr
+}
+
+// kaldiEvent — что процесс сообщил об utterance
+type kaldiEvent struct {
+	uttID   string
+	text    *string
+	lattice []byte
+	warning string
+}
+
+// Transcribe декодирует файл в процессе воркера
+func (s *KaldiServer) Transcribe(wavPath string) (*DecodeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(wavPath); os.IsNotExist(err) {
+		return nil, Permanent(fmt.Errorf("audio file not found: %s", wavPath))
+	}
+
+	duration, err := audio.GetAudioDuration(wavPath)
+	if err != nil {
+		return nil, Permanent(fmt.Errorf("get duration: %w", err))
+	}
+
+	start := time.Now()
+
+	if err := s.ensureProcess(); err != nil {
+		return nil, err
+	}
+	p := s.proc
+
+	s.seq++
+	uttID := fmt.Sprintf("srv%012d", s.seq)
+	s.stats.Utterances++
+
+	timeout := kaldiServerTimeout + time.Duration(duration*kaldiServerTimeoutRTF*float64(time.Second))
+	if p.wav == nil {
+		timeout += kaldiServerStartup
+	}
+	deadline := time.Now().Add(timeout)
+
+	if err := s.send(p, uttID, wavPath, deadline); err != nil {
+		return s.fail(p, fmt.Sprintf("send %s: %v", uttID, err)), nil
+	}
+
+	text, lattice, failure := s.await(p, uttID, deadline)
+	if failure != nil {
+		return failure, nil
+	}
+
+	latticePath := filepath.Join(s.dir, uttID+".ark")
+	if err := os.WriteFile(latticePath, lattice, 0644); err != nil {
+		return nil, err
+	}
+	defer os.Remove(latticePath)
+
+	// LM scale ≠ 1 (NoLM: 0) — rescoring lattice utterance
+	if s.d.lmScale != 1 {
+		output, err := s.d.rescoreBestPath(latticePath)
+		if err != nil {
+			return &DecodeResult{
+				Success:    false,
+				Error:      fmt.Sprintf("rescore step failed: %v, output: %s", err, string(output)),
+				ErrorClass: classifyKaldiFailure(output),
+			}, nil
+		}
+		text = s.d.parseOutput(string(output), uttID)
+	}
+
+	elapsed := time.Since(start).Seconds()
+	rtf := 0.0
+	if duration > 0 {
+		rtf = elapsed / duration
+	}
+
+	result := &DecodeResult{
+		Text:           text,
+		Duration:       duration,
+		ProcessingTime: elapsed,
+		RTF:            rtf,
+		Success:        true,
+	}
+	s.d.attachLattice(latticePath, map[string]string{uttID: wavPath}, map[string]*DecodeResult{wavPath: result})
+	return result, nil
+}
+
+func (s *KaldiServer) Health() error {
+	return s.d.Health()
+}
+
+// Stats — запуски, перезапуски и время загрузки модели
+func (s *KaldiServer) Stats() WorkerStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stats
+}
+
+// Close завершает процесс: EOF в spk2utt — декодер дописывает и выходит
+func (s *KaldiServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p := s.proc; p != nil {
+		s.proc = nil
+		close(p.quit)
+		p.stdin.Close()
+		if p.wav != nil {
+			p.wav.Close()
+		}
+		select {
+		case <-p.done:
+		case <-time.After(10 * time.Second):
+			p.cmd.Process.Kill()
+			<-p.done
+		}
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+	return nil
+}
+
+// ensureProcess запускает декодер, если его нет или он завершился
+func (s *KaldiServer) ensureProcess() error {
+	if s.proc != nil {
+		select {
+		case <-s.proc.done:
+			log.Printf("⚠ Kaldi server: decoder exited (%v), restarting", s.proc.exitErr)
+			s.discard()
+			s.stats.Restarts++
+		default:
+			return nil
+		}
+	}
+
+	if s.dir == "" {
+		dir, err := os.MkdirTemp("", "kaldi_server_")
+		if err != nil {
+			return fmt.Errorf("create temp dir: %w", err)
+		}
+		s.dir = dir
+	}
+
+	fifo := filepath.Join(s.dir, fmt.Sprintf("wav_%d.fifo", s.stats.Starts))
+	os.Remove(fifo)
+	if err := syscall.Mkfifo(fifo, 0600); err != nil {
+		return fmt.Errorf("mkfifo: %w", err)
+	}
+
+	decoderBin := filepath.Join(s.d.kaldiRoot, "src/online2bin/online2-wav-nnet3-latgen-faster")
+
+	// wav — sorted archive: читается по мере запросов, а не целиком как scp;
+	// lattice — text archive в stdout с flush после каждого utterance
+	cmd := exec.Command(decoderBin, s.d.decoderArgs(
+		"ark:-",
+		"ark,s,cs:"+fifo,
+		"ark,t,f:-",
+	)...)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("start decoder: %w", err)
+	}
+
+	p := &kaldiProcess{
+		cmd:     cmd,
+		stdin:   stdin,
+		fifo:    fifo,
+		started: time.Now(),
+		events:  make(chan kaldiEvent, 16),
+		quit:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		p.readLattices(stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		p.readLog(stderr)
+	}()
+	go func() {
+		readers.Wait()
+		p.exitErr = cmd.Wait()
+		close(p.done)
+	}()
+
+	s.proc = p
+	s.stats.Starts++
+	return nil
+}
+
+// send пишет utterance: spk2utt (свой спикер на файл, как в Decode) и wav в archive.
+// Открытие FIFO и запись ограничены deadline: декодер, который умер до открытия wav
+// или перестал читать, не вешает воркер — Transcribe по ошибке убивает процесс
+func (s *KaldiServer) send(p *kaldiProcess, uttID, wavPath string, deadline time.Time) error {
+	if _, err := fmt.Fprintf(p.stdin, "%s %s\n", uttID, uttID); err != nil {
+		return err
+	}
+
+	if p.wav == nil {
+		wav, err := openFIFO(p, deadline)
+		if err != nil {
+			return err
+		}
+		p.wav = wav
+
+		loaded := time.Since(p.started)
+		s.stats.StartupTime += loaded
+		log.Printf("✓ Kaldi server: model loaded in %s (pid %d)", loaded.Round(time.Millisecond), p.cmd.Process.Pid)
+	}
+
+	f, err := os.Open(wavPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := p.wav.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	if _, err := io.WriteString(p.wav, uttID+" "); err != nil {
+		return err
+	}
+	_, err = io.Copy(p.wav, f)
+	return err
+}
+
+// openFIFO открывает wav archive на запись, когда декодер откроет его на чтение
+// (после загрузки модели). O_NONBLOCK: open не блокируется, а запись в такой файл
+// идёт через poller и соблюдает SetWriteDeadline
+func openFIFO(p *kaldiProcess, deadline time.Time) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(p.fifo, os.O_WRONLY|syscall.O_NONBLOCK, 0)
+		if err == nil {
+			return f, nil
+		}
+		if !errors.Is(err, syscall.ENXIO) {
+			return nil, err // ENXIO — читателя ещё нет
+		}
+
+		select {
+		case <-p.done:
+			return nil, fmt.Errorf("decoder exited during startup")
+		case <-time.After(min(kaldiServerFIFOPoll, time.Until(deadline))):
+		}
+		if !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("decoder did not open wav input: %w", os.ErrDeadlineExceeded)
+		}
+	}
+}
+
+// await ждёт текст и lattice utterance до deadline. failure != nil — utterance не декодирован
+func (s *KaldiServer) await(p *kaldiProcess, uttID string, deadline time.Time) (string, []byte, *DecodeResult) {
+	timeout := time.Until(deadline)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var text *string
+	var lattice []byte
+
+	// apply учитывает событие; false — Kaldi пропустил utterance
+	apply := func(ev kaldiEvent) bool {
+		if ev.uttID != uttID {
+			return true
+		}
+		switch {
+		case ev.warning != "":
+			return false
+		case ev.text != nil:
+			text = ev.text
+		case ev.lattice != nil:
+			lattice = ev.lattice
+		}
+		return true
+	}
+	skipped := func(ev kaldiEvent) *DecodeResult {
+		return &DecodeResult{
+			Success:    false,
+			Error:      "decode error: " + ev.warning,
+			ErrorClass: classifyKaldiFailure([]byte(ev.warning)),
+		}
+	}
+
+	for text == nil || lattice == nil {
+		select {
+		case ev := <-p.events:
+			if !apply(ev) {
+				return "", nil, skipped(ev)
+			}
+		case <-p.done:
+			// читатели дочитали вывод до выхода процесса: события уже в канале
+			for drained := false; !drained; {
+				select {
+				case ev := <-p.events:
+					if !apply(ev) {
+						return "", nil, skipped(ev)
+					}
+				default:
+					drained = true
+				}
+			}
+			if text != nil && lattice != nil {
+				break
+			}
+			return "", nil, s.fail(p, fmt.Sprintf("decoder exited: %v", p.exitErr))
+		case <-timer.C:
+			return "", nil, s.fail(p, "decoder timeout: no result by utterance deadline")
+		}
+	}
+	return *text, lattice, nil
+}
+
+// fail убивает процесс (следующий Transcribe запустит новый) и возвращает ошибку
+// с хвостом stderr; класс — по выводу Kaldi
+func (s *KaldiServer) fail(p *kaldiProcess, msg string) *DecodeResult {
+	tail := p.logTail()
+	if s.proc == p {
+		s.discard()
+		s.stats.Restarts++
+	}
+	return &DecodeResult{
+		Success:    false,
+		Error:      fmt.Sprintf("%s, output: %s", msg, tail),
+		ErrorClass: classifyKaldiFailure([]byte(tail)),
+	}
+}
+
+// discard останавливает текущий процесс без ожидания результатов
+func (s *KaldiServer) discard() {
+	p := s.proc
+	s.proc = nil
+
+	p.cmd.Process.Kill()
+	p.stdin.Close()
+	if p.wav != nil {
+		p.wav.Close()
+	}
+	close(p.quit)
+	<-p.done
+	os.Remove(p.fifo)
+}
+
+// emit отдаёт событие ожидающему Transcribe; false — процесс уже не нужен,
+// читатель дочитывает вывод вхолостую, чтобы декодер не встал на полном pipe
+func (p *kaldiProcess) emit(ev kaldiEvent) bool {
+	select {
+	case p.events <- ev:
+		return true
+	case <-p.quit:
+		return false
+	}
+}
+
+// readLattices режет text archive на lattice: "uttID \n<дуги>\n\n"
+func (p *kaldiProcess) readLattices(r io.Reader) {
+	reader := bufio.NewReaderSize(r, 1<<20)
+
+	var uttID string
+	var buf strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		trimmed := strings.TrimSpace(line)
+		if uttID == "" {
+			if trimmed != "" {
+				uttID = strings.Fields(trimmed)[0]
+				buf.Reset()
+				buf.WriteString(line)
+			}
+			continue
+		}
+
+		buf.WriteString(line)
+		if trimmed == "" {
+			if !p.emit(kaldiEvent{uttID: uttID, lattice: []byte(buf.String())}) {
+				io.Copy(io.Discard, reader)
+				return
+			}
+			uttID = ""
+		}
+	}
+}
+
+// readLog разбирает stderr: "uttID слова" — текст, WARNING/ERROR с uttID — ошибка utterance
+func (p *kaldiProcess) readLog(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		p.addTail(line)
+
+		fields := strings.SplitN(line, " ", 2)
+		if kaldiUttKey.MatchString(fields[0]) {
+			text := ""
+			if len(fields) > 1 {
+				text = strings.TrimSpace(fields[1])
+			}
+			if !p.emit(kaldiEvent{uttID: fields[0], text: &text}) {
+				io.Copy(io.Discard, r)
+				return
+			}
+			continue
+		}
+
+		// Kaldi пропускает utterance без аудио и идёт дальше
+		if strings.Contains(line, "Did not find audio") {
+			if uttID := kaldiUttPattern.FindString(line); uttID != "" {
+				if !p.emit(kaldiEvent{uttID: uttID, warning: line}) {
+					io.Copy(io.Discard, r)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *kaldiProcess) addTail(line string) {
+	p.tailMu.Lock()
+	defer p.tailMu.Unlock()
+	p.tail = append(p.tail, line)
+	if len(p.tail) > kaldiServerTailLines {
+		p.tail = p.tail[len(p.tail)-kaldiServerTailLines:]
+	}
+}
+
+func (p *kaldiProcess) logTail() string {
+	p.tailMu.Lock()
+	defer p.tailMu.Unlock()
+	return strings.Join(p.tail, "\n")
+}
@@ -0,0 +1,134 @@
+package asr_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/testutil"
+)
+
+func TestMain(m *testing.M) {
+	testutil.Main(m)
+}
+
+func newDecoder(t *testing.T, lmScale float64, texts ...string) (*asr.KaldiDecoder, []string) {
+	t.Helper()
+	return newDecoderWith(t, testutil.KaldiOptions{}, lmScale, texts...)
+}
+
+// newDecoderWith — newDecoder с поведением заглушки (CrashOn, LoadDelay)
+func newDecoderWith(t *testing.T, opts testutil.KaldiOptions, lmScale float64, texts ...string) (*asr.KaldiDecoder, []string) {
+	t.Helper()
+	testutil.AudioTools(t)
+	opts.Vocabulary = testutil.Vocabulary(texts...)
+	kaldi := testutil.NewKaldi(t, opts)
+
+	d, err := asr.NewKaldiDecoderWithParams(asr.KaldiParams{
+		KaldiRoot: kaldi.Root,
+		ModelDir:  kaldi.ModelDir,
+		LMScale:   lmScale,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Health(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	paths := make([]string, len(texts))
+	for i, text := range texts {
+		paths[i] = filepath.Join(dir, fmt.Sprintf("utt%d.wav", i))
+		if err := testutil.WriteWAV(paths[i], testutil.Speech(text, 16000)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d, paths
+}
+
+// Процесс на файл: прямое декодирование и lattice rescoring (lm-scale ≠ 1)
+func TestKaldiDecode(t *testing.T) {
+	for _, lmScale := range []float64{1, 0} {
+		d, paths := newDecoder(t, lmScale, "one two three")
+		r, err := d.Decode(paths[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Success || r.Text != "one two three" || r.Duration <= 0 {
+			t.Errorf("lm_scale=%g: %+v", lmScale, r)
+		}
+	}
+}
+
+func TestKaldiDecodeBatch(t *testing.T) {
+	texts := []string{"one two", "three four five", "six"}
+	for _, lmScale := range []float64{1, 0} {
+		d, paths := newDecoder(t, lmScale, texts...)
+		results, err := d.DecodeBatch(paths)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, path := range paths {
+			if r := results[path]; r == nil || !r.Success || r.Text != texts[i] {
+				t.Errorf("lm_scale=%g %s: %+v", lmScale, texts[i], r)
+			}
+		}
+	}
+}
+
+// Server mode: один процесс на все файлы воркера
+func TestKaldiServer(t *testing.T) {
+	texts := []string{"one two", "three four five", "six"}
+	d, paths := newDecoder(t, 1, texts...)
+
+	w := asr.NewKaldiServerDecoder(d).NewWorker()
+	defer w.Close()
+	for i, path := range paths {
+		r, err := w.Transcribe(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !r.Success || r.Text != texts[i] {
+			t.Errorf("%s: %+v", texts[i], r)
+		}
+	}
+	if st := w.Stats(); st.Starts != 1 || st.Restarts != 0 || st.Utterances != len(texts) {
+		t.Errorf("stats: %+v", st)
+	}
+}
+
+// Процесс упал на файле: ошибка transient, следующий файл поднимает новый процесс
+func TestKaldiServerRestart(t *testing.T) {
+	texts := []string{"one two", "three four five", "six"}
+	d, paths := newDecoderWith(t, testutil.KaldiOptions{CrashOn: "four"}, 1, texts...)
+
+	w := asr.NewKaldiServerDecoder(d).NewWorker()
+	defer w.Close()
+
+	tests := []struct {
+		ok    bool
+		class string
+	}{
+		{true, ""},
+		{false, asr.ErrorTransient},
+		{true, ""},
+		{true, ""}, // повтор упавшего файла
+	}
+	paths = append(paths, paths[1])
+	texts = append(texts, texts[1])
+	for i, tt := range tests {
+		r, err := w.Transcribe(paths[i])
+		msg, class, _ := asr.Failure(r, err)
+		if tt.ok && (err != nil || !r.Success || r.Text != texts[i]) {
+			t.Errorf("%s: %+v %v", texts[i], r, err)
+		}
+		if !tt.ok && (r != nil && r.Success || class != tt.class) {
+			t.Errorf("%s: crash not reported as %s: %s", texts[i], tt.class, msg)
+		}
+	}
+	if st := w.Stats(); st.Starts != 2 || st.Restarts != 1 {
+		t.Errorf("stats: %+v", st)
+	}
+}
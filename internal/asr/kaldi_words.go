@@ -0,0 +1,125 @@
+package asr
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// alignWords — тайминги и уверенность слов для всех utterance в lattice (ark):
+// lattice-align-words + lattice-to-ctm-conf. --decode-mbr=false: слова те же, что в best path
+// (совпадают с Text), confidence — апостериорная вероятность слова в lattice.
+// nil, nil — у модели нет graph/phones/word_boundary.int
+func (d *KaldiDecoder) alignWords(latticePath string) (map[string][]WordTiming, error) {
+	if d.wordBoundary == "" {
+		return nil, nil
+	}
+
+	symbols, err := d.wordSymbols()
+	if err != nil {
+		return nil, err
+	}
+
+	scaleBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-scale")
+	alignBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-align-words")
+	ctmBin := filepath.Join(d.kaldiRoot, "src/latbin/lattice-to-ctm-conf")
+
+	cmd := exec.Command("bash", "-c", fmt.Sprintf(
+		"set -o pipefail; %s --lm-scale=%g --acoustic-scale=1.0 'ark:%s' ark:- | %s %s %s ark:- ark:- | "+
+			"%s --decode-mbr=false --acoustic-scale=%g --frame-shift=%g ark:- -",
+		scaleBin, d.lmScale, latticePath, alignBin, d.wordBoundary, d.modelPath, ctmBin, d.acousticScale, d.frameShift(),
+	))
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("word alignment: %v, output: %s", err, stderr.String())
+	}
+	return parseCTM(output, symbols), nil
+}
+
+// attachWords — Words для результатов (path -> result) по lattice с utterance uttIDs (uttID -> path).
+// Ошибка выравнивания не валит декодирование: результат остаётся без слов
+func (d *KaldiDecoder) attachWords(latticePath string, uttIDs map[string]string, results map[string]*DecodeResult) {
+	words, err := d.alignWords(latticePath)
+	if err != nil {
+		log.Printf("⚠ Kaldi: %v", err)
+		return
+	}
+	if words == nil {
+		return
+	}
+	for uttID, path := range uttIDs {
+		if result := results[path]; result != nil && result.Success {
+			result.Words = append([]WordTiming{}, words[uttID]...)
+		}
+	}
+}
+
+// parseCTM разбирает "utt channel start duration word-id confidence"
+func parseCTM(output []byte, symbols map[int]string) map[string][]WordTiming {
+	words := make(map[string][]WordTiming)
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		start, err1 := strconv.ParseFloat(fields[2], 64)
+		dur, err2 := strconv.ParseFloat(fields[3], 64)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+
+		word := fields[4]
+		if id, err := strconv.Atoi(word); err == nil {
+			if sym, ok := symbols[id]; ok {
+				word = sym
+			}
+		}
+
+		w := WordTiming{Word: word, Start: start, End: start + dur}
+		if len(fields) > 5 {
+			if conf, err := strconv.ParseFloat(fields[5], 64); err == nil {
+				w.Confidence = &conf
+			}
+		}
+		words[fields[0]] = append(words[fields[0]], w)
+	}
+	return words
+}
+
+// wordSymbols — id -> слово из words.txt (читается один раз)
+func (d *KaldiDecoder) wordSymbols() (map[int]string, error) {
+	d.symbolsOnce.Do(func() {
+		f, err := os.Open(d.wordsTxt)
+		if err != nil {
+			d.symbolsErr = err
+			return
+		}
+		defer f.Close()
+
+		d.symbols = make(map[int]string)
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) != 2 {
+				continue
+			}
+			if id, err := strconv.Atoi(fields[1]); err == nil {
+				d.symbols[id] = fields[0]
+			}
+		}
+		d.symbolsErr = scanner.Err()
+	})
+	return d.symbols, d.symbolsErr
+}
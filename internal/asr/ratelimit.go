@@ -0,0 +1,137 @@
+package asr
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimitPause — пауза для всех воркеров после 429 без Retry-After
+const rateLimitPause = 2 * time.Second
+
+// RateLimiter — ограничитель для платных API: token bucket по запросам в минуту
+// и по минутам аудио в минуту, плюс лимит одновременных запросов.
+// Пауза по Retry-After действует сразу на всех воркеров. nil — без ограничений
+type RateLimiter struct {
+	mu          sync.Mutex
+	requests    *tokenBucket // nil — без лимита
+	audio       *tokenBucket // в секундах аудио; nil — без лимита
+	pausedUntil time.Time
+	sem         chan struct{} // nil — без лимита
+}
+
+// NewRateLimiter — 0 в любом параметре отключает соответствующий лимит
+func NewRateLimiter(requestsPerMin, audioMinutesPerMin float64, maxConcurrent int) *RateLimiter {
+	l := &RateLimiter{
+		requests: newTokenBucket(requestsPerMin),
+		audio:    newTokenBucket(audioMinutesPerMin * 60),
+	}
+	if maxConcurrent > 0 {
+		l.sem = make(chan struct{}, maxConcurrent)
+	}
+	return l
+}
+
+// Acquire ждёт слот и токены под запрос с audioSeconds аудио.
+// release нужно вызвать после ответа API
+func (l *RateLimiter) Acquire(audioSeconds float64) (release func()) {
+	if l == nil {
+		return func() {}
+	}
+
+	if l.sem != nil {
+		l.sem <- struct{}{}
+	}
+
+	for {
+		l.mu.Lock()
+		now := time.Now()
+		wait := l.pausedUntil.Sub(now)
+		if w := l.requests.wait(now, 1); w > wait {
+			wait = w
+		}
+		if w := l.audio.wait(now, audioSeconds); w > wait {
+			wait = w
+		}
+		if wait <= 0 {
+			l.requests.take(1)
+			l.audio.take(audioSeconds)
+			l.mu.Unlock()
+			break
+		}
+		l.mu.Unlock()
+		time.Sleep(wait)
+	}
+
+	return func() {
+		if l.sem != nil {
+			<-l.sem
+		}
+	}
+}
+
+// Pause — сервер ответил 429: следующие запросы не раньше чем через d
+func (l *RateLimiter) Pause(d time.Duration) {
+	if l == nil {
+		return
+	}
+	if d <= 0 {
+		d = rateLimitPause
+	}
+
+	l.mu.Lock()
+	if until := time.Now().Add(d); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	l.mu.Unlock()
+}
+
+// tokenBucket — perMin токенов в минуту, ёмкость — минутный запас
+type tokenBucket struct {
+	rate     float64 // токенов в секунду
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(perMin float64) *tokenBucket {
+	if perMin <= 0 {
+		return nil
+	}
+	return &tokenBucket{
+		rate:     perMin / 60,
+		capacity: perMin,
+		tokens:   perMin,
+		last:     time.Now(),
+	}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// wait — сколько ждать, пока накопится need токенов.
+// Запрос больше ёмкости (файл длиннее минутного лимита) ждёт полный бак и уводит его в минус
+func (b *tokenBucket) wait(now time.Time, need float64) time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.refill(now)
+	if need > b.capacity {
+		need = b.capacity
+	}
+	if b.tokens >= need {
+		return 0
+	}
+	return time.Duration((need - b.tokens) / b.rate * float64(time.Second))
+}
+
+func (b *tokenBucket) take(n float64) {
+	if b == nil {
+		return
+	}
+	b.tokens -= n
+}
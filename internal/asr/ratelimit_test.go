@@ -0,0 +1,85 @@
+package asr
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	tests := []struct {
+		name    string
+		perMin  float64
+		take    float64       // потрачено в момент start
+		elapsed time.Duration // с момента start
+		need    float64
+		want    time.Duration
+	}{
+		{"full", 60, 0, 0, 1, 0},
+		{"empty", 60, 60, 0, 1, time.Second},
+		{"refilled", 60, 60, time.Second, 1, 0},
+		{"partly refilled", 60, 60, 500 * time.Millisecond, 1, 500 * time.Millisecond},
+		{"refill capped", 60, 0, time.Hour, 60, 0},
+		{"over capacity waits for full bucket", 60, 30, 0, 120, 30 * time.Second},
+		{"debt", 60, 90, 0, 1, 31 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTokenBucket(tt.perMin)
+			b.last = start
+			b.take(tt.take)
+			got := b.wait(start.Add(tt.elapsed), tt.need)
+			if d := got - tt.want; d < -time.Millisecond || d > time.Millisecond {
+				t.Errorf("wait = %s, want %s", got, tt.want)
+			}
+			if b.tokens > b.capacity {
+				t.Errorf("tokens %.1f over capacity %.1f", b.tokens, b.capacity)
+			}
+		})
+	}
+
+	unlimited := newTokenBucket(0)
+	if unlimited != nil || unlimited.wait(start, 1e9) != 0 {
+		t.Error("zero rate must disable the bucket")
+	}
+	unlimited.take(1)
+}
+
+func TestRateLimiterConcurrency(t *testing.T) {
+	l := NewRateLimiter(0, 0, 1)
+	release := l.Acquire(1)
+
+	acquired := make(chan struct{})
+	go func() {
+		l.Acquire(1)()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second request ran past MaxConcurrent=1")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("slot not released")
+	}
+}
+
+func TestRateLimiterPause(t *testing.T) {
+	l := NewRateLimiter(0, 0, 0)
+	l.Pause(100 * time.Millisecond)
+	l.Pause(10 * time.Millisecond) // более короткая пауза не сокращает текущую
+
+	start := time.Now()
+	l.Acquire(1)()
+	if d := time.Since(start); d < 90*time.Millisecond {
+		t.Errorf("Acquire after Pause returned in %s", d)
+	}
+
+	var none *RateLimiter
+	none.Pause(time.Hour)
+	none.Acquire(1)()
+}
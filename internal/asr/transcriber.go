@@ -0,0 +1,77 @@
+package asr
+
+import "time"
+
+// Transcriber — общий интерфейс для всех ASR движков (Kaldi, Whisper, ...)
+type Transcriber interface {
+	Transcribe(audioPath string) (*DecodeResult, error)
+	Health() error
+}
+
+// Segment — отрезок распознанного текста с таймингом (Whisper verbose_json).
+// AvgLogprob/NoSpeechProb — nil, если сервер их не вернул
+type Segment struct {
+	Start        float64  `json:"start"`
+	End          float64  `json:"end"`
+	Text         string   `json:"text"`
+	AvgLogprob   *float64 `json:"avg_logprob"`
+	NoSpeechProb *float64 `json:"no_speech_prob"`
+}
+
+// WordTiming — слово с таймингом (секунды от начала файла) и уверенностью движка
+// (posterior Kaldi, probability faster-whisper). Confidence nil — движок её не даёт
+type WordTiming struct {
+	Word       string   `json:"word"`
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Confidence *float64 `json:"confidence"`
+}
+
+// BatchTranscriber — движок, умеющий декодировать пачку файлов за один вызов.
+// Результат — map[audioPath]*DecodeResult
+type BatchTranscriber interface {
+	Transcriber
+	TranscribeBatch(audioPaths []string) (map[string]*DecodeResult, error)
+}
+
+// WorkerTranscriber — движок, которому выгодно держать состояние на воркер
+// (Kaldi server mode: процесс с загруженной моделью). Воркер берёт свой
+// TranscriberWorker на весь запуск и закрывает его в конце
+type WorkerTranscriber interface {
+	Transcriber
+	NewWorker() TranscriberWorker
+}
+
+// TranscriberWorker — Transcriber одного воркера
+type TranscriberWorker interface {
+	Transcriber
+	Stats() WorkerStats
+	Close() error
+}
+
+// WorkerStats — счётчики долгоживущего процесса воркера
+type WorkerStats struct {
+	Starts      int           // запусков процесса
+	Restarts    int           // процесс упал или завис и был убит
+	StartupTime time.Duration // суммарная загрузка модели и графа
+	Utterances  int           // отправлено файлов
+}
+
+// Transcribe реализует Transcriber для Kaldi (CPU, по одному файлу)
+func (d *KaldiDecoder) Transcribe(audioPath string) (*DecodeResult, error) {
+	return d.Decode(audioPath)
+}
+
+// KaldiGPUDecoder — обёртка над KaldiDecoder для batch декодирования на GPU
+type KaldiGPUDecoder struct {
+	*KaldiDecoder
+}
+
+func NewKaldiGPUDecoder(d *KaldiDecoder) *KaldiGPUDecoder {
+	return &KaldiGPUDecoder{KaldiDecoder: d}
+}
+
+// TranscribeBatch реализует BatchTranscriber через batched-wav-nnet3-cuda
+func (g *KaldiGPUDecoder) TranscribeBatch(audioPaths []string) (map[string]*DecodeResult, error) {
+	return g.DecodeBatchGPU(audioPaths)
+}
@@ -10,6 +10,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -55,6 +57,7 @@ func (c *WhisperLocalClient) Transcribe(audioPath string) (*DecodeResult, error)
 	}
 
 	writer.WriteField("language", c.language)
+	writer.WriteField("word_timestamps", "true")
 	writer.Close()
 
 	req, err := http.NewRequest("POST", c.baseURL+"/transcribe", &buf)
@@ -75,16 +78,10 @@ func (c *WhisperLocalClient) Transcribe(audioPath string) (*DecodeResult, error)
 	}
 
 	if resp.StatusCode != 200 {
-		return &DecodeResult{
-			Success: false,
-			Error:   fmt.Sprintf("status %d: %s", resp.StatusCode, string(body)),
-		}, nil
+		return httpFailure(resp, body), nil
 	}
 
-	var result struct {
-		Text     string  `json:"text"`
-		Duration float64 `json:"duration"`
-	}
+	var result whisperResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
@@ -100,12 +97,15 @@ func (c *WhisperLocalClient) Transcribe(audioPath string) (*DecodeResult, error)
 		rtf = elapsed / duration
 	}
 
+	// сегменты и слова — если сервер их отдаёт
 	return &DecodeResult{
 		Text:           result.Text,
 		Duration:       duration,
 		ProcessingTime: elapsed,
 		RTF:            rtf,
 		Success:        true,
+		Segments:       result.segments(),
+		Words:          result.words(),
 	}, nil
 }
 
@@ -123,12 +123,25 @@ func (c *WhisperLocalClient) Health() error {
 
 // === Whisper OpenAI Client ===
 
+// OpenAIDefaultURL — API OpenAI; для совместимых серверов (self-hosted, заглушка) задаётся свой
+const OpenAIDefaultURL = "https://api.openai.com/v1"
+
+// WhisperOpenAIOptions — параметры запроса /audio/transcriptions
+type WhisperOpenAIOptions struct {
+	ResponseFormat string   // json | verbose_json (сегменты с таймингом и уверенностью)
+	Timestamps     []string // timestamp_granularities для verbose_json: segment, word
+	Prompt         string   // подсказка: словарь, стиль пунктуации
+	Temperature    float64  // 0 — по умолчанию сервера
+}
+
 type WhisperOpenAIClient struct {
 	baseURL    string
 	apiKey     string
 	model      string
 	language   string
+	opts       WhisperOpenAIOptions
 	httpClient *http.Client
+	limiter    *RateLimiter // nil — без ограничений
 }
 
 func NewWhisperOpenAIClient(apiKey, model, language string) *WhisperOpenAIClient {
@@ -139,18 +152,42 @@ func NewWhisperOpenAIClient(apiKey, model, language string) *WhisperOpenAIClient
 		language = "az"
 	}
 	return &WhisperOpenAIClient{
-		baseURL:  "https://api.openai.com/v1",
+		baseURL:  OpenAIDefaultURL,
 		apiKey:   apiKey,
 		model:    model,
 		language: language,
+		opts:     WhisperOpenAIOptions{ResponseFormat: "json"},
 		httpClient: &http.Client{
 			Timeout: 300 * time.Second,
 		},
 	}
 }
 
+// WithBaseURL — OpenAI-совместимый сервер вместо api.openai.com
+func (c *WhisperOpenAIClient) WithBaseURL(baseURL string) *WhisperOpenAIClient {
+	if baseURL != "" {
+		c.baseURL = strings.TrimRight(baseURL, "/")
+	}
+	return c
+}
+
+// WithOptions — формат ответа, prompt, temperature
+func (c *WhisperOpenAIClient) WithOptions(opts WhisperOpenAIOptions) *WhisperOpenAIClient {
+	if opts.ResponseFormat == "" {
+		opts.ResponseFormat = "json"
+	}
+	c.opts = opts
+	return c
+}
+
+// WithRateLimiter — общий для всех воркеров лимит запросов/минут аудио
+func (c *WhisperOpenAIClient) WithRateLimiter(l *RateLimiter) *WhisperOpenAIClient {
+	c.limiter = l
+	return c
+}
+
 func (c *WhisperOpenAIClient) Transcribe(audioPath string) (*DecodeResult, error) {
-	start := time.Now()
+	duration, _ := audio.GetAudioDuration(audioPath)
 
 	file, err := os.Open(audioPath)
 	if err != nil {
@@ -171,6 +208,18 @@ func (c *WhisperOpenAIClient) Transcribe(audioPath string) (*DecodeResult, error
 
 	writer.WriteField("model", c.model)
 	writer.WriteField("language", c.language)
+	writer.WriteField("response_format", c.opts.ResponseFormat)
+	if c.verbose() {
+		for _, g := range c.opts.Timestamps {
+			writer.WriteField("timestamp_granularities[]", g)
+		}
+	}
+	if c.opts.Prompt != "" {
+		writer.WriteField("prompt", c.opts.Prompt)
+	}
+	if c.opts.Temperature > 0 {
+		writer.WriteField("temperature", strconv.FormatFloat(c.opts.Temperature, 'f', -1, 64))
+	}
 	writer.Close()
 
 	req, err := http.NewRequest("POST", c.baseURL+"/audio/transcriptions", &buf)
@@ -180,6 +229,10 @@ func (c *WhisperOpenAIClient) Transcribe(audioPath string) (*DecodeResult, error
 	req.Header.Set("Content-Type", writer.FormDataContentType())
 	req.Header.Set("Authorization", "Bearer "+c.apiKey)
 
+	release := c.limiter.Acquire(duration)
+	defer release()
+
+	start := time.Now()
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
 		return nil, err
@@ -192,34 +245,45 @@ func (c *WhisperOpenAIClient) Transcribe(audioPath string) (*DecodeResult, error
 	}
 
 	if resp.StatusCode != 200 {
-		return &DecodeResult{
-			Success: false,
-			Error:   fmt.Sprintf("status %d: %s", resp.StatusCode, string(body)),
-		}, nil
+		failure := httpFailure(resp, body)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			c.limiter.Pause(failure.RetryAfter)
+		}
+		return failure, nil
 	}
 
-	var result struct {
-		Text string `json:"text"`
-	}
+	var result whisperResponse
 	if err := json.Unmarshal(body, &result); err != nil {
 		return nil, err
 	}
 
 	elapsed := time.Since(start).Seconds()
-	duration, _ := audio.GetAudioDuration(audioPath)
+	if result.Duration > 0 {
+		duration = result.Duration
+	}
 
 	rtf := 0.0
 	if duration > 0 {
 		rtf = elapsed / duration
 	}
 
-	return &DecodeResult{
+	decoded := &DecodeResult{
 		Text:           result.Text,
 		Duration:       duration,
 		ProcessingTime: elapsed,
 		RTF:            rtf,
 		Success:        true,
-	}, nil
+	}
+	if c.verbose() {
+		// пустые, но не nil: старые сегменты и слова файла будут удалены
+		decoded.Segments = append([]Segment{}, result.segments()...)
+		decoded.Words = append([]WordTiming{}, result.words()...)
+	}
+	return decoded, nil
+}
+
+func (c *WhisperOpenAIClient) verbose() bool {
+	return c.opts.ResponseFormat == "verbose_json"
 }
 
 func (c *WhisperOpenAIClient) Health() error {
@@ -233,3 +297,59 @@ func (c *WhisperOpenAIClient) Health() error {
 	defer resp.Body.Close()
 	return nil
 }
+
+// whisperResponse — ответ faster-whisper сервера и OpenAI (verbose_json).
+// Слова бывают на верхнем уровне (OpenAI timestamp_granularities=word) или внутри сегментов
+type whisperResponse struct {
+	Text     string           `json:"text"`
+	Duration float64          `json:"duration"`
+	Segments []whisperSegment `json:"segments"`
+	Words    []whisperWord    `json:"words"`
+}
+
+type whisperSegment struct {
+	Segment
+	Words []whisperWord `json:"words"`
+}
+
+// whisperWord — probability есть у faster-whisper, у OpenAI нет
+type whisperWord struct {
+	Word        string   `json:"word"`
+	Start       float64  `json:"start"`
+	End         float64  `json:"end"`
+	Probability *float64 `json:"probability"`
+}
+
+func (r *whisperResponse) segments() []Segment {
+	if r.Segments == nil {
+		return nil
+	}
+	segments := make([]Segment, len(r.Segments))
+	for i, seg := range r.Segments {
+		segments[i] = seg.Segment
+	}
+	return segments
+}
+
+// words — слова с таймингом; nil, если сервер их не вернул
+func (r *whisperResponse) words() []WordTiming {
+	src := r.Words
+	if len(src) == 0 {
+		for _, seg := range r.Segments {
+			src = append(src, seg.Words...)
+		}
+	}
+	if len(src) == 0 {
+		return nil
+	}
+
+	words := make([]WordTiming, 0, len(src))
+	for _, w := range src {
+		text := strings.TrimSpace(w.Word)
+		if text == "" {
+			continue
+		}
+		words = append(words, WordTiming{Word: text, Start: w.Start, End: w.End, Confidence: w.Probability})
+	}
+	return words
+}
@@ -0,0 +1,75 @@
+package asr_test
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/testutil"
+)
+
+func TestWhisperOpenAI(t *testing.T) {
+	testutil.AudioTools(t)
+	server := testutil.NewOpenAI(t, "sk-test")
+	path := filepath.Join(t.TempDir(), "utt.wav")
+	if err := testutil.WriteWAV(path, testutil.Speech("hello world", 16000)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		opts     asr.WhisperOpenAIOptions
+		segments bool
+		words    int
+	}{
+		{"default json", asr.WhisperOpenAIOptions{}, false, 0},
+		{"verbose", asr.WhisperOpenAIOptions{ResponseFormat: "verbose_json", Timestamps: []string{"segment"}}, true, 0},
+		{"verbose words", asr.WhisperOpenAIOptions{ResponseFormat: "verbose_json", Timestamps: []string{"segment", "word"}}, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := asr.NewWhisperOpenAIClient("sk-test", "", "en").WithBaseURL(server.URL).WithOptions(tt.opts)
+			if err := c.Health(); err != nil {
+				t.Fatal(err)
+			}
+			r, err := c.Transcribe(path)
+			if err != nil || !r.Success || r.Text != "hello world" {
+				t.Fatalf("result: %+v %v", r, err)
+			}
+			if (r.Segments != nil) != tt.segments || len(r.Words) != tt.words {
+				t.Errorf("segments %v, words %v", r.Segments, r.Words)
+			}
+		})
+	}
+}
+
+func TestWhisperOpenAIFailures(t *testing.T) {
+	testutil.AudioTools(t)
+	server := testutil.NewOpenAI(t, "sk-test")
+	path := filepath.Join(t.TempDir(), "utt.wav")
+	if err := testutil.WriteWAV(path, testutil.Speech("hello", 16000)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		key    string
+		status int // FailNext; 0 — без сбоя
+		class  string
+	}{
+		{"bad key", "sk-wrong", 0, asr.ErrorFatal},
+		{"overloaded", "sk-test", http.StatusServiceUnavailable, asr.ErrorTransient},
+		{"rejected file", "sk-test", http.StatusRequestEntityTooLarge, asr.ErrorPermanent},
+	}
+	for _, tt := range tests {
+		if tt.status != 0 {
+			server.FailNext(1, tt.status)
+		}
+		c := asr.NewWhisperOpenAIClient(tt.key, "", "en").WithBaseURL(server.URL)
+		r, err := c.Transcribe(path)
+		if err != nil || r.Success || r.ErrorClass != tt.class {
+			t.Errorf("%s: %+v %v, want class %s", tt.name, r, err, tt.class)
+		}
+	}
+}
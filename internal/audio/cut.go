@@ -0,0 +1,38 @@
+package audio
+
+import (
+	"fmt"
+	"os/exec"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// CutAudio вырезает [start, start+duration) в PCM 16 bit; rate и channels — частота и число
+// каналов результата (0 — как в исходнике). WAV режется напрямую, остальное через ffmpeg
+func CutAudio(inputPath, outputPath string, start, duration float64, rate, channels int) error {
+	if b, err := wav.ReadRange(inputPath, start, duration); err == nil {
+		out := b.Convert(rate, channels)
+		out.Format = wav.PCM16(out.Format.SampleRate, out.Format.Channels)
+		return wav.WriteFile(outputPath, out)
+	}
+
+	args := []string{"-y",
+		"-i", inputPath,
+		"-ss", fmt.Sprintf("%.3f", start),
+		"-t", fmt.Sprintf("%.3f", duration),
+		"-c:a", "pcm_s16le",
+	}
+	if rate > 0 {
+		args = append(args, "-ar", fmt.Sprintf("%d", rate))
+	}
+	if channels > 0 {
+		args = append(args, "-ac", fmt.Sprintf("%d", channels))
+	}
+	args = append(args, outputPath)
+
+	output, err := exec.Command("ffmpeg", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ffmpeg cut error: %v, output: %s", err, string(output))
+	}
+	return nil
+}
@@ -0,0 +1,378 @@
+package audio
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// Типы дефектов записи
+const (
+	DefectClipping   = "clipping"   // отсчёты упираются в полную шкалу
+	DefectDCOffset   = "dc_offset"  // постоянная составляющая
+	DefectDropout    = "dropout"    // цифровые нули посреди сигнала
+	DefectHum        = "hum"        // сетевой фон 50/60 Hz
+	DefectNarrowband = "narrowband" // апсемпл: полоса 8 kHz записи в файле 16 kHz+
+)
+
+// Серьёзность по возрастанию
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// DefectTypes — все типы, для проверки фильтров
+var DefectTypes = []string{DefectClipping, DefectDCOffset, DefectDropout, DefectHum, DefectNarrowband}
+
+// Severities — все уровни по возрастанию
+var Severities = []string{SeverityLow, SeverityMedium, SeverityHigh}
+
+// Defect — найденный дефект; дефекты всего файла — от 0 до длительности
+type Defect struct {
+	Type     string  `json:"type"`
+	Severity string  `json:"severity"`
+	Start    float64 `json:"start"`
+	End      float64 `json:"end"`
+	Value    float64 `json:"value"` // мера дефекта, единицы — в Detail
+	Detail   string  `json:"detail,omitempty"`
+}
+
+const (
+	clipLevel       = 0.999 // |x| не ниже — полная шкала
+	clipMinRun      = 3     // столько отсчётов подряд на полной шкале — клиппинг
+	clipMergeSec    = 0.1   // соседние участки клиппинга ближе — один участок
+	dcOffsetMin     = 0.01
+	dropoutMinSec   = 0.01
+	dropoutEdgeSec  = 0.02 // окно по краям нулей: сигнал вокруг должен быть громким
+	dropoutEdgeDB   = -45.0
+	humProminenceDB = 15.0 // пик 50/60 Hz над соседними частотами
+	humBlockSec     = 1.0  // разрешение 1 Hz
+	narrowbandHz    = 4500.0
+	narrowbandDB    = -50.0 // выше полосы среза — ниже полосы речи на столько
+	maxDefectRanges = 50    // участков одного типа на файл, дальше — только самые серьёзные
+)
+
+// DetectDefects анализирует WAV файл
+func DetectDefects(path string) ([]Defect, error) {
+	b, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzeDefects(b), nil
+}
+
+// AnalyzeDefects — клиппинг и DC по каналам, остальное по моно
+func AnalyzeDefects(b *wav.Buffer) []Defect {
+	defects := []Defect{}
+	if b.Frames() == 0 {
+		return defects
+	}
+	mono := b.Mono()
+	rate := b.Format.SampleRate
+
+	defects = append(defects, detectClipping(b)...)
+	defects = append(defects, detectDCOffset(b)...)
+	defects = append(defects, detectDropouts(b)...)
+	if d := detectHum(mono.Samples, rate); d != nil {
+		defects = append(defects, *d)
+	}
+	if d := detectNarrowband(mono.Samples, rate); d != nil {
+		defects = append(defects, *d)
+	}
+	return defects
+}
+
+// detectClipping — участки с сериями отсчётов на полной шкале. Серьёзность по длительности
+// срезанного сигнала в участке: от 10ms — high, от 1ms — medium
+func detectClipping(b *wav.Buffer) []Defect {
+	n := b.Format.Channels
+	rate := float64(b.Format.SampleRate)
+
+	var ranges []Defect
+	clipped := 0 // отсчётов в текущем участке
+	run := make([]int, n)
+	for i, v := range b.Samples {
+		ch := i % n
+		if math.Abs(v) < clipLevel {
+			run[ch] = 0
+			continue
+		}
+		run[ch]++
+		if run[ch] < clipMinRun {
+			continue
+		}
+		t := float64(i/n) / rate
+		count := 1
+		if run[ch] == clipMinRun {
+			count = clipMinRun
+		}
+		if last := len(ranges) - 1; last >= 0 && t-ranges[last].End <= clipMergeSec {
+			ranges[last].End = t + 1/rate
+			clipped += count
+			ranges[last].Value = float64(clipped) / rate * 1000
+			continue
+		}
+		clipped = count
+		ranges = append(ranges, Defect{Type: DefectClipping, Start: t - float64(count-1)/rate, End: t + 1/rate,
+			Value: float64(clipped) / rate * 1000})
+	}
+
+	for i := range ranges {
+		ranges[i].Severity = severity(ranges[i].Value, 1, 10)
+		ranges[i].Detail = fmt.Sprintf("%.1f ms at full scale", ranges[i].Value)
+	}
+	return limitRanges(ranges)
+}
+
+// detectDCOffset — среднее канала по модулю от 1% шкалы
+func detectDCOffset(b *wav.Buffer) []Defect {
+	var defects []Defect
+	for ch := 0; ch < b.Format.Channels; ch++ {
+		var sum float64
+		samples := b.Channel(ch)
+		for _, v := range samples {
+			sum += v
+		}
+		mean := sum / float64(len(samples))
+		if math.Abs(mean) < dcOffsetMin {
+			continue
+		}
+		detail := fmt.Sprintf("mean %.3f", mean)
+		if b.Format.Channels > 1 {
+			detail = fmt.Sprintf("channel %d: mean %.3f", ch+1, mean)
+		}
+		defects = append(defects, Defect{
+			Type: DefectDCOffset, Severity: severity(math.Abs(mean), 0.02, 0.05),
+			Start: 0, End: b.Duration(), Value: mean, Detail: detail,
+		})
+	}
+	return defects
+}
+
+// detectDropouts — серии точных нулей во всех каналах от 10ms, вокруг которых громкий сигнал.
+// Нули в начале и в конце файла и паузы после тихих участков (тишина, склейка merge) не считаются
+func detectDropouts(b *wav.Buffer) []Defect {
+	n := b.Format.Channels
+	frames := b.Frames()
+	rate := float64(b.Format.SampleRate)
+	minRun := max(int(dropoutMinSec*rate), 1)
+	edge := max(int(dropoutEdgeSec*rate), 1)
+
+	zero := func(i int) bool {
+		for _, v := range b.Samples[i*n : (i+1)*n] {
+			if v != 0 {
+				return false
+			}
+		}
+		return true
+	}
+	loud := func(from, to int) bool {
+		if from < 0 || to > frames || to <= from {
+			return false
+		}
+		return rmsDB(b.Samples[from*n:to*n]) >= dropoutEdgeDB
+	}
+
+	var ranges []Defect
+	for i := 0; i < frames; {
+		if !zero(i) {
+			i++
+			continue
+		}
+		j := i
+		for j < frames && zero(j) {
+			j++
+		}
+		if j-i >= minRun && i > 0 && j < frames && loud(i-edge, i) && loud(j, j+edge) {
+			ms := float64(j-i) / rate * 1000
+			ranges = append(ranges, Defect{
+				Type: DefectDropout, Severity: severity(ms, 30, 100),
+				Start: float64(i) / rate, End: float64(j) / rate,
+				Value: ms, Detail: fmt.Sprintf("%.0f ms of digital silence", ms),
+			})
+		}
+		i = j
+	}
+	return limitRanges(ranges)
+}
+
+// detectHum — пик 50 или 60 Hz в среднем спектре (блоки по 1s, окно Ханна) над соседними
+// частотами ±3..7 Hz. Серьёзность — по уровню фона относительно речи
+func detectHum(samples []float64, rate int) *Defect {
+	block := int(humBlockSec * float64(rate))
+	if len(samples) < block {
+		return nil
+	}
+
+	var best *Defect
+	for _, mains := range []float64{50, 60} {
+		freqs := []float64{mains}
+		for d := 3.0; d <= 7; d++ {
+			freqs = append(freqs, mains-d, mains+d)
+		}
+		power := blockPowers(samples, rate, block, freqs)
+
+		neighbors := append([]float64(nil), power[1:]...)
+		sort.Float64s(neighbors)
+		floor := neighbors[len(neighbors)/2]
+		if floor <= 0 {
+			floor = 1e-20
+		}
+		prominence := 10 * math.Log10(power[0]/floor)
+		if prominence < humProminenceDB || (best != nil && prominence <= best.Value) {
+			continue
+		}
+
+		// амплитуда синуса по Goertzel с окном Ханна: |X| = A·N/4; уровень — RMS, dBFS
+		amp := 4 * math.Sqrt(power[0]) / float64(block)
+		humDB := 20 * math.Log10(amp/math.Sqrt2)
+		signalDB := rmsDB(samples)
+		if vad := DetectSpeech(samples, rate, vadOptions); vad.HasSpeech() {
+			if speechDB, _, ok := vad.LevelsDB(); ok {
+				signalDB = speechDB
+			}
+		}
+		below := signalDB - humDB
+
+		sev := SeverityLow
+		switch {
+		case below < 20:
+			sev = SeverityHigh
+		case below < 35:
+			sev = SeverityMedium
+		}
+		best = &Defect{
+			Type: DefectHum, Severity: sev, Start: 0, End: float64(len(samples)) / float64(rate),
+			Value:  math.Round(prominence*10) / 10,
+			Detail: fmt.Sprintf("%.0f Hz at %.1f dBFS, %.1f dB below speech", mains, humDB, below),
+		}
+	}
+	return best
+}
+
+// blockPowers — средняя по блокам мощность Goertzel на частотах freqs
+func blockPowers(samples []float64, rate, block int, freqs []float64) []float64 {
+	win := make([]float64, block)
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(block))
+	}
+
+	power := make([]float64, len(freqs))
+	blocks := 0
+	x := make([]float64, block)
+	for start := 0; start+block <= len(samples); start += block / 2 {
+		for i := range x {
+			x[i] = samples[start+i] * win[i]
+		}
+		for k, f := range freqs {
+			power[k] += goertzelPower(x, f, float64(rate))
+		}
+		blocks++
+	}
+	for k := range power {
+		power[k] /= float64(blocks)
+	}
+	return power
+}
+
+func goertzelPower(x []float64, freq, rate float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/rate)
+	var s1, s2 float64
+	for _, v := range x {
+		s1, s2 = v+coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// detectNarrowband — файл от 12 kHz, а выше narrowbandHz энергии почти нет: запись сделана
+// с меньшей частотой и передискретизирована. Полоса — последний бин не ниже полосы речи - 50 dB
+func detectNarrowband(samples []float64, rate int) *Defect {
+	if rate < 12000 {
+		return nil
+	}
+	s := stft(samples, rate)
+	if len(s.Power) == 0 {
+		return nil
+	}
+
+	// средний спектр речевых кадров (без речи — всех)
+	vad := DetectSpeech(samples, rate, vadOptions)
+	bins := len(s.Power[0])
+	mean := make([]float64, bins)
+	frames := 0
+	for t, p := range s.Power {
+		if vad.HasSpeech() {
+			i := int(s.FrameTime(t) / vad.FrameSec)
+			if i >= len(vad.Voiced) || !vad.Voiced[i] {
+				continue
+			}
+		}
+		for k, v := range p {
+			mean[k] += v
+		}
+		frames++
+	}
+	if frames == 0 {
+		return nil
+	}
+
+	slo, shi := s.bins(speechBandLoHz, speechBandHiHz)
+	speech := bandMean(mean, slo, shi)
+	if speech <= 0 {
+		return nil
+	}
+	threshold := speech * math.Pow(10, narrowbandDB/10)
+	top := 0
+	for k := bins - 1; k > 0; k-- {
+		if mean[k] >= threshold {
+			top = k
+			break
+		}
+	}
+	cutoff := float64(top) * s.BinHz
+	if cutoff >= narrowbandHz {
+		return nil
+	}
+
+	return &Defect{
+		Type: DefectNarrowband, Severity: SeverityHigh, Start: 0, End: float64(len(samples)) / float64(rate),
+		Value:  math.Round(cutoff),
+		Detail: fmt.Sprintf("content up to %.0f Hz in %d Hz file", cutoff, rate),
+	}
+}
+
+// severity — low ниже medium, high от high
+func severity(v, medium, high float64) string {
+	switch {
+	case v >= high:
+		return SeverityHigh
+	case v >= medium:
+		return SeverityMedium
+	}
+	return SeverityLow
+}
+
+// SeverityRank — 0 для low, 2 для high, -1 для неизвестного
+func SeverityRank(s string) int {
+	for i, v := range Severities {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// limitRanges оставляет maxDefectRanges самых серьёзных участков по порядку времени
+func limitRanges(ranges []Defect) []Defect {
+	if len(ranges) <= maxDefectRanges {
+		return ranges
+	}
+	sorted := append([]Defect(nil), ranges...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Value > sorted[j].Value })
+	sorted = sorted[:maxDefectRanges]
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	return sorted
+}
@@ -0,0 +1,79 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// cleanSpeech — speechLike с шумом -60 dBFS, без дефектов
+func cleanSpeech(rate int) []float64 {
+	rng := rand.New(rand.NewSource(3))
+	x, _ := speechLike(rate, 3)
+	for i := range x {
+		x[i] += 0.001 * rng.NormFloat64()
+	}
+	return x
+}
+
+func defectTypes(defects []Defect) map[string]Defect {
+	m := make(map[string]Defect)
+	for _, d := range defects {
+		m[d.Type] = d
+	}
+	return m
+}
+
+func TestAnalyzeDefects(t *testing.T) {
+	mono := func(rate int, x []float64) *wav.Buffer {
+		return &wav.Buffer{Format: wav.PCM16(rate, 1), Samples: x}
+	}
+
+	if d := AnalyzeDefects(mono(16000, cleanSpeech(16000))); len(d) != 0 {
+		t.Errorf("clean: %+v", d)
+	}
+
+	// клиппинг: усиление в 20 раз
+	x := cleanSpeech(16000)
+	for i := range x {
+		x[i] = math.Max(-1, math.Min(1, x[i]*20))
+	}
+	if d, ok := defectTypes(AnalyzeDefects(mono(16000, x)))[DefectClipping]; !ok || d.Severity != SeverityHigh {
+		t.Errorf("clipping: %+v", d)
+	}
+
+	// DC offset 0.03
+	x = cleanSpeech(16000)
+	for i := range x {
+		x[i] += 0.03
+	}
+	if d, ok := defectTypes(AnalyzeDefects(mono(16000, x)))[DefectDCOffset]; !ok || d.Severity != SeverityMedium {
+		t.Errorf("dc offset: %+v", d)
+	}
+
+	// 50ms нулей посреди слова (слово 0.3..1.1s)
+	x = cleanSpeech(16000)
+	for i := 8800; i < 9600; i++ {
+		x[i] = 0
+	}
+	if d, ok := defectTypes(AnalyzeDefects(mono(16000, x)))[DefectDropout]; !ok || math.Abs(d.Start-0.55) > 0.01 || d.Severity != SeverityMedium {
+		t.Errorf("dropout: %+v", d)
+	}
+
+	// фон 60 Hz на ~25 dB ниже речи
+	x = cleanSpeech(16000)
+	for i := range x {
+		x[i] += 0.01 * math.Sin(2*math.Pi*60*float64(i)/16000)
+	}
+	if d, ok := defectTypes(AnalyzeDefects(mono(16000, x)))[DefectHum]; !ok || d.Severity != SeverityMedium {
+		t.Errorf("hum: %+v", d)
+	}
+
+	// 8 kHz запись, передискретизированная в 16 kHz
+	x = wav.Resample(cleanSpeech(8000), 8000, 16000)
+	if d, ok := defectTypes(AnalyzeDefects(mono(16000, x)))[DefectNarrowband]; !ok || d.Value > 4100 {
+		t.Errorf("narrowband: %+v", d)
+	}
+}
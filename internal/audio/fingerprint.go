@@ -0,0 +1,288 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+	"math/cmplx"
+	"sort"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// Акустический отпечаток (Haitsma–Kalker): звук приводится к 8kHz моно, каждые 16ms
+// кадр 128ms раскладывается на 33 логарифмические полосы 300-3400Hz, бит m кадра n —
+// знак (E[n,m]-E[n,m+1]) - (E[n-1,m]-E[n-1,m+1]). Знаки разностей не зависят от громкости
+// и переживают ресемплинг и перекодирование; тишина по краям отрезается, так что
+// добавленные AddSilence паузы отпечаток не меняют
+const (
+	fpRate      = 8000
+	fpFrame     = 1024 // 128ms
+	fpHop       = 128  // 16ms
+	fpBands     = 33
+	fpLoHz      = 300
+	fpHiHz      = 3400
+	fpSilenceDB = -40 // кадр тише самого громкого на 40dB — тишина
+
+	fpMinCoverage = 0.8 // перекрытие — доля более длинного отпечатка
+	fpMaxShifts   = 3   // сдвигов-кандидатов по совпавшим ключам
+	fpIndexStride = 2   // в индекс попадает каждый второй кадр
+	fpMinHits     = 4   // совпавших ключей у кандидата в индексе
+	fpCommonKey   = 500 // ключи с большим числом записей не различают файлы
+)
+
+// FingerprintHopSec — шаг кадров отпечатка в секундах
+const FingerprintHopSec = float64(fpHop) / fpRate
+
+// Fingerprint — 32-битные суботпечатки кадров
+type Fingerprint []uint32
+
+// ErrNoFingerprint — в файле нет звука громче тишины или он короче кадра
+var ErrNoFingerprint = errors.New("audio too short or silent for fingerprint")
+
+// FingerprintFile — отпечаток WAV файла
+func FingerprintFile(path string) (Fingerprint, error) {
+	b, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FingerprintBuffer(b)
+}
+
+// FingerprintBuffer — отпечаток звука любой частоты и числа каналов
+func FingerprintBuffer(b *wav.Buffer) (Fingerprint, error) {
+	x := b.Mono().Resample(fpRate).Samples
+	if len(x) < fpFrame {
+		return nil, ErrNoFingerprint
+	}
+
+	win := make([]float64, fpFrame)
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(fpFrame))
+	}
+	edges := make([]int, fpBands+1)
+	for m := range edges {
+		hz := fpLoHz * math.Pow(float64(fpHiHz)/fpLoHz, float64(m)/fpBands)
+		edges[m] = int(math.Round(hz * fpFrame / fpRate))
+	}
+
+	var energy [][]float64
+	var total []float64
+	buf := make([]complex128, fpFrame)
+	for start := 0; start+fpFrame <= len(x); start += fpHop {
+		for i := range buf {
+			buf[i] = complex(x[start+i]*win[i], 0)
+		}
+		fft(buf)
+
+		e := make([]float64, fpBands)
+		var sum float64
+		for m := range e {
+			for k := edges[m]; k < max(edges[m+1], edges[m]+1); k++ {
+				a := cmplx.Abs(buf[k])
+				e[m] += a * a
+			}
+			sum += e[m]
+		}
+		energy = append(energy, e)
+		total = append(total, sum)
+	}
+
+	// Тишина по краям
+	var peak float64
+	for _, v := range total {
+		peak = max(peak, v)
+	}
+	floor := peak * math.Pow(10, fpSilenceDB/10.0)
+	first, last := 0, len(total)-1
+	for first <= last && total[first] <= floor {
+		first++
+	}
+	for last >= first && total[last] <= floor {
+		last--
+	}
+	if peak == 0 || last-first < 1 {
+		return nil, ErrNoFingerprint
+	}
+
+	fp := make(Fingerprint, 0, last-first)
+	for n := first + 1; n <= last; n++ {
+		var v uint32
+		for m := 0; m < 32; m++ {
+			d := (energy[n][m] - energy[n][m+1]) - (energy[n-1][m] - energy[n-1][m+1])
+			if d > 0 {
+				v |= 1 << m
+			}
+		}
+		fp = append(fp, v)
+	}
+	return fp, nil
+}
+
+// Duration — длительность звука без тишины по краям, в секундах
+func (f Fingerprint) Duration() float64 {
+	return float64(len(f)) * FingerprintHopSec
+}
+
+// Bytes — отпечаток для хранения (little endian)
+func (f Fingerprint) Bytes() []byte {
+	out := make([]byte, 4*len(f))
+	for i, v := range f {
+		binary.LittleEndian.PutUint32(out[4*i:], v)
+	}
+	return out
+}
+
+// ParseFingerprint — обратное к Bytes
+func ParseFingerprint(data []byte) Fingerprint {
+	f := make(Fingerprint, len(data)/4)
+	for i := range f {
+		f[i] = binary.LittleEndian.Uint32(data[4*i:])
+	}
+	return f
+}
+
+// fpKeys — ключи кадра для поиска: половины суботпечатка. 16 бит совпадают
+// у копии того же звука намного чаще, чем все 32
+func fpKeys(v uint32) [2]uint32 {
+	return [2]uint32{v & 0xffff, v>>16 | 1<<16}
+}
+
+// CompareFingerprints — доля совпавших битов при лучшем выравнивании, 0..1.
+// Случайные пары дают около 0.5; 0 — перекрытие меньше fpMinCoverage более длинного
+func CompareFingerprints(a, b Fingerprint) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+
+	// Сдвиги-кандидаты — по совпавшим ключам, плюс выравнивание по началу
+	positions := make(map[uint32][]int, 2*len(a))
+	for i, v := range a {
+		for _, k := range fpKeys(v) {
+			positions[k] = append(positions[k], i)
+		}
+	}
+	votes := make(map[int]int)
+	for j, v := range b {
+		for _, k := range fpKeys(v) {
+			for _, i := range positions[k] {
+				votes[i-j]++
+			}
+		}
+	}
+	shifts := make([]int, 0, len(votes))
+	for s := range votes {
+		shifts = append(shifts, s)
+	}
+	sort.Slice(shifts, func(i, j int) bool {
+		if votes[shifts[i]] != votes[shifts[j]] {
+			return votes[shifts[i]] > votes[shifts[j]]
+		}
+		return shifts[i] < shifts[j]
+	})
+	shifts = append(shifts[:min(len(shifts), fpMaxShifts)], 0)
+
+	best := 0.0
+	tried := make(map[int]bool)
+	for _, s := range shifts {
+		for d := -1; d <= 1; d++ {
+			if tried[s+d] {
+				continue
+			}
+			tried[s+d] = true
+			best = max(best, alignedSimilarity(a, b, s+d))
+		}
+	}
+	return best
+}
+
+// alignedSimilarity — совпадение битов при a[i] ~ b[i-shift]
+func alignedSimilarity(a, b Fingerprint, shift int) float64 {
+	lo, hi := max(0, shift), min(len(a), len(b)+shift)
+	n := hi - lo
+	if n <= 0 || float64(n) < fpMinCoverage*float64(max(len(a), len(b))) {
+		return 0
+	}
+	diff := 0
+	for i := lo; i < hi; i++ {
+		diff += bits.OnesCount32(a[i] ^ b[i-shift])
+	}
+	return 1 - float64(diff)/float64(32*n)
+}
+
+// FingerprintIndex — поиск похожих отпечатков без сравнения со всеми: по совпадающим
+// ключам кадров отбираются кандидаты, которые затем сравниваются целиком
+type FingerprintIndex struct {
+	prints   map[int64]Fingerprint
+	postings map[uint32][]int64
+}
+
+func NewFingerprintIndex() *FingerprintIndex {
+	return &FingerprintIndex{prints: make(map[int64]Fingerprint), postings: make(map[uint32][]int64)}
+}
+
+// Len — число отпечатков в индексе
+func (x *FingerprintIndex) Len() int {
+	return len(x.prints)
+}
+
+// Add добавляет отпечаток файла id
+func (x *FingerprintIndex) Add(id int64, f Fingerprint) {
+	if _, ok := x.prints[id]; ok || len(f) == 0 {
+		return
+	}
+	x.prints[id] = f
+	seen := make(map[uint32]bool)
+	for i := 0; i < len(f); i += fpIndexStride {
+		for _, k := range fpKeys(f[i]) {
+			if !seen[k] {
+				seen[k] = true
+				x.postings[k] = append(x.postings[k], id)
+			}
+		}
+	}
+}
+
+// FingerprintMatch — похожий файл и доля совпавших битов
+type FingerprintMatch struct {
+	ID         int64   `json:"id"`
+	Similarity float64 `json:"similarity"`
+}
+
+// Search — файлы индекса с похожестью не ниже threshold, по убыванию похожести
+func (x *FingerprintIndex) Search(f Fingerprint, threshold float64) []FingerprintMatch {
+	hits := make(map[int64]int)
+	seen := make(map[uint32]bool)
+	for _, v := range f {
+		for _, k := range fpKeys(v) {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if ids := x.postings[k]; len(ids) <= fpCommonKey {
+				for _, id := range ids {
+					hits[id]++
+				}
+			}
+		}
+	}
+
+	var matches []FingerprintMatch
+	for id, n := range hits {
+		other := x.prints[id]
+		if n < fpMinHits || float64(min(len(f), len(other))) < fpMinCoverage*float64(max(len(f), len(other))) {
+			continue
+		}
+		if s := CompareFingerprints(other, f); s >= threshold {
+			matches = append(matches, FingerprintMatch{ID: id, Similarity: s})
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Similarity != matches[j].Similarity {
+			return matches[i].Similarity > matches[j].Similarity
+		}
+		return matches[i].ID < matches[j].ID
+	})
+	return matches
+}
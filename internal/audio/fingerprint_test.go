@@ -0,0 +1,91 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// utterance — слоги со случайными f0 и формантами: разные seed — разные «фразы»
+func utterance(seed int64, rate int, sec float64) []float64 {
+	rng := rand.New(rand.NewSource(seed))
+	x := make([]float64, int(sec*float64(rate)))
+	syllable := int(0.18 * float64(rate))
+	var f0, f1, f2, phase float64
+	for i := range x {
+		if i%syllable == 0 {
+			f0 = 100 + 120*rng.Float64()
+			f1 = 300 + 600*rng.Float64()
+			f2 = 900 + 1600*rng.Float64()
+		}
+		pos := float64(i%syllable) / float64(syllable)
+		env := math.Max(0, math.Sin(math.Pi*pos/0.8))
+		if pos > 0.8 {
+			env = 0
+		}
+		phase += 2 * math.Pi * f0 / float64(rate)
+		var v float64
+		for h := 1; float64(h)*f0 < float64(rate)/2 && float64(h)*f0 < 3800; h++ {
+			f := float64(h) * f0
+			amp := 1/(1+math.Pow((f-f1)/150, 2)) + 0.6/(1+math.Pow((f-f2)/250, 2)) + 0.02
+			v += amp * math.Sin(float64(h)*phase)
+		}
+		x[i] = 0.1*env*v + 0.0005*rng.NormFloat64()
+	}
+	return x
+}
+
+func TestFingerprint(t *testing.T) {
+	mono := func(rate int, x []float64) *wav.Buffer {
+		return &wav.Buffer{Format: wav.PCM16(rate, 1), Samples: x}
+	}
+	fpOf := func(b *wav.Buffer) Fingerprint {
+		t.Helper()
+		f, err := FingerprintBuffer(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return f
+	}
+
+	orig := mono(16000, utterance(1, 16000, 4))
+	a := fpOf(orig)
+
+	// Та же фраза: другая частота и громкость, добавленная тишина, шум
+	rng := rand.New(rand.NewSource(9))
+	copyB := orig.Resample(22050).Pad(0.37, 0.6)
+	for i := range copyB.Samples {
+		copyB.Samples[i] = 0.5*copyB.Samples[i] + 0.0003*rng.NormFloat64()
+	}
+	b := fpOf(copyB)
+
+	// Обрезанная на 150ms с начала
+	c := fpOf(orig.Trim(0.15, 0))
+
+	other := fpOf(mono(16000, utterance(2, 16000, 4)))
+
+	for name, f := range map[string]Fingerprint{"resampled": b, "trimmed": c} {
+		if s := CompareFingerprints(a, f); s < 0.85 {
+			t.Errorf("%s: similarity %.3f", name, s)
+		}
+	}
+	if s := CompareFingerprints(a, other); s > 0.65 {
+		t.Errorf("other phrase: similarity %.3f", s)
+	}
+	if got := ParseFingerprint(a.Bytes()); len(got) != len(a) || CompareFingerprints(a, got) != 1 {
+		t.Error("bytes round trip")
+	}
+
+	x := NewFingerprintIndex()
+	x.Add(1, a)
+	x.Add(2, other)
+	if m := x.Search(b, 0.8); len(m) != 1 || m[0].ID != 1 {
+		t.Errorf("search: %+v", m)
+	}
+
+	if _, err := FingerprintBuffer(mono(16000, make([]float64, 16000))); err != ErrNoFingerprint {
+		t.Errorf("silence: %v", err)
+	}
+}
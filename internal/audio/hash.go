@@ -3,8 +3,11 @@ package audio
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"fmt"
 	"io"
 	"os"
+	"sync"
+	"time"
 )
 
 func MD5File(path string) (string, error) {
@@ -21,3 +24,39 @@ func MD5File(path string) (string, error) {
 
 	return hex.EncodeToString(h.Sum(nil)), nil
 }
+
+// SegmentHash — hash виртуального отрезка: hash записи и границы в миллисекундах
+func SegmentHash(recordingHash string, start, end float64) string {
+	sum := md5.Sum(fmt.Appendf(nil, "%s@%d-%d", recordingHash, int64(start*1000+0.5), int64(end*1000+0.5)))
+	return hex.EncodeToString(sum[:])
+}
+
+// recordingHash — запомненный md5 записи и её размер/mtime на момент расчёта
+type recordingHash struct {
+	size    int64
+	modTime time.Time
+	hash    string
+}
+
+var recordingHashes sync.Map // path -> recordingHash
+
+// RecordingHash — md5 длинной записи с кэшем в памяти: правка одного отрезка не
+// перечитывает часы аудио. Кэш сбрасывается, если у файла изменились размер или mtime
+func RecordingHash(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if v, ok := recordingHashes.Load(path); ok {
+		if c := v.(recordingHash); c.size == info.Size() && c.modTime.Equal(info.ModTime()) {
+			return c.hash, nil
+		}
+	}
+
+	hash, err := MD5File(path)
+	if err != nil {
+		return "", err
+	}
+	recordingHashes.Store(path, recordingHash{size: info.Size(), modTime: info.ModTime(), hash: hash})
+	return hash, nil
+}
@@ -0,0 +1,27 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingHash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rec.wav")
+	if err := os.WriteFile(path, []byte("first"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	first, err := RecordingHash(path)
+	if want, _ := MD5File(path); err != nil || first != want {
+		t.Fatalf("hash %s %v, want %s", first, err, want)
+	}
+
+	// Запись заменена: кэш по размеру/mtime не отдаёт старый hash
+	if err := os.WriteFile(path, []byte("second take"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := RecordingHash(path)
+	if want, _ := MD5File(path); err != nil || second != want || second == first {
+		t.Errorf("after rewrite: %s %v, want %s", second, err, want)
+	}
+}
@@ -0,0 +1,343 @@
+package audio
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// Loudness — громкость по ITU-R BS.1770-4 / EBU R128
+type Loudness struct {
+	Integrated float64 `json:"integrated_lufs"` // интегральная, LUFS
+	Range      float64 `json:"range_lu"`        // LRA (EBU Tech 3342), LU
+	TruePeak   float64 `json:"true_peak_dbtp"`  // максимум по каналам, dBTP (4x oversampling)
+}
+
+// ErrNoLoudness — все блоки ниже абсолютного порога -70 LUFS (тишина) или файл короче 400ms
+var ErrNoLoudness = errors.New("loudness undefined: no blocks above -70 LUFS")
+
+const (
+	absoluteGate   = -70.0 // LUFS
+	relativeGate   = -10.0 // LU ниже громкости по абсолютному порогу
+	lraGate        = -20.0 // LU, относительный порог для LRA
+	momentarySec   = 0.4
+	shortTermSec   = 3.0
+	blockStepSec   = 0.1
+	truePeakFactor = 4
+)
+
+// LoudnessFile измеряет WAV файл
+func LoudnessFile(path string) (*Loudness, error) {
+	b, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return MeasureLoudness(b)
+}
+
+// MeasureLoudness — интегральная громкость с порогами -70 LUFS / -10 LU, LRA по
+// 3-секундным окнам (порог -20 LU, 95-й минус 10-й процентиль) и true peak
+func MeasureLoudness(b *wav.Buffer) (*Loudness, error) {
+	power := kWeightedPower(b)
+	rate := float64(b.Format.SampleRate)
+
+	blocks := meanPowers(power, int(momentarySec*rate), int(blockStepSec*rate))
+	integrated, ok := gatedLoudness(blocks, relativeGate)
+	if !ok {
+		return nil, ErrNoLoudness
+	}
+
+	l := &Loudness{Integrated: round2(integrated), TruePeak: round2(TruePeak(b))}
+	l.Range = round2(loudnessRange(meanPowers(power, int(shortTermSec*rate), int(blockStepSec*rate))))
+	return l, nil
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// kWeightedPower — Σ G_i·y_i² по каналам на каждый отсчёт, y — сигнал после K-фильтра
+func kWeightedPower(b *wav.Buffer) []float64 {
+	n := b.Format.Channels
+	power := make([]float64, b.Frames())
+	for ch := 0; ch < n; ch++ {
+		weight := channelWeight(ch, n)
+		if weight == 0 {
+			continue
+		}
+		y := kWeight(b.Channel(ch), b.Format.SampleRate)
+		for i, v := range y {
+			power[i] += weight * v * v
+		}
+	}
+	return power
+}
+
+// channelWeight — веса BS.1770 для 5.1 (L R C LFE Ls Rs): LFE не учитывается, тыловые 1.41
+func channelWeight(ch, channels int) float64 {
+	if channels < 5 {
+		return 1
+	}
+	switch ch {
+	case 3:
+		return 0
+	case 4, 5:
+		return 1.41
+	}
+	return 1
+}
+
+// kWeight — K-фильтр BS.1770: high shelf +4 dB (модель головы) и ФВЧ ~38 Hz (RLB).
+// Коэффициенты пересчитываются под частоту, как в libebur128
+func kWeight(x []float64, rate int) []float64 {
+	fs := float64(rate)
+
+	// stage 1: high shelf
+	f0, gain, q := 1681.974450955533, 3.999843853973347, 0.7071752369554196
+	k := math.Tan(math.Pi * f0 / fs)
+	vh := math.Pow(10, gain/20)
+	vb := math.Pow(vh, 0.4996667741545416)
+	a0 := 1 + k/q + k*k
+	shelf := biquad{
+		b0: (vh + vb*k/q + k*k) / a0, b1: 2 * (k*k - vh) / a0, b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0,
+	}
+
+	// stage 2: high-pass
+	f0, q = 38.13547087602444, 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / fs)
+	a0 = 1 + k/q + k*k
+	highpass := biquad{b0: 1, b1: -2, b2: 1, a1: 2 * (k*k - 1) / a0, a2: (1 - k/q + k*k) / a0}
+
+	return highpass.filter(shelf.filter(x))
+}
+
+type biquad struct {
+	b0, b1, b2, a1, a2 float64
+}
+
+func (f biquad) filter(x []float64) []float64 {
+	y := make([]float64, len(x))
+	var x1, x2, y1, y2 float64
+	for i, v := range x {
+		out := f.b0*v + f.b1*x1 + f.b2*x2 - f.a1*y1 - f.a2*y2
+		x2, x1 = x1, v
+		y2, y1 = y1, out
+		y[i] = out
+	}
+	return y
+}
+
+// meanPowers — средняя взвешенная мощность блоков длиной size с шагом step
+func meanPowers(power []float64, size, step int) []float64 {
+	if size <= 0 || step <= 0 || len(power) < size {
+		return nil
+	}
+	prefix := make([]float64, len(power)+1)
+	for i, p := range power {
+		prefix[i+1] = prefix[i] + p
+	}
+	var blocks []float64
+	for start := 0; start+size <= len(power); start += step {
+		blocks = append(blocks, (prefix[start+size]-prefix[start])/float64(size))
+	}
+	return blocks
+}
+
+func powerToLUFS(p float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	return -0.691 + 10*math.Log10(p)
+}
+
+// gatedLoudness — громкость блоков выше абсолютного порога и порога rel LU ниже их среднего
+func gatedLoudness(blocks []float64, rel float64) (float64, bool) {
+	var sum float64
+	var n int
+	for _, p := range blocks {
+		if powerToLUFS(p) > absoluteGate {
+			sum += p
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	gate := powerToLUFS(sum/float64(n)) + rel
+
+	sum, n = 0, 0
+	for _, p := range blocks {
+		if l := powerToLUFS(p); l > absoluteGate && l > gate {
+			sum += p
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return powerToLUFS(sum / float64(n)), true
+}
+
+// loudnessRange — разброс кратковременной громкости; файл короче 3s — 0
+func loudnessRange(blocks []float64) float64 {
+	var sum float64
+	var n int
+	for _, p := range blocks {
+		if powerToLUFS(p) > absoluteGate {
+			sum += p
+			n++
+		}
+	}
+	if n == 0 {
+		return 0
+	}
+	gate := powerToLUFS(sum/float64(n)) + lraGate
+
+	var levels []float64
+	for _, p := range blocks {
+		if l := powerToLUFS(p); l > absoluteGate && l > gate {
+			levels = append(levels, l)
+		}
+	}
+	if len(levels) < 2 {
+		return 0
+	}
+	sort.Float64s(levels)
+	percentile := func(q float64) float64 {
+		return levels[int(math.Round(q*float64(len(levels)-1)))]
+	}
+	return percentile(0.95) - percentile(0.10)
+}
+
+// TruePeak — максимум модуля по каналам после 4-кратной передискретизации, dBTP
+func TruePeak(b *wav.Buffer) float64 {
+	var peak float64
+	rate := b.Format.SampleRate
+	for ch := 0; ch < b.Format.Channels; ch++ {
+		for _, v := range wav.Resample(b.Channel(ch), rate, rate*truePeakFactor) {
+			peak = math.Max(peak, math.Abs(v))
+		}
+	}
+	if peak == 0 {
+		return math.Inf(-1)
+	}
+	return 20 * math.Log10(peak)
+}
+
+// LoudnessNormalization — результат NormalizeLoudness
+type LoudnessNormalization struct {
+	Before  *Loudness `json:"before"`
+	After   *Loudness `json:"after"`
+	GainDB  float64   `json:"gain_db"` // линейное усиление; обратное — -GainDB, если Limited = false
+	Limited bool      `json:"limited"` // сработал ограничитель пиков
+}
+
+const (
+	limiterAttackSec  = 0.005
+	limiterReleaseSec = 0.05
+	limiterPasses     = 4
+)
+
+// NormalizeLoudness пишет в outputPath копию с интегральной громкостью targetLUFS.
+// Если после усиления true peak выше ceilingDBTP, пики ограничиваются look-ahead
+// лимитером (интегральная громкость при этом немного ниже цели)
+func NormalizeLoudness(inputPath, outputPath string, targetLUFS, ceilingDBTP float64) (*LoudnessNormalization, error) {
+	b, err := wav.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	before, err := MeasureLoudness(b)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &LoudnessNormalization{Before: before, GainDB: round2(targetLUFS - before.Integrated)}
+	gain := math.Pow(10, res.GainDB/20)
+	out := &wav.Buffer{Format: b.Format, Samples: make([]float64, len(b.Samples))}
+	for i, v := range b.Samples {
+		out.Samples[i] = v * gain
+	}
+
+	// межотсчётные пики лимитер по отсчётам не видит: порог опускается на превышение
+	ceiling := ceilingDBTP
+	for pass := 0; pass < limiterPasses; pass++ {
+		over := TruePeak(out) - ceilingDBTP
+		if over <= 0.01 {
+			break
+		}
+		if pass > 0 {
+			ceiling -= over
+		}
+		limitPeaks(out, math.Pow(10, ceiling/20))
+		res.Limited = true
+	}
+
+	if err := wav.WriteFile(outputPath, out); err != nil {
+		return nil, err
+	}
+	// повторное измерение записанного файла (с квантованием)
+	if res.After, err = LoudnessFile(outputPath); err != nil {
+		return nil, fmt.Errorf("measure output: %w", err)
+	}
+	return res, nil
+}
+
+// limitPeaks — look-ahead лимитер: требуемое усиление min(1, ceiling/|x|) сглаживается
+// минимумом по окну ±attack и скользящим средним (не превышает требуемого ни в одной
+// точке), отпускание — экспонента limiterReleaseSec
+func limitPeaks(b *wav.Buffer, ceiling float64) {
+	n := b.Format.Channels
+	frames := b.Frames()
+	rate := float64(b.Format.SampleRate)
+	attack := max(int(limiterAttackSec*rate), 1)
+
+	need := make([]float64, frames)
+	for i := range need {
+		peak := 0.0
+		for _, v := range b.Samples[i*n : (i+1)*n] {
+			peak = math.Max(peak, math.Abs(v))
+		}
+		need[i] = 1
+		if peak > ceiling {
+			need[i] = ceiling / peak
+		}
+	}
+
+	// минимум по окну [i-attack, i+attack] (монотонная очередь)
+	window := make([]float64, frames)
+	var deque []int
+	next := 0
+	for i := 0; i < frames; i++ {
+		for ; next < frames && next <= i+attack; next++ {
+			for len(deque) > 0 && need[deque[len(deque)-1]] >= need[next] {
+				deque = deque[:len(deque)-1]
+			}
+			deque = append(deque, next)
+		}
+		for deque[0] < i-attack {
+			deque = deque[1:]
+		}
+		window[i] = need[deque[0]]
+	}
+
+	// скользящее среднее ±attack/2: каждое слагаемое покрывает i, значит не больше need[i]
+	half := attack / 2
+	prefix := make([]float64, frames+1)
+	for i, v := range window {
+		prefix[i+1] = prefix[i] + v
+	}
+	release := 1 - math.Exp(-1/(limiterReleaseSec*rate))
+	g := 1.0
+	for i := 0; i < frames; i++ {
+		from, to := max(i-half, 0), min(i+half+1, frames)
+		smooth := (prefix[to] - prefix[from]) / float64(to-from)
+		g = math.Min(smooth, g+(1-g)*release)
+		for c := 0; c < n; c++ {
+			b.Samples[i*n+c] *= g
+		}
+	}
+}
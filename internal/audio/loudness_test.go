@@ -0,0 +1,63 @@
+package audio
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+func sine(rate int, sec, freq, amp float64) []float64 {
+	x := make([]float64, int(sec*float64(rate)))
+	for i := range x {
+		x[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return x
+}
+
+func TestMeasureLoudness(t *testing.T) {
+	// BS.1770: синус 997 Hz с пиком 0 dBFS в одном канале — -3.01 LUFS
+	for _, rate := range []int{16000, 44100, 48000} {
+		b := &wav.Buffer{Format: wav.PCM16(rate, 1), Samples: sine(rate, 5, 997, 0.1)}
+		l, err := MeasureLoudness(b)
+		if err != nil || math.Abs(l.Integrated+23.01) > 0.1 || l.Range > 0.1 || math.Abs(l.TruePeak+20) > 0.1 {
+			t.Errorf("rate %d: %+v %v", rate, l, err)
+		}
+	}
+
+	// два шага громкости по 6 dB: LRA ≈ 6 LU
+	x := append(sine(16000, 10, 997, 0.05), sine(16000, 10, 997, 0.1)...)
+	l, err := MeasureLoudness(&wav.Buffer{Format: wav.PCM16(16000, 1), Samples: x})
+	if err != nil || math.Abs(l.Range-6) > 0.5 {
+		t.Errorf("range: %+v %v", l, err)
+	}
+
+	if _, err := MeasureLoudness(&wav.Buffer{Format: wav.PCM16(16000, 1), Samples: make([]float64, 16000)}); err != ErrNoLoudness {
+		t.Errorf("silence: %v", err)
+	}
+}
+
+func TestNormalizeLoudness(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "in.wav")
+	x, _ := speechLike(16000, 5)
+	if err := wav.WriteFile(in, &wav.Buffer{Format: wav.PCM16(16000, 1), Samples: x}); err != nil {
+		t.Fatal(err)
+	}
+
+	// тихая цель — чистое усиление, без лимитера
+	r, err := NormalizeLoudness(in, filepath.Join(dir, "quiet.wav"), -30, -1)
+	if err != nil || r.Limited || math.Abs(r.After.Integrated+30) > 0.1 {
+		t.Fatalf("quiet: %+v %v", r, err)
+	}
+
+	// громкая цель — пики ограничиваются до -1 dBTP
+	r, err = NormalizeLoudness(in, filepath.Join(dir, "loud.wav"), -14, -1)
+	if err != nil || !r.Limited || r.After.TruePeak > -0.9 || r.After.Integrated > -14 || r.After.Integrated < -20 {
+		t.Fatalf("loud: %+v %v", r.After, err)
+	}
+	if r.GainDB != math.Round((-14-r.Before.Integrated)*100)/100 {
+		t.Errorf("gain %.2f, before %.2f", r.GainDB, r.Before.Integrated)
+	}
+}
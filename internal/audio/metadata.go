@@ -5,6 +5,8 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+
+	"audio-labeler/internal/audio/wav"
 )
 
 type Metadata struct {
@@ -17,12 +19,25 @@ type Metadata struct {
 	Format      string  `json:"format"`
 }
 
+// GetMetadata — WAV читается напрямую, остальные форматы через ffprobe
 func GetMetadata(path string) (*Metadata, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
 
+	if info, err := wav.Stat(path); err == nil {
+		return &Metadata{
+			DurationSec: info.Duration,
+			SampleRate:  info.SampleRate,
+			Channels:    info.Channels,
+			BitDepth:    info.BitsPerSample,
+			FileSize:    fi.Size(),
+			Codec:       info.Codec(),
+			Format:      "wav",
+		}, nil
+	}
+
 	cmd := exec.Command("ffprobe",
 		"-v", "quiet",
 		"-print_format", "json",
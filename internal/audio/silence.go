@@ -1,8 +1,10 @@
 package audio
 
 import (
+	"audio-labeler/internal/audio/wav"
 	"audio-labeler/internal/utils"
 	"fmt"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -10,15 +12,20 @@ import (
 	"strings"
 )
 
-// SilenceInfo информация о тишине в конце файла
+// SilenceInfo информация о тишине в начале и конце файла
 type SilenceInfo struct {
 	HasTrailingSilence bool    `json:"has_trailing_silence"`
 	SilenceDuration    float64 `json:"silence_duration_ms"` // в миллисекундах
+	LeadingSilence     float64 `json:"leading_silence_ms"`  // в миллисекундах
 	TotalDuration      float64 `json:"total_duration"`
 }
 
-// GetAudioDuration получает длительность аудио через ffprobe
+// GetAudioDuration получает длительность аудио: WAV — из заголовка, остальное через ffprobe
 func GetAudioDuration(path string) (float64, error) {
+	if info, err := wav.Stat(path); err == nil {
+		return info.Duration, nil
+	}
+
 	cmd := exec.Command("ffprobe",
 		"-v", "error",
 		"-show_entries", "format=duration",
@@ -42,29 +49,29 @@ func DetectTrailingSilence(wavPath string, minSilenceMs float64) (*SilenceInfo,
 		minSilenceMs = 100 // 100ms по умолчанию для Kaldi
 	}
 
-	// Получаем длительность файла
+	// WAV — VAD в процессе, одинаково на любой машине
+	if vad, err := VADFile(wavPath); err == nil {
+		silence := vad.TrailingSilence() * 1000
+		return &SilenceInfo{
+			TotalDuration:      vad.Duration,
+			SilenceDuration:    silence,
+			LeadingSilence:     vad.LeadingSilence() * 1000,
+			HasTrailingSilence: silence >= minSilenceMs,
+		}, nil
+	}
+
+	// Остальные форматы — ffmpeg silencedetect
 	duration, err := GetAudioDuration(wavPath)
 	if err != nil {
 		return nil, err
 	}
-
-	// Используем sox для определения тишины в конце
-	// reverse -> silence detect -> получаем длину тишины с конца
-	cmd := exec.Command("sox", wavPath, "-n", "reverse", "silence", "1", "0.01", "1%", "reverse", "stat")
-	output, _ := cmd.CombinedOutput()
-
-	// Альтернативный метод через ffmpeg silencedetect
 	silenceDur := detectSilenceFFmpeg(wavPath, duration)
 
-	info := &SilenceInfo{
+	return &SilenceInfo{
 		TotalDuration:      duration,
 		SilenceDuration:    silenceDur * 1000, // в ms
 		HasTrailingSilence: silenceDur*1000 >= minSilenceMs,
-	}
-
-	_ = output // sox output для дебага если нужно
-
-	return info, nil
+	}, nil
 }
 
 // detectSilenceFFmpeg определяет тишину в конце через ffmpeg
@@ -149,6 +156,11 @@ func AddTrailingSilence(inputPath, outputPath string, silenceMs float64) error {
 		silenceMs = 100
 	}
 
+	// WAV дописываем сами, в том же формате
+	if b, err := wav.ReadFile(inputPath); err == nil {
+		return wav.WriteFile(outputPath, b.Pad(0, silenceMs/1000.0))
+	}
+
 	// Получаем sample rate исходного файла
 	meta, err := GetMetadata(inputPath)
 	if err != nil {
@@ -176,6 +188,10 @@ func AddTrailingSilence(inputPath, outputPath string, silenceMs float64) error {
 
 // RemoveTrailingSilence удаляет тишину с конца файла
 func RemoveTrailingSilence(inputPath, outputPath string) error {
+	if b, err := wav.ReadFile(inputPath); err == nil {
+		return wav.WriteFile(outputPath, trimTrailingSilence(b))
+	}
+
 	// sox input.wav output.wav reverse silence 1 0.01 1% reverse
 	cmd := exec.Command("sox", inputPath, outputPath,
 		"reverse", "silence", "1", "0.01", "1%", "reverse")
@@ -188,6 +204,42 @@ func RemoveTrailingSilence(inputPath, outputPath string) error {
 	return nil
 }
 
+// trimTrailingSilence — то же, что sox "reverse silence 1 0.01 1% reverse": с конца срезается
+// всё, пока RMS в окне 20ms не продержится выше 1% хотя бы 10ms
+func trimTrailingSilence(b *wav.Buffer) *wav.Buffer {
+	const threshold = 0.01
+
+	x := b.Mono().Samples
+	rate := b.Format.SampleRate
+	win := max(rate/50, 1)
+	need := max(rate/100, 1)
+
+	end := 0
+	run := 0
+	var sumSq float64
+	for i := len(x) - 1; i >= 0; i-- {
+		// окно x[i, i+win)
+		sumSq += x[i] * x[i]
+		if i+win < len(x) {
+			sumSq -= x[i+win] * x[i+win]
+		}
+		n := min(win, len(x)-i)
+		if math.Sqrt(math.Max(sumSq, 0)/float64(n)) < threshold {
+			run = 0
+			continue
+		}
+		run++
+		if run >= need {
+			// окно, с которого начался звук, сохраняем целиком
+			end = min(i+need-1+win, len(x))
+			break
+		}
+	}
+
+	ch := b.Format.Channels
+	return &wav.Buffer{Format: b.Format, Samples: b.Samples[:end*ch]}
+}
+
 // MergeAudioFiles склеивает несколько WAV файлов в один с паузами между ними
 func MergeAudioFiles(inputPaths []string, outputPath string, pauseMs float64) error {
 	if len(inputPaths) == 0 {
@@ -209,6 +261,24 @@ func MergeAudioFiles(inputPaths []string, outputPath string, pauseMs float64) er
 		pauseMs = 150
 	}
 
+	// Все входы WAV — склеиваем сами: формат первого файла, остальные приводятся к нему
+	var bufs []*wav.Buffer
+	for _, path := range inputPaths {
+		b, err := wav.ReadFile(path)
+		if err != nil {
+			bufs = nil
+			break
+		}
+		bufs = append(bufs, b)
+	}
+	if bufs != nil {
+		merged, err := wav.Concat(pauseMs/1000.0, bufs...)
+		if err != nil {
+			return err
+		}
+		return wav.WriteFile(outputPath, merged)
+	}
+
 	// Получаем sample rate из первого файла
 	meta, err := GetMetadata(inputPaths[0])
 	if err != nil {
@@ -257,3 +327,61 @@ func MergeAudioFiles(inputPaths []string, outputPath string, pauseMs float64) er
 
 	return nil
 }
+
+// SilenceNormalization — тишина по краям до и после NormalizeSilence, ms
+type SilenceNormalization struct {
+	LeadingBefore  float64 `json:"leading_before_ms"`
+	TrailingBefore float64 `json:"trailing_before_ms"`
+	LeadingAfter   float64 `json:"leading_after_ms"`
+	TrailingAfter  float64 `json:"trailing_after_ms"`
+	Changed        bool    `json:"changed"`
+	Duration       float64 `json:"duration"` // секунды результата
+}
+
+// NormalizeSilence приводит тишину в начале и в конце (по VAD) к [minMs, maxMs]:
+// короче minMs — дополняется тишиной, длиннее maxMs — обрезается. outputPath пишется,
+// только если что-то изменилось; исходный файл не трогается
+func NormalizeSilence(inputPath, outputPath string, minMs, maxMs float64) (*SilenceNormalization, error) {
+	if maxMs < minMs {
+		return nil, fmt.Errorf("invalid silence range %.0f-%.0f ms", minMs, maxMs)
+	}
+
+	b, err := wav.ReadFile(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	vad := DetectSpeech(b.Mono().Samples, b.Format.SampleRate, vadOptions)
+	if !vad.HasSpeech() {
+		return nil, fmt.Errorf("no speech detected")
+	}
+
+	lead, trail := vad.LeadingSilence(), vad.TrailingSilence()
+	newLead := math.Min(math.Max(lead, minMs/1000), maxMs/1000)
+	newTrail := math.Min(math.Max(trail, minMs/1000), maxMs/1000)
+
+	res := &SilenceNormalization{
+		LeadingBefore:  lead * 1000,
+		TrailingBefore: trail * 1000,
+		LeadingAfter:   newLead * 1000,
+		TrailingAfter:  newTrail * 1000,
+		Duration:       b.Duration(),
+	}
+
+	// меньше одного отсчёта — не изменение
+	eps := 1 / float64(b.Format.SampleRate)
+	if math.Abs(newLead-lead) < eps && math.Abs(newTrail-trail) < eps {
+		return res, nil
+	}
+
+	cutHead := math.Max(lead-newLead, 0)
+	cutTail := math.Max(trail-newTrail, 0)
+	out := b.Trim(cutHead, b.Duration()-cutHead-cutTail).
+		Pad(math.Max(newLead-lead, 0), math.Max(newTrail-trail, 0))
+	if err := wav.WriteFile(outputPath, out); err != nil {
+		return nil, err
+	}
+
+	res.Changed = true
+	res.Duration = out.Duration()
+	return res, nil
+}
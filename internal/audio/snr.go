@@ -0,0 +1,212 @@
+package audio
+
+import (
+	"math"
+	"sort"
+)
+
+// Полосы анализа, Hz. Оценки приводятся к SNR всей полосы (analysisLoHz..0.95 Найквиста),
+// чтобы noise_level значил одно и то же для 8 и 16 kHz
+const (
+	analysisLoHz   = 100
+	speechBandLoHz = 300
+	speechBandHiHz = 3400
+	noiseBandLoHz  = 3600 // выше полосы речи: шипение, остальное — речь
+	nyquistShare   = 0.95 // край полосы: выше — спад antialias фильтра
+)
+
+// Minimum statistics (Martin, 2001): сглаженный спектр, минимум по скользящему окну
+// из minStatSubwindows подокон, поправка на смещение минимума minStatBias.
+// Коэффициент сглаживания адаптивный: над шумом спектр отслеживается быстро,
+// иначе после громкого слога он не успевает опуститься до шума за паузу
+const (
+	minStatAlpha      = 0.96
+	minStatAlphaMin   = 0.3
+	minStatWindowSec  = 1.5
+	minStatSubwindows = 8
+	minStatBias       = 2.0  // подобрано по белому шуму: E[шум] / E[минимум]
+	minStatActiveDB   = 3.0  // кадр с речью: мощность выше шума на 3 dB
+	maxSNR            = 50.0 // выше — неотличимо от чистой записи
+)
+
+// SpectralSNR — оценки SNR по STFT, dB; 0 — оценить нельзя
+type SpectralSNR struct {
+	MinStat float64 // речь над шумом, отслеживаемым minimum statistics
+	Band    float64 // полоса речи против полосы выше неё (белый шум)
+	VAD     float64 // речевые кадры VAD против пауз
+}
+
+// EstimateSNR считает все три оценки по одному STFT
+func EstimateSNR(samples []float64, rate int, vad *VADResult) SpectralSNR {
+	s := stft(samples, rate)
+	if len(s.Power) == 0 {
+		return SpectralSNR{}
+	}
+	lo, hi := s.bins(analysisLoHz, nyquistShare*float64(rate)/2)
+	noise := s.noiseFloor(lo, hi)
+
+	// кадры с речью по minimum statistics
+	active := make([]bool, len(s.Power))
+	var signal, noisePow float64
+	for t := range s.Power {
+		p := s.bandPower(t, lo, hi)
+		n := bandMean(noise[t], lo, hi)
+		if p > n*math.Pow(10, minStatActiveDB/10) {
+			active[t] = true
+			signal += p - n
+			noisePow += n
+		}
+	}
+
+	res := SpectralSNR{
+		Band: s.bandSNR(active, noise, lo, hi),
+		VAD:  s.vadSNR(vad, lo, hi),
+	}
+	if noisePow > 0 {
+		res.MinStat = snrDB(signal / noisePow)
+	}
+	return res
+}
+
+// noiseFloor — оценка спектра шума по кадрам для бинов [lo, hi)
+func (s *spectrogram) noiseFloor(lo, hi int) [][]float64 {
+	frameSec := float64(s.Hop) / float64(s.Rate)
+	sub := max(int(minStatWindowSec/frameSec)/minStatSubwindows, 1)
+
+	bins := len(s.Power[0])
+	smooth := append([]float64(nil), s.Power[0]...)
+	cur := make([]float64, bins) // минимум текущего подокна
+	for k := range cur {
+		cur[k] = math.Inf(1)
+	}
+	var past [][]float64 // минимумы прошлых подокон, не больше minStatSubwindows-1
+
+	prev := append([]float64(nil), s.Power[0]...) // оценка шума на прошлом кадре
+
+	out := make([][]float64, len(s.Power))
+	for t, p := range s.Power {
+		n := make([]float64, bins)
+		for k := lo; k < hi; k++ {
+			alpha := minStatAlpha
+			if prev[k] > 0 {
+				r := smooth[k]/prev[k] - 1
+				alpha = math.Max(minStatAlpha/(1+r*r), minStatAlphaMin)
+			}
+			smooth[k] = alpha*smooth[k] + (1-alpha)*p[k]
+			cur[k] = math.Min(cur[k], smooth[k])
+			m := cur[k]
+			for _, pm := range past {
+				m = math.Min(m, pm[k])
+			}
+			// минимум сглаженного спектра не выше самого спектра
+			n[k] = math.Min(minStatBias*m, smooth[k])
+		}
+		out[t] = n
+		prev = n
+
+		if (t+1)%sub == 0 {
+			past = append(past, cur)
+			if len(past) >= minStatSubwindows {
+				past = past[1:]
+			}
+			cur = make([]float64, bins)
+			for k := range cur {
+				cur[k] = math.Inf(1)
+			}
+		}
+	}
+	return out
+}
+
+// bandSNR — плотность мощности в полосе речи (кадры с речью) против плотности
+// выше полосы речи (медиана по всем кадрам, всплески фрикативных не мешают).
+// Если выше полосы речи пусто (запись с ограниченной полосой, 8 kHz апсемпл) — 0
+func (s *spectrogram) bandSNR(active []bool, noise [][]float64, lo, hi int) float64 {
+	slo, shi := s.bins(speechBandLoHz, speechBandHiHz)
+	nlo, nhi := s.bins(noiseBandLoHz, nyquistShare*float64(s.Rate)/2)
+	if nhi-nlo < 2 || shi <= slo {
+		return 0
+	}
+
+	var inBand float64
+	var nActive int
+	noiseDensity := make([]float64, len(s.Power))
+	var floor float64 // шум в полосе речи по minimum statistics
+	for t := range s.Power {
+		noiseDensity[t] = s.bandPower(t, nlo, nhi)
+		floor += bandMean(noise[t], slo, shi)
+		if active[t] {
+			inBand += s.bandPower(t, slo, shi)
+			nActive++
+		}
+	}
+	if nActive == 0 {
+		return 0
+	}
+	sort.Float64s(noiseDensity)
+	n := noiseDensity[len(noiseDensity)/2]
+	floor /= float64(len(s.Power))
+	if n <= 0 || n < floor/100 {
+		return 0
+	}
+
+	// речь целиком в полосе, шум белый по всей полосе анализа
+	speech := (inBand/float64(nActive) - n) * float64(shi-slo)
+	return snrDB(speech / (n * float64(hi-lo)))
+}
+
+// vadSNR — мощность речевых кадров VAD за вычетом шума против пауз (hangover не учитывается)
+func (s *spectrogram) vadSNR(vad *VADResult, lo, hi int) float64 {
+	if vad == nil || vad.FrameSec <= 0 {
+		return 0
+	}
+	var speech float64
+	var nSpeech int
+	var pause []float64
+	for t := range s.Power {
+		i := int(s.FrameTime(t) / vad.FrameSec)
+		if i >= len(vad.Voiced) {
+			break
+		}
+		switch {
+		case vad.Voiced[i]:
+			speech += s.bandPower(t, lo, hi)
+			nSpeech++
+		case !vad.speech[i]:
+			pause = append(pause, s.bandPower(t, lo, hi))
+		}
+	}
+	if nSpeech == 0 || len(pause) == 0 {
+		return 0
+	}
+	// медиана: в паузы попадают начала слов тише порога VAD
+	sort.Float64s(pause)
+	n := pause[len(pause)/2]
+	if n <= 0 {
+		return 0
+	}
+	return snrDB((speech/float64(nSpeech) - n) / n)
+}
+
+func bandMean(p []float64, lo, hi int) float64 {
+	if hi <= lo {
+		return 0
+	}
+	var sum float64
+	for _, v := range p[lo:hi] {
+		sum += v
+	}
+	return sum / float64(hi-lo)
+}
+
+// snrDB — отношение мощностей в dB, в [0, maxSNR], с точностью 0.1
+func snrDB(ratio float64) float64 {
+	if ratio <= 0 || math.IsNaN(ratio) {
+		return 0
+	}
+	db := math.Min(10*math.Log10(ratio), maxSNR)
+	if db < 0 {
+		return 0
+	}
+	return math.Round(db*10) / 10
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// speechLike — гармоники 120 Hz с формантами ~700 и ~1800 Hz, слоги 4 Hz, слова по 0.8s
+// через паузы 0.4s. Возвращает отсчёты и среднюю мощность активной речи
+func speechLike(rate int, sec float64) ([]float64, float64) {
+	x := make([]float64, int(sec*float64(rate)))
+	var pow float64
+	var active int
+	phase := 0.0
+	for i := range x {
+		t := float64(i) / float64(rate)
+		env := math.Max(0, math.Sin(2*math.Pi*4*t))
+		if t < 0.3 || math.Mod(t, 1.2) > 0.8 {
+			env = 0
+		}
+		f0 := 120 + 20*math.Sin(2*math.Pi*0.7*t)
+		phase += 2 * math.Pi * f0 / float64(rate)
+		var v float64
+		for h := 1; float64(h)*f0 < speechBandHiHz; h++ {
+			f := float64(h) * f0
+			amp := 1/(1+math.Pow((f-700)/300, 2)) + 0.6/(1+math.Pow((f-1800)/400, 2)) + 0.05
+			v += amp * math.Sin(float64(h)*phase)
+		}
+		x[i] = 0.1 * env * v
+		if env > 0 {
+			pow += x[i] * x[i]
+			active++
+		}
+	}
+	return x, pow / float64(active)
+}
+
+func TestEstimateSNR(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, rate := range []int{8000, 16000} {
+		prev := ""
+		for _, target := range []float64{10, 20, 30} {
+			x, pow := speechLike(rate, 5)
+			sigma := math.Sqrt(pow / math.Pow(10, target/10))
+			for i := range x {
+				x[i] += sigma * rng.NormFloat64()
+			}
+
+			r := EstimateSNR(x, rate, DetectSpeech(x, rate, DefaultVADOptions()))
+			if math.Abs(r.VAD-target) > 3 || math.Abs(r.Band-target) > 2 || math.Abs(r.MinStat-target) > 4 {
+				t.Errorf("rate %d, SNR %.0f dB: %+v", rate, target, r)
+			}
+
+			// noise_level по оценке без sox и WADA
+			level := classifyNoise(combineSNRAll(0, r.MinStat, r.Band, r.VAD, 0))
+			if level == prev {
+				t.Errorf("rate %d: SNR %.0f dB and 10 dB lower are both %q", rate, target, level)
+			}
+			prev = level
+		}
+	}
+}
+
+func TestEstimateSNRNoSpeech(t *testing.T) {
+	rng := rand.New(rand.NewSource(2))
+	x := make([]float64, 16000*3)
+	for i := range x {
+		x[i] = 0.01 * rng.NormFloat64()
+	}
+	if r := EstimateSNR(x, 16000, DetectSpeech(x, 16000, DefaultVADOptions())); r.VAD != 0 || r.MinStat > 3 {
+		t.Errorf("white noise: %+v", r)
+	}
+}
@@ -7,6 +7,8 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+
+	"audio-labeler/internal/audio/wav"
 )
 
 type AudioStats struct {
@@ -27,13 +29,17 @@ type AudioStats struct {
 
 	// SNR estimates (разные методы)
 	SNRSox      float64 `json:"snr_sox"`      // RMS Pk - RMS Tr
-	SNRSpectral float64 `json:"snr_spectral"` // ffmpeg astats
-	SNRVad      float64 `json:"snr_vad"`      // silence detection
+	SNRSpectral float64 `json:"snr_spectral"` // STFT, minimum statistics
+	SNRBand     float64 `json:"snr_band"`     // полоса речи 300-3400 Hz против полосы выше
+	SNRVad      float64 `json:"snr_vad"`      // VAD: речевые кадры против пауз
 	SNRWada     float64 `json:"snr_wada"`     // WADA algorithm
 	SNREstimate float64 `json:"snr_estimate"` // Combined estimate
 
 	// Quality
 	NoiseLevel string `json:"noise_level"` // low, medium, high, very_high
+
+	// EBU R128; nil — не WAV или тишина
+	Loudness *Loudness `json:"loudness,omitempty"`
 }
 
 // AudioQuality на основе метрик
@@ -53,51 +59,39 @@ func GetStats(path string) (*AudioStats, error) {
 	// Method 1: Sox stats
 	getSoxStats(path, stats)
 
-	// SNR from Sox (RMS Pk - RMS Tr)
-	// Если RMS Tr = -inf (нет тишины), используем альтернативный метод
-	if math.IsInf(stats.RMSTrDB, -1) {
-		// Альтернатива: оценка на основе Crest Factor и RMS level
-		// Чистая речь: Crest Factor 10-15, RMS -30 to -20 dB
-		baseSNR := 20.0
-
-		// Crest Factor 8-18 — идеально для речи
-		if stats.CrestFactor >= 8 && stats.CrestFactor <= 18 {
-			baseSNR += 10
-		} else if stats.CrestFactor >= 5 && stats.CrestFactor <= 25 {
-			baseSNR += 5
-		}
-
-		// RMS level -35 to -18 — нормальная громкость
-		if stats.RMSLevDB >= -35 && stats.RMSLevDB <= -18 {
-			baseSNR += 5
-		}
-
-		// Flat factor > 0 означает клиппинг — плохо
-		if stats.FlatFactor > 0 {
-			baseSNR -= 15
-		}
-
-		stats.SNRSox = baseSNR
-	} else if stats.RMSTrDB < 0 && stats.RMSPkDB < 0 && stats.RMSTrDB != stats.RMSPkDB {
+	// SNR from Sox (RMS Pk - RMS Tr): громкое окно 50ms против самого тихого.
+	// RMS Tr = -inf (нет тишины) — оценить нельзя
+	if !math.IsInf(stats.RMSTrDB, 0) && stats.RMSTrDB < 0 && stats.RMSPkDB < 0 && stats.RMSTrDB != stats.RMSPkDB {
 		snr := stats.RMSPkDB - stats.RMSTrDB
 		if snr > 0 && snr < 100 {
 			stats.SNRSox = snr
 		}
 	}
 
-	// Method 2: Spectral (ffmpeg astats)
-	stats.SNRSpectral = getSpectralSNR(path)
+	// Methods 2-5: по отсчётам WAV (Go native)
+	if b, err := wav.ReadFile(path); err == nil {
+		samples, rate := b.Mono().Samples, b.Format.SampleRate
+		vad := DetectSpeech(samples, rate, vadOptions)
 
-	// Method 3: VAD-based (silence detection)
-	stats.SNRVad = getVADBasedSNR(path)
+		// STFT: minimum statistics, полосы, VAD
+		spectral := EstimateSNR(samples, rate, vad)
+		stats.SNRSpectral = spectral.MinStat
+		stats.SNRBand = spectral.Band
+		stats.SNRVad = spectral.VAD
 
-	// Method 4: WADA-SNR (Go native, самый точный для речи)
-	if snr, err := WADASNR(path); err == nil && snr > 0 && snr < 100 {
-		stats.SNRWada = snr
+		// WADA-SNR
+		if snr, err := wadaSNR(samples, rate); err == nil && snr > 0 && snr < 100 {
+			stats.SNRWada = snr
+		}
+
+		// громкость по всем каналам
+		if l, err := MeasureLoudness(b); err == nil {
+			stats.Loudness = l
+		}
 	}
 
 	// Combined estimate (взвешенное среднее всех методов)
-	stats.SNREstimate = combineSNRAll(stats.SNRSox, stats.SNRSpectral, stats.SNRVad, stats.SNRWada)
+	stats.SNREstimate = combineSNRAll(stats.SNRSox, stats.SNRSpectral, stats.SNRBand, stats.SNRVad, stats.SNRWada)
 	stats.NoiseLevel = classifyNoise(stats.SNREstimate)
 
 	return stats, nil
@@ -160,99 +154,25 @@ func getSoxStats(path string, stats *AudioStats) error {
 	return scanner.Err()
 }
 
-// getSpectralSNR через ffmpeg astats
-func getSpectralSNR(path string) float64 {
-	cmd := exec.Command("ffmpeg",
-		"-i", path,
-		"-af", "astats",
-		"-f", "null", "-",
-	)
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return 0
-	}
-
-	var rmsLevel, peakLevel float64
-	lines := strings.Split(string(output), "\n")
-
-	for _, line := range lines {
-		if strings.Contains(line, "RMS level dB:") {
-			idx := strings.LastIndex(line, ":")
-			if idx >= 0 && idx < len(line)-1 {
-				valStr := strings.TrimSpace(line[idx+1:])
-				rmsLevel, _ = strconv.ParseFloat(valStr, 64)
-			}
-		}
-		if strings.Contains(line, "Peak level dB:") {
-			idx := strings.LastIndex(line, ":")
-			if idx >= 0 && idx < len(line)-1 {
-				valStr := strings.TrimSpace(line[idx+1:])
-				peakLevel, _ = strconv.ParseFloat(valStr, 64)
-			}
-		}
-	}
-
-	// Crest factor в dB — разница между пиком и RMS
-	if rmsLevel < 0 && peakLevel < 0 {
-		crest := peakLevel - rmsLevel
-		return crest * 1.5
-	}
-
-	return 0
-}
-
-// getVADBasedSNR через silencedetect
-func getVADBasedSNR(path string) float64 {
-	cmd := exec.Command("ffmpeg",
-		"-i", path,
-		"-af", "silencedetect=noise=-40dB:d=0.1",
-		"-f", "null", "-",
-	)
-
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return 0
-	}
-
-	// Считаем количество silence_start событий
-	silenceCount := strings.Count(string(output), "silence_start")
-
-	// Если много пауз — вероятно чистая запись
-	// Мало пауз — либо непрерывная речь, либо шум
-	if silenceCount > 5 {
-		return 25 // Хорошее качество
-	} else if silenceCount > 2 {
-		return 18
-	}
-	return 12
-}
-
-// combineSNRAll комбинирует все методы с весами
-func combineSNRAll(soxSNR, spectralSNR, vadSNR, wadaSNR float64) float64 {
+// combineSNRAll — взвешенное среднее методов. Веса по синтетической речи с белым шумом
+// известного SNR (snr_test.go): VAD и полосы точны в пределах 1-2 dB, minimum statistics
+// занижает чистые записи на 2-4 dB, но работает без пауз; WADA рассчитан на естественную
+// речь и проверяется только на ней, sox — грубый минимум по окнам 50ms
+func combineSNRAll(soxSNR, spectralSNR, bandSNR, vadSNR, wadaSNR float64) float64 {
 	values := []float64{}
 	weights := []float64{}
-
-	// Sox — самый надёжный для телефонии
-	if isValidSNR(soxSNR) {
-		values = append(values, soxSNR)
-		weights = append(weights, 3.0) // было 1.0
-	}
-
-	if isValidSNR(spectralSNR) {
-		values = append(values, spectralSNR)
-		weights = append(weights, 1.0) // было 1.5
+	add := func(snr, weight float64) {
+		if isValidSNR(snr) {
+			values = append(values, snr)
+			weights = append(weights, weight)
+		}
 	}
 
-	if isValidSNR(vadSNR) {
-		values = append(values, vadSNR)
-		weights = append(weights, 0.5)
-	}
-
-	if isValidSNR(wadaSNR) {
-		values = append(values, wadaSNR)
-		weights = append(weights, 1.5) // было 2.5
-	}
+	add(vadSNR, 3.0)
+	add(spectralSNR, 2.0)
+	add(bandSNR, 1.5)
+	add(wadaSNR, 1.0)
+	add(soxSNR, 0.5)
 
 	if len(values) == 0 {
 		return 0
@@ -384,6 +304,9 @@ func (s *AudioStats) ToJSON() string {
 	if math.IsInf(safe.SNRSpectral, 0) || math.IsNaN(safe.SNRSpectral) {
 		safe.SNRSpectral = 0
 	}
+	if math.IsInf(safe.SNRBand, 0) || math.IsNaN(safe.SNRBand) {
+		safe.SNRBand = 0
+	}
 	if math.IsInf(safe.SNREstimate, 0) || math.IsNaN(safe.SNREstimate) {
 		safe.SNREstimate = 0
 	}
@@ -0,0 +1,105 @@
+package audio
+
+import (
+	"math"
+	"math/cmplx"
+)
+
+// stftFrameMs — длина окна STFT; размер FFT — ближайшая степень двойки не меньше
+const stftFrameMs = 32
+
+// spectrogram — спектр мощности по кадрам: окно Ханна, шаг в половину окна
+type spectrogram struct {
+	Rate  int
+	Hop   int         // отсчётов между началами кадров
+	BinHz float64     // ширина бина
+	Power [][]float64 // [кадр][бин], бины 0..N/2
+}
+
+func stft(samples []float64, rate int) *spectrogram {
+	n := 1
+	for n < rate*stftFrameMs/1000 {
+		n <<= 1
+	}
+	hop := n / 2
+
+	win := make([]float64, n)
+	var winPow float64
+	for i := range win {
+		win[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+		winPow += win[i] * win[i]
+	}
+
+	s := &spectrogram{Rate: rate, Hop: hop, BinHz: float64(rate) / float64(n)}
+	buf := make([]complex128, n)
+	for start := 0; start+n <= len(samples); start += hop {
+		for i := range buf {
+			buf[i] = complex(samples[start+i]*win[i], 0)
+		}
+		fft(buf)
+
+		// мощность на отсчёт: |X|² / Σw² — для белого шума σ² в каждом бине
+		p := make([]float64, n/2+1)
+		for k := range p {
+			a := cmplx.Abs(buf[k])
+			p[k] = a * a / winPow
+		}
+		s.Power = append(s.Power, p)
+	}
+	return s
+}
+
+// FrameTime — середина кадра t в секундах
+func (s *spectrogram) FrameTime(t int) float64 {
+	return (float64(t*s.Hop) + float64(s.Hop)) / float64(s.Rate)
+}
+
+// bins — диапазон бинов [lo, hi) для полосы loHz..hiHz, ограниченной частотой Найквиста
+func (s *spectrogram) bins(loHz, hiHz float64) (int, int) {
+	if len(s.Power) == 0 {
+		return 0, 0
+	}
+	last := len(s.Power[0])
+	lo := min(max(int(math.Ceil(loHz/s.BinHz)), 1), last)
+	hi := min(int(math.Floor(hiHz/s.BinHz))+1, last)
+	return lo, max(hi, lo)
+}
+
+// bandPower — средняя мощность кадра t на бин в полосе [lo, hi)
+func (s *spectrogram) bandPower(t, lo, hi int) float64 {
+	if hi <= lo {
+		return 0
+	}
+	var sum float64
+	for _, p := range s.Power[t][lo:hi] {
+		sum += p
+	}
+	return sum / float64(hi-lo)
+}
+
+// fft — итеративное БПФ по основанию 2 на месте; len(x) — степень двойки
+func fft(x []complex128) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j ^= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		w := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			wk := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				a, b := x[start+k], x[start+k+size/2]*wk
+				x[start+k], x[start+k+size/2] = a+b, a-b
+				wk *= w
+			}
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package audio
+
+import (
+	"math"
+	"sort"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// VADOptions — параметры детектора речи. Aggressiveness 0..3, как в WebRTC VAD:
+// чем выше, тем больше энергии над уровнем шума нужно кадру, чтобы считаться речью
+type VADOptions struct {
+	FrameMs        int // 10, 20 или 30
+	Aggressiveness int
+	HangoverMs     int // речь продлевается после последнего речевого кадра
+}
+
+// DefaultVADOptions — 20ms кадры, режим 2, hangover 100ms
+func DefaultVADOptions() VADOptions {
+	return VADOptions{FrameMs: 20, Aggressiveness: 2, HangoverMs: 100}
+}
+
+var vadOptions = DefaultVADOptions()
+
+// SetVADOptions задаёт параметры VAD для DetectTrailingSilence, SNR и экспорта сегментов.
+// Вызывается один раз при старте
+func SetVADOptions(opts VADOptions) {
+	vadOptions = opts.normalize()
+}
+
+// CurrentVADOptions — параметры, заданные SetVADOptions
+func CurrentVADOptions() VADOptions {
+	return vadOptions
+}
+
+func (o VADOptions) normalize() VADOptions {
+	def := DefaultVADOptions()
+	if o.FrameMs <= 0 {
+		o.FrameMs = def.FrameMs
+	}
+	if o.Aggressiveness < 0 {
+		o.Aggressiveness = 0
+	}
+	if o.Aggressiveness > 3 {
+		o.Aggressiveness = 3
+	}
+	if o.HangoverMs < 0 {
+		o.HangoverMs = 0
+	}
+	return o
+}
+
+// По режиму: превышение над шумом (dB), абсолютный минимум уровня речи (dBFS)
+// и сколько кадров подряд нужно для начала речи
+var (
+	vadMarginDB = [4]float64{6, 9, 12, 15}
+	vadFloorDB  = [4]float64{-60, -55, -50, -45}
+	vadOnset    = [4]int{1, 1, 2, 3}
+)
+
+// Region — участок записи в секундах
+type Region struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+func (r Region) Duration() float64 {
+	return r.End - r.Start
+}
+
+// VADResult — разметка речь/не речь
+type VADResult struct {
+	FrameSec  float64   `json:"frame_sec"`
+	Duration  float64   `json:"duration"`
+	NoiseDB   float64   `json:"noise_db"`     // уровень шума: 10-й процентиль энергии кадров
+	Threshold float64   `json:"threshold_db"` // порог речи
+	Speech    []Region  `json:"speech"`       // с hangover
+	Silence   []Region  `json:"silence"`      // всё, что не Speech
+	Energy    []float64 `json:"-"`            // dBFS по кадрам
+	Voiced    []bool    `json:"-"`            // речевые кадры без hangover
+
+	speech []bool // кадры внутри Speech
+}
+
+// VADFile размечает WAV файл (каналы сводятся в моно) с параметрами SetVADOptions
+func VADFile(path string) (*VADResult, error) {
+	return VADFileWith(path, vadOptions)
+}
+
+func VADFileWith(path string, opts VADOptions) (*VADResult, error) {
+	b, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return DetectSpeech(b.Mono().Samples, b.Format.SampleRate, opts), nil
+}
+
+// DetectSpeech — энергетический VAD: порог = уровень шума + запас режима, но не ниже
+// абсолютного минимума; начало речи — vadOnset кадров подряд, конец — после hangover
+func DetectSpeech(samples []float64, rate int, opts VADOptions) *VADResult {
+	opts = opts.normalize()
+	frameLen := max(rate*opts.FrameMs/1000, 1)
+	r := &VADResult{
+		FrameSec: float64(frameLen) / float64(rate),
+		Duration: float64(len(samples)) / float64(rate),
+	}
+
+	frames := (len(samples) + frameLen - 1) / frameLen
+	r.Energy = make([]float64, frames)
+	for i := range r.Energy {
+		r.Energy[i] = rmsDB(samples[i*frameLen : min((i+1)*frameLen, len(samples))])
+	}
+	if frames == 0 {
+		return r
+	}
+
+	sorted := append([]float64(nil), r.Energy...)
+	sort.Float64s(sorted)
+	r.NoiseDB = sorted[frames/10]
+	peak := sorted[frames*95/100]
+
+	mode := opts.Aggressiveness
+	r.Threshold = r.NoiseDB + vadMarginDB[mode]
+	if peak-r.NoiseDB < vadMarginDB[mode] {
+		// пауз нет: непрерывная речь или сплошной шум — решает абсолютный уровень
+		r.Threshold = vadFloorDB[mode]
+	}
+	r.Threshold = math.Max(r.Threshold, vadFloorDB[mode])
+
+	hangover := opts.HangoverMs * rate / 1000 / frameLen
+	speech := make([]bool, frames)
+	r.speech = speech
+	r.Voiced = make([]bool, frames)
+	inSpeech := false
+	run, hang := 0, 0
+	for i, e := range r.Energy {
+		loud := e >= r.Threshold
+		if loud {
+			run++
+		} else {
+			run = 0
+		}
+
+		if !inSpeech && run >= vadOnset[mode] {
+			inSpeech = true
+			for j := i - run + 1; j < i; j++ {
+				speech[j], r.Voiced[j] = true, true
+			}
+		}
+		if inSpeech {
+			switch {
+			case loud:
+				hang = hangover
+				r.Voiced[i] = true
+			case hang > 0:
+				hang--
+			default:
+				inSpeech = false
+			}
+		}
+		speech[i] = inSpeech
+	}
+
+	for i := 0; i < frames; {
+		j := i
+		for j < frames && speech[j] == speech[i] {
+			j++
+		}
+		region := Region{
+			Start: float64(i) * r.FrameSec,
+			End:   math.Min(float64(j)*r.FrameSec, r.Duration),
+		}
+		if speech[i] {
+			r.Speech = append(r.Speech, region)
+		} else {
+			r.Silence = append(r.Silence, region)
+		}
+		i = j
+	}
+	return r
+}
+
+func rmsDB(x []float64) float64 {
+	var sum float64
+	for _, v := range x {
+		sum += v * v
+	}
+	rms := math.Sqrt(sum / float64(len(x)))
+	if rms < 1e-10 {
+		return -200
+	}
+	return 20 * math.Log10(rms)
+}
+
+// HasSpeech — найден хотя бы один речевой кадр
+func (r *VADResult) HasSpeech() bool {
+	return len(r.Speech) > 0
+}
+
+// LeadingSilence — секунды до первого речевого кадра; без речи — вся длительность
+func (r *VADResult) LeadingSilence() float64 {
+	for i, v := range r.Voiced {
+		if v {
+			return float64(i) * r.FrameSec
+		}
+	}
+	return r.Duration
+}
+
+// TrailingSilence — секунды после последнего речевого кадра (без hangover)
+func (r *VADResult) TrailingSilence() float64 {
+	for i := len(r.Voiced) - 1; i >= 0; i-- {
+		if r.Voiced[i] {
+			return math.Max(r.Duration-float64(i+1)*r.FrameSec, 0)
+		}
+	}
+	return r.Duration
+}
+
+// SpeechDuration — суммарная длительность речи
+func (r *VADResult) SpeechDuration() float64 {
+	var d float64
+	for _, s := range r.Speech {
+		d += s.Duration()
+	}
+	return d
+}
+
+// SpeechSamples — отсчёты речевых участков
+func (r *VADResult) SpeechSamples(samples []float64, rate int) []float64 {
+	var out []float64
+	for _, s := range r.Speech {
+		from := min(int(s.Start*float64(rate)), len(samples))
+		to := min(int(s.End*float64(rate)), len(samples))
+		out = append(out, samples[from:to]...)
+	}
+	return out
+}
+
+// LevelsDB — средняя энергия (по мощности) речевых и остальных кадров, dBFS.
+// ok=false, если нет кадров одного из классов
+func (r *VADResult) LevelsDB() (speechDB, noiseDB float64, ok bool) {
+	var speechPow, noisePow float64
+	var nSpeech, nNoise int
+	for i, e := range r.Energy {
+		p := math.Pow(10, e/10)
+		if r.Voiced[i] {
+			speechPow += p
+			nSpeech++
+		} else if !r.speech[i] {
+			// пауза; кадры hangover не входят ни в речь, ни в шум
+			noisePow += p
+			nNoise++
+		}
+	}
+	if nSpeech == 0 || nNoise == 0 {
+		return 0, 0, false
+	}
+	return 10 * math.Log10(speechPow/float64(nSpeech)), 10 * math.Log10(noisePow/float64(nNoise)), true
+}
+
+// SnapToPause расширяет [start, end] так, чтобы границы не резали речь: граница внутри
+// речевого участка сдвигается к его краю (не дальше maxShift), затем захватывается до margin
+// тишины. Результат не выходит за [lo, hi]
+func (r *VADResult) SnapToPause(start, end, maxShift, margin, lo, hi float64) (float64, float64) {
+	for _, s := range r.Speech {
+		if start > s.Start && start < s.End && start-s.Start <= maxShift {
+			start = s.Start
+		}
+		if end > s.Start && end < s.End && s.End-end <= maxShift {
+			end = s.End
+		}
+	}
+	for _, s := range r.Silence {
+		if start >= s.Start && start <= s.End {
+			start = math.Max(s.Start, start-margin)
+		}
+		if end >= s.Start && end <= s.End {
+			end = math.Min(s.End, end+margin)
+		}
+	}
+	return math.Max(start, lo), math.Min(end, hi)
+}
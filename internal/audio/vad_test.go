@@ -0,0 +1,66 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+// vadSignal — 0.5s паузы (шум -60 dBFS), 1s тона, 0.3s паузы
+func vadSignal(rate int) []float64 {
+	var x []float64
+	noise := func(sec float64) {
+		for i := 0; i < int(sec*float64(rate)); i++ {
+			x = append(x, 0.001*math.Sin(float64(len(x))*1.7))
+		}
+	}
+	noise(0.5)
+	for i := 0; i < rate; i++ {
+		x = append(x, 0.3*math.Sin(2*math.Pi*440*float64(i)/float64(rate)))
+	}
+	noise(0.3)
+	return x
+}
+
+func TestDetectSpeech(t *testing.T) {
+	for _, rate := range []int{8000, 16000} {
+		for mode := 0; mode <= 3; mode++ {
+			opts := VADOptions{FrameMs: 20, Aggressiveness: mode, HangoverMs: 100}
+			r := DetectSpeech(vadSignal(rate), rate, opts)
+
+			if len(r.Speech) != 1 {
+				t.Fatalf("rate %d mode %d: speech %+v", rate, mode, r.Speech)
+			}
+			if s := r.Speech[0]; math.Abs(s.Start-0.5) > 0.021 || math.Abs(s.End-1.6) > 0.021 {
+				t.Errorf("rate %d mode %d: speech %+v, want 0.5-1.6 with hangover", rate, mode, s)
+			}
+			if d := r.LeadingSilence(); math.Abs(d-0.5) > 0.021 {
+				t.Errorf("rate %d mode %d: leading %.3f", rate, mode, d)
+			}
+			if d := r.TrailingSilence(); math.Abs(d-0.3) > 0.021 {
+				t.Errorf("rate %d mode %d: trailing %.3f", rate, mode, d)
+			}
+			speechDB, noiseDB, ok := r.LevelsDB()
+			if !ok || math.Abs(speechDB-noiseDB-49.5) > 1 {
+				t.Errorf("rate %d mode %d: levels %.1f / %.1f", rate, mode, speechDB, noiseDB)
+			}
+		}
+	}
+
+	// тишина без речи
+	if r := DetectSpeech(make([]float64, 8000), 8000, DefaultVADOptions()); r.HasSpeech() || r.TrailingSilence() != 1 {
+		t.Errorf("silence: %+v", r.Speech)
+	}
+}
+
+func TestSnapToPause(t *testing.T) {
+	r := DetectSpeech(vadSignal(16000), 16000, VADOptions{FrameMs: 20, Aggressiveness: 2})
+	// граница внутри речи у самого начала уходит в паузу, конец расширяется на margin
+	start, end := r.SnapToPause(0.6, 1.5, 0.3, 0.1, 0, r.Duration)
+	if math.Abs(start-0.4) > 0.021 || math.Abs(end-1.6) > 0.021 {
+		t.Errorf("snap: %.3f-%.3f", start, end)
+	}
+	// соседняя группа ограничивает расширение
+	if start, _ := r.SnapToPause(0.52, 1.5, 0.3, 0.1, 0.45, r.Duration); start != 0.45 {
+		t.Errorf("snap bounded: %.3f", start)
+	}
+}
@@ -1,19 +1,26 @@
 package audio
 
 import (
-	"encoding/binary"
 	"errors"
-	"io"
 	"math"
-	"os"
+
+	"audio-labeler/internal/audio/wav"
 )
 
 // WADA-SNR: Waveform Amplitude Distribution Analysis
 func WADASNR(path string) (float64, error) {
-	samples, err := readWavSamples(path)
+	samples, rate, err := readWavSamples(path)
 	if err != nil {
 		return 0, err
 	}
+	return wadaSNR(samples, rate)
+}
+
+func wadaSNR(samples []float64, rate int) (float64, error) {
+	// Только речь по VAD: длинные паузы искажают распределение амплитуд
+	if vad := DetectSpeech(samples, rate, vadOptions); vad.HasSpeech() {
+		samples = vad.SpeechSamples(samples, rate)
+	}
 
 	if len(samples) < 1000 {
 		return 0, errors.New("audio too short")
@@ -79,103 +86,11 @@ func WADASNR(path string) (float64, error) {
 	return math.Round(snr*10) / 10, nil
 }
 
-// readWavSamples читает WAV и возвращает нормализованные сэмплы [-1, 1]
-func readWavSamples(path string) ([]float64, error) {
-	f, err := os.Open(path)
+// readWavSamples читает WAV и возвращает нормализованные сэмплы [-1, 1] (каналы сводятся в моно) и частоту
+func readWavSamples(path string) ([]float64, int, error) {
+	b, err := wav.ReadFile(path)
 	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	// Читаем RIFF заголовок (12 байт)
-	riffHeader := make([]byte, 12)
-	if _, err := io.ReadFull(f, riffHeader); err != nil {
-		return nil, err
-	}
-
-	if string(riffHeader[0:4]) != "RIFF" || string(riffHeader[8:12]) != "WAVE" {
-		return nil, errors.New("not a valid WAV file")
+		return nil, 0, err
 	}
-
-	var numChannels uint16 = 1
-	var bitsPerSample uint16 = 16
-
-	// Читаем chunks
-	for {
-		chunkHeader := make([]byte, 8)
-		if _, err := io.ReadFull(f, chunkHeader); err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
-		}
-
-		chunkID := string(chunkHeader[0:4])
-		chunkSize := binary.LittleEndian.Uint32(chunkHeader[4:8])
-
-		switch chunkID {
-		case "fmt ":
-			fmtData := make([]byte, chunkSize)
-			if _, err := io.ReadFull(f, fmtData); err != nil {
-				return nil, err
-			}
-			audioFormat := binary.LittleEndian.Uint16(fmtData[0:2])
-			if audioFormat != 1 {
-				return nil, errors.New("only PCM format supported")
-			}
-			numChannels = binary.LittleEndian.Uint16(fmtData[2:4])
-			bitsPerSample = binary.LittleEndian.Uint16(fmtData[14:16])
-
-		case "data":
-			data := make([]byte, chunkSize)
-			if _, err := io.ReadFull(f, data); err != nil {
-				return nil, err
-			}
-
-			bytesPerSample := int(bitsPerSample / 8)
-			numSamples := len(data) / bytesPerSample / int(numChannels)
-			samples := make([]float64, numSamples)
-
-			for i := 0; i < numSamples; i++ {
-				offset := i * bytesPerSample * int(numChannels)
-
-				var sample float64
-				switch bitsPerSample {
-				case 8:
-					sample = (float64(data[offset]) - 128) / 128
-				case 16:
-					val := int16(binary.LittleEndian.Uint16(data[offset : offset+2]))
-					sample = float64(val) / 32768
-				case 24:
-					b := data[offset : offset+3]
-					val := int32(b[0]) | int32(b[1])<<8 | int32(b[2])<<16
-					if val&0x800000 != 0 {
-						val |= ^0xFFFFFF
-					}
-					sample = float64(val) / 8388608
-				case 32:
-					val := int32(binary.LittleEndian.Uint32(data[offset : offset+4]))
-					sample = float64(val) / 2147483648
-				default:
-					return nil, errors.New("unsupported bit depth")
-				}
-
-				samples[i] = sample
-			}
-
-			return samples, nil
-
-		default:
-			// Пропускаем неизвестные chunks (LIST, INFO и т.д.)
-			skipSize := int64(chunkSize)
-			if chunkSize%2 != 0 {
-				skipSize++ // WAV chunks выравнены на 2 байта
-			}
-			if _, err := f.Seek(skipSize, io.SeekCurrent); err != nil {
-				return nil, err
-			}
-		}
-	}
-
-	return nil, errors.New("data chunk not found")
+	return b.Mono().Samples, b.Format.SampleRate, nil
 }
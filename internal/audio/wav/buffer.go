@@ -0,0 +1,244 @@
+package wav
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Buffer — аудио целиком в памяти: отсчёты в [-1, 1), каналы чередуются
+type Buffer struct {
+	Format  Format
+	Samples []float64
+}
+
+// ReadFile читает файл целиком
+func ReadFile(path string) (*Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Decode(f)
+}
+
+// ReadRange читает из файла только [start, start+duration) в секундах (duration <= 0 — до конца):
+// начало пропускается без чтения, так что отрезок длинной записи не грузит её целиком
+func ReadRange(path string, start, duration float64) (*Buffer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	rd, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	b := &Buffer{Format: rd.Format()}
+	if err := rd.Skip(int64(frames(b.Format, start))); err != nil {
+		return nil, err
+	}
+
+	want := -1
+	if duration > 0 {
+		want = frames(b.Format, duration) * b.Format.Channels
+		b.Samples = make([]float64, 0, want)
+	}
+	chunk := make([]float64, 4096*b.Format.Channels)
+	for want < 0 || len(b.Samples) < want {
+		if want > 0 && want-len(b.Samples) < len(chunk) {
+			chunk = chunk[:want-len(b.Samples)]
+		}
+		n, err := rd.Read(chunk)
+		b.Samples = append(b.Samples, chunk[:n]...)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Decode читает поток до конца data chunk
+func Decode(r io.Reader) (*Buffer, error) {
+	rd, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	b := &Buffer{Format: rd.Format()}
+	if frames := rd.Frames(); frames > 0 {
+		b.Samples = make([]float64, 0, frames*int64(b.Format.Channels))
+	}
+
+	chunk := make([]float64, 4096*b.Format.Channels)
+	for {
+		n, err := rd.Read(chunk)
+		b.Samples = append(b.Samples, chunk[:n]...)
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Silence — тишина длиной sec в формате f
+func Silence(f Format, sec float64) *Buffer {
+	return &Buffer{Format: f, Samples: make([]float64, frames(f, sec)*f.Channels)}
+}
+
+func frames(f Format, sec float64) int {
+	if sec <= 0 {
+		return 0
+	}
+	return int(math.Round(sec * float64(f.SampleRate)))
+}
+
+func (b *Buffer) Frames() int {
+	return len(b.Samples) / b.Format.Channels
+}
+
+func (b *Buffer) Duration() float64 {
+	return float64(b.Frames()) / float64(b.Format.SampleRate)
+}
+
+// Channel — отсчёты канала ch
+func (b *Buffer) Channel(ch int) []float64 {
+	n := b.Format.Channels
+	out := make([]float64, b.Frames())
+	for i := range out {
+		out[i] = b.Samples[i*n+ch]
+	}
+	return out
+}
+
+// Mono — среднее по каналам
+func (b *Buffer) Mono() *Buffer {
+	return b.Remix(1)
+}
+
+// Remix меняет число каналов: N→1 — среднее, 1→N — копия в каждый канал,
+// иначе общие каналы сохраняются, лишние отбрасываются, недостающие — тишина
+func (b *Buffer) Remix(channels int) *Buffer {
+	in := b.Format.Channels
+	if channels <= 0 || channels == in {
+		return b
+	}
+	f := b.Format
+	f.Channels = channels
+	out := &Buffer{Format: f, Samples: make([]float64, b.Frames()*channels)}
+
+	for i := 0; i < b.Frames(); i++ {
+		frame := b.Samples[i*in : (i+1)*in]
+		dst := out.Samples[i*channels : (i+1)*channels]
+		switch {
+		case channels == 1:
+			var sum float64
+			for _, v := range frame {
+				sum += v
+			}
+			dst[0] = sum / float64(in)
+		case in == 1:
+			for c := range dst {
+				dst[c] = frame[0]
+			}
+		default:
+			copy(dst, frame)
+		}
+	}
+	return out
+}
+
+// Resample — частота rate с полосовым ограничением (см. Resample)
+func (b *Buffer) Resample(rate int) *Buffer {
+	if rate <= 0 || rate == b.Format.SampleRate {
+		return b
+	}
+	f := b.Format
+	f.SampleRate = rate
+	n := b.Format.Channels
+	if n == 1 {
+		return &Buffer{Format: f, Samples: Resample(b.Samples, b.Format.SampleRate, rate)}
+	}
+
+	var out *Buffer
+	for c := 0; c < n; c++ {
+		ch := Resample(b.Channel(c), b.Format.SampleRate, rate)
+		if out == nil {
+			out = &Buffer{Format: f, Samples: make([]float64, len(ch)*n)}
+		}
+		for i, v := range ch {
+			out.Samples[i*n+c] = v
+		}
+	}
+	return out
+}
+
+// Convert приводит частоту и число каналов; 0 — оставить как есть. Разрядность не меняется
+func (b *Buffer) Convert(rate, channels int) *Buffer {
+	if channels > 0 && channels < b.Format.Channels {
+		// сначала уменьшаем число каналов: ресемплить меньше
+		return b.Remix(channels).Resample(rate)
+	}
+	return b.Resample(rate).Remix(channels)
+}
+
+// Trim — фрагмент [start, start+duration) в секундах; duration <= 0 — до конца.
+// Границы за пределами файла обрезаются, как у sox trim и ffmpeg -ss/-t
+func (b *Buffer) Trim(start, duration float64) *Buffer {
+	total := b.Frames()
+	from := min(frames(b.Format, start), total)
+	to := total
+	if duration > 0 {
+		to = min(from+frames(b.Format, duration), total)
+	}
+	n := b.Format.Channels
+	return &Buffer{
+		Format:  b.Format,
+		Samples: append([]float64(nil), b.Samples[from*n:to*n]...),
+	}
+}
+
+// Pad добавляет тишину до и после, в секундах (sox pad)
+func (b *Buffer) Pad(before, after float64) *Buffer {
+	n := b.Format.Channels
+	head := frames(b.Format, before) * n
+	tail := frames(b.Format, after) * n
+	out := &Buffer{Format: b.Format, Samples: make([]float64, head+len(b.Samples)+tail)}
+	copy(out.Samples[head:], b.Samples)
+	return out
+}
+
+// Append дописывает o в конец; частота и каналы o приводятся к формату b
+func (b *Buffer) Append(o *Buffer) {
+	o = o.Convert(b.Format.SampleRate, b.Format.Channels)
+	b.Samples = append(b.Samples, o.Samples...)
+}
+
+// Concat склеивает буферы, вставляя между ними pause секунд тишины.
+// Формат результата — формат первого буфера
+func Concat(pause float64, bufs ...*Buffer) (*Buffer, error) {
+	if len(bufs) == 0 {
+		return nil, errors.New("wav: nothing to concat")
+	}
+	f := bufs[0].Format
+	if err := f.validate(); err != nil {
+		return nil, fmt.Errorf("wav: concat: %w", err)
+	}
+
+	gap := Silence(f, pause)
+	out := &Buffer{Format: f}
+	for i, b := range bufs {
+		if i > 0 {
+			out.Append(gap)
+		}
+		out.Append(b)
+	}
+	return out, nil
+}
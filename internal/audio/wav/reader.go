@@ -0,0 +1,264 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// Размер data chunk, который пишут потоковые кодеры (ffmpeg в pipe), когда длина неизвестна
+const unknownSize = 0xFFFFFFFF
+
+// maxFormatSize — fmt chunk больше этого не бывает (WAVE_FORMAT_EXTENSIBLE — 40 байт):
+// размер из битого заголовка не должен превращаться в гигабайтную аллокацию
+const maxFormatSize = 64 << 10
+
+// Reader читает отсчёты из data chunk по мере надобности, не загружая файл целиком
+type Reader struct {
+	r         io.Reader
+	format    Format
+	dataSize  int64 // -1 — до конца потока
+	remaining int64
+	buf       []byte
+}
+
+// NewReader разбирает заголовок до data chunk; неизвестные chunks (LIST, fact, ...) пропускаются
+func NewReader(r io.Reader) (*Reader, error) {
+	var riff [12]byte
+	if _, err := io.ReadFull(r, riff[:]); err != nil {
+		return nil, ErrNotWAV
+	}
+	if string(riff[0:4]) != "RIFF" || string(riff[8:12]) != "WAVE" {
+		return nil, ErrNotWAV
+	}
+
+	rd := &Reader{r: r}
+	haveFormat := false
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if !haveFormat {
+				return nil, fmt.Errorf("wav: fmt chunk not found: %w", err)
+			}
+			return nil, fmt.Errorf("wav: data chunk not found: %w", err)
+		}
+		id := string(hdr[0:4])
+		size := binary.LittleEndian.Uint32(hdr[4:8])
+
+		switch id {
+		case "fmt ":
+			if size > maxFormatSize {
+				return nil, fmt.Errorf("wav: fmt chunk of %d bytes", size)
+			}
+			data := make([]byte, size+size%2)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("wav: fmt chunk: %w", err)
+			}
+			f, err := parseFormat(data[:size])
+			if err != nil {
+				return nil, err
+			}
+			rd.format = f
+			haveFormat = true
+
+		case "data":
+			if !haveFormat {
+				return nil, errors.New("wav: data chunk before fmt")
+			}
+			rd.dataSize = int64(size)
+			if size == unknownSize || size == 0 {
+				rd.dataSize = -1
+			}
+			rd.remaining = rd.dataSize
+			return rd, nil
+
+		default:
+			if _, err := io.CopyN(io.Discard, r, int64(size)+int64(size%2)); err != nil {
+				return nil, fmt.Errorf("wav: skip %q chunk: %w", id, err)
+			}
+		}
+	}
+}
+
+func parseFormat(data []byte) (Format, error) {
+	if len(data) < 16 {
+		return Format{}, errors.New("wav: short fmt chunk")
+	}
+	code := binary.LittleEndian.Uint16(data[0:2])
+	f := Format{
+		Channels:   int(binary.LittleEndian.Uint16(data[2:4])),
+		SampleRate: int(binary.LittleEndian.Uint32(data[4:8])),
+	}
+	blockAlign := int(binary.LittleEndian.Uint16(data[12:14]))
+	bits := int(binary.LittleEndian.Uint16(data[14:16]))
+
+	if code == FormatExtensible {
+		// cbSize, validBits, channelMask, затем GUID подформата: первые 2 байта — код
+		if len(data) < 40 {
+			return Format{}, errors.New("wav: short WAVE_FORMAT_EXTENSIBLE fmt chunk")
+		}
+		code = binary.LittleEndian.Uint16(data[24:26])
+	}
+
+	// контейнер отсчёта берём из blockAlign: 24 значащих бита в 32-битном контейнере
+	// читаются как 32 bit
+	if f.Channels > 0 && blockAlign > 0 && blockAlign%f.Channels == 0 {
+		bits = blockAlign / f.Channels * 8
+	}
+	f.BitsPerSample = bits
+
+	switch code {
+	case FormatPCM:
+	case FormatFloat:
+		f.Float = true
+	default:
+		return Format{}, fmt.Errorf("%w: format code 0x%04x", ErrUnsupported, code)
+	}
+	if err := f.validate(); err != nil {
+		return Format{}, err
+	}
+	return f, nil
+}
+
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Frames — число кадров в data chunk; -1, если размер не записан
+func (r *Reader) Frames() int64 {
+	if r.dataSize < 0 {
+		return -1
+	}
+	return r.dataSize / int64(r.format.blockAlign())
+}
+
+// Duration — длительность в секундах; 0, если размер не записан
+func (r *Reader) Duration() float64 {
+	frames := r.Frames()
+	if frames < 0 {
+		return 0
+	}
+	return float64(frames) / float64(r.format.SampleRate)
+}
+
+// Read читает целые кадры в dst (каналы чередуются) отсчётами в [-1, 1).
+// Возвращает число отсчётов; io.EOF — данных больше нет. Обрезанный файл читается до последнего целого кадра
+func (r *Reader) Read(dst []float64) (int, error) {
+	block := r.format.blockAlign()
+	frames := len(dst) / r.format.Channels
+	if frames == 0 {
+		return 0, nil
+	}
+	want := int64(frames * block)
+	if r.remaining >= 0 && want > r.remaining {
+		want = r.remaining - r.remaining%int64(block)
+	}
+	if want == 0 {
+		return 0, io.EOF
+	}
+
+	if int64(cap(r.buf)) < want {
+		r.buf = make([]byte, want)
+	}
+	buf := r.buf[:want]
+	n, err := io.ReadFull(r.r, buf)
+	n -= n % block
+	if r.remaining >= 0 {
+		r.remaining -= int64(n)
+	}
+	if n == 0 {
+		if err == nil || err == io.ErrUnexpectedEOF {
+			err = io.EOF
+		}
+		r.remaining = 0
+		return 0, err
+	}
+
+	decode(dst, buf[:n], r.format)
+	if err == io.ErrUnexpectedEOF {
+		r.remaining = 0
+		err = nil
+	}
+	return n / r.format.bytesPerSample(), err
+}
+
+// Skip пропускает frames кадров; у файла (io.Seeker) — без чтения
+func (r *Reader) Skip(frames int64) error {
+	n := frames * int64(r.format.blockAlign())
+	if r.remaining >= 0 && n > r.remaining {
+		n = r.remaining
+	}
+	if n <= 0 {
+		return nil
+	}
+	if s, ok := r.r.(io.Seeker); ok {
+		if _, err := s.Seek(n, io.SeekCurrent); err != nil {
+			return err
+		}
+	} else if _, err := io.CopyN(io.Discard, r.r, n); err != nil && err != io.EOF {
+		return err
+	}
+	if r.remaining >= 0 {
+		r.remaining -= n
+	}
+	return nil
+}
+
+func decode(dst []float64, src []byte, f Format) {
+	size := f.bytesPerSample()
+	for i := 0; i*size < len(src); i++ {
+		b := src[i*size:]
+		switch {
+		case f.Float && size == 4:
+			dst[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+		case f.Float:
+			dst[i] = math.Float64frombits(binary.LittleEndian.Uint64(b))
+		case size == 1:
+			dst[i] = (float64(b[0]) - 128) / 128
+		case size == 2:
+			dst[i] = float64(int16(binary.LittleEndian.Uint16(b))) / 32768
+		case size == 3:
+			v := int32(b[0]) | int32(b[1])<<8 | int32(int8(b[2]))<<16
+			dst[i] = float64(v) / 8388608
+		default:
+			dst[i] = float64(int32(binary.LittleEndian.Uint32(b))) / 2147483648
+		}
+	}
+}
+
+// Info — заголовок файла без чтения отсчётов
+type Info struct {
+	Format
+	Frames   int64
+	Duration float64
+}
+
+// Stat читает заголовок; если размер data не записан, длина считается по размеру файла
+func Stat(path string) (*Info, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	info := &Info{Format: r.Format(), Frames: r.Frames()}
+
+	// размер data может быть больше файла (обрезанная запись) или не записан
+	if pos, err := f.Seek(0, io.SeekCurrent); err == nil {
+		if fi, err := f.Stat(); err == nil {
+			available := (fi.Size() - pos) / int64(info.blockAlign())
+			if info.Frames < 0 || info.Frames > available {
+				info.Frames = available
+			}
+		}
+	}
+	info.Duration = float64(info.Frames) / float64(info.SampleRate)
+	return info, nil
+}
@@ -0,0 +1,111 @@
+package wav
+
+import "math"
+
+// Параметры фильтра: полоса пропускания до 95% частоты Найквиста меньшей из частот,
+// 32 перехода через ноль sinc с каждой стороны, окно Кайзера (~ -90 dB в полосе подавления)
+const (
+	resampleRolloff   = 0.95
+	resampleZeros     = 32
+	resampleBeta      = 8.6
+	maxResamplePhases = 4096
+)
+
+// Resample переводит один канал из частоты from в to: windowed-sinc интерполяция
+// с фильтром против наложения спектра при понижении частоты. Для рациональных
+// отношений (16000/8000, 44100/16000) ядро считается заранее для каждой фазы
+func Resample(in []float64, from, to int) []float64 {
+	if from == to || from <= 0 || to <= 0 {
+		return append([]float64(nil), in...)
+	}
+
+	g := gcd(from, to)
+	up, down := to/g, from/g
+	n := int((int64(len(in))*int64(to) + int64(from)/2) / int64(from))
+	out := make([]float64, n)
+
+	// cutoff — доля частоты Найквиста входа
+	scale := math.Min(1, float64(to)/float64(from))
+	cutoff := scale * resampleRolloff
+	half := int(math.Ceil(resampleZeros / scale))
+
+	kernel := func(x float64) float64 {
+		if math.Abs(x) >= float64(half) {
+			return 0
+		}
+		return cutoff * sinc(cutoff*x) * kaiser(x/float64(half))
+	}
+
+	var table [][]float64
+	if up <= maxResamplePhases {
+		table = make([][]float64, up)
+		for p := range table {
+			frac := float64(p) / float64(up)
+			taps := make([]float64, 2*half)
+			for k := range taps {
+				taps[k] = kernel(float64(k-half+1) - frac)
+			}
+			table[p] = taps
+		}
+	}
+
+	for i := range out {
+		pos := int64(i) * int64(down)
+		base := int(pos / int64(up))
+		phase := int(pos % int64(up))
+
+		var taps []float64
+		if table != nil {
+			taps = table[phase]
+		}
+		var sum float64
+		for k := 0; k < 2*half; k++ {
+			j := base + k - half + 1
+			if j < 0 || j >= len(in) {
+				continue
+			}
+			if taps != nil {
+				sum += in[j] * taps[k]
+			} else {
+				sum += in[j] * kernel(float64(k-half+1)-float64(phase)/float64(up))
+			}
+		}
+		out[i] = sum
+	}
+	return out
+}
+
+func sinc(x float64) float64 {
+	if x == 0 {
+		return 1
+	}
+	return math.Sin(math.Pi*x) / (math.Pi * x)
+}
+
+// kaiser — окно Кайзера на [-1, 1]
+func kaiser(x float64) float64 {
+	if x <= -1 || x >= 1 {
+		return 0
+	}
+	return bessel0(resampleBeta*math.Sqrt(1-x*x)) / bessel0(resampleBeta)
+}
+
+// bessel0 — модифицированная функция Бесселя первого рода нулевого порядка (ряд)
+func bessel0(x float64) float64 {
+	sum, term := 1.0, 1.0
+	for k := 1; k < 50; k++ {
+		term *= (x / (2 * float64(k))) * (x / (2 * float64(k)))
+		sum += term
+		if term < sum*1e-12 {
+			break
+		}
+	}
+	return sum
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,66 @@
+// Package wav — чтение и запись RIFF WAVE без внешних утилит: PCM 8/16/24/32 bit,
+// float 32/64, WAVE_FORMAT_EXTENSIBLE; сведение каналов, ресемплинг и trim/pad/concat
+package wav
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Коды формата в fmt chunk
+const (
+	FormatPCM        = 0x0001
+	FormatFloat      = 0x0003
+	FormatExtensible = 0xFFFE
+)
+
+var (
+	ErrNotWAV      = errors.New("wav: not a RIFF WAVE file")
+	ErrUnsupported = errors.New("wav: unsupported format")
+)
+
+// Format — параметры потока. BitsPerSample — размер контейнера отсчёта (8, 16, 24, 32, 64)
+type Format struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+	Float         bool
+}
+
+// PCM16 — формат LibriSpeech/Kaldi: 16 bit, signed
+func PCM16(rate, channels int) Format {
+	return Format{SampleRate: rate, Channels: channels, BitsPerSample: 16}
+}
+
+func (f Format) bytesPerSample() int {
+	return f.BitsPerSample / 8
+}
+
+func (f Format) blockAlign() int {
+	return f.bytesPerSample() * f.Channels
+}
+
+func (f Format) validate() error {
+	if f.SampleRate <= 0 || f.Channels <= 0 {
+		return fmt.Errorf("%w: rate %d, channels %d", ErrUnsupported, f.SampleRate, f.Channels)
+	}
+	switch {
+	case f.Float && (f.BitsPerSample == 32 || f.BitsPerSample == 64):
+	case !f.Float && (f.BitsPerSample == 8 || f.BitsPerSample == 16 || f.BitsPerSample == 24 || f.BitsPerSample == 32):
+	default:
+		return fmt.Errorf("%w: %d bit, float=%v", ErrUnsupported, f.BitsPerSample, f.Float)
+	}
+	return nil
+}
+
+// Codec — имя кодека как у ffprobe (pcm_s16le, pcm_f32le, ...)
+func (f Format) Codec() string {
+	switch {
+	case f.Float:
+		return fmt.Sprintf("pcm_f%dle", f.BitsPerSample)
+	case f.BitsPerSample == 8:
+		return "pcm_u8"
+	default:
+		return fmt.Sprintf("pcm_s%dle", f.BitsPerSample)
+	}
+}
@@ -0,0 +1,225 @@
+package wav
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// memFile — io.WriteSeeker в памяти
+type memFile struct {
+	data []byte
+	pos  int64
+}
+
+func (m *memFile) Write(p []byte) (int, error) {
+	if end := m.pos + int64(len(p)); end > int64(len(m.data)) {
+		m.data = append(m.data, make([]byte, end-int64(len(m.data)))...)
+	}
+	copy(m.data[m.pos:], p)
+	m.pos += int64(len(p))
+	return len(p), nil
+}
+
+func (m *memFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+		m.pos = offset
+	case io.SeekCurrent:
+		m.pos += offset
+	case io.SeekEnd:
+		m.pos = int64(len(m.data)) + offset
+	}
+	return m.pos, nil
+}
+
+func sine(freq float64, rate int, sec, amp float64) []float64 {
+	out := make([]float64, int(sec*float64(rate)))
+	for i := range out {
+		out[i] = amp * math.Sin(2*math.Pi*freq*float64(i)/float64(rate))
+	}
+	return out
+}
+
+func rms(x []float64) float64 {
+	var s float64
+	for _, v := range x {
+		s += v * v
+	}
+	return math.Sqrt(s / float64(len(x)))
+}
+
+func TestRoundTrip(t *testing.T) {
+	formats := []Format{
+		{SampleRate: 8000, Channels: 1, BitsPerSample: 8},
+		{SampleRate: 16000, Channels: 1, BitsPerSample: 16},
+		{SampleRate: 16000, Channels: 2, BitsPerSample: 16},
+		{SampleRate: 48000, Channels: 1, BitsPerSample: 24},
+		{SampleRate: 44100, Channels: 3, BitsPerSample: 32},
+		{SampleRate: 16000, Channels: 1, BitsPerSample: 32, Float: true},
+		{SampleRate: 16000, Channels: 2, BitsPerSample: 64, Float: true},
+	}
+	for _, f := range formats {
+		samples := make([]float64, 301*f.Channels) // нечётный размер data у 8 bit
+		for i := range samples {
+			samples[i] = math.Sin(float64(i)) * 0.9
+		}
+		var m memFile
+		w, err := NewWriter(&m, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(samples[:100*f.Channels])
+		w.Write(samples[100*f.Channels:])
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := Decode(bytes.NewReader(m.data))
+		if err != nil {
+			t.Fatalf("%+v: %v", f, err)
+		}
+		if b.Format != f || len(b.Samples) != len(samples) {
+			t.Fatalf("%+v: got %+v, %d samples", f, b.Format, len(b.Samples))
+		}
+		tol := 1.0 / 100
+		if f.BitsPerSample >= 16 {
+			tol = 1.0 / 30000
+		}
+		for i := range samples {
+			if math.Abs(b.Samples[i]-samples[i]) > tol {
+				t.Fatalf("%+v: sample %d = %f, want %f", f, i, b.Samples[i], samples[i])
+			}
+		}
+	}
+}
+
+func TestStatAndTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.wav")
+	b := &Buffer{Format: PCM16(16000, 1), Samples: sine(440, 16000, 1, 0.5)}
+	if err := WriteFile(path, b); err != nil {
+		t.Fatal(err)
+	}
+	info, err := Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Frames != 16000 || info.Duration != 1 || info.Codec() != "pcm_s16le" {
+		t.Errorf("stat: %+v", info)
+	}
+
+	// обрезанная запись: заголовок обещает 1s, в файле половина и нечётный байт
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:44+16000+1], 0644)
+	if info, _ := Stat(path); info.Frames != 8000 {
+		t.Errorf("truncated stat: %+v", info)
+	}
+	got, err := ReadFile(path)
+	if err != nil || got.Frames() != 8000 {
+		t.Errorf("truncated read: %v, %d frames", err, got.Frames())
+	}
+
+	if _, err := Decode(bytes.NewReader([]byte("ID3\x03 not a wav file"))); err != ErrNotWAV {
+		t.Errorf("mp3: %v", err)
+	}
+
+	// битый заголовок: fmt chunk на 4 GB отвергается до аллокации
+	huge := append([]byte(nil), data[:16]...)
+	huge = binary.LittleEndian.AppendUint32(huge, 0xFFFFFFF0)
+	if _, err := NewReader(bytes.NewReader(huge)); err == nil || !strings.Contains(err.Error(), "fmt chunk") {
+		t.Errorf("huge fmt: %v", err)
+	}
+}
+
+func TestResample(t *testing.T) {
+	// частоты ниже новой частоты Найквиста проходят без потерь, выше — подавляются
+	for _, tc := range []struct {
+		freq     float64
+		from, to int
+		gain     float64
+	}{
+		{1000, 16000, 8000, 1},
+		{5000, 16000, 8000, 0},
+		{1000, 8000, 16000, 1},
+		{1000, 44100, 16000, 1},
+		{9000, 44100, 16000, 0},
+	} {
+		in := sine(tc.freq, tc.from, 1, 0.5)
+		out := Resample(in, tc.from, tc.to)
+		if len(out) != tc.to {
+			t.Errorf("%+v: %d samples", tc, len(out))
+		}
+		// края отбрасываем: там фильтр видит нули за границей
+		got := rms(out[len(out)/10:len(out)*9/10]) / rms(in)
+		if math.Abs(got-tc.gain) > 0.01 {
+			t.Errorf("%+v: gain %.4f", tc, got)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	f := PCM16(16000, 1)
+	b := &Buffer{Format: f, Samples: sine(440, 16000, 1, 0.5)}
+
+	if d := b.Trim(0.25, 0.5).Duration(); d != 0.5 {
+		t.Errorf("trim: %f", d)
+	}
+	if d := b.Trim(0.75, 0.5).Duration(); d != 0.25 {
+		t.Errorf("trim past end: %f", d)
+	}
+	if d := b.Pad(0.1, 0.2).Duration(); math.Abs(d-1.3) > 1e-9 {
+		t.Errorf("pad: %f", d)
+	}
+
+	stereo := &Buffer{Format: Format{SampleRate: 8000, Channels: 2, BitsPerSample: 24}, Samples: make([]float64, 8000*2)}
+	for i := range stereo.Samples {
+		stereo.Samples[i] = 0.25 * float64(i%2)
+	}
+	out, err := Concat(0.15, b, stereo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Format != f || math.Abs(out.Duration()-2.15) > 1e-9 {
+		t.Errorf("concat: %+v, %f", out.Format, out.Duration())
+	}
+	if v := out.Samples[out.Frames()-100]; math.Abs(v-0.125) > 1e-3 {
+		t.Errorf("concat downmix: %f", v)
+	}
+}
+
+func TestReadRange(t *testing.T) {
+	b := &Buffer{Format: PCM16(16000, 2), Samples: make([]float64, 16000*2*2)}
+	for i := range b.Samples {
+		b.Samples[i] = float64(i/2) / float64(len(b.Samples))
+	}
+	path := filepath.Join(t.TempDir(), "long.wav")
+	if err := WriteFile(path, b); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ReadRange(path, 0.5, 0.25)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := b.Trim(0.5, 0.25)
+	if got.Frames() != want.Frames() {
+		t.Fatalf("frames %d, want %d", got.Frames(), want.Frames())
+	}
+	for i := range want.Samples {
+		if math.Abs(got.Samples[i]-want.Samples[i]) > 1e-4 {
+			t.Fatalf("sample %d: %f, want %f", i, got.Samples[i], want.Samples[i])
+		}
+	}
+
+	if tail, err := ReadRange(path, 1.5, 0); err != nil || tail.Duration() != 0.5 {
+		t.Errorf("tail: %v, %v", tail, err)
+	}
+	if past, err := ReadRange(path, 3, 1); err != nil || past.Frames() != 0 {
+		t.Errorf("past end: %v, %v", past, err)
+	}
+}
@@ -0,0 +1,197 @@
+package wav
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+)
+
+// Writer пишет отсчёты потоком; размеры chunks проставляются в Close
+type Writer struct {
+	w       io.WriteSeeker
+	format  Format
+	written int64 // байт в data
+	sizeAt  int64 // смещение поля размера data
+	factAt  int64 // смещение числа кадров в fact (float), 0 — нет fact
+	buf     []byte
+	closed  bool
+}
+
+// KSDATAFORMAT_SUBTYPE_* без первых двух байт (кода формата)
+var subformatGUID = []byte{0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0xAA, 0x00, 0x38, 0x9B, 0x71}
+
+// NewWriter пишет заголовок. Больше двух каналов или больше 16 bit — WAVE_FORMAT_EXTENSIBLE, как у ffmpeg
+func NewWriter(w io.WriteSeeker, f Format) (*Writer, error) {
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	wr := &Writer{w: w, format: f}
+
+	code := uint16(FormatPCM)
+	if f.Float {
+		code = FormatFloat
+	}
+	extensible := f.Channels > 2 || f.BitsPerSample > 16
+	tag := code
+	if extensible {
+		tag = FormatExtensible
+	}
+
+	var fmtChunk []byte
+	le := binary.LittleEndian
+	fmtChunk = le.AppendUint16(fmtChunk, tag)
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(f.Channels))
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(f.SampleRate))
+	fmtChunk = le.AppendUint32(fmtChunk, uint32(f.SampleRate*f.blockAlign()))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(f.blockAlign()))
+	fmtChunk = le.AppendUint16(fmtChunk, uint16(f.BitsPerSample))
+	if extensible {
+		fmtChunk = le.AppendUint16(fmtChunk, 22)
+		fmtChunk = le.AppendUint16(fmtChunk, uint16(f.BitsPerSample))
+		fmtChunk = le.AppendUint32(fmtChunk, channelMask(f.Channels))
+		fmtChunk = le.AppendUint16(fmtChunk, code)
+		fmtChunk = append(fmtChunk, subformatGUID...)
+	}
+
+	hdr := []byte("RIFF\x00\x00\x00\x00WAVEfmt ")
+	hdr = le.AppendUint32(hdr, uint32(len(fmtChunk)))
+	hdr = append(hdr, fmtChunk...)
+	if f.Float {
+		// fact обязателен для не-PCM: число кадров
+		hdr = append(hdr, "fact"...)
+		hdr = le.AppendUint32(hdr, 4)
+		wr.factAt = int64(len(hdr))
+		hdr = le.AppendUint32(hdr, 0)
+	}
+	hdr = append(hdr, "data"...)
+	wr.sizeAt = int64(len(hdr))
+	hdr = le.AppendUint32(hdr, 0)
+
+	if _, err := w.Write(hdr); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// channelMask — стандартная раскладка для 1..8 каналов
+func channelMask(channels int) uint32 {
+	masks := []uint32{0, 0x4, 0x3, 0x7, 0x33, 0x37, 0x3F, 0x13F, 0x63F}
+	if channels < len(masks) {
+		return masks[channels]
+	}
+	return 0
+}
+
+// Write пишет отсчёты (каналы чередуются); значения вне [-1, 1) ограничиваются
+func (w *Writer) Write(samples []float64) error {
+	if w.closed {
+		return errors.New("wav: write to closed writer")
+	}
+	size := w.format.bytesPerSample()
+	need := len(samples) * size
+	if cap(w.buf) < need {
+		w.buf = make([]byte, need)
+	}
+	buf := w.buf[:need]
+	encode(buf, samples, w.format)
+
+	n, err := w.w.Write(buf)
+	w.written += int64(n)
+	return err
+}
+
+func encode(dst []byte, src []float64, f Format) {
+	size := f.bytesPerSample()
+	le := binary.LittleEndian
+	for i, v := range src {
+		b := dst[i*size:]
+		switch {
+		case f.Float && size == 4:
+			le.PutUint32(b, math.Float32bits(float32(v)))
+		case f.Float:
+			le.PutUint64(b, math.Float64bits(v))
+		case size == 1:
+			b[0] = uint8(quantize(v, 128) + 128)
+		case size == 2:
+			le.PutUint16(b, uint16(int16(quantize(v, 32768))))
+		case size == 3:
+			q := quantize(v, 8388608)
+			b[0], b[1], b[2] = byte(q), byte(q>>8), byte(q>>16)
+		default:
+			le.PutUint32(b, uint32(int32(quantize(v, 2147483648))))
+		}
+	}
+}
+
+func quantize(v, scale float64) int64 {
+	q := math.Round(v * scale)
+	if q > scale-1 {
+		q = scale - 1
+	}
+	if q < -scale {
+		q = -scale
+	}
+	return int64(q)
+}
+
+// Close дописывает выравнивающий байт и размеры RIFF/data/fact. Нижний writer не закрывается
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+
+	end := w.sizeAt + 4 + w.written
+	if w.written%2 != 0 {
+		if _, err := w.w.Write([]byte{0}); err != nil {
+			return err
+		}
+		end++
+	}
+
+	le := binary.LittleEndian
+	patch := func(at int64, v uint32) error {
+		if _, err := w.w.Seek(at, io.SeekStart); err != nil {
+			return err
+		}
+		return binary.Write(w.w, le, v)
+	}
+	if err := patch(4, uint32(end-8)); err != nil {
+		return err
+	}
+	if err := patch(w.sizeAt, uint32(w.written)); err != nil {
+		return err
+	}
+	if w.factAt > 0 {
+		if err := patch(w.factAt, uint32(w.written/int64(w.format.blockAlign()))); err != nil {
+			return err
+		}
+	}
+	_, err := w.w.Seek(end, io.SeekStart)
+	return err
+}
+
+// WriteFile пишет буфер в path
+func WriteFile(path string, b *Buffer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	w, err := NewWriter(f, b.Format)
+	if err == nil {
+		err = w.Write(b.Samples)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path)
+	}
+	return err
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -14,6 +15,13 @@ type Config struct {
 	Kaldi    KaldiConfig
 	Whisper  WhisperConfig
 	Workers  WorkersConfig
+	Retry    RetryConfig
+	Pyannote PyannoteConfig
+	VAD      VADConfig
+	Silence  SilenceConfig
+	Loudness LoudnessConfig
+	Watch    WatchConfig
+	Dedup    DedupConfig
 }
 
 type ServerConfig struct {
@@ -21,6 +29,8 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
+	Driver   string // mysql | sqlite
+	Path     string // файл базы для sqlite
 	Host     string
 	Port     int
 	User     string
@@ -29,13 +39,66 @@ type DatabaseConfig struct {
 }
 
 type DataConfig struct {
-	Dir string
+	Dir       string
+	MergedDir string // результат merge (LibriSpeech структура)
+	SplitDir  string // экспорт сегментов
+}
+
+type PyannoteConfig struct {
+	URL string
+}
+
+// VADConfig — детектор речи (internal/audio): кадр, режим 0..3 как у WebRTC, hangover
+type VADConfig struct {
+	FrameMs        int
+	Aggressiveness int
+	HangoverMs     int
+}
+
+// SilenceConfig — целевой диапазон тишины в начале и в конце файла (нормализация тишины)
+type SilenceConfig struct {
+	MinMs float64
+	MaxMs float64
+}
+
+// LoudnessConfig — цель нормализации громкости: интегральная LUFS и потолок true peak
+type LoudnessConfig struct {
+	TargetLUFS float64
+	PeakDB     float64
+}
+
+// WatchConfig — слежение за DATA_DIR: периодический пересмотр каталога по mtime,
+// новые файлы импортируются и (AutoEngines) отправляются в ASR движки
+type WatchConfig struct {
+	Enabled     bool
+	IntervalSec int
+	Format      string   // формат корпуса (scanner.Formats), "" — librispeech
+	Dir         string   // подкаталог DATA_DIR
+	AutoEngines []string // движки, запускаемые после импорта новых файлов
+}
+
+// DedupConfig — поиск почти-дубликатов по акустическому отпечатку (audio.Fingerprint)
+type DedupConfig struct {
+	Threshold float64 // доля совпавших битов отпечатка, с которой файлы — дубликаты
+	ScanMode  string  // что скан делает с дубликатами: off | flag | skip
 }
 
 type KaldiConfig struct {
 	ModelDir string
 	Host     string
 	Key      string
+
+	// Параметры декодирования: по умолчанию и именованные профили (выбор при запуске: profile=)
+	Decoding KaldiProfile
+	Profiles map[string]KaldiProfile
+
+	// Извлечение из lattice: каталог для сохранения lattice, размер N-best, MBR уверенности
+	LatticeDir string
+	NBest      int
+	MBR        bool
+
+	// Server mode: процесс декодера на воркер вместо процесса на файл (CPU движки)
+	Server bool
 }
 
 type WhisperConfig struct {
@@ -43,6 +106,23 @@ type WhisperConfig struct {
 	Lang        string
 	OpenAIKey   string
 	OpenAIModel string
+	OpenAIURL   string // OpenAI-совместимый сервер (self-hosted, заглушка)
+	OpenAI      OpenAILimits
+
+	// Параметры запроса OpenAI
+	OpenAIFormat      string // json | verbose_json
+	OpenAITimestamps  string // segment,word (только verbose_json)
+	OpenAIPrompt      string
+	OpenAITemperature float64
+}
+
+// OpenAILimits — лимиты и стоимость Whisper OpenAI (0 — без ограничения)
+type OpenAILimits struct {
+	RequestsPerMin     int
+	AudioMinutesPerMin int
+	MaxConcurrent      int
+	PricePerMinute     float64 // $ за минуту аудио
+	Budget             float64 // $ на задачу по умолчанию
 }
 
 type WorkersConfig struct {
@@ -50,14 +130,25 @@ type WorkersConfig struct {
 	ASR  int
 }
 
+// RetryConfig — повторы transient ошибок ASR (429/5xx, таймауты, падения Kaldi)
+type RetryConfig struct {
+	MaxAttempts int // попыток на файл за запуск, включая первую
+	BaseDelayMs int // пауза перед 2-й попыткой, дальше удваивается
+	MaxDelayMs  int
+}
+
 func Load(envFile string) (*Config, error) {
 	godotenv.Load(envFile)
 
+	decoding, profiles := loadKaldiProfiles()
+
 	return &Config{
 		Server: ServerConfig{
 			Addr: getEnv("SERVER_ADDR", ":8082"),
 		},
 		Database: DatabaseConfig{
+			Driver:   getEnv("DB_DRIVER", "mysql"),
+			Path:     getEnv("DB_PATH", "labeler.db"),
 			Host:     getEnv("DB_HOST", "127.0.0.1"),
 			Port:     getEnvInt("DB_PORT", 53306),
 			User:     getEnv("DB_USER", "root"),
@@ -65,23 +156,78 @@ func Load(envFile string) (*Config, error) {
 			Name:     getEnv("DB_NAME", "label1"),
 		},
 		Data: DataConfig{
-			Dir: getEnv("DATA_DIR", ""),
+			Dir:       getEnv("DATA_DIR", ""),
+			MergedDir: getEnv("MERGED_DIR", "/data/processed_labeler/merged"),
+			SplitDir:  getEnv("SPLIT_DIR", "/data/processed_labeler/split"),
 		},
 		Kaldi: KaldiConfig{
 			ModelDir: getEnv("KALDI_MODEL_DIR", ""),
 			Host:     getEnv("ASR_HOST", ""),
 			Key:      getEnv("ASR_KEY", ""),
+
+			Decoding: decoding,
+			Profiles: profiles,
+
+			LatticeDir: getEnv("KALDI_LATTICE_DIR", ""),
+			NBest:      getEnvInt("KALDI_NBEST", 0),
+			MBR:        getEnvBool("KALDI_MBR", false),
+
+			Server: getEnvBool("KALDI_SERVER", false),
 		},
 		Whisper: WhisperConfig{
 			LocalURL:    getEnv("WHISPER_LOCAL_URL", ""),
 			Lang:        getEnv("WHISPER_LOCAL_LANG", "az"),
 			OpenAIKey:   getEnv("WHISPER_OPENAI_KEY", ""),
 			OpenAIModel: getEnv("WHISPER_OPENAI_MODEL", "whisper-1"),
+			OpenAIURL:   getEnv("WHISPER_OPENAI_URL", "https://api.openai.com/v1"),
+			OpenAI: OpenAILimits{
+				RequestsPerMin:     getEnvInt("WHISPER_OPENAI_RPM", 50),
+				AudioMinutesPerMin: getEnvInt("WHISPER_OPENAI_AUDIO_MIN_PER_MIN", 0),
+				MaxConcurrent:      getEnvInt("WHISPER_OPENAI_MAX_CONCURRENT", 3),
+				PricePerMinute:     getEnvFloat("WHISPER_OPENAI_PRICE_PER_MIN", 0.006),
+				Budget:             getEnvFloat("WHISPER_OPENAI_BUDGET", 0),
+			},
+			OpenAIFormat:      getEnv("WHISPER_OPENAI_FORMAT", "json"),
+			OpenAITimestamps:  getEnv("WHISPER_OPENAI_TIMESTAMPS", "segment"),
+			OpenAIPrompt:      getEnv("WHISPER_OPENAI_PROMPT", ""),
+			OpenAITemperature: getEnvFloat("WHISPER_OPENAI_TEMPERATURE", 0),
 		},
 		Workers: WorkersConfig{
 			Scan: getEnvInt("SCAN_WORKERS", 10),
 			ASR:  getEnvInt("ASR_WORKERS", 5),
 		},
+		Retry: RetryConfig{
+			MaxAttempts: getEnvInt("ASR_RETRY_MAX_ATTEMPTS", 3),
+			BaseDelayMs: getEnvInt("ASR_RETRY_BASE_DELAY_MS", 1000),
+			MaxDelayMs:  getEnvInt("ASR_RETRY_MAX_DELAY_MS", 30000),
+		},
+		Pyannote: PyannoteConfig{
+			URL: getEnv("PYANNOTE_URL", "http://127.0.0.1:8087"),
+		},
+		VAD: VADConfig{
+			FrameMs:        getEnvInt("VAD_FRAME_MS", 20),
+			Aggressiveness: getEnvInt("VAD_AGGRESSIVENESS", 2),
+			HangoverMs:     getEnvInt("VAD_HANGOVER_MS", 100),
+		},
+		Silence: SilenceConfig{
+			MinMs: getEnvFloat("SILENCE_MIN_MS", 100),
+			MaxMs: getEnvFloat("SILENCE_MAX_MS", 300),
+		},
+		Loudness: LoudnessConfig{
+			TargetLUFS: getEnvFloat("LOUDNESS_TARGET_LUFS", -23),
+			PeakDB:     getEnvFloat("LOUDNESS_TRUE_PEAK_DB", -1),
+		},
+		Watch: WatchConfig{
+			Enabled:     getEnvBool("WATCH_ENABLED", false),
+			IntervalSec: getEnvInt("WATCH_INTERVAL_SEC", 300),
+			Format:      getEnv("WATCH_FORMAT", ""),
+			Dir:         getEnv("WATCH_DIR", ""),
+			AutoEngines: getEnvList("WATCH_AUTO_ENGINES"),
+		},
+		Dedup: DedupConfig{
+			Threshold: getEnvFloat("DUPLICATE_THRESHOLD", 0.8),
+			ScanMode:  getEnv("SCAN_DUPLICATES", "off"),
+		},
 	}, nil
 }
 
@@ -100,3 +246,32 @@ func getEnvInt(key string, defaultVal int) int {
 	}
 	return defaultVal
 }
+
+func getEnvFloat(key string, defaultVal float64) float64 {
+	if v := os.Getenv(key); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	return defaultVal
+}
+
+// getEnvList — список через запятую, пустые элементы пропускаются
+func getEnvList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func getEnvBool(key string, defaultVal bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return defaultVal
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"strings"
+	"unicode"
+)
+
+// KaldiProfile — параметры декодирования Kaldi. Профиль по умолчанию — KALDI_*,
+// именованные — KALDI_PROFILES=3gram,4gram и KALDI_PROFILE_<ИМЯ>_<ПАРАМЕТР>
+// (например KALDI_PROFILE_4GRAM_GRAPH_DIR); пропущенное берётся из профиля по умолчанию
+type KaldiProfile struct {
+	Name             string // пусто — профиль по умолчанию
+	KaldiRoot        string
+	ModelDir         string
+	GraphDir         string // пусто — ModelDir/graph
+	Beam             float64
+	MaxActive        int
+	LatticeBeam      float64
+	AcousticScale    float64
+	LMScale          float64
+	FrameSubsampling int
+}
+
+// loadKaldiProfile читает профиль из переменных prefix+ROOT, prefix+MODEL_DIR, ...
+func loadKaldiProfile(prefix string, base KaldiProfile) KaldiProfile {
+	return KaldiProfile{
+		Name:             base.Name,
+		KaldiRoot:        getEnv(prefix+"ROOT", base.KaldiRoot),
+		ModelDir:         getEnv(prefix+"MODEL_DIR", base.ModelDir),
+		GraphDir:         getEnv(prefix+"GRAPH_DIR", base.GraphDir),
+		Beam:             getEnvFloat(prefix+"BEAM", base.Beam),
+		MaxActive:        getEnvInt(prefix+"MAX_ACTIVE", base.MaxActive),
+		LatticeBeam:      getEnvFloat(prefix+"LATTICE_BEAM", base.LatticeBeam),
+		AcousticScale:    getEnvFloat(prefix+"ACOUSTIC_SCALE", base.AcousticScale),
+		LMScale:          getEnvFloat(prefix+"LM_SCALE", base.LMScale),
+		FrameSubsampling: getEnvInt(prefix+"FRAME_SUBSAMPLING", base.FrameSubsampling),
+	}
+}
+
+// loadKaldiProfiles — профиль по умолчанию и именованные профили из KALDI_PROFILES
+func loadKaldiProfiles() (KaldiProfile, map[string]KaldiProfile) {
+	def := loadKaldiProfile("KALDI_", KaldiProfile{
+		KaldiRoot:        "/opt/kaldi",
+		Beam:             15.0,
+		MaxActive:        7000,
+		LatticeBeam:      8.0,
+		AcousticScale:    1.0,
+		LMScale:          1.0,
+		FrameSubsampling: 3,
+	})
+
+	profiles := make(map[string]KaldiProfile)
+	for _, name := range strings.Split(getEnv("KALDI_PROFILES", ""), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		base := def
+		base.Name = name
+		profiles[name] = loadKaldiProfile("KALDI_PROFILE_"+envName(name)+"_", base)
+	}
+	return def, profiles
+}
+
+// envName — имя профиля в имени переменной: 4gram-big -> 4GRAM_BIG
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
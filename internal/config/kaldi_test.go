@@ -0,0 +1,50 @@
+package config
+
+import "testing"
+
+func TestEnvName(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{"3gram", "3GRAM"},
+		{"4gram-big", "4GRAM_BIG"},
+		{"lm.v2", "LM_V2"},
+	}
+	for _, tt := range tests {
+		if got := envName(tt.name); got != tt.want {
+			t.Errorf("envName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLoadKaldiProfiles(t *testing.T) {
+	t.Setenv("KALDI_MODEL_DIR", "/models/am")
+	t.Setenv("KALDI_BEAM", "13")
+	t.Setenv("KALDI_PROFILES", "3gram, 4gram-big,")
+	t.Setenv("KALDI_PROFILE_4GRAM_BIG_GRAPH_DIR", "/models/graph_4gram")
+	t.Setenv("KALDI_PROFILE_4GRAM_BIG_LM_SCALE", "0.8")
+
+	def, profiles := loadKaldiProfiles()
+	if def.Name != "" || def.ModelDir != "/models/am" || def.Beam != 13 {
+		t.Errorf("default: %+v", def)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("profiles: %+v", profiles)
+	}
+
+	// Профиль наследует незаданное от профиля по умолчанию
+	tests := []struct {
+		name     string
+		graphDir string
+		lmScale  float64
+	}{
+		{"3gram", "", 1},
+		{"4gram-big", "/models/graph_4gram", 0.8},
+	}
+	for _, tt := range tests {
+		p := profiles[tt.name]
+		if p.Name != tt.name || p.GraphDir != tt.graphDir || p.LMScale != tt.lmScale || p.ModelDir != def.ModelDir || p.Beam != def.Beam {
+			t.Errorf("%s: %+v", tt.name, p)
+		}
+	}
+}
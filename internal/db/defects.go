@@ -0,0 +1,126 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+
+	"audio-labeler/internal/audio"
+)
+
+// SaveDefects заменяет дефекты файла и обновляет audio_files.defect_count
+func (db *DB) SaveDefects(audioFileID int64, defects []audio.Defect) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM audio_defects WHERE audio_file_id = ?`, audioFileID); err != nil {
+		return err
+	}
+
+	if len(defects) > 0 {
+		stmt, err := tx.Prepare(`
+			INSERT INTO audio_defects
+			(audio_file_id, seq, type, severity, start_sec, end_sec, value, detail)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for i, d := range defects {
+			if _, err := stmt.Exec(audioFileID, i, d.Type, d.Severity, d.Start, d.End, d.Value, d.Detail); err != nil {
+				return err
+			}
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE audio_files SET defect_count = ? WHERE id = ?`, len(defects), audioFileID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetDefectsForFiles — дефекты для списка файлов: id -> дефекты по порядку
+func (db *DB) GetDefectsForFiles(ids []int64) (map[int64][]audio.Defect, error) {
+	result := make(map[int64][]audio.Defect)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := db.conn.Query(fmt.Sprintf(`
+		SELECT audio_file_id, type, severity, start_sec, end_sec, value, detail
+		FROM audio_defects WHERE audio_file_id IN (%s)
+		ORDER BY audio_file_id, seq`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		var d audio.Defect
+		if err := rows.Scan(&id, &d.Type, &d.Severity, &d.Start, &d.End, &d.Value, &d.Detail); err != nil {
+			return nil, err
+		}
+		result[id] = append(result[id], d)
+	}
+	return result, rows.Err()
+}
+
+// attachDefects заполняет AudioFile.Defects для списка файлов
+func (db *DB) attachDefects(files []AudioFile) error {
+	ids := make([]int64, 0, len(files))
+	for i := range files {
+		if files[i].DefectCount != nil && *files[i].DefectCount > 0 {
+			ids = append(ids, files[i].ID)
+		}
+	}
+
+	byFile, err := db.GetDefectsForFiles(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		files[i].Defects = byFile[files[i].ID]
+	}
+	return nil
+}
+
+// defectCondition — условие фильтра по дефектам для GetFilesFiltered.
+// defect: any | none (проверен, чистый) | unchecked | тип дефекта;
+// minSeverity — только дефекты не ниже (low | medium | high)
+func defectCondition(defect, minSeverity string) (string, []interface{}) {
+	switch defect {
+	case "":
+		return "", nil
+	case "none":
+		return "defect_count = 0", nil
+	case "unchecked":
+		return "defect_count IS NULL", nil
+	}
+
+	cond := `EXISTS (SELECT 1 FROM audio_defects d WHERE d.audio_file_id = audio_files.id`
+	var args []interface{}
+	if defect != "any" {
+		cond += " AND d.type = ?"
+		args = append(args, defect)
+	}
+	if rank := audio.SeverityRank(minSeverity); rank > 0 {
+		levels := audio.Severities[rank:]
+		cond += " AND d.severity IN (" + strings.TrimSuffix(strings.Repeat("?,", len(levels)), ",") + ")"
+		for _, s := range levels {
+			args = append(args, s)
+		}
+	}
+	return cond + ")", args
+}
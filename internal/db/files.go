@@ -3,205 +3,81 @@ package db
 import (
 	"audio-labeler/internal/audio"
 	"database/sql"
-	"fmt"
 	"strings"
 )
 
-func (db *DB) GetFiles(page, limit int) ([]AudioFile, int, error) {
-	var total int
-	err := db.conn.QueryRow("SELECT COUNT(*) FROM audio_files").Scan(&total)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	offset := (page - 1) * limit
-	rows, err := db.conn.Query(`
-		SELECT id, user_id, chapter_id, file_path, file_hash, duration_sec,
-		       snr_db, rms_db, transcription_original, transcription_asr, 
-		       wer, cer, asr_status
-		FROM audio_files 
-		ORDER BY id DESC
-		LIMIT ? OFFSET ?`, limit, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
-
-	var files []AudioFile
-	for rows.Next() {
-		var af AudioFile
-		var transASR sql.NullString
-		var wer, cer sql.NullFloat64
-		err := rows.Scan(&af.ID, &af.UserID, &af.ChapterID, &af.FilePath,
-			&af.FileHash, &af.DurationSec, &af.SNRDB, &af.RMSDB,
-			&af.TranscriptionOriginal, &transASR, &wer, &cer, &af.ASRStatus)
-		if err != nil {
-			return nil, 0, err
-		}
-		if transASR.Valid {
-			af.TranscriptionASR = transASR.String
-		}
-		if wer.Valid {
-			af.WER = wer.Float64
-		}
-		if cer.Valid {
-			af.CER = cer.Float64
-		}
-		files = append(files, af)
-	}
-	return files, total, nil
-}
-
-// GetFile - обновлённая версия с NoLM и верификацией
+// GetFile возвращает файл вместе с результатами всех движков
 func (db *DB) GetFile(id int64) (*AudioFile, error) {
 	var af AudioFile
-	var transASR, transASRNoLM, transWhisperLocal, transWhisperOpenAI sql.NullString
-	var wer, cer, werNoLM, cerNoLM, werWL, cerWL, werWO, cerWO sql.NullFloat64
 	var verifiedAt sql.NullTime
-	var asrNoLMStatus sql.NullString
+	var leadingMs, trailingMs sql.NullFloat64
+	var defectCount sql.NullInt64
+	var lufs, lra, truePeak, gain sql.NullFloat64
+	var loudnessSource, sourceKey sql.NullString
+	var segStart, segEnd sql.NullFloat64
+	var duplicateOf sql.NullInt64
+	var duplicateScore sql.NullFloat64
 
 	err := db.conn.QueryRow(`
 		SELECT id, user_id, chapter_id, file_path, file_hash, duration_sec,
 		       COALESCE(snr_db, 0), COALESCE(rms_db, 0), COALESCE(sample_rate, 0), COALESCE(channels, 0), COALESCE(bit_depth, 0), COALESCE(file_size, 0),
 		       COALESCE(audio_metadata, ''), COALESCE(transcription_original, ''), 
-		       transcription_asr, transcription_asr_nolm,
-		       transcription_whisper_local, transcription_whisper_openai,
-		       wer, cer, wer_nolm, cer_nolm,
-		       wer_whisper_local, cer_whisper_local,
-		       wer_whisper_openai, cer_whisper_openai,
-		       asr_status, asr_nolm_status,
-		       COALESCE(whisper_local_status, 'pending'), COALESCE(whisper_openai_status, 'pending'),
 		       COALESCE(review_status, 'pending'),
 		       COALESCE(operator_verified, 0), verified_at, COALESCE(original_edited, 0),
-		       created_at
+		       leading_silence_ms, trailing_silence_ms, defect_count,
+		       loudness_lufs, loudness_range, true_peak_db, loudness_gain_db, loudness_source_path,
+		       segment_start, segment_end, source_key, duplicate_of, duplicate_score, created_at
 		FROM audio_files WHERE id = ?`, id).Scan(
 		&af.ID, &af.UserID, &af.ChapterID, &af.FilePath, &af.FileHash,
 		&af.DurationSec, &af.SNRDB, &af.RMSDB, &af.SampleRate, &af.Channels,
 		&af.BitDepth, &af.FileSize, &af.AudioMetadata, &af.TranscriptionOriginal,
-		&transASR, &transASRNoLM, &transWhisperLocal, &transWhisperOpenAI,
-		&wer, &cer, &werNoLM, &cerNoLM, &werWL, &cerWL, &werWO, &cerWO,
-		&af.ASRStatus, &asrNoLMStatus,
-		&af.WhisperLocalStatus, &af.WhisperOpenAIStatus,
 		&af.ReviewStatus,
 		&af.OperatorVerified, &verifiedAt, &af.OriginalEdited,
-		&af.CreatedAt)
+		&leadingMs, &trailingMs, &defectCount,
+		&lufs, &lra, &truePeak, &gain, &loudnessSource,
+		&segStart, &segEnd, &sourceKey, &duplicateOf, &duplicateScore, &af.CreatedAt)
 	if err != nil {
 		return nil, err
 	}
 
-	if transASR.Valid {
-		af.TranscriptionASR = transASR.String
-	}
-	if transASRNoLM.Valid {
-		af.TranscriptionASRNoLM = transASRNoLM.String
-	}
-	if transWhisperLocal.Valid {
-		af.TranscriptionWhisperLocal = transWhisperLocal.String
-	}
-	if transWhisperOpenAI.Valid {
-		af.TranscriptionWhisperOpenAI = transWhisperOpenAI.String
-	}
-	if wer.Valid {
-		af.WER = wer.Float64
-	}
-	if cer.Valid {
-		af.CER = cer.Float64
-	}
-	if werNoLM.Valid {
-		af.WERNoLM = werNoLM.Float64
-	}
-	if cerNoLM.Valid {
-		af.CERNoLM = cerNoLM.Float64
-	}
-	if werWL.Valid {
-		af.WERWhisperLocal = werWL.Float64
-	}
-	if cerWL.Valid {
-		af.CERWhisperLocal = cerWL.Float64
-	}
-	if werWO.Valid {
-		af.WERWhisperOpenAI = werWO.Float64
+	if verifiedAt.Valid {
+		af.VerifiedAt = &verifiedAt.Time
 	}
-	if cerWO.Valid {
-		af.CERWhisperOpenAI = cerWO.Float64
+	if leadingMs.Valid {
+		af.LeadingSilenceMs = &leadingMs.Float64
 	}
-	if asrNoLMStatus.Valid {
-		af.ASRNoLMStatus = asrNoLMStatus.String
-	} else {
-		af.ASRNoLMStatus = "pending"
+	if trailingMs.Valid {
+		af.TrailingSilenceMs = &trailingMs.Float64
 	}
-	if verifiedAt.Valid {
-		af.VerifiedAt = &verifiedAt.Time
+	if defectCount.Valid {
+		n := int(defectCount.Int64)
+		af.DefectCount = &n
 	}
-
-	return &af, nil
-}
-
-func (db *DB) GetFilesWithTotal(page, limit int, minWER float64) (*FileListResult, error) {
-	offset := (page - 1) * limit
-
-	// Count total
-	var total int64
-	countQuery := "SELECT COUNT(*) FROM audio_files"
-	if minWER > 0 {
-		countQuery += fmt.Sprintf(" WHERE wer >= %f", minWER)
+	af.LoudnessLUFS, af.LoudnessRange, af.TruePeakDB = floatPtr(lufs), floatPtr(lra), floatPtr(truePeak)
+	af.LoudnessGainDB = floatPtr(gain)
+	af.LoudnessSourcePath = loudnessSource.String
+	af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+	af.SourceKey = sourceKey.String
+	if duplicateOf.Valid {
+		af.DuplicateOf = &duplicateOf.Int64
 	}
-	db.conn.QueryRow(countQuery).Scan(&total)
+	af.DuplicateScore = floatPtr(duplicateScore)
 
-	// Get files
-	query := `SELECT id, user_id, chapter_id, file_path, file_hash, 
-	          duration_sec, sample_rate, channels, bit_depth, file_size,
-	          COALESCE(snr_db, 0), COALESCE(rms_db, 0),
-	          COALESCE(transcription_original, ''), COALESCE(transcription_asr, ''),
-	          COALESCE(transcription_whisper_local, ''), COALESCE(transcription_whisper_openai, ''),
-	          COALESCE(wer, 0), COALESCE(cer, 0),
-	          COALESCE(wer_whisper_local, 0), COALESCE(cer_whisper_local, 0),
-	          COALESCE(wer_whisper_openai, 0), COALESCE(cer_whisper_openai, 0),
-	          asr_status
-	          FROM audio_files`
-
-	if minWER > 0 {
-		query += fmt.Sprintf(" WHERE wer >= %f", minWER)
-	}
-	query += " ORDER BY id DESC LIMIT ? OFFSET ?"
-
-	rows, err := db.conn.Query(query, limit, offset)
-	if err != nil {
+	files := []AudioFile{af}
+	if err := db.attachTranscriptions(files); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var files []AudioFile
-	for rows.Next() {
-		var af AudioFile
-		err := rows.Scan(
-			&af.ID, &af.UserID, &af.ChapterID, &af.FilePath, &af.FileHash,
-			&af.DurationSec, &af.SampleRate, &af.Channels, &af.BitDepth, &af.FileSize,
-			&af.SNRDB, &af.RMSDB,
-			&af.TranscriptionOriginal, &af.TranscriptionASR,
-			&af.TranscriptionWhisperLocal, &af.TranscriptionWhisperOpenAI,
-			&af.WER, &af.CER,
-			&af.WERWhisperLocal, &af.CERWhisperLocal,
-			&af.WERWhisperOpenAI, &af.CERWhisperOpenAI,
-			&af.ASRStatus,
-		)
-		if err != nil {
-			return nil, err
-		}
-		files = append(files, af)
+	if err := db.attachDefects(files); err != nil {
+		return nil, err
 	}
 
-	return &FileListResult{
-		Files: files,
-		Total: total,
-		Page:  page,
-		Limit: limit,
-	}, nil
+	return &files[0], nil
 }
 
-// GetAllFilePaths возвращает все пути файлов из базы
+// GetAllFilePaths возвращает все пути файлов из базы (виртуальные отрезки — SegmentKey)
+// и source_key: файл, переписанный правкой, не импортируется повторно
 func (db *DB) GetAllFilePaths() (map[string]bool, error) {
-	rows, err := db.conn.Query("SELECT file_path FROM audio_files")
+	rows, err := db.conn.Query("SELECT file_path, segment_start, segment_end, source_key FROM audio_files")
 	if err != nil {
 		return nil, err
 	}
@@ -210,17 +86,25 @@ func (db *DB) GetAllFilePaths() (map[string]bool, error) {
 	paths := make(map[string]bool)
 	for rows.Next() {
 		var path string
-		if err := rows.Scan(&path); err != nil {
+		var start, end sql.NullFloat64
+		var sourceKey sql.NullString
+		if err := rows.Scan(&path, &start, &end, &sourceKey); err != nil {
 			return nil, err
 		}
-		paths[path] = true
+		paths[SegmentKey(path, floatPtr(start), floatPtr(end))] = true
+		if sourceKey.Valid {
+			paths[sourceKey.String] = true
+		}
 	}
 	return paths, nil
 }
 
-// GetFilesFiltered - обновлённая версия с фильтрами verified и asr_nolm_status
-func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue float64, durOp string, durValue float64, asrStatus, asrNoLMStatus,
-	whisperLocalStatus, whisperOpenaiStatus, verified, merged, active, noiseLevel, textSearch, chapter string) (*FileListResult, error) {
+// GetFilesFiltered — список файлов с фильтрами.
+// engineStatus: engine -> status (pending/processed/error), werEngine — чей WER фильтруется,
+// confEngine — чья уверенность (Kaldi MBR) фильтруется (confMax > 0) и сортируется (sortBy = "confidence")
+func (db *DB) GetFilesFiltered(page, limit int, speaker, werEngine, werOp string, werValue float64, durOp string, durValue float64,
+	engineStatus map[string]string, verified, merged, active, noiseLevel, textSearch, chapter string,
+	confEngine string, confMax float64, sortBy string, defect, defectSeverity string) (*FileListResult, error) {
 
 	offset := (page - 1) * limit
 
@@ -234,17 +118,24 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 
 	// WER filter
 	if werOp != "" && werValue >= 0 {
+		if werEngine == "" {
+			werEngine = PrimaryEngine
+		}
 		werDecimal := werValue / 100.0
+		var cmp string
 		switch werOp {
 		case "lt":
-			conditions = append(conditions, "wer < ?")
-			args = append(args, werDecimal)
+			cmp = "<"
 		case "gt":
-			conditions = append(conditions, "wer > ?")
-			args = append(args, werDecimal)
+			cmp = ">"
 		case "eq":
-			conditions = append(conditions, "wer = ?")
-			args = append(args, werDecimal)
+			cmp = "="
+		}
+		if cmp != "" {
+			conditions = append(conditions, `EXISTS (SELECT 1 FROM transcriptions t
+				WHERE t.audio_file_id = audio_files.id AND t.engine = ? AND t.model_version = ''
+				  AND t.status = 'processed' AND t.wer `+cmp+` ?)`)
+			args = append(args, werEngine, werDecimal)
 		}
 	}
 
@@ -260,22 +151,22 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 		}
 	}
 
-	// Status filters
-	if asrStatus != "" {
-		conditions = append(conditions, "asr_status = ?")
-		args = append(args, asrStatus)
-	}
-	if asrNoLMStatus != "" {
-		conditions = append(conditions, "asr_nolm_status = ?")
-		args = append(args, asrNoLMStatus)
-	}
-	if whisperLocalStatus != "" {
-		conditions = append(conditions, "whisper_local_status = ?")
-		args = append(args, whisperLocalStatus)
-	}
-	if whisperOpenaiStatus != "" {
-		conditions = append(conditions, "whisper_openai_status = ?")
-		args = append(args, whisperOpenaiStatus)
+	// Status filters — по каждому движку; pending = нет готового результата
+	for engine, status := range engineStatus {
+		if status == "" {
+			continue
+		}
+		if status == "pending" {
+			conditions = append(conditions, `NOT EXISTS (SELECT 1 FROM transcriptions t
+				WHERE t.audio_file_id = audio_files.id AND t.engine = ? AND t.model_version = ''
+				  AND t.status <> 'pending')`)
+			args = append(args, engine)
+		} else {
+			conditions = append(conditions, `EXISTS (SELECT 1 FROM transcriptions t
+				WHERE t.audio_file_id = audio_files.id AND t.engine = ? AND t.model_version = ''
+				  AND t.status = ?)`)
+			args = append(args, engine, status)
+		}
 	}
 
 	// Verified filter
@@ -334,6 +225,21 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 		args = append(args, chapter)
 	}
 
+	if confEngine == "" {
+		confEngine = PrimaryEngine
+	}
+	if confMax > 0 {
+		conditions = append(conditions, `EXISTS (SELECT 1 FROM transcriptions t
+			WHERE t.audio_file_id = audio_files.id AND t.engine = ? AND t.model_version = ''
+			  AND t.confidence < ?)`)
+		args = append(args, confEngine, confMax)
+	}
+
+	if cond, condArgs := defectCondition(defect, defectSeverity); cond != "" {
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+
 	whereClause := ""
 	if len(conditions) > 0 {
 		whereClause = "WHERE " + strings.Join(conditions, " AND ")
@@ -343,22 +249,26 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 	countQuery := "SELECT COUNT(*) FROM audio_files " + whereClause
 	db.conn.QueryRow(countQuery, args...).Scan(&total)
 
+	// Сортировка: новые сверху; confidence — сначала наименее уверенные, без оценки в конце
+	orderBy := "id DESC"
+	var orderArgs []interface{}
+	if sortBy == "confidence" {
+		conf := `(SELECT t.confidence FROM transcriptions t
+			WHERE t.audio_file_id = audio_files.id AND t.engine = ? AND t.model_version = '')`
+		orderBy = conf + " IS NULL, " + conf + " ASC, id DESC"
+		orderArgs = []interface{}{confEngine, confEngine}
+	}
+
 	query := `SELECT id, user_id, chapter_id, file_path, file_hash, 
           duration_sec, sample_rate, channels, COALESCE(bit_depth, 0), COALESCE(file_size, 0),
           COALESCE(snr_db, 0), COALESCE(snr_sox, 0), COALESCE(snr_wada, 0), COALESCE(snr_spectral, 0),
           COALESCE(rms_db, 0), COALESCE(noise_level, ''),
-          COALESCE(transcription_original, ''), COALESCE(transcription_asr, ''),
-          COALESCE(transcription_asr_nolm, ''),
-          COALESCE(transcription_whisper_local, ''), COALESCE(transcription_whisper_openai, ''),
-          COALESCE(wer, 0), COALESCE(cer, 0),
-          COALESCE(wer_nolm, 0), COALESCE(cer_nolm, 0),
-          COALESCE(wer_whisper_local, 0), COALESCE(cer_whisper_local, 0),
-          COALESCE(wer_whisper_openai, 0), COALESCE(cer_whisper_openai, 0),
-          asr_status, COALESCE(asr_nolm_status, 'pending'),
-          COALESCE(whisper_local_status, 'pending'), COALESCE(whisper_openai_status, 'pending'),
-          COALESCE(operator_verified, 0), COALESCE(original_edited, 0)
-          FROM audio_files ` + whereClause + ` ORDER BY id DESC LIMIT ? OFFSET ?`
+          COALESCE(transcription_original, ''),
+          COALESCE(operator_verified, 0), COALESCE(original_edited, 0), defect_count,
+          loudness_lufs, true_peak_db, loudness_gain_db, segment_start, segment_end
+          FROM audio_files ` + whereClause + ` ORDER BY ` + orderBy + ` LIMIT ? OFFSET ?`
 
+	args = append(args, orderArgs...)
 	args = append(args, limit, offset)
 	rows, err := db.conn.Query(query, args...)
 	if err != nil {
@@ -369,27 +279,38 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 	var files []AudioFile
 	for rows.Next() {
 		var af AudioFile
+		var defectCount sql.NullInt64
+		var lufs, truePeak, gain, segStart, segEnd sql.NullFloat64
 		err := rows.Scan(
 			&af.ID, &af.UserID, &af.ChapterID, &af.FilePath, &af.FileHash,
 			&af.DurationSec, &af.SampleRate, &af.Channels, &af.BitDepth, &af.FileSize,
 			&af.SNRDB, &af.SNRSox, &af.SNRWada, &af.SNRSpectral,
 			&af.RMSDB, &af.NoiseLevel,
-			&af.TranscriptionOriginal, &af.TranscriptionASR,
-			&af.TranscriptionASRNoLM,
-			&af.TranscriptionWhisperLocal, &af.TranscriptionWhisperOpenAI,
-			&af.WER, &af.CER,
-			&af.WERNoLM, &af.CERNoLM,
-			&af.WERWhisperLocal, &af.CERWhisperLocal,
-			&af.WERWhisperOpenAI, &af.CERWhisperOpenAI,
-			&af.ASRStatus, &af.ASRNoLMStatus,
-			&af.WhisperLocalStatus, &af.WhisperOpenAIStatus,
-			&af.OperatorVerified, &af.OriginalEdited,
+			&af.TranscriptionOriginal,
+			&af.OperatorVerified, &af.OriginalEdited, &defectCount,
+			&lufs, &truePeak, &gain, &segStart, &segEnd,
 		)
 		if err != nil {
 			return nil, err
 		}
+		if defectCount.Valid {
+			n := int(defectCount.Int64)
+			af.DefectCount = &n
+		}
+		af.LoudnessLUFS, af.TruePeakDB, af.LoudnessGainDB = floatPtr(lufs), floatPtr(truePeak), floatPtr(gain)
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
 		files = append(files, af)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := db.attachTranscriptions(files); err != nil {
+		return nil, err
+	}
+	if err := db.attachDefects(files); err != nil {
+		return nil, err
+	}
 
 	return &FileListResult{
 		Files: files,
@@ -403,6 +324,7 @@ func (db *DB) GetFilesFiltered(page, limit int, speaker, werOp string, werValue
 func (d *DB) UpdateAudioStats(id int64, stats *audio.AudioStats) error {
 	quality := stats.Quality()
 	metadata := stats.ToJSON()
+	lufs, lra, truePeak := loudnessValues(stats.Loudness)
 
 	_, err := d.conn.Exec(`
 		UPDATE audio_files SET
@@ -414,25 +336,28 @@ func (d *DB) UpdateAudioStats(id int64, stats *audio.AudioStats) error {
 			rms_db = ?,
 			audio_quality_score = ?,
 			audio_quality_level = ?,
-			audio_metadata = ?
+			audio_metadata = ?,
+			loudness_lufs = ?,
+			loudness_range = ?,
+			true_peak_db = ?
 		WHERE id = ?
 	`, stats.SNREstimate, stats.SNRSox, stats.SNRWada, stats.SNRSpectral,
 		stats.NoiseLevel, stats.RMSLevDB,
-		quality.Score, quality.Level, metadata, id)
+		quality.Score, quality.Level, metadata, lufs, lra, truePeak, id)
 	return err
 }
 
-// GetFilesForAnalyze возвращает файлы для анализа
-// force=true — все файлы, force=false — только без SNR
-func (db *DB) GetFilesForAnalyze(limit int, force bool) ([]AudioFile, error) {
+// GetFilesForAnalyze возвращает файлы для анализа по возрастанию id, начиная после afterID
+// (курсор задачи для resume). force=true — все файлы, force=false — только без SNR или без проверки дефектов
+func (db *DB) GetFilesForAnalyze(limit int, force bool, afterID int64) ([]AudioFile, error) {
 	var query string
 	if force {
-		query = `SELECT id, file_path FROM audio_files WHERE active = 1 LIMIT ?`
+		query = `SELECT id, file_path, segment_start, segment_end FROM audio_files WHERE active = 1 AND id > ? ORDER BY id LIMIT ?`
 	} else {
-		query = `SELECT id, file_path FROM audio_files WHERE (snr_db IS NULL OR snr_db = 0) AND active = 1 LIMIT ?`
+		query = `SELECT id, file_path, segment_start, segment_end FROM audio_files WHERE (snr_db IS NULL OR snr_db = 0 OR defect_count IS NULL) AND active = 1 AND id > ? ORDER BY id LIMIT ?`
 	}
 
-	rows, err := db.conn.Query(query, limit)
+	rows, err := db.conn.Query(query, afterID, limit)
 	if err != nil {
 		return nil, err
 	}
@@ -441,16 +366,24 @@ func (db *DB) GetFilesForAnalyze(limit int, force bool) ([]AudioFile, error) {
 	var files []AudioFile
 	for rows.Next() {
 		var af AudioFile
-		if err := rows.Scan(&af.ID, &af.FilePath); err != nil {
+		var segStart, segEnd sql.NullFloat64
+		if err := rows.Scan(&af.ID, &af.FilePath, &segStart, &segEnd); err != nil {
 			return nil, err
 		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
 		files = append(files, af)
 	}
 	return files, nil
 }
 
-// DeleteFile удаляет файл из БД
+// DeleteFile удаляет файл из БД вместе с результатами движков
 func (db *DB) DeleteFile(id int64) error {
+	if err := db.DeleteTranscriptions(id); err != nil {
+		return err
+	}
+	if err := db.dropFingerprint(id); err != nil {
+		return err
+	}
 	_, err := db.conn.Exec("DELETE FROM audio_files WHERE id = ?", id)
 	return err
 }
@@ -0,0 +1,97 @@
+package db
+
+import (
+	"database/sql"
+
+	"audio-labeler/internal/audio"
+)
+
+// FileFingerprint — отпечаток активного файла и поля для отчёта о дубликатах
+type FileFingerprint struct {
+	AudioFile
+	Fingerprint audio.Fingerprint
+}
+
+// SaveFingerprint записывает (перезаписывает) отпечаток файла. Пустой fp (frames = 0) —
+// метка для тишины и слишком коротких файлов (audio.ErrNoFingerprint): файл считается
+// обработанным, но в поиск дубликатов не попадает
+func (db *DB) SaveFingerprint(audioFileID int64, fp audio.Fingerprint) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO audio_fingerprints (audio_file_id, frames, fingerprint) VALUES (?, ?, ?)`+
+		db.upsert("audio_file_id", `frames = `+db.excluded("frames")+`, fingerprint = `+db.excluded("fingerprint")),
+		audioFileID, len(fp), fp.Bytes())
+	return err
+}
+
+// dropFingerprint удаляет отпечаток файла, чьё аудио изменилось: его пересчитает
+// POST /api/duplicates/fingerprint/start, а до тех пор файл не сравнивается по старому звуку
+func (db *DB) dropFingerprint(audioFileID int64) error {
+	_, err := db.conn.Exec("DELETE FROM audio_fingerprints WHERE audio_file_id = ?", audioFileID)
+	return err
+}
+
+// GetFingerprints — отпечатки всех активных файлов, без меток frames = 0
+func (db *DB) GetFingerprints() ([]FileFingerprint, error) {
+	rows, err := db.conn.Query(`
+		SELECT a.id, a.user_id, a.chapter_id, a.file_path, a.duration_sec, a.segment_start, a.segment_end,
+		       COALESCE(a.transcription_original, ''), f.fingerprint
+		FROM audio_fingerprints f
+		JOIN audio_files a ON a.id = f.audio_file_id
+		WHERE a.active = 1 AND f.frames > 0
+		ORDER BY a.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []FileFingerprint
+	for rows.Next() {
+		var f FileFingerprint
+		var segStart, segEnd sql.NullFloat64
+		var data []byte
+		if err := rows.Scan(&f.ID, &f.UserID, &f.ChapterID, &f.FilePath, &f.DurationSec, &segStart, &segEnd,
+			&f.TranscriptionOriginal, &data); err != nil {
+			return nil, err
+		}
+		f.SegmentStart, f.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		f.Fingerprint = audio.ParseFingerprint(data)
+		f.Active = true
+		list = append(list, f)
+	}
+	return list, rows.Err()
+}
+
+// GetFilesWithoutFingerprint — активные файлы без отпечатка, порциями по id
+func (db *DB) GetFilesWithoutFingerprint(limit int, afterID int64) ([]AudioFile, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, file_path, segment_start, segment_end FROM audio_files
+		WHERE active = 1 AND id > ?
+		  AND NOT EXISTS (SELECT 1 FROM audio_fingerprints f WHERE f.audio_file_id = audio_files.id)
+		ORDER BY id LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudioFile
+	for rows.Next() {
+		var af AudioFile
+		var segStart, segEnd sql.NullFloat64
+		if err := rows.Scan(&af.ID, &af.FilePath, &segStart, &segEnd); err != nil {
+			return nil, err
+		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		files = append(files, af)
+	}
+	return files, rows.Err()
+}
+
+// CountFilesWithoutFingerprint — сколько активных файлов ещё без отпечатка
+func (db *DB) CountFilesWithoutFingerprint() (int64, error) {
+	var n int64
+	err := db.conn.QueryRow(`
+		SELECT COUNT(*) FROM audio_files
+		WHERE active = 1
+		  AND NOT EXISTS (SELECT 1 FROM audio_fingerprints f WHERE f.audio_file_id = audio_files.id)`).Scan(&n)
+	return n, err
+}
@@ -0,0 +1,49 @@
+package db
+
+import (
+	"testing"
+
+	"audio-labeler/internal/audio"
+)
+
+func TestFingerprints(t *testing.T) {
+	database := testDB(t)
+
+	var ids []int64
+	for _, path := range []string{"/data/a.wav", "/data/silence.wav", "/data/new.wav"} {
+		id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: path, FileHash: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+
+	fp := audio.Fingerprint{1, 2, 3}
+	if err := database.SaveFingerprint(ids[0], fp); err != nil {
+		t.Fatal(err)
+	}
+	// Тишина: метка без кадров — обработан, но не участвует в поиске
+	if err := database.SaveFingerprint(ids[1], nil); err != nil {
+		t.Fatal(err)
+	}
+
+	prints, err := database.GetFingerprints()
+	if err != nil || len(prints) != 1 || prints[0].ID != ids[0] || len(prints[0].Fingerprint) != len(fp) {
+		t.Errorf("fingerprints: %+v %v", prints, err)
+	}
+	missing, err := database.GetFilesWithoutFingerprint(10, 0)
+	if err != nil || len(missing) != 1 || missing[0].ID != ids[2] {
+		t.Errorf("without fingerprint: %+v %v", missing, err)
+	}
+	if n, err := database.CountFilesWithoutFingerprint(); err != nil || n != 1 {
+		t.Errorf("count: %d %v", n, err)
+	}
+
+	// Новый звук — отпечаток удаляется и снова считается недостающим
+	if err := database.UpdateFilePath(ids[0], "/data/a_trim.wav", 1, "h2"); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := database.CountFilesWithoutFingerprint(); err != nil || n != 2 {
+		t.Errorf("count after rewrite: %d %v", n, err)
+	}
+}
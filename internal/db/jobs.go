@@ -0,0 +1,218 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Типы задач
+const (
+	JobTypeScan        = "scan"
+	JobTypeASR         = "asr"
+	JobTypeMerge       = "merge"
+	JobTypeAnalyze     = "analyze"
+	JobTypeSweep       = "sweep"
+	JobTypeSilence     = "silence"
+	JobTypeLoudness    = "loudness"
+	JobTypeWatch       = "watch" // проход слежения за DATA_DIR, в котором нашлись изменения
+	JobTypeReconcile   = "reconcile"
+	JobTypeFingerprint = "fingerprint"
+)
+
+// Статусы задач
+const (
+	JobRunning     = "running"
+	JobCompleted   = "completed"
+	JobStopped     = "stopped"     // остановлена оператором
+	JobFailed      = "failed"      // не смогла стартовать / упала целиком
+	JobInterrupted = "interrupted" // сервер перезапустился во время выполнения
+)
+
+// Job — один запуск пакетной задачи
+type Job struct {
+	ID         int64      `json:"id"`
+	Type       string     `json:"type"`
+	Name       string     `json:"name,omitempty"` // имя движка для asr
+	Params     string     `json:"params,omitempty"`
+	StartedBy  string     `json:"started_by,omitempty"`
+	Status     string     `json:"status"`
+	Total      int64      `json:"total"`
+	Processed  int64      `json:"processed"`
+	Skipped    int64      `json:"skipped"`
+	Errors     int64      `json:"errors"`
+	LastError  string     `json:"last_error,omitempty"`
+	CursorID   int64      `json:"cursor_id"` // последний обработанный audio_files.id — для resume
+	Runs       int        `json:"runs"`      // 1 + число resume
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  *time.Time `json:"updated_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+
+	// Платные API: секунды отправленного аудио, потрачено и лимит (0 — без лимита)
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+	Budget       float64 `json:"budget,omitempty"`
+}
+
+// CreateJob записывает новую задачу со статусом running
+func (db *DB) CreateJob(j *Job) (int64, error) {
+	res, err := db.conn.Exec(`
+		INSERT INTO jobs (type, name, params, started_by, status, total, budget, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)`,
+		j.Type, j.Name, j.Params, j.StartedBy, JobRunning, j.Total, nullFloat(j.Budget))
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// UpdateJobProgress сохраняет счётчики и курсор
+func (db *DB) UpdateJobProgress(j *Job) error {
+	_, err := db.conn.Exec(`
+		UPDATE jobs SET total = ?, processed = ?, skipped = ?, errors = ?,
+		       last_error = ?, cursor_id = ?, audio_seconds = ?, cost = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		j.Total, j.Processed, j.Skipped, j.Errors, nullString(j.LastError), j.CursorID,
+		j.AudioSeconds, j.Cost, j.ID)
+	return err
+}
+
+// FinishJob сохраняет итоговые счётчики и статус
+func (db *DB) FinishJob(j *Job) error {
+	_, err := db.conn.Exec(`
+		UPDATE jobs SET status = ?, total = ?, processed = ?, skipped = ?, errors = ?,
+		       last_error = ?, cursor_id = ?, audio_seconds = ?, cost = ?,
+		       updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+		WHERE id = ?`,
+		j.Status, j.Total, j.Processed, j.Skipped, j.Errors, nullString(j.LastError), j.CursorID,
+		j.AudioSeconds, j.Cost, j.ID)
+	return err
+}
+
+// ResumeJob переводит прерванную задачу обратно в running
+func (db *DB) ResumeJob(id int64, startedBy string) error {
+	res, err := db.conn.Exec(`
+		UPDATE jobs SET status = ?, runs = runs + 1, started_by = ?,
+		       finished_at = NULL, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status IN (?, ?, ?)`,
+		JobRunning, startedBy, id, JobInterrupted, JobStopped, JobFailed)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("job %d is not resumable", id)
+	}
+	return nil
+}
+
+// MarkInterruptedJobs помечает interrupted задачи running, чей прогресс не сбрасывался
+// staleSec секунд: их процесс упал. Живые задачи (в том числе других серверов на той же
+// базе) обновляют updated_at каждые несколько секунд и не трогаются
+func (db *DB) MarkInterruptedJobs(staleSec int) (int64, error) {
+	res, err := db.conn.Exec(`
+		UPDATE jobs SET status = ?, finished_at = CURRENT_TIMESTAMP
+		WHERE status = ? AND updated_at < `+db.secondsAgo(staleSec), JobInterrupted, JobRunning)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+const jobColumns = `id, type, name, COALESCE(params, ''), started_by, status,
+	total, processed, skipped, errors, COALESCE(last_error, ''), cursor_id, runs,
+	audio_seconds, cost, COALESCE(budget, 0), started_at, updated_at, finished_at`
+
+func scanJob(scanner interface{ Scan(...interface{}) error }) (*Job, error) {
+	var j Job
+	var updatedAt, finishedAt sql.NullTime
+	err := scanner.Scan(&j.ID, &j.Type, &j.Name, &j.Params, &j.StartedBy, &j.Status,
+		&j.Total, &j.Processed, &j.Skipped, &j.Errors, &j.LastError, &j.CursorID, &j.Runs,
+		&j.AudioSeconds, &j.Cost, &j.Budget, &j.StartedAt, &updatedAt, &finishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if updatedAt.Valid {
+		j.UpdatedAt = &updatedAt.Time
+	}
+	if finishedAt.Valid {
+		j.FinishedAt = &finishedAt.Time
+	}
+	return &j, nil
+}
+
+// GetJob возвращает задачу по id
+func (db *DB) GetJob(id int64) (*Job, error) {
+	return scanJob(db.conn.QueryRow(`SELECT `+jobColumns+` FROM jobs WHERE id = ?`, id))
+}
+
+// LastJob — последний запуск задачи данного типа (и имени); nil если не запускалась
+func (db *DB) LastJob(jobType, name string) (*Job, error) {
+	j, err := scanJob(db.conn.QueryRow(`SELECT `+jobColumns+`
+		FROM jobs WHERE type = ? AND name = ? ORDER BY id DESC LIMIT 1`, jobType, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+// ListJobs — история задач, новые сверху. Пустые фильтры не применяются
+func (db *DB) ListJobs(jobType, name, status string, page, limit int) ([]Job, int64, error) {
+	var conditions []string
+	var args []interface{}
+
+	if jobType != "" {
+		conditions = append(conditions, "type = ?")
+		args = append(args, jobType)
+	}
+	if name != "" {
+		conditions = append(conditions, "name = ?")
+		args = append(args, name)
+	}
+	if status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+
+	where := ""
+	if len(conditions) > 0 {
+		where = " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	var total int64
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM jobs"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.conn.Query(`SELECT `+jobColumns+` FROM jobs`+where+
+		` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var jobs []Job
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, total, rows.Err()
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func nullFloat(f float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: f, Valid: f != 0}
+}
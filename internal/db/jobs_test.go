@@ -0,0 +1,30 @@
+package db
+
+import "testing"
+
+func TestMarkInterruptedJobs(t *testing.T) {
+	database := testDB(t)
+
+	live, err := database.CreateJob(&Job{Type: JobTypeScan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	crashed, err := database.CreateJob(&Job{Type: JobTypeScan})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Процесс упал минуту назад: прогресс с тех пор не сбрасывался
+	if _, err := database.conn.Exec(`UPDATE jobs SET updated_at = datetime('now', '-60 seconds') WHERE id = ?`, crashed); err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := database.MarkInterruptedJobs(30)
+	if err != nil || n != 1 {
+		t.Fatalf("marked %d %v", n, err)
+	}
+	for id, want := range map[int64]string{live: JobRunning, crashed: JobInterrupted} {
+		if j, err := database.GetJob(id); err != nil || j.Status != want {
+			t.Errorf("job %d: %+v %v, want %s", id, j, err, want)
+		}
+	}
+}
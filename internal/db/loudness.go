@@ -0,0 +1,112 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+
+	"audio-labeler/internal/audio"
+)
+
+// LoudnessFilter — какие активные файлы берёт нормализация громкости (пустое поле — без фильтра)
+type LoudnessFilter struct {
+	Speaker string `json:"speaker,omitempty"`
+	Chapter string `json:"chapter,omitempty"`
+	// OutOfRange — только не измеренные файлы, громкость дальше Tolerance LU от TargetLUFS
+	// или true peak выше PeakDB
+	OutOfRange bool    `json:"out_of_range,omitempty"`
+	TargetLUFS float64 `json:"target_lufs,omitempty"`
+	Tolerance  float64 `json:"tolerance,omitempty"`
+	PeakDB     float64 `json:"peak_db,omitempty"`
+}
+
+// loudnessValues — значения для loudness_lufs, loudness_range, true_peak_db (nil — NULL)
+func loudnessValues(l *audio.Loudness) (interface{}, interface{}, interface{}) {
+	if l == nil {
+		return nil, nil, nil
+	}
+	return l.Integrated, l.Range, l.TruePeak
+}
+
+func floatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// UpdateLoudness сохраняет измерение громкости (nil — тишина, NULL)
+func (db *DB) UpdateLoudness(id int64, l *audio.Loudness) error {
+	lufs, lra, truePeak := loudnessValues(l)
+	_, err := db.conn.Exec(`
+		UPDATE audio_files SET loudness_lufs = ?, loudness_range = ?, true_peak_db = ?
+		WHERE id = ?`, lufs, lra, truePeak, id)
+	return err
+}
+
+// SetLoudnessNormalized переключает файл на нормализованную копию (длительность не меняется):
+// путь, hash, громкость после, применённое усиление и исходный файл для отката.
+// Виртуальный отрезок становится обычным файлом
+func (db *DB) SetLoudnessNormalized(id int64, newPath, hash string, gainDB float64, sourcePath string, l *audio.Loudness) error {
+	lufs, lra, truePeak := loudnessValues(l)
+	_, err := db.conn.Exec(`
+		UPDATE audio_files
+		SET file_path = ?, file_hash = ?,
+		    loudness_lufs = ?, loudness_range = ?, true_peak_db = ?,
+		    loudness_gain_db = ?, loudness_source_path = ?,
+		    segment_start = NULL, segment_end = NULL
+		WHERE id = ?`, newPath, hash, lufs, lra, truePeak, gainDB, sourcePath, id)
+	return err
+}
+
+// RevertLoudness возвращает файл на loudness_source_path и сбрасывает усиление
+func (db *DB) RevertLoudness(id int64, hash string, l *audio.Loudness) error {
+	lufs, lra, truePeak := loudnessValues(l)
+	_, err := db.conn.Exec(`
+		UPDATE audio_files
+		SET file_path = loudness_source_path, file_hash = ?,
+		    loudness_lufs = ?, loudness_range = ?, true_peak_db = ?,
+		    loudness_gain_db = NULL, loudness_source_path = NULL
+		WHERE id = ? AND loudness_source_path IS NOT NULL`, hash, lufs, lra, truePeak, id)
+	return err
+}
+
+// GetFilesForLoudness возвращает файлы по возрастанию id, начиная после afterID (курсор задачи)
+func (db *DB) GetFilesForLoudness(f LoudnessFilter, limit int, afterID int64) ([]AudioFile, error) {
+	conditions := []string{"active = 1", "id > ?"}
+	args := []interface{}{afterID}
+
+	if f.Speaker != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, f.Speaker)
+	}
+	if f.Chapter != "" {
+		conditions = append(conditions, "chapter_id = ?")
+		args = append(args, f.Chapter)
+	}
+	if f.OutOfRange {
+		conditions = append(conditions, `(loudness_lufs IS NULL OR ABS(loudness_lufs - ?) > ? OR true_peak_db > ?)`)
+		args = append(args, f.TargetLUFS, f.Tolerance, f.PeakDB)
+	}
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(`
+		SELECT id, file_path, duration_sec, COALESCE(loudness_source_path, ''), segment_start, segment_end FROM audio_files
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY id LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudioFile
+	for rows.Next() {
+		var af AudioFile
+		var segStart, segEnd sql.NullFloat64
+		if err := rows.Scan(&af.ID, &af.FilePath, &af.DurationSec, &af.LoudnessSourcePath, &segStart, &segEnd); err != nil {
+			return nil, err
+		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		files = append(files, af)
+	}
+	return files, rows.Err()
+}
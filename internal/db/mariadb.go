@@ -8,6 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/segment"
+
 	_ "github.com/go-sql-driver/mysql"
 )
 
@@ -40,45 +43,30 @@ func (db *DB) StatsExtendedCached() (map[string]interface{}, error) {
 }
 
 type AudioFile struct {
-	ID                         int64     `json:"id"`
-	UserID                     string    `json:"user_id"`
-	ChapterID                  string    `json:"chapter_id"`
-	MergedID                   int64     `json:"merged_id"`
-	FilePath                   string    `json:"file_path"`
-	FileHash                   string    `json:"file_hash"`
-	DurationSec                float64   `json:"duration_sec"`
-	SampleRate                 int       `json:"sample_rate"`
-	Channels                   int       `json:"channels"`
-	BitDepth                   int       `json:"bit_depth"`
-	FileSize                   int64     `json:"file_size"`
-	SNRDB                      float64   `json:"snr_db"`
-	SNRSox                     float64   `json:"snr_sox"`
-	SNRSpectral                float64   `json:"snr_spectral"`
-	SNRWada                    float64   `json:"snr_wada"`
-	NoiseLevel                 string    `json:"noise_level"`
-	RMSDB                      float64   `json:"rms_db"`
-	AudioMetadata              string    `json:"audio_metadata"`
-	TranscriptionOriginal      string    `json:"transcription_original"`
-	TranscriptionASR           string    `json:"transcription_asr"`
-	TranscriptionWhisperLocal  string    `json:"transcription_whisper_local"`
-	TranscriptionWhisperOpenAI string    `json:"transcription_whisper_openai"`
-	WER                        float64   `json:"wer"`
-	CER                        float64   `json:"cer"`
-	WERWhisperLocal            float64   `json:"wer_whisper_local"`
-	CERWhisperLocal            float64   `json:"cer_whisper_local"`
-	WERWhisperOpenAI           float64   `json:"wer_whisper_openai"`
-	CERWhisperOpenAI           float64   `json:"cer_whisper_openai"`
-	ASRStatus                  string    `json:"asr_status"`
-	WhisperLocalStatus         string    `json:"whisper_local_status"`
-	WhisperOpenAIStatus        string    `json:"whisper_openai_status"`
-	ReviewStatus               string    `json:"review_status"`
-	CreatedAt                  time.Time `json:"created_at"`
-
-	// Kaldi NoLM
-	TranscriptionASRNoLM string  `json:"transcription_asr_nolm"`
-	WERNoLM              float64 `json:"wer_nolm"`
-	CERNoLM              float64 `json:"cer_nolm"`
-	ASRNoLMStatus        string  `json:"asr_nolm_status"`
+	ID                    int64     `json:"id"`
+	UserID                string    `json:"user_id"`
+	ChapterID             string    `json:"chapter_id"`
+	MergedID              int64     `json:"merged_id"`
+	FilePath              string    `json:"file_path"`
+	FileHash              string    `json:"file_hash"`
+	DurationSec           float64   `json:"duration_sec"`
+	SampleRate            int       `json:"sample_rate"`
+	Channels              int       `json:"channels"`
+	BitDepth              int       `json:"bit_depth"`
+	FileSize              int64     `json:"file_size"`
+	SNRDB                 float64   `json:"snr_db"`
+	SNRSox                float64   `json:"snr_sox"`
+	SNRSpectral           float64   `json:"snr_spectral"`
+	SNRWada               float64   `json:"snr_wada"`
+	NoiseLevel            string    `json:"noise_level"`
+	RMSDB                 float64   `json:"rms_db"`
+	AudioMetadata         string    `json:"audio_metadata"`
+	TranscriptionOriginal string    `json:"transcription_original"`
+	ReviewStatus          string    `json:"review_status"`
+	CreatedAt             time.Time `json:"created_at"`
+
+	// Результаты ASR движков: key ("kaldi", "whisper-local@large-v3", ...) -> транскрипция
+	Transcriptions map[string]*Transcription `json:"transcriptions,omitempty"`
 
 	// Verification
 	OperatorVerified bool       `json:"operator_verified"`
@@ -86,23 +74,41 @@ type AudioFile struct {
 	OriginalEdited   bool       `json:"original_edited"`
 
 	// Silence & Merge  <-- ДОБАВИТЬ ЭТИ ПОЛЯ
-	HasTrailingSilence bool   `json:"has_trailing_silence"`
-	SilenceAdded       bool   `json:"silence_added"`
-	ParentIDs          string `json:"parent_ids,omitempty"`
+	HasTrailingSilence bool     `json:"has_trailing_silence"`
+	SilenceAdded       bool     `json:"silence_added"`
+	LeadingSilenceMs   *float64 `json:"leading_silence_ms"` // VAD; nil — не измерялась
+	TrailingSilenceMs  *float64 `json:"trailing_silence_ms"`
+	ParentIDs          string   `json:"parent_ids,omitempty"`
+
+	// Дефекты записи (audio.DetectDefects); DefectCount nil — не проверялся
+	DefectCount *int           `json:"defect_count"`
+	Defects     []audio.Defect `json:"defects,omitempty"`
+
+	// EBU R128 (nil — не измерялась); LoudnessGainDB и LoudnessSourcePath — после нормализации
+	LoudnessLUFS       *float64 `json:"loudness_lufs"`
+	LoudnessRange      *float64 `json:"loudness_range"`
+	TruePeakDB         *float64 `json:"true_peak_db"`
+	LoudnessGainDB     *float64 `json:"loudness_gain_db"`
+	LoudnessSourcePath string   `json:"loudness_source_path,omitempty"`
+
+	// Виртуальный отрезок: FilePath — длинная запись, [SegmentStart, SegmentEnd) в секундах;
+	// nil — обычный файл
+	SegmentStart *float64 `json:"segment_start,omitempty"`
+	SegmentEnd   *float64 `json:"segment_end,omitempty"`
+
+	// Откуда импортирован (путь или SegmentKey на момент скана); "" — не из скана
+	SourceKey string `json:"source_key,omitempty"`
+	// Эталон, каким он был на диске при импорте или последней сверке; nil — неизвестен.
+	// Заполняется только GetFilesUnderDir
+	TranscriptionSource *string `json:"-"`
+
+	// Почти-дубликат по акустическому отпечатку (скан с SCAN_DUPLICATES=flag)
+	DuplicateOf    *int64   `json:"duplicate_of,omitempty"`
+	DuplicateScore *float64 `json:"duplicate_score,omitempty"`
 
 	Active bool `json:"active"`
 }
 
-// AudioFileRecalc - структура для пересчёта WER/CER
-type AudioFileRecalc struct {
-	ID                         int64
-	TranscriptionOriginal      string
-	TranscriptionASR           string
-	TranscriptionASRNoLM       string
-	TranscriptionWhisperLocal  string
-	TranscriptionWhisperOpenAI string
-}
-
 type FileListResult struct {
 	Files []AudioFile `json:"files"`
 	Total int64       `json:"total"`
@@ -111,7 +117,8 @@ type FileListResult struct {
 }
 
 type DB struct {
-	conn *sql.DB
+	conn   *sql.DB
+	driver string // DriverMySQL или DriverSQLite
 }
 
 func New(host string, port int, user, password, dbname string) (*DB, error) {
@@ -131,13 +138,23 @@ func New(host string, port int, user, password, dbname string) (*DB, error) {
 		return nil, err
 	}
 
-	return &DB{conn: conn}, nil
+	return &DB{conn: conn, driver: DriverMySQL}, nil
 }
 
 func (d *DB) DB() *sql.DB {
 	return d.conn
 }
 
+// Driver — имя SQL драйвера (mysql / sqlite3)
+func (d *DB) Driver() string {
+	return d.driver
+}
+
+// Segments — репозиторий сегментов pyannote на том же соединении
+func (d *DB) Segments() segment.Store {
+	return segment.NewRepository(d.conn)
+}
+
 func (db *DB) Close() error {
 	return db.conn.Close()
 }
@@ -160,64 +177,21 @@ func (db *DB) Insert(af *AudioFile) (int64, error) {
 		(user_id, chapter_id, file_path, file_hash, duration_sec, 
 		 snr_db, snr_sox, snr_wada, noise_level, rms_db,
 		 sample_rate, channels, bit_depth, file_size, audio_metadata, 
-		 transcription_original, asr_status, asr_nolm_status, whisper_local_status, whisper_openai_status, review_status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending', 'pending', 'pending', 'pending', 'pending')`,
+		 transcription_original, loudness_lufs, loudness_range, true_peak_db,
+		 segment_start, segment_end, source_key, transcription_source, duplicate_of, duplicate_score, review_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending')`,
 		af.UserID, af.ChapterID, af.FilePath, af.FileHash, af.DurationSec,
 		af.SNRDB, af.SNRSox, af.SNRWada, af.NoiseLevel, af.RMSDB,
 		af.SampleRate, af.Channels, af.BitDepth, af.FileSize,
-		af.AudioMetadata, af.TranscriptionOriginal)
+		af.AudioMetadata, af.TranscriptionOriginal, af.LoudnessLUFS, af.LoudnessRange, af.TruePeakDB,
+		af.SegmentStart, af.SegmentEnd, nullString(af.SourceKey), af.TranscriptionOriginal,
+		af.DuplicateOf, af.DuplicateScore)
 	if err != nil {
 		return 0, err
 	}
 	return res.LastInsertId()
 }
 
-func (db *DB) UpdateError(id int64, errMsg string) error {
-	_, err := db.conn.Exec(`
-		UPDATE audio_files SET asr_status = 'error', 
-		audio_metadata = JSON_SET(COALESCE(audio_metadata, '{}'), '$.error', ?)
-		WHERE id = ?`, errMsg, id)
-	return err
-}
-
-func (db *DB) GetPending(limit int) ([]AudioFile, error) {
-	query := `
-		SELECT id, file_path, file_hash, transcription_original 
-		FROM audio_files 
-		WHERE asr_status = 'pending'`
-
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-	}
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var files []AudioFile
-	for rows.Next() {
-		var af AudioFile
-		if err := rows.Scan(&af.ID, &af.FilePath, &af.FileHash, &af.TranscriptionOriginal); err != nil {
-			return nil, err
-		}
-		files = append(files, af)
-	}
-	return files, nil
-}
-
-func (db *DB) Stats() (total, pending, processed, errors int, err error) {
-	err = db.conn.QueryRow(`
-		SELECT 
-			COUNT(*),
-			COALESCE(SUM(CASE WHEN asr_status = 'pending' THEN 1 ELSE 0 END), 0),
-			COALESCE(SUM(CASE WHEN asr_status = 'processed' THEN 1 ELSE 0 END), 0),
-			COALESCE(SUM(CASE WHEN asr_status = 'error' THEN 1 ELSE 0 END), 0)
-		FROM audio_files`).Scan(&total, &pending, &processed, &errors)
-	return
-}
-
 func (db *DB) GetSpeakers() ([]string, error) {
 	rows, err := db.conn.Query("SELECT DISTINCT user_id FROM audio_files ORDER BY user_id")
 	if err != nil {
@@ -236,221 +210,36 @@ func (db *DB) GetSpeakers() ([]string, error) {
 	return speakers, nil
 }
 
-// StatsExtended возвращает расширенную статистику включая верификацию и все pending
+// StatsExtended возвращает расширенную статистику: верификация и по каждому движку
 func (db *DB) StatsExtended() (map[string]interface{}, error) {
 	result := make(map[string]interface{})
 
-	var total, pending, processed, errors int
-	var verified, needsReview int
-	var pendingNoLM, processedNoLM int
-	var pendingWhisperLocal, processedWhisperLocal int
-	var pendingWhisperOpenAI, processedWhisperOpenAI int
+	var total, verified, needsReview int
 
-	db.conn.QueryRow(`
+	err := db.conn.QueryRow(`
         SELECT 
             COUNT(*),
-            COALESCE(SUM(CASE WHEN asr_status = 'pending' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN asr_status = 'processed' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN asr_status = 'error' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN operator_verified = 1 THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN wer > 0.15 AND operator_verified = 0 THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN asr_nolm_status = 'pending' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN asr_nolm_status = 'processed' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN whisper_local_status = 'pending' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN whisper_local_status = 'processed' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN whisper_openai_status = 'pending' THEN 1 ELSE 0 END), 0),
-            COALESCE(SUM(CASE WHEN whisper_openai_status = 'processed' THEN 1 ELSE 0 END), 0)
-        FROM audio_files`).Scan(&total, &pending, &processed, &errors, &verified, &needsReview,
-		&pendingNoLM, &processedNoLM, &pendingWhisperLocal, &processedWhisperLocal,
-		&pendingWhisperOpenAI, &processedWhisperOpenAI)
-
-	result["total"] = total
-	result["pending"] = pending
-	result["processed"] = processed
-	result["errors"] = errors
-	result["verified"] = verified
-	result["needs_review"] = needsReview
-	result["pending_nolm"] = pendingNoLM
-	result["processed_nolm"] = processedNoLM
-	result["pending_whisper_local"] = pendingWhisperLocal
-	result["processed_whisper_local"] = processedWhisperLocal
-	result["pending_whisper_openai"] = pendingWhisperOpenAI
-	result["processed_whisper_openai"] = processedWhisperOpenAI
-
-	return result, nil
-}
-
-func (db *DB) AvgMetrics() (avgWER, avgCER float64, err error) {
-	err = db.conn.QueryRow(`
-		SELECT 
-			COALESCE(AVG(wer), 0),
-			COALESCE(AVG(cer), 0)
-		FROM audio_files 
-		WHERE asr_status = 'processed'`).Scan(&avgWER, &avgCER)
-	return
-}
-
-// AvgMetricsAll - обновлённая версия с NoLM метриками
-func (db *DB) AvgMetricsAll_old() (map[string]float64, error) {
-	result := make(map[string]float64)
-
-	var kaldiWer, kaldiCer float64
-	var kaldiNoLMWer, kaldiNoLMCer float64
-	var whisperLocalWer, whisperLocalCer float64
-	var whisperOpenaiWer, whisperOpenaiCer float64
-
-	// Kaldi
-	db.conn.QueryRow(`
-        SELECT COALESCE(AVG(wer), 0), COALESCE(AVG(cer), 0)
-        FROM audio_files 
-        WHERE asr_status = 'processed'`).Scan(&kaldiWer, &kaldiCer)
-
-	// Kaldi NoLM
-	db.conn.QueryRow(`
-        SELECT COALESCE(AVG(wer_nolm), 0), COALESCE(AVG(cer_nolm), 0)
-        FROM audio_files 
-        WHERE asr_nolm_status = 'processed'`).Scan(&kaldiNoLMWer, &kaldiNoLMCer)
-
-	// Whisper Local
-	db.conn.QueryRow(`
-        SELECT COALESCE(AVG(wer_whisper_local), 0), COALESCE(AVG(cer_whisper_local), 0)
-        FROM audio_files 
-        WHERE whisper_local_status = 'processed'`).Scan(&whisperLocalWer, &whisperLocalCer)
-
-	// Whisper OpenAI
-	db.conn.QueryRow(`
-        SELECT COALESCE(AVG(wer_whisper_openai), 0), COALESCE(AVG(cer_whisper_openai), 0)
-        FROM audio_files 
-        WHERE whisper_openai_status = 'processed'`).Scan(&whisperOpenaiWer, &whisperOpenaiCer)
-
-	result["kaldi_wer"] = kaldiWer
-	result["kaldi_cer"] = kaldiCer
-	result["kaldi_nolm_wer"] = kaldiNoLMWer
-	result["kaldi_nolm_cer"] = kaldiNoLMCer
-	result["whisper_local_wer"] = whisperLocalWer
-	result["whisper_local_cer"] = whisperLocalCer
-	result["whisper_openai_wer"] = whisperOpenaiWer
-	result["whisper_openai_cer"] = whisperOpenaiCer
-
-	return result, nil
-}
-
-func (db *DB) AvgMetricsAll() (map[string]float64, error) {
-	result := make(map[string]float64)
-
-	var kaldiWer, kaldiCer float64
-	var kaldiNoLMWer, kaldiNoLMCer float64
-	var whisperLocalWer, whisperLocalCer float64
-	var whisperOpenaiWer, whisperOpenaiCer float64
-
-	db.conn.QueryRow(`
-        SELECT 
-            COALESCE(AVG(CASE WHEN asr_status = 'processed' THEN wer END), 0),
-            COALESCE(AVG(CASE WHEN asr_status = 'processed' THEN cer END), 0),
-            COALESCE(AVG(CASE WHEN asr_nolm_status = 'processed' THEN wer_nolm END), 0),
-            COALESCE(AVG(CASE WHEN asr_nolm_status = 'processed' THEN cer_nolm END), 0),
-            COALESCE(AVG(CASE WHEN whisper_local_status = 'processed' THEN wer_whisper_local END), 0),
-            COALESCE(AVG(CASE WHEN whisper_local_status = 'processed' THEN cer_whisper_local END), 0),
-            COALESCE(AVG(CASE WHEN whisper_openai_status = 'processed' THEN wer_whisper_openai END), 0),
-            COALESCE(AVG(CASE WHEN whisper_openai_status = 'processed' THEN cer_whisper_openai END), 0)
-        FROM audio_files
-    `).Scan(&kaldiWer, &kaldiCer, &kaldiNoLMWer, &kaldiNoLMCer,
-		&whisperLocalWer, &whisperLocalCer, &whisperOpenaiWer, &whisperOpenaiCer)
-
-	result["kaldi_wer"] = kaldiWer
-	result["kaldi_cer"] = kaldiCer
-	result["kaldi_nolm_wer"] = kaldiNoLMWer
-	result["kaldi_nolm_cer"] = kaldiNoLMCer
-	result["whisper_local_wer"] = whisperLocalWer
-	result["whisper_local_cer"] = whisperLocalCer
-	result["whisper_openai_wer"] = whisperOpenaiWer
-	result["whisper_openai_cer"] = whisperOpenaiCer
-
-	return result, nil
-}
-
-// GetFileForRecalc - обновлённая версия с NoLM
-func (db *DB) GetFileForRecalc(id int64) (*AudioFileRecalc, error) {
-	var af AudioFileRecalc
-	err := db.conn.QueryRow(`
-        SELECT id, 
-               COALESCE(transcription_original, ''),
-               COALESCE(transcription_asr, ''),
-               COALESCE(transcription_asr_nolm, ''),
-               COALESCE(transcription_whisper_local, ''),
-               COALESCE(transcription_whisper_openai, '')
-        FROM audio_files WHERE id = ?`, id).Scan(
-		&af.ID, &af.TranscriptionOriginal, &af.TranscriptionASR,
-		&af.TranscriptionASRNoLM,
-		&af.TranscriptionWhisperLocal, &af.TranscriptionWhisperOpenAI)
+            COALESCE(SUM(CASE WHEN a.operator_verified = 1 THEN 1 ELSE 0 END), 0),
+            COALESCE(SUM(CASE WHEN t.wer > 0.15 AND a.operator_verified = 0 THEN 1 ELSE 0 END), 0)
+        FROM audio_files a
+        LEFT JOIN transcriptions t
+               ON t.audio_file_id = a.id AND t.engine = ? AND t.model_version = ''
+              AND t.status = 'processed'`, PrimaryEngine).Scan(&total, &verified, &needsReview)
 	if err != nil {
 		return nil, err
 	}
-	return &af, nil
-}
 
-// GetAllForRecalc - обновлённая версия с NoLM
-func (db *DB) GetAllForRecalc() ([]AudioFileRecalc, error) {
-	rows, err := db.conn.Query(`
-        SELECT id, 
-               COALESCE(transcription_original, ''),
-               COALESCE(transcription_asr, ''),
-               COALESCE(transcription_asr_nolm, ''),
-               COALESCE(transcription_whisper_local, ''),
-               COALESCE(transcription_whisper_openai, '')
-        FROM audio_files 
-        WHERE asr_status = 'processed' 
-           OR asr_nolm_status = 'processed'
-           OR whisper_local_status = 'processed' 
-           OR whisper_openai_status = 'processed'`)
+	engines, err := db.TranscriptionStats(total)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var files []AudioFileRecalc
-	for rows.Next() {
-		var af AudioFileRecalc
-		if err := rows.Scan(&af.ID, &af.TranscriptionOriginal, &af.TranscriptionASR,
-			&af.TranscriptionASRNoLM,
-			&af.TranscriptionWhisperLocal, &af.TranscriptionWhisperOpenAI); err != nil {
-			return nil, err
-		}
-		files = append(files, af)
-	}
-	return files, nil
-}
 
-// ============================================================
-// МЕТОДЫ ДЛЯ NoLM (Kaldi без языковой модели)
-// ============================================================
-
-// GetPendingNoLM возвращает файлы для обработки Kaldi без LM
-func (db *DB) GetPendingNoLM(limit int) ([]AudioFile, error) {
-	query := `
-		SELECT id, file_path, file_hash, transcription_original 
-		FROM audio_files 
-		WHERE asr_nolm_status = 'pending'`
-
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT %d", limit)
-	}
-
-	rows, err := db.conn.Query(query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
+	result["total"] = total
+	result["verified"] = verified
+	result["needs_review"] = needsReview
+	result["engines"] = engines
 
-	var files []AudioFile
-	for rows.Next() {
-		var af AudioFile
-		if err := rows.Scan(&af.ID, &af.FilePath, &af.FileHash, &af.TranscriptionOriginal); err != nil {
-			return nil, err
-		}
-		files = append(files, af)
-	}
-	return files, nil
+	return result, nil
 }
 
 // ============================================================
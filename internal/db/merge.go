@@ -69,6 +69,16 @@ func (db *DB) AddToMergeQueue(idsString string) (int64, error) {
 	return res.LastInsertId()
 }
 
+// ResetStaleMergeQueue возвращает в pending записи, застрявшие в processing
+// (сервер упал посреди merge)
+func (db *DB) ResetStaleMergeQueue() (int64, error) {
+	res, err := db.conn.Exec("UPDATE merge_queue SET status = 'pending' WHERE status = 'processing'")
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // GetPendingMergeQueue возвращает pending записи из очереди
 func (db *DB) GetPendingMergeQueue(limit int) ([]MergeQueueItem, error) {
 	query := `
@@ -123,7 +133,7 @@ func (db *DB) UpdateMergeQueueCompleted(id int64, mergedFileID int64, filePath s
 			merged_file_path = ?,
 			merged_duration = ?,
 			merged_transcription = ?,
-			processed_at = NOW()
+			processed_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, mergedFileID, filePath, duration, transcription, id)
 	return err
@@ -135,7 +145,7 @@ func (db *DB) UpdateMergeQueueError(id int64, errMsg string) error {
 		UPDATE merge_queue SET 
 			status = 'error',
 			error_message = ?,
-			processed_at = NOW()
+			processed_at = CURRENT_TIMESTAMP
 		WHERE id = ?
 	`, errMsg, id)
 	return err
@@ -233,12 +243,14 @@ func (db *DB) CheckFilesForMerge(ids []int64) (string, []string, error) {
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, user_id, active, wer, asr_status, operator_verified, merged_id 
-		FROM audio_files 
-		WHERE id IN (%s)
+		SELECT a.id, a.user_id, a.active, t.wer, COALESCE(t.status, 'pending'), a.operator_verified, a.merged_id 
+		FROM audio_files a
+		LEFT JOIN transcriptions t
+		       ON t.audio_file_id = a.id AND t.engine = ? AND t.model_version = ''
+		WHERE a.id IN (%s)
 	`, strings.Join(placeholders, ","))
 
-	rows, err := db.conn.Query(query, args...)
+	rows, err := db.conn.Query(query, append([]interface{}{PrimaryEngine}, args...)...)
 	if err != nil {
 		return "", nil, err
 	}
@@ -301,10 +313,8 @@ func (db *DB) InsertMerged(af *AudioFile, parentIDs string) (int64, error) {
 		(user_id, chapter_id, file_path, file_hash, duration_sec, 
 		 snr_db, snr_sox, snr_wada, noise_level, rms_db,
 		 sample_rate, channels, bit_depth, file_size, audio_metadata, 
-		 transcription_original, parent_ids,
-		 asr_status, asr_nolm_status, whisper_local_status, whisper_openai_status, review_status)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 
-		        'pending', 'pending', 'pending', 'pending', 'pending')`,
+		 transcription_original, parent_ids, review_status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 'pending')`,
 		af.UserID, af.ChapterID, af.FilePath, af.FileHash, af.DurationSec,
 		af.SNRDB, af.SNRSox, af.SNRWada, af.NoiseLevel, af.RMSDB,
 		af.SampleRate, af.Channels, af.BitDepth, af.FileSize,
@@ -319,7 +329,7 @@ func (db *DB) InsertMerged(af *AudioFile, parentIDs string) (int64, error) {
 func (db *DB) GetShortFilesBySpeaker(maxDuration float64, limit int) (map[string][]AudioFile, error) {
 	query := `
 		SELECT id, user_id, chapter_id, file_path, duration_sec, 
-		       transcription_original, has_trailing_silence
+		       transcription_original, has_trailing_silence, segment_start, segment_end
 		FROM audio_files 
 		WHERE duration_sec < ? AND parent_ids IS NULL
 		ORDER BY user_id, id`
@@ -338,10 +348,12 @@ func (db *DB) GetShortFilesBySpeaker(maxDuration float64, limit int) (map[string
 	for rows.Next() {
 		var af AudioFile
 		var hasSilence sql.NullBool
+		var segStart, segEnd sql.NullFloat64
 		if err := rows.Scan(&af.ID, &af.UserID, &af.ChapterID, &af.FilePath,
-			&af.DurationSec, &af.TranscriptionOriginal, &hasSilence); err != nil {
+			&af.DurationSec, &af.TranscriptionOriginal, &hasSilence, &segStart, &segEnd); err != nil {
 			return nil, err
 		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
 		if hasSilence.Valid {
 			af.HasTrailingSilence = hasSilence.Bool
 		}
@@ -360,13 +372,17 @@ func (db *DB) UpdateSilenceStatus(id int64, hasSilence bool, silenceAdded bool)
 	return err
 }
 
-// UpdateFilePath обновляет путь к файлу (после добавления/удаления тишины)
+// UpdateFilePath обновляет путь к файлу (после добавления/удаления тишины);
+// виртуальный отрезок после правки становится обычным файлом. Отпечаток старого звука удаляется
 func (db *DB) UpdateFilePath(id int64, newPath string, newDuration float64, newHash string) error {
 	_, err := db.conn.Exec(`
 		UPDATE audio_files 
-		SET file_path = ?, duration_sec = ?, file_hash = ?
+		SET file_path = ?, duration_sec = ?, file_hash = ?, segment_start = NULL, segment_end = NULL
 		WHERE id = ?`, newPath, newDuration, newHash, id)
-	return err
+	if err != nil {
+		return err
+	}
+	return db.dropFingerprint(id)
 }
 
 // UpdateMergedID помечает файлы как объединённые
@@ -634,12 +650,14 @@ func (db *DB) AddBatchToMergeQueue(idsStrings []string) ([]map[string]interface{
 		}
 
 		query := fmt.Sprintf(`
-			SELECT id, user_id, active, wer, asr_status, operator_verified, COALESCE(merged_id, 0) 
-			FROM audio_files 
-			WHERE id IN (%s)
+			SELECT a.id, a.user_id, a.active, t.wer, t.status, a.operator_verified, COALESCE(a.merged_id, 0) 
+			FROM audio_files a
+			LEFT JOIN transcriptions t
+			       ON t.audio_file_id = a.id AND t.engine = ? AND t.model_version = ''
+			WHERE a.id IN (%s)
 		`, strings.Join(placeholders, ","))
 
-		rows, err := db.conn.Query(query, args...)
+		rows, err := db.conn.Query(query, append([]interface{}{PrimaryEngine}, args...)...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to fetch files: %w", err)
 		}
@@ -773,7 +791,7 @@ func (db *DB) AddBatchToMergeQueue(idsStrings []string) ([]map[string]interface{
 			return results, nil
 		}
 
-		firstID, _ := res.LastInsertId()
+		firstID, _ := db.firstInsertID(res, len(validItems))
 		for i, item := range validItems {
 			results[item.index]["queue_id"] = firstID + int64(i)
 		}
@@ -788,40 +806,28 @@ func (db *DB) GetFileIncludingInactive(id int64) (*AudioFile, error) {
 
 	// Nullable string поля
 	var noiseLevel, audioMetadata sql.NullString
-	var transcriptionASR, transcriptionASRNoLM sql.NullString
-	var transcriptionWhisperLocal, transcriptionWhisperOpenAI sql.NullString
 	var parentIDs sql.NullString
 
 	// Nullable числовые поля
 	var mergedID sql.NullInt64
-	var wer, cer, werNoLM, cerNoLM sql.NullFloat64
-	var werWhisperLocal, cerWhisperLocal sql.NullFloat64
-	var werWhisperOpenAI, cerWhisperOpenAI sql.NullFloat64
 	var snrDB, snrSox, snrWada, rmsDB sql.NullFloat64
+	var segStart, segEnd sql.NullFloat64
 
 	err := db.conn.QueryRow(`
 		SELECT id, user_id, chapter_id, file_path, file_hash, duration_sec,
 		       snr_db, snr_sox, snr_wada, noise_level, rms_db,
 		       sample_rate, channels, bit_depth, file_size, audio_metadata,
-		       transcription_original, transcription_asr, transcription_asr_nolm,
-		       transcription_whisper_local, transcription_whisper_openai,
-		       wer, cer, wer_nolm, cer_nolm, 
-		       wer_whisper_local, cer_whisper_local,
-		       wer_whisper_openai, cer_whisper_openai,
-		       asr_status, asr_nolm_status, whisper_local_status, whisper_openai_status,
-		       operator_verified, original_edited, active, merged_id, parent_ids
+		       transcription_original,
+		       operator_verified, original_edited, active, merged_id, parent_ids,
+		       segment_start, segment_end
 		FROM audio_files WHERE id = ?
 	`, id).Scan(
 		&file.ID, &file.UserID, &file.ChapterID, &file.FilePath, &file.FileHash, &file.DurationSec,
 		&snrDB, &snrSox, &snrWada, &noiseLevel, &rmsDB,
 		&file.SampleRate, &file.Channels, &file.BitDepth, &file.FileSize, &audioMetadata,
-		&file.TranscriptionOriginal, &transcriptionASR, &transcriptionASRNoLM,
-		&transcriptionWhisperLocal, &transcriptionWhisperOpenAI,
-		&wer, &cer, &werNoLM, &cerNoLM,
-		&werWhisperLocal, &cerWhisperLocal,
-		&werWhisperOpenAI, &cerWhisperOpenAI,
-		&file.ASRStatus, &file.ASRNoLMStatus, &file.WhisperLocalStatus, &file.WhisperOpenAIStatus,
+		&file.TranscriptionOriginal,
 		&file.OperatorVerified, &file.OriginalEdited, &file.Active, &mergedID, &parentIDs,
+		&segStart, &segEnd,
 	)
 	if err != nil {
 		return nil, err
@@ -834,18 +840,6 @@ func (db *DB) GetFileIncludingInactive(id int64) (*AudioFile, error) {
 	if audioMetadata.Valid {
 		file.AudioMetadata = audioMetadata.String
 	}
-	if transcriptionASR.Valid {
-		file.TranscriptionASR = transcriptionASR.String
-	}
-	if transcriptionASRNoLM.Valid {
-		file.TranscriptionASRNoLM = transcriptionASRNoLM.String
-	}
-	if transcriptionWhisperLocal.Valid {
-		file.TranscriptionWhisperLocal = transcriptionWhisperLocal.String
-	}
-	if transcriptionWhisperOpenAI.Valid {
-		file.TranscriptionWhisperOpenAI = transcriptionWhisperOpenAI.String
-	}
 	if parentIDs.Valid {
 		file.ParentIDs = parentIDs.String
 	}
@@ -864,32 +858,14 @@ func (db *DB) GetFileIncludingInactive(id int64) (*AudioFile, error) {
 	if rmsDB.Valid {
 		file.RMSDB = rmsDB.Float64
 	}
-	if wer.Valid {
-		file.WER = wer.Float64
-	}
-	if cer.Valid {
-		file.CER = cer.Float64
-	}
-	if werNoLM.Valid {
-		file.WERNoLM = werNoLM.Float64
-	}
-	if cerNoLM.Valid {
-		file.CERNoLM = cerNoLM.Float64
-	}
-	if werWhisperLocal.Valid {
-		file.WERWhisperLocal = werWhisperLocal.Float64
-	}
-	if cerWhisperLocal.Valid {
-		file.CERWhisperLocal = cerWhisperLocal.Float64
-	}
-	if werWhisperOpenAI.Valid {
-		file.WERWhisperOpenAI = werWhisperOpenAI.Float64
-	}
-	if cerWhisperOpenAI.Valid {
-		file.CERWhisperOpenAI = cerWhisperOpenAI.Float64
+	file.SegmentStart, file.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+
+	files := []AudioFile{file}
+	if err := db.attachTranscriptions(files); err != nil {
+		return nil, err
 	}
 
-	return &file, nil
+	return &files[0], nil
 }
 
 // DeleteMergeQueueItem удаляет одну запись из очереди
@@ -0,0 +1,401 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Миграции лежат отдельно для каждого драйвера: migrations/mysql, migrations/sqlite.
+// Версии в обоих каталогах должны совпадать
+//
+//go:embed migrations/mysql/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// migrationDir — каталог миграций для драйвера
+func migrationDir(driver string) string {
+	if driver == DriverSQLite {
+		return "migrations/sqlite"
+	}
+	return "migrations/mysql"
+}
+
+// migrationLock — имя MySQL GET_LOCK, чтобы два сервера не мигрировали одновременно
+const (
+	migrationLock        = "audio_labeler_schema_migrations"
+	migrationLockTimeout = 60 // сек
+)
+
+// baselineVersion — базовая схема: принимает существующие базы и не откатывается
+const baselineVersion = 1
+
+// Migration — одна версия схемы: NNNN_name.up.sql + NNNN_name.down.sql
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationState — состояние миграции в базе
+type MigrationState struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// LoadMigrations читает встроенные миграции драйвера, отсортированные по версии
+func LoadMigrations(driver string) ([]Migration, error) {
+	dir := migrationDir(driver)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		name := e.Name()
+
+		var direction string
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction = "up"
+		case strings.HasSuffix(name, ".down.sql"):
+			direction = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(name, "."+direction+".sql")
+		versionStr, title, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: expected NNNN_name.%s.sql", name, direction)
+		}
+		version, err := strconv.ParseInt(versionStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: bad version: %w", name, err)
+		}
+
+		data, err := migrationFiles.ReadFile(path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: title}
+			byVersion[version] = m
+		} else if m.Name != title {
+			return nil, fmt.Errorf("migration %d: name mismatch %q vs %q", version, m.Name, title)
+		}
+
+		if direction == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s: missing up.sql", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, nil
+}
+
+// splitStatements режет SQL файл на отдельные запросы (драйвер не исполняет несколько за раз).
+// Разделитель — ';' в конце строки, строки-комментарии "--" пропускаются
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		if strings.HasSuffix(trimmed, ";") {
+			stmt := strings.TrimSuffix(strings.TrimSpace(current.String()), ";")
+			statements = append(statements, stmt)
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+// withMigrationLock держит GET_LOCK на отдельном соединении, пока выполняется fn.
+// В SQLite GET_LOCK нет — там каждая миграция идёт в своей транзакции (см. applyMigration)
+func (db *DB) withMigrationLock(fn func(ctx context.Context, conn *sql.Conn) error) error {
+	ctx := context.Background()
+
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if db.driver != DriverSQLite {
+		if err := acquireMigrationLock(ctx, conn); err != nil {
+			return err
+		}
+		defer conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", migrationLock)
+	}
+
+	if _, err := conn.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+		)`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	return fn(ctx, conn)
+}
+
+func acquireMigrationLock(ctx context.Context, conn *sql.Conn) error {
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", migrationLock, migrationLockTimeout).Scan(&got); err != nil {
+		return fmt.Errorf("get migration lock: %w", err)
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return fmt.Errorf("migration lock %q is held by another process", migrationLock)
+	}
+	return nil
+}
+
+// queryer — *sql.DB или *sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func appliedMigrations(ctx context.Context, q queryer) (map[int64]time.Time, error) {
+	rows, err := q.QueryContext(ctx, "SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version int64
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// execer — *sql.Conn или *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func runMigrationScript(ctx context.Context, conn execer, script string) error {
+	for _, stmt := range splitStatements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\n--- statement:\n%s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// applyMigration выполняет up или down скрипт и отмечает это в schema_migrations.
+// В SQLite DDL транзакционный: скрипт и отметка идут одной транзакцией
+// (_txlock=immediate — блокировка записи берётся сразу), упавшая миграция откатывается целиком,
+// а второй процесс ждёт busy_timeout и видит, что версия уже применена — false.
+// DDL в MySQL коммитится неявно: при ошибке версия не записывается,
+// поэтому миграции пишутся идемпотентно (IF NOT EXISTS / INSERT IGNORE)
+func (db *DB) applyMigration(ctx context.Context, conn *sql.Conn, m Migration, up bool) (bool, error) {
+	script, mark := m.Down, "DELETE FROM schema_migrations WHERE version = ?"
+	args := []interface{}{m.Version}
+	if up {
+		script, mark = m.Up, "INSERT INTO schema_migrations (version, name) VALUES (?, ?)"
+		args = append(args, m.Name)
+	}
+
+	if db.driver != DriverSQLite {
+		if err := runMigrationScript(ctx, conn, script); err != nil {
+			return false, err
+		}
+		_, err := conn.ExecContext(ctx, mark, args...)
+		return err == nil, err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations WHERE version = ?", m.Version).Scan(&n); err != nil {
+		return false, err
+	}
+	if (n > 0) == up {
+		return false, nil
+	}
+	if err := runMigrationScript(ctx, tx, script); err != nil {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, mark, args...); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// MigrateUp применяет все непримененные миграции до target включительно (0 — до последней).
+// Возвращает список применённых
+func (db *DB) MigrateUp(target int64) ([]Migration, error) {
+	migrations, err := LoadMigrations(db.driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = db.withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range migrations {
+			if target > 0 && m.Version > target {
+				break
+			}
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+
+			log.Printf("→ Migration %04d_%s up", m.Version, m.Name)
+			ok, err := db.applyMigration(ctx, conn, m, true)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if ok {
+				done = append(done, m)
+			}
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrateDown откатывает последние steps применённых миграций; базовая схема
+// (baselineVersion) не откатывается никогда
+func (db *DB) MigrateDown(steps int) ([]Migration, error) {
+	migrations, err := LoadMigrations(db.driver)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	err = db.withMigrationLock(func(ctx context.Context, conn *sql.Conn) error {
+		applied, err := appliedMigrations(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			m := migrations[i]
+			if _, ok := applied[m.Version]; !ok {
+				continue
+			}
+			if m.Version <= baselineVersion {
+				return fmt.Errorf("migration %04d_%s is the baseline and cannot be rolled back", m.Version, m.Name)
+			}
+			if m.Down == "" {
+				return fmt.Errorf("migration %04d_%s has no down.sql", m.Version, m.Name)
+			}
+
+			log.Printf("← Migration %04d_%s down", m.Version, m.Name)
+			ok, err := db.applyMigration(ctx, conn, m, false)
+			if err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+			}
+			if !ok {
+				break
+			}
+			done = append(done, m)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// MigrationStatus возвращает все встроенные миграции с отметкой, применены ли они
+func (db *DB) MigrationStatus() ([]MigrationState, error) {
+	migrations, err := LoadMigrations(db.driver)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	query := `SELECT COUNT(*) FROM information_schema.tables
+		WHERE table_schema = DATABASE() AND table_name = 'schema_migrations'`
+	if db.driver == DriverSQLite {
+		query = `SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`
+	}
+
+	var exists int
+	err = db.conn.QueryRowContext(ctx, query).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[int64]time.Time)
+	if exists > 0 {
+		if applied, err = appliedMigrations(ctx, db.conn); err != nil {
+			return nil, err
+		}
+	}
+
+	states := make([]MigrationState, len(migrations))
+	for i, m := range migrations {
+		states[i] = MigrationState{Version: m.Version, Name: m.Name}
+		if at, ok := applied[m.Version]; ok {
+			states[i].Applied = true
+			states[i].AppliedAt = &at
+		}
+	}
+	return states, nil
+}
+
+// PendingMigrations — число непримененных миграций
+func (db *DB) PendingMigrations() (int, error) {
+	states, err := db.MigrationStatus()
+	if err != nil {
+		return 0, err
+	}
+	pending := 0
+	for _, s := range states {
+		if !s.Applied {
+			pending++
+		}
+	}
+	return pending, nil
+}
@@ -0,0 +1,137 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testDB — пустая SQLite база со всеми миграциями
+func testDB(t *testing.T) *DB {
+	t.Helper()
+	database, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+func TestMigrateRoundTrip(t *testing.T) {
+	database := testDB(t)
+	migrations, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: "/data/a.wav", FileHash: "h"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Откат до нуля останавливается на базовой схеме: корпус остаётся
+	done, err := database.MigrateDown(len(migrations) + 1)
+	if err == nil || !strings.Contains(err.Error(), "baseline") {
+		t.Errorf("down past baseline: %v", err)
+	}
+	if len(done) != len(migrations)-1 {
+		t.Errorf("rolled back %d of %d", len(done), len(migrations)-1)
+	}
+	if pending, err := database.PendingMigrations(); err != nil || pending != len(migrations)-1 {
+		t.Errorf("pending after down: %d %v", pending, err)
+	}
+	var n int
+	if err := database.conn.QueryRow("SELECT COUNT(*) FROM audio_files WHERE id = ?", id).Scan(&n); err != nil || n != 1 {
+		t.Fatalf("audio_files after down: %d %v", n, err)
+	}
+
+	done, err = database.MigrateUp(0)
+	if err != nil || len(done) != len(migrations)-1 {
+		t.Fatalf("up again: %d %v", len(done), err)
+	}
+	if f, err := database.GetFile(id); err != nil || f.FilePath != "/data/a.wav" {
+		t.Errorf("file after up: %+v %v", f, err)
+	}
+}
+
+func TestMigrationRollsBackOnError(t *testing.T) {
+	database := testDB(t)
+	ctx := context.Background()
+	conn, err := database.conn.Conn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Второй запрос падает: первый тоже откатывается, версия не записывается
+	broken := Migration{Version: 9999, Name: "broken", Up: "CREATE TABLE half_done (id INTEGER);\nSELECT missing FROM nowhere;"}
+	if _, err := database.applyMigration(ctx, conn, broken, true); err == nil {
+		t.Fatal("broken migration applied")
+	}
+	var n int
+	if err := database.conn.QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'`).Scan(&n); err != nil || n != 0 {
+		t.Errorf("half_done after failed migration: %d %v", n, err)
+	}
+	if err := database.conn.QueryRow("SELECT COUNT(*) FROM schema_migrations WHERE version = 9999").Scan(&n); err != nil || n != 0 {
+		t.Errorf("schema_migrations after failed migration: %d %v", n, err)
+	}
+
+	// Уже применённая (другим процессом) версия пропускается
+	migrations, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := migrations[len(migrations)-1]
+	if ok, err := database.applyMigration(ctx, conn, last, true); ok || err != nil {
+		t.Errorf("reapply %04d: %v %v", last.Version, ok, err)
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{"empty", "-- только комментарий\n\n", nil},
+		{"two", "CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n", []string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"}},
+		{"multiline", "ALTER TABLE a\n  ADD COLUMN x INT;\n", []string{"ALTER TABLE a\n  ADD COLUMN x INT"}},
+		{"comments between", "-- a\nSELECT 1;\n-- b\nSELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"no trailing semicolon", "SELECT 1;\nSELECT 2", []string{"SELECT 1", "SELECT 2"}},
+		{"semicolon inside line", "INSERT INTO a VALUES ('x;y');\n", []string{"INSERT INTO a VALUES ('x;y')"}},
+	}
+	for _, tt := range tests {
+		got := splitStatements(tt.script)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMigrationsMatchAcrossDrivers(t *testing.T) {
+	mysql, err := LoadMigrations(DriverMySQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := LoadMigrations(DriverSQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mysql) != len(sqlite) {
+		t.Fatalf("mysql has %d migrations, sqlite %d", len(mysql), len(sqlite))
+	}
+	for i := range mysql {
+		m, s := mysql[i], sqlite[i]
+		if m.Version != int64(i+1) || m.Version != s.Version || m.Name != s.Name {
+			t.Errorf("migration %d: mysql %04d_%s, sqlite %04d_%s", i, m.Version, m.Name, s.Version, s.Name)
+		}
+		if m.Down == "" || s.Down == "" {
+			t.Errorf("%04d_%s: missing down.sql", m.Version, m.Name)
+		}
+	}
+}
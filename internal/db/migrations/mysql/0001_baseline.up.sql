@@ -0,0 +1,97 @@
+-- Базовая схема: то, что раньше создавалось руками.
+-- IF NOT EXISTS — чтобы на существующей базе миграция прошла без изменений.
+
+CREATE TABLE IF NOT EXISTS audio_files (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    user_id VARCHAR(64) NOT NULL,
+    chapter_id VARCHAR(64) NOT NULL,
+    chapter_id_int BIGINT NULL,
+    file_path VARCHAR(1024) NOT NULL,
+    file_hash VARCHAR(64) NOT NULL DEFAULT '',
+    duration_sec DOUBLE NOT NULL DEFAULT 0,
+    sample_rate INT NULL DEFAULT 0,
+    channels INT NULL DEFAULT 0,
+    bit_depth INT NULL DEFAULT 0,
+    file_size BIGINT NULL DEFAULT 0,
+
+    snr_db DOUBLE NULL,
+    snr_sox DOUBLE NULL,
+    snr_wada DOUBLE NULL,
+    snr_spectral DOUBLE NULL,
+    noise_level VARCHAR(20) NULL,
+    rms_db DOUBLE NULL,
+    audio_quality_score DOUBLE NULL,
+    audio_quality_level VARCHAR(20) NULL,
+    audio_metadata LONGTEXT NULL,
+
+    transcription_original TEXT NULL,
+
+    -- Legacy per-engine колонки (данные перенесены в transcriptions, см. 0002)
+    transcription_asr TEXT NULL,
+    wer DOUBLE NULL,
+    cer DOUBLE NULL,
+    asr_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    processed_at TIMESTAMP NULL,
+    transcription_asr_nolm TEXT NULL,
+    wer_nolm DOUBLE NULL,
+    cer_nolm DOUBLE NULL,
+    asr_nolm_status VARCHAR(20) NULL DEFAULT 'pending',
+    transcription_whisper_local TEXT NULL,
+    wer_whisper_local DOUBLE NULL,
+    cer_whisper_local DOUBLE NULL,
+    whisper_local_status VARCHAR(20) NULL DEFAULT 'pending',
+    transcription_whisper_openai TEXT NULL,
+    wer_whisper_openai DOUBLE NULL,
+    cer_whisper_openai DOUBLE NULL,
+    whisper_openai_status VARCHAR(20) NULL DEFAULT 'pending',
+
+    review_status VARCHAR(20) NULL DEFAULT 'pending',
+    operator_verified TINYINT(1) NOT NULL DEFAULT 0,
+    verified_at TIMESTAMP NULL,
+    original_edited TINYINT(1) NOT NULL DEFAULT 0,
+
+    has_trailing_silence TINYINT(1) NULL,
+    silence_added TINYINT(1) NOT NULL DEFAULT 0,
+    parent_ids VARCHAR(1024) NULL,
+    merged_id BIGINT NULL DEFAULT 0,
+    split_source_id BIGINT NULL,
+    active TINYINT(1) NOT NULL DEFAULT 1,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+
+    INDEX idx_file_path (file_path(255)),
+    INDEX idx_file_hash (file_hash),
+    INDEX idx_user_chapter (user_id, chapter_id),
+    INDEX idx_parent_ids (parent_ids(191)),
+    INDEX idx_active (active)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS merge_queue (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    ids_string VARCHAR(1024) NOT NULL,
+    user_id VARCHAR(64) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    merged_file_id BIGINT NULL,
+    merged_file_path VARCHAR(1024) NULL,
+    merged_duration DOUBLE NULL,
+    merged_transcription TEXT NULL,
+    error_message TEXT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP NULL,
+
+    INDEX idx_status (status),
+    INDEX idx_ids_string (ids_string(191))
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS audio_segments (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    audio_file_id INT NOT NULL,
+    start_time DECIMAL(10,3) NOT NULL,
+    end_time DECIMAL(10,3) NOT NULL,
+    speaker VARCHAR(50),
+    has_overlap TINYINT(1) DEFAULT 0,
+    selected TINYINT(1) DEFAULT 0,
+    transcript TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_audio_file (audio_file_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
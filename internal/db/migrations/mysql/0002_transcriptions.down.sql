@@ -0,0 +1,2 @@
+-- Legacy колонки audio_files не трогались, поэтому откат — просто удаление таблицы
+DROP TABLE IF EXISTS transcriptions;
@@ -0,0 +1,41 @@
+-- Результаты ASR движков: одна строка на (файл, движок, версия модели)
+
+CREATE TABLE IF NOT EXISTS transcriptions (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    audio_file_id BIGINT NOT NULL,
+    engine VARCHAR(64) NOT NULL,
+    model_version VARCHAR(128) NOT NULL DEFAULT '',
+    text TEXT,
+    wer DOUBLE,
+    cer DOUBLE,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    error TEXT,
+    rtf DOUBLE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_file_engine_version (audio_file_id, engine, model_version),
+    INDEX idx_engine_status (engine, model_version, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- Перенос данных из legacy колонок audio_files (model_version = '').
+-- Kaldi писал текст ошибки в audio_metadata.error, остальные — никуда
+
+INSERT IGNORE INTO transcriptions (audio_file_id, engine, model_version, text, wer, cer, status, error)
+SELECT id, 'kaldi', '', transcription_asr, wer, cer, asr_status,
+       CASE WHEN asr_status = 'error' THEN JSON_UNQUOTE(JSON_EXTRACT(audio_metadata, '$.error')) END
+FROM audio_files
+WHERE asr_status IN ('processed', 'error');
+
+INSERT IGNORE INTO transcriptions (audio_file_id, engine, model_version, text, wer, cer, status)
+SELECT id, 'kaldi-nolm', '', transcription_asr_nolm, wer_nolm, cer_nolm, asr_nolm_status
+FROM audio_files
+WHERE asr_nolm_status IN ('processed', 'error');
+
+INSERT IGNORE INTO transcriptions (audio_file_id, engine, model_version, text, wer, cer, status)
+SELECT id, 'whisper-local', '', transcription_whisper_local, wer_whisper_local, cer_whisper_local, whisper_local_status
+FROM audio_files
+WHERE whisper_local_status IN ('processed', 'error');
+
+INSERT IGNORE INTO transcriptions (audio_file_id, engine, model_version, text, wer, cer, status)
+SELECT id, 'whisper-openai', '', transcription_whisper_openai, wer_whisper_openai, cer_whisper_openai, whisper_openai_status
+FROM audio_files
+WHERE whisper_openai_status IN ('processed', 'error');
@@ -0,0 +1,23 @@
+-- Журнал пакетных задач (scan, asr, merge, analyze): прогресс переживает рестарт,
+-- история остаётся для аудита
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    type VARCHAR(32) NOT NULL,
+    name VARCHAR(64) NOT NULL DEFAULT '',
+    params TEXT,
+    started_by VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'running',
+    total BIGINT NOT NULL DEFAULT 0,
+    processed BIGINT NOT NULL DEFAULT 0,
+    skipped BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    cursor_id BIGINT NOT NULL DEFAULT 0,
+    runs INT NOT NULL DEFAULT 1,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL,
+    INDEX idx_jobs_type_name (type, name),
+    INDEX idx_jobs_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS error_class;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS attempts;
+ALTER TABLE transcriptions CHANGE COLUMN IF EXISTS last_error error TEXT;
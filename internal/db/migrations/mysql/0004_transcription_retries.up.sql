@@ -0,0 +1,9 @@
+-- Повторы ASR: число попыток, последняя ошибка и её класс (transient / permanent).
+-- error -> last_error: текст последней ошибки сохраняется и после успешного повтора
+
+ALTER TABLE transcriptions CHANGE COLUMN IF EXISTS error last_error TEXT;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS attempts INT NOT NULL DEFAULT 0;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS error_class VARCHAR(16) NULL;
+
+-- У старых результатов была ровно одна попытка; класс старых ошибок неизвестен (NULL)
+UPDATE transcriptions SET attempts = 1 WHERE attempts = 0 AND status IN ('processed', 'error');
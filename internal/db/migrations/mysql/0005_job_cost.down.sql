@@ -0,0 +1,3 @@
+ALTER TABLE jobs DROP COLUMN IF EXISTS budget;
+ALTER TABLE jobs DROP COLUMN IF EXISTS cost;
+ALTER TABLE jobs DROP COLUMN IF EXISTS audio_seconds;
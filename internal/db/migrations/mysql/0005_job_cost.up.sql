@@ -0,0 +1,6 @@
+-- Учёт стоимости платных API (Whisper OpenAI): отправленное аудио и сумма по задаче.
+-- budget — лимит, при достижении которого задача останавливается (NULL — без лимита)
+
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS audio_seconds DOUBLE NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS cost DOUBLE NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS budget DOUBLE NULL;
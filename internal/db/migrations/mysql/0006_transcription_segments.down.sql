@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transcription_segments;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS no_speech_prob;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS avg_logprob;
@@ -0,0 +1,20 @@
+-- Сегменты Whisper (verbose_json): тайминги и уверенность для фильтрации по качеству.
+-- В transcriptions — сводка по файлу: avg_logprob взвешен по длительности сегментов,
+-- no_speech_prob — максимум по сегментам
+
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS avg_logprob DOUBLE NULL;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS no_speech_prob DOUBLE NULL;
+
+CREATE TABLE IF NOT EXISTS transcription_segments (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    audio_file_id BIGINT NOT NULL,
+    engine VARCHAR(64) NOT NULL,
+    model_version VARCHAR(128) NOT NULL DEFAULT '',
+    seq INT NOT NULL,
+    start_sec DOUBLE NOT NULL,
+    end_sec DOUBLE NOT NULL,
+    text TEXT,
+    avg_logprob DOUBLE NULL,
+    no_speech_prob DOUBLE NULL,
+    UNIQUE KEY uniq_segment (audio_file_id, engine, model_version, seq)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,15 @@
+-- Слова с таймингом и уверенностью для каждого результата движка
+-- (Kaldi CTM, Whisper word timestamps) — подсветка слов при воспроизведении
+
+CREATE TABLE IF NOT EXISTS transcription_words (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    audio_file_id BIGINT NOT NULL,
+    engine VARCHAR(64) NOT NULL,
+    model_version VARCHAR(128) NOT NULL DEFAULT '',
+    seq INT NOT NULL,
+    word VARCHAR(255) NOT NULL,
+    start_sec DOUBLE NOT NULL,
+    end_sec DOUBLE NOT NULL,
+    confidence DOUBLE NULL,
+    UNIQUE KEY uniq_word (audio_file_id, engine, model_version, seq)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transcriptions_confidence ON transcriptions;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS mbr;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS nbest;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS lattice_path;
+ALTER TABLE transcriptions DROP COLUMN IF EXISTS confidence;
@@ -0,0 +1,9 @@
+-- Kaldi lattice: уверенность MBR (средняя по словам) для сортировки файлов на проверку,
+-- путь к сохранённой lattice (rxfilename "file.ark:offset"), N-best и MBR гипотеза в JSON
+
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS confidence DOUBLE NULL;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS lattice_path VARCHAR(1024) NULL;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS nbest MEDIUMTEXT NULL;
+ALTER TABLE transcriptions ADD COLUMN IF NOT EXISTS mbr MEDIUMTEXT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transcriptions_confidence ON transcriptions (engine, model_version, confidence);
@@ -0,0 +1,18 @@
+-- Перебор веса LM и word insertion penalty по сохранённым lattice (как Kaldi score.sh):
+-- WER по корпусу для каждой пары, лучшая помечена best = 1
+
+CREATE TABLE IF NOT EXISTS lm_sweep_results (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    job_id BIGINT NOT NULL,
+    engine VARCHAR(64) NOT NULL,
+    model_version VARCHAR(128) NOT NULL DEFAULT '',
+    lm_scale DOUBLE NOT NULL,
+    wip DOUBLE NOT NULL,
+    files INT NOT NULL DEFAULT 0,
+    word_errors INT NOT NULL DEFAULT 0,
+    ref_words INT NOT NULL DEFAULT 0,
+    wer DOUBLE NOT NULL DEFAULT 0,
+    best TINYINT(1) NOT NULL DEFAULT 0,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uniq_sweep_point (job_id, lm_scale, wip)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
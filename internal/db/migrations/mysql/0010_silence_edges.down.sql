@@ -0,0 +1,2 @@
+ALTER TABLE audio_files DROP COLUMN IF EXISTS trailing_silence_ms;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS leading_silence_ms;
@@ -0,0 +1,5 @@
+-- Тишина в начале и в конце файла по VAD, ms (NULL — не измерялась).
+-- Нормализация тишины приводит обе к целевому диапазону
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS leading_silence_ms DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS trailing_silence_ms DOUBLE NULL;
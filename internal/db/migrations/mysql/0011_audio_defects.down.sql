@@ -0,0 +1,2 @@
+ALTER TABLE audio_files DROP COLUMN IF EXISTS defect_count;
+DROP TABLE IF EXISTS audio_defects;
@@ -0,0 +1,18 @@
+-- Дефекты записи (audio.DetectDefects): клиппинг, DC offset, выпадения, сетевой фон, узкая полоса.
+-- defect_count — число найденных дефектов, NULL — файл не проверялся
+
+CREATE TABLE IF NOT EXISTS audio_defects (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    audio_file_id BIGINT NOT NULL,
+    seq INT NOT NULL,
+    type VARCHAR(32) NOT NULL,
+    severity VARCHAR(16) NOT NULL,
+    start_sec DOUBLE NOT NULL,
+    end_sec DOUBLE NOT NULL,
+    value DOUBLE NOT NULL DEFAULT 0,
+    detail VARCHAR(255) NOT NULL DEFAULT '',
+    UNIQUE KEY uniq_defect (audio_file_id, seq),
+    KEY idx_defect_type (type, severity)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS defect_count INT NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE audio_files DROP COLUMN IF EXISTS loudness_source_path;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS loudness_gain_db;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS true_peak_db;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS loudness_range;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS loudness_lufs;
@@ -0,0 +1,9 @@
+-- Громкость по EBU R128 (audio.MeasureLoudness): интегральная LUFS, LRA, true peak dBTP.
+-- Нормализация громкости пишет копию _loud: loudness_gain_db — применённое усиление,
+-- loudness_source_path — файл до нормализации (для отката); NULL — не нормализовался
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS loudness_lufs DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS loudness_range DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS true_peak_db DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS loudness_gain_db DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS loudness_source_path VARCHAR(1024) NULL;
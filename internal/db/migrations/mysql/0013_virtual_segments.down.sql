@@ -0,0 +1,2 @@
+ALTER TABLE audio_files DROP COLUMN IF EXISTS segment_end;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS segment_start;
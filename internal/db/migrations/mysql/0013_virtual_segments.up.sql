@@ -0,0 +1,5 @@
+-- Виртуальные отрезки (Kaldi segments, NeMo offset): file_path — длинная запись,
+-- [segment_start, segment_end) в секундах режется на лету. NULL — обычный файл
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS segment_start DOUBLE NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS segment_end DOUBLE NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_source_key ON audio_files;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS source_key;
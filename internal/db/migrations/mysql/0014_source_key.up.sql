@@ -0,0 +1,6 @@
+-- Откуда файл импортирован: путь (для виртуального отрезка — db.SegmentKey) на момент скана.
+-- Не меняется при правках (нормализация, обрезка), по нему скан и слежение за каталогом
+-- узнают уже импортированные файлы. NULL — файл из merge/split или импортирован раньше
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS source_key VARCHAR(1024) NULL;
+CREATE INDEX IF NOT EXISTS idx_source_key ON audio_files (source_key(255));
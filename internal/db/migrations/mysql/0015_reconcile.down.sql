@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reconcile_conflicts;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS transcription_source;
@@ -0,0 +1,27 @@
+-- Сверка эталонов на диске и в базе.
+-- transcription_source — эталон, каким он был на диске при импорте или последней сверке:
+-- по нему видно, какая сторона изменилась. NULL — файл импортирован раньше
+-- reconcile_conflicts — очередь расхождений на разбор оператором
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS transcription_source TEXT NULL;
+
+CREATE TABLE IF NOT EXISTS reconcile_conflicts (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    job_id BIGINT NOT NULL,
+    audio_file_id BIGINT NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    disk_text TEXT NULL,
+    db_text TEXT NULL,
+    base_text TEXT NULL,
+    disk_hash VARCHAR(64) NOT NULL DEFAULT '',
+    db_hash VARCHAR(64) NOT NULL DEFAULT '',
+    trans_file VARCHAR(1024) NOT NULL DEFAULT '',
+    utt_id VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    winner VARCHAR(16) NOT NULL DEFAULT '',
+    resolved_by VARCHAR(128) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP NULL,
+    KEY idx_conflict_status (status, kind),
+    KEY idx_conflict_file (audio_file_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
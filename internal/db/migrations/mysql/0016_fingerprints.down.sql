@@ -0,0 +1,3 @@
+ALTER TABLE audio_files DROP COLUMN IF EXISTS duplicate_score;
+ALTER TABLE audio_files DROP COLUMN IF EXISTS duplicate_of;
+DROP TABLE IF EXISTS audio_fingerprints;
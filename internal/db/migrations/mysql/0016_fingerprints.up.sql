@@ -0,0 +1,13 @@
+-- Акустические отпечатки (audio.Fingerprint) для поиска почти-дубликатов;
+-- frames = 0 — в файле нет звука для отпечатка (тишина, слишком короткий).
+-- duplicate_of / duplicate_score — скан с SCAN_DUPLICATES=flag: на какой файл похож и насколько
+
+CREATE TABLE IF NOT EXISTS audio_fingerprints (
+    audio_file_id BIGINT PRIMARY KEY,
+    frames INT NOT NULL,
+    fingerprint MEDIUMBLOB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS duplicate_of BIGINT NULL;
+ALTER TABLE audio_files ADD COLUMN IF NOT EXISTS duplicate_score DOUBLE NULL;
@@ -0,0 +1,3 @@
+-- Базовая схема не откатывается: up создаёт таблицы через IF NOT EXISTS и на
+-- существующей базе ничего не делает, а DROP здесь удалил бы весь корпус.
+-- MigrateDown останавливается на этой версии.
@@ -0,0 +1,79 @@
+-- Базовая схема для SQLite (та же, что mysql/0001, без legacy per-engine колонок:
+-- в SQLite базах их никогда не было).
+
+CREATE TABLE IF NOT EXISTS audio_files (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    chapter_id TEXT NOT NULL,
+    chapter_id_int INTEGER NULL,
+    file_path TEXT NOT NULL,
+    file_hash TEXT NOT NULL DEFAULT '',
+    duration_sec REAL NOT NULL DEFAULT 0,
+    sample_rate INTEGER NULL DEFAULT 0,
+    channels INTEGER NULL DEFAULT 0,
+    bit_depth INTEGER NULL DEFAULT 0,
+    file_size INTEGER NULL DEFAULT 0,
+
+    snr_db REAL NULL,
+    snr_sox REAL NULL,
+    snr_wada REAL NULL,
+    snr_spectral REAL NULL,
+    noise_level TEXT NULL,
+    rms_db REAL NULL,
+    audio_quality_score REAL NULL,
+    audio_quality_level TEXT NULL,
+    audio_metadata TEXT NULL,
+
+    transcription_original TEXT NULL,
+
+    review_status TEXT NULL DEFAULT 'pending',
+    operator_verified INTEGER NOT NULL DEFAULT 0,
+    verified_at TIMESTAMP NULL,
+    original_edited INTEGER NOT NULL DEFAULT 0,
+
+    has_trailing_silence INTEGER NULL,
+    silence_added INTEGER NOT NULL DEFAULT 0,
+    parent_ids TEXT NULL,
+    merged_id INTEGER NULL DEFAULT 0,
+    split_source_id INTEGER NULL,
+    active INTEGER NOT NULL DEFAULT 1,
+
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_file_path ON audio_files (file_path);
+CREATE INDEX IF NOT EXISTS idx_file_hash ON audio_files (file_hash);
+CREATE INDEX IF NOT EXISTS idx_user_chapter ON audio_files (user_id, chapter_id);
+CREATE INDEX IF NOT EXISTS idx_parent_ids ON audio_files (parent_ids);
+CREATE INDEX IF NOT EXISTS idx_active ON audio_files (active);
+
+CREATE TABLE IF NOT EXISTS merge_queue (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    ids_string TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    merged_file_id INTEGER NULL,
+    merged_file_path TEXT NULL,
+    merged_duration REAL NULL,
+    merged_transcription TEXT NULL,
+    error_message TEXT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    processed_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_merge_status ON merge_queue (status);
+CREATE INDEX IF NOT EXISTS idx_merge_ids_string ON merge_queue (ids_string);
+
+CREATE TABLE IF NOT EXISTS audio_segments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audio_file_id INTEGER NOT NULL,
+    start_time REAL NOT NULL,
+    end_time REAL NOT NULL,
+    speaker TEXT,
+    has_overlap INTEGER DEFAULT 0,
+    selected INTEGER DEFAULT 0,
+    transcript TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_segments_audio_file ON audio_segments (audio_file_id);
@@ -0,0 +1,19 @@
+-- Результаты ASR движков: одна строка на (файл, движок, версия модели).
+-- Переносить нечего — legacy колонок в SQLite схеме нет
+
+CREATE TABLE IF NOT EXISTS transcriptions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audio_file_id INTEGER NOT NULL,
+    engine TEXT NOT NULL,
+    model_version TEXT NOT NULL DEFAULT '',
+    text TEXT,
+    wer REAL,
+    cer REAL,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    rtf REAL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (audio_file_id, engine, model_version)
+);
+
+CREATE INDEX IF NOT EXISTS idx_engine_status ON transcriptions (engine, model_version, status);
@@ -0,0 +1,23 @@
+-- Журнал пакетных задач (scan, asr, merge, analyze), см. mysql/0003
+
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT NOT NULL,
+    name TEXT NOT NULL DEFAULT '',
+    params TEXT,
+    started_by TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'running',
+    total INTEGER NOT NULL DEFAULT 0,
+    processed INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    errors INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT,
+    cursor_id INTEGER NOT NULL DEFAULT 0,
+    runs INTEGER NOT NULL DEFAULT 1,
+    started_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NULL,
+    finished_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_type_name ON jobs (type, name);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs (status);
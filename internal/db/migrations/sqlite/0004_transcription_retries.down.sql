@@ -0,0 +1,3 @@
+ALTER TABLE transcriptions DROP COLUMN error_class;
+ALTER TABLE transcriptions DROP COLUMN attempts;
+ALTER TABLE transcriptions RENAME COLUMN last_error TO error;
@@ -0,0 +1,7 @@
+-- Повторы ASR: число попыток, последняя ошибка и её класс, см. mysql/0004
+
+ALTER TABLE transcriptions RENAME COLUMN error TO last_error;
+ALTER TABLE transcriptions ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE transcriptions ADD COLUMN error_class TEXT NULL;
+
+UPDATE transcriptions SET attempts = 1 WHERE attempts = 0 AND status IN ('processed', 'error');
@@ -0,0 +1,3 @@
+ALTER TABLE jobs DROP COLUMN budget;
+ALTER TABLE jobs DROP COLUMN cost;
+ALTER TABLE jobs DROP COLUMN audio_seconds;
@@ -0,0 +1,5 @@
+-- Учёт стоимости платных API, см. mysql/0005
+
+ALTER TABLE jobs ADD COLUMN audio_seconds REAL NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN cost REAL NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN budget REAL NULL;
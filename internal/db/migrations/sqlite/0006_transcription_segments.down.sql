@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS transcription_segments;
+ALTER TABLE transcriptions DROP COLUMN no_speech_prob;
+ALTER TABLE transcriptions DROP COLUMN avg_logprob;
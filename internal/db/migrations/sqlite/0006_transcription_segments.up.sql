@@ -0,0 +1,18 @@
+-- Сегменты Whisper (verbose_json), см. mysql/0006
+
+ALTER TABLE transcriptions ADD COLUMN avg_logprob REAL NULL;
+ALTER TABLE transcriptions ADD COLUMN no_speech_prob REAL NULL;
+
+CREATE TABLE IF NOT EXISTS transcription_segments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audio_file_id INTEGER NOT NULL,
+    engine TEXT NOT NULL,
+    model_version TEXT NOT NULL DEFAULT '',
+    seq INTEGER NOT NULL,
+    start_sec REAL NOT NULL,
+    end_sec REAL NOT NULL,
+    text TEXT,
+    avg_logprob REAL NULL,
+    no_speech_prob REAL NULL,
+    UNIQUE (audio_file_id, engine, model_version, seq)
+);
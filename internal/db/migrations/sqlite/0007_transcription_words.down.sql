@@ -0,0 +1 @@
+DROP TABLE IF EXISTS transcription_words;
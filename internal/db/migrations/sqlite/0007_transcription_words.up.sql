@@ -0,0 +1,14 @@
+-- Слова с таймингом и уверенностью, см. mysql/0007
+
+CREATE TABLE IF NOT EXISTS transcription_words (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audio_file_id INTEGER NOT NULL,
+    engine TEXT NOT NULL,
+    model_version TEXT NOT NULL DEFAULT '',
+    seq INTEGER NOT NULL,
+    word TEXT NOT NULL,
+    start_sec REAL NOT NULL,
+    end_sec REAL NOT NULL,
+    confidence REAL NULL,
+    UNIQUE (audio_file_id, engine, model_version, seq)
+);
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_transcriptions_confidence;
+ALTER TABLE transcriptions DROP COLUMN mbr;
+ALTER TABLE transcriptions DROP COLUMN nbest;
+ALTER TABLE transcriptions DROP COLUMN lattice_path;
+ALTER TABLE transcriptions DROP COLUMN confidence;
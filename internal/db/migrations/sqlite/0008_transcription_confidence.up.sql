@@ -0,0 +1,8 @@
+-- Kaldi lattice: уверенность, lattice, N-best и MBR, см. mysql/0008
+
+ALTER TABLE transcriptions ADD COLUMN confidence REAL NULL;
+ALTER TABLE transcriptions ADD COLUMN lattice_path TEXT NULL;
+ALTER TABLE transcriptions ADD COLUMN nbest TEXT NULL;
+ALTER TABLE transcriptions ADD COLUMN mbr TEXT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transcriptions_confidence ON transcriptions (engine, model_version, confidence);
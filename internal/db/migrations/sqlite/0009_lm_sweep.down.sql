@@ -0,0 +1 @@
+DROP TABLE IF EXISTS lm_sweep_results;
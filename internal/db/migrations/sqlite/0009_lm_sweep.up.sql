@@ -0,0 +1,17 @@
+-- Перебор веса LM и word insertion penalty, см. mysql/0009
+
+CREATE TABLE IF NOT EXISTS lm_sweep_results (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    engine TEXT NOT NULL,
+    model_version TEXT NOT NULL DEFAULT '',
+    lm_scale REAL NOT NULL,
+    wip REAL NOT NULL,
+    files INTEGER NOT NULL DEFAULT 0,
+    word_errors INTEGER NOT NULL DEFAULT 0,
+    ref_words INTEGER NOT NULL DEFAULT 0,
+    wer REAL NOT NULL DEFAULT 0,
+    best INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (job_id, lm_scale, wip)
+);
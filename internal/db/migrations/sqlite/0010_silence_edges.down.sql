@@ -0,0 +1,2 @@
+ALTER TABLE audio_files DROP COLUMN trailing_silence_ms;
+ALTER TABLE audio_files DROP COLUMN leading_silence_ms;
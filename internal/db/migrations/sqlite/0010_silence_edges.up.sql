@@ -0,0 +1,4 @@
+-- Тишина в начале и в конце файла по VAD, см. mysql/0010
+
+ALTER TABLE audio_files ADD COLUMN leading_silence_ms REAL NULL;
+ALTER TABLE audio_files ADD COLUMN trailing_silence_ms REAL NULL;
@@ -0,0 +1,17 @@
+-- Дефекты записи, см. mysql/0011
+
+CREATE TABLE IF NOT EXISTS audio_defects (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    audio_file_id INTEGER NOT NULL,
+    seq INTEGER NOT NULL,
+    type TEXT NOT NULL,
+    severity TEXT NOT NULL,
+    start_sec REAL NOT NULL,
+    end_sec REAL NOT NULL,
+    value REAL NOT NULL DEFAULT 0,
+    detail TEXT NOT NULL DEFAULT '',
+    UNIQUE (audio_file_id, seq)
+);
+CREATE INDEX IF NOT EXISTS idx_defect_type ON audio_defects (type, severity);
+
+ALTER TABLE audio_files ADD COLUMN defect_count INTEGER NULL;
@@ -0,0 +1,5 @@
+ALTER TABLE audio_files DROP COLUMN loudness_source_path;
+ALTER TABLE audio_files DROP COLUMN loudness_gain_db;
+ALTER TABLE audio_files DROP COLUMN true_peak_db;
+ALTER TABLE audio_files DROP COLUMN loudness_range;
+ALTER TABLE audio_files DROP COLUMN loudness_lufs;
@@ -0,0 +1,7 @@
+-- Громкость по EBU R128 и нормализация громкости, см. mysql/0012
+
+ALTER TABLE audio_files ADD COLUMN loudness_lufs REAL NULL;
+ALTER TABLE audio_files ADD COLUMN loudness_range REAL NULL;
+ALTER TABLE audio_files ADD COLUMN true_peak_db REAL NULL;
+ALTER TABLE audio_files ADD COLUMN loudness_gain_db REAL NULL;
+ALTER TABLE audio_files ADD COLUMN loudness_source_path TEXT NULL;
@@ -0,0 +1,2 @@
+ALTER TABLE audio_files DROP COLUMN segment_end;
+ALTER TABLE audio_files DROP COLUMN segment_start;
@@ -0,0 +1,4 @@
+-- Виртуальные отрезки длинных записей, см. mysql/0013
+
+ALTER TABLE audio_files ADD COLUMN segment_start REAL NULL;
+ALTER TABLE audio_files ADD COLUMN segment_end REAL NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_source_key;
+ALTER TABLE audio_files DROP COLUMN source_key;
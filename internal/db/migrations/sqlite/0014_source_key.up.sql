@@ -0,0 +1,4 @@
+-- Откуда файл импортирован, см. mysql/0014
+
+ALTER TABLE audio_files ADD COLUMN source_key TEXT NULL;
+CREATE INDEX IF NOT EXISTS idx_source_key ON audio_files (source_key);
@@ -0,0 +1,24 @@
+-- Сверка эталонов на диске и в базе, см. mysql/0015
+
+ALTER TABLE audio_files ADD COLUMN transcription_source TEXT NULL;
+
+CREATE TABLE IF NOT EXISTS reconcile_conflicts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    audio_file_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    disk_text TEXT NULL,
+    db_text TEXT NULL,
+    base_text TEXT NULL,
+    disk_hash TEXT NOT NULL DEFAULT '',
+    db_hash TEXT NOT NULL DEFAULT '',
+    trans_file TEXT NOT NULL DEFAULT '',
+    utt_id TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL DEFAULT 'pending',
+    winner TEXT NOT NULL DEFAULT '',
+    resolved_by TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    resolved_at DATETIME NULL
+);
+CREATE INDEX IF NOT EXISTS idx_conflict_status ON reconcile_conflicts (status, kind);
+CREATE INDEX IF NOT EXISTS idx_conflict_file ON reconcile_conflicts (audio_file_id);
@@ -0,0 +1,3 @@
+ALTER TABLE audio_files DROP COLUMN duplicate_score;
+ALTER TABLE audio_files DROP COLUMN duplicate_of;
+DROP TABLE IF EXISTS audio_fingerprints;
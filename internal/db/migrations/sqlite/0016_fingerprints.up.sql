@@ -0,0 +1,11 @@
+-- Акустические отпечатки, см. mysql/0016
+
+CREATE TABLE IF NOT EXISTS audio_fingerprints (
+    audio_file_id INTEGER PRIMARY KEY,
+    frames INTEGER NOT NULL,
+    fingerprint BLOB NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+ALTER TABLE audio_files ADD COLUMN duplicate_of INTEGER NULL;
+ALTER TABLE audio_files ADD COLUMN duplicate_score REAL NULL;
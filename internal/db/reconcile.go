@@ -0,0 +1,233 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+// Виды расхождений диска и базы (reconcile_conflicts.kind)
+const (
+	ConflictDiskChanged = "disk_changed" // эталон изменился на диске, в базе не правился
+	ConflictDBEdited    = "db_edited"    // эталон правил оператор, на диске старый
+	ConflictBothChanged = "both_changed" // изменились обе стороны, и по-разному
+	ConflictFileMissing = "file_missing" // аудио нет на диске
+	ConflictHashChanged = "hash_changed" // аудио на диске не совпадает с file_hash
+)
+
+// Состояние расхождения и выбранная сторона
+const (
+	ConflictPending  = "pending"
+	ConflictResolved = "resolved"
+
+	WinnerDisk = "disk"
+	WinnerDB   = "db"
+)
+
+// ReconcileConflict — расхождение в очереди на разбор
+type ReconcileConflict struct {
+	ID          int64      `json:"id"`
+	JobID       int64      `json:"job_id"`
+	AudioFileID int64      `json:"audio_file_id"`
+	Kind        string     `json:"kind"`
+	DiskText    string     `json:"disk_text,omitempty"`
+	DBText      string     `json:"db_text,omitempty"`
+	BaseText    string     `json:"base_text,omitempty"` // эталон при импорте / прошлой сверке
+	DiskHash    string     `json:"disk_hash,omitempty"`
+	DBHash      string     `json:"db_hash,omitempty"`
+	TransFile   string     `json:"trans_file,omitempty"` // куда записывается эталон, если побеждает база
+	UttID       string     `json:"utt_id,omitempty"`
+	Status      string     `json:"status"`
+	Winner      string     `json:"winner,omitempty"`
+	ResolvedBy  string     `json:"resolved_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ResolvedAt  *time.Time `json:"resolved_at,omitempty"`
+}
+
+// ConflictFilter — отбор расхождений; пустые поля не фильтруют
+type ConflictFilter struct {
+	JobID  int64
+	Kind   string
+	Status string
+}
+
+func (f ConflictFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.JobID > 0 {
+		conditions = append(conditions, "job_id = ?")
+		args = append(args, f.JobID)
+	}
+	if f.Kind != "" {
+		conditions = append(conditions, "kind = ?")
+		args = append(args, f.Kind)
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, f.Status)
+	}
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+const conflictColumns = `id, job_id, audio_file_id, kind, COALESCE(disk_text, ''), COALESCE(db_text, ''),
+	COALESCE(base_text, ''), disk_hash, db_hash, trans_file, utt_id, status, winner, resolved_by,
+	created_at, resolved_at`
+
+func scanConflict(scanner interface{ Scan(...interface{}) error }) (*ReconcileConflict, error) {
+	var c ReconcileConflict
+	var resolvedAt sql.NullTime
+	err := scanner.Scan(&c.ID, &c.JobID, &c.AudioFileID, &c.Kind, &c.DiskText, &c.DBText,
+		&c.BaseText, &c.DiskHash, &c.DBHash, &c.TransFile, &c.UttID, &c.Status, &c.Winner, &c.ResolvedBy,
+		&c.CreatedAt, &resolvedAt)
+	if err != nil {
+		return nil, err
+	}
+	if resolvedAt.Valid {
+		c.ResolvedAt = &resolvedAt.Time
+	}
+	return &c, nil
+}
+
+// InsertReconcileConflict ставит расхождение в очередь
+func (db *DB) InsertReconcileConflict(c *ReconcileConflict) (int64, error) {
+	res, err := db.conn.Exec(`
+		INSERT INTO reconcile_conflicts
+		(job_id, audio_file_id, kind, disk_text, db_text, base_text, disk_hash, db_hash, trans_file, utt_id, status)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.JobID, c.AudioFileID, c.Kind, nullString(c.DiskText), nullString(c.DBText), nullString(c.BaseText),
+		c.DiskHash, c.DBHash, c.TransFile, c.UttID, ConflictPending)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// GetReconcileConflict возвращает расхождение по id
+func (db *DB) GetReconcileConflict(id int64) (*ReconcileConflict, error) {
+	return scanConflict(db.conn.QueryRow(`SELECT `+conflictColumns+` FROM reconcile_conflicts WHERE id = ?`, id))
+}
+
+// GetReconcileConflicts — страница очереди, новые сверху
+func (db *DB) GetReconcileConflicts(f ConflictFilter, page, limit int) ([]ReconcileConflict, int64, error) {
+	where, args := f.where()
+
+	var total int64
+	if err := db.conn.QueryRow("SELECT COUNT(*) FROM reconcile_conflicts"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	if page < 1 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	rows, err := db.conn.Query(`SELECT `+conflictColumns+` FROM reconcile_conflicts`+where+
+		` ORDER BY id DESC LIMIT ? OFFSET ?`, append(args, limit, (page-1)*limit)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	conflicts := []ReconcileConflict{}
+	for rows.Next() {
+		c, err := scanConflict(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, total, rows.Err()
+}
+
+// GetReconcileConflictIDs — id всех расхождений под фильтром (массовое решение)
+func (db *DB) GetReconcileConflictIDs(f ConflictFilter) ([]int64, error) {
+	where, args := f.where()
+	rows, err := db.conn.Query(`SELECT id FROM reconcile_conflicts`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetStandingConflicts — нерешённые расхождения и те, где победила база:
+// сверка не ставит такое же расхождение в очередь повторно
+func (db *DB) GetStandingConflicts() ([]ReconcileConflict, error) {
+	rows, err := db.conn.Query(`SELECT `+conflictColumns+` FROM reconcile_conflicts
+		WHERE status = ? OR winner = ?`, ConflictPending, WinnerDB)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var conflicts []ReconcileConflict
+	for rows.Next() {
+		c, err := scanConflict(rows)
+		if err != nil {
+			return nil, err
+		}
+		conflicts = append(conflicts, *c)
+	}
+	return conflicts, rows.Err()
+}
+
+// CountPendingConflicts — нерешённые расхождения по видам
+func (db *DB) CountPendingConflicts() (map[string]int64, error) {
+	rows, err := db.conn.Query(`SELECT kind, COUNT(*) FROM reconcile_conflicts
+		WHERE status = ? GROUP BY kind`, ConflictPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int64)
+	for rows.Next() {
+		var kind string
+		var n int64
+		if err := rows.Scan(&kind, &n); err != nil {
+			return nil, err
+		}
+		counts[kind] = n
+	}
+	return counts, rows.Err()
+}
+
+// ResolveReconcileConflict закрывает расхождение; false — уже решено
+func (db *DB) ResolveReconcileConflict(id int64, winner, resolvedBy string) (bool, error) {
+	res, err := db.conn.Exec(`
+		UPDATE reconcile_conflicts SET status = ?, winner = ?, resolved_by = ?, resolved_at = CURRENT_TIMESTAMP
+		WHERE id = ? AND status = ?`, ConflictResolved, winner, resolvedBy, id, ConflictPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// SetTranscriptionSource запоминает эталон, который сейчас на диске
+func (db *DB) SetTranscriptionSource(id int64, text string) error {
+	_, err := db.conn.Exec(`UPDATE audio_files SET transcription_source = ? WHERE id = ?`, text, id)
+	return err
+}
+
+// AcceptDiskTranscription — побеждает диск: эталон с диска, правка оператора сбрасывается
+func (db *DB) AcceptDiskTranscription(id int64, text string) error {
+	_, err := db.conn.Exec(`
+		UPDATE audio_files SET transcription_original = ?, transcription_source = ?, original_edited = 0
+		WHERE id = ?`, text, text, id)
+	return err
+}
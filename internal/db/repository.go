@@ -0,0 +1,162 @@
+package db
+
+import (
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/segment"
+)
+
+// Интерфейсы хранилища. *DB реализует их и для MariaDB, и для SQLite
+// (см. New / NewSQLite); сервисы и handlers зависят только от интерфейсов.
+
+// FileRepository — audio_files
+type FileRepository interface {
+	ExistsByHash(hash string) (bool, error)
+	ExistsByPath(path string) (bool, error)
+	Insert(af *AudioFile) (int64, error)
+	GetFile(id int64) (*AudioFile, error)
+	GetFileIncludingInactive(id int64) (*AudioFile, error)
+	GetFilesFiltered(page, limit int, speaker, werEngine, werOp string, werValue float64, durOp string, durValue float64,
+		engineStatus map[string]string, verified, merged, active, noiseLevel, textSearch, chapter string,
+		confEngine string, confMax float64, sortBy string, defect, defectSeverity string) (*FileListResult, error)
+	GetFilesForAnalyze(limit int, force bool, afterID int64) ([]AudioFile, error)
+	GetAllFilePaths() (map[string]bool, error)
+	GetShortFilesBySpeaker(maxDuration float64, limit int) (map[string][]AudioFile, error)
+	GetSpeakers() ([]string, error)
+	StatsExtended() (map[string]interface{}, error)
+	StatsExtendedCached() (map[string]interface{}, error)
+
+	UpdateOriginalTranscription(id int64, text string) error
+	SetVerificationStatus(id int64, verified bool) error
+	UpdateAudioStats(id int64, stats *audio.AudioStats) error
+	SaveDefects(audioFileID int64, defects []audio.Defect) error
+	UpdateSilenceStatus(id int64, hasSilence bool, silenceAdded bool) error
+	UpdateSilenceEdges(id int64, leadingMs, trailingMs float64) error
+	GetFilesForSilence(f SilenceFilter, limit int, afterID int64) ([]AudioFile, error)
+	UpdateLoudness(id int64, l *audio.Loudness) error
+	SetLoudnessNormalized(id int64, newPath, hash string, gainDB float64, sourcePath string, l *audio.Loudness) error
+	RevertLoudness(id int64, hash string, l *audio.Loudness) error
+	GetFilesForLoudness(f LoudnessFilter, limit int, afterID int64) ([]AudioFile, error)
+	UpdateFilePath(id int64, newPath string, newDuration float64, newHash string) error
+	UpdateSegmentRange(id int64, start, end float64, hash string) error
+	GetFilesUnderDir(dir string) ([]AudioFile, error)
+	UpdateDiskTranscription(id int64, text string) (bool, error)
+	DeactivateFiles(ids []int64) error
+	DeleteFile(id int64) error
+
+	// Split
+	InsertSplitFile(filePath, userID, chapterID, transcript string, duration float64, sourceID int64, fileHash string) (int64, error)
+	MarkAsSplitSource(id int64) error
+	GetNextFileIndex(userID, chapterID string) (int, error)
+	GetNextSplitChapter(userID string) (string, error)
+}
+
+// MergeQueueRepository — merge_queue и связанные изменения audio_files
+type MergeQueueRepository interface {
+	AddToMergeQueue(idsString string) (int64, error)
+	AddBatchToMergeQueue(idsStrings []string) ([]map[string]interface{}, error)
+	GetPendingMergeQueue(limit int) ([]MergeQueueItem, error)
+	ResetStaleMergeQueue() (int64, error)
+	GetMergeQueueItem(id int64) (*MergeQueueItem, error)
+	GetMergeQueueList(page, limit int, status string) ([]MergeQueueItem, int64, error)
+	UpdateMergeQueueStatus(id int64, status string) error
+	UpdateMergeQueueCompleted(id int64, mergedFileID int64, filePath string, duration float64, transcription string) error
+	UpdateMergeQueueError(id int64, errMsg string) error
+	DeleteMergeQueueItem(id int64) error
+	ClearMergeQueue(status string) (int64, error)
+	CheckMergeExists(idsString string) (bool, int64, string, error)
+	CheckFilesForMerge(ids []int64) (string, []string, error)
+
+	InsertMerged(af *AudioFile, parentIDs string) (int64, error)
+	UpdateMergedID(ids []int64, mergedID int64) error
+	DeactivateFiles(ids []int64) error
+	GetNextChapterID(speakerID string) (string, error)
+}
+
+// TranscriptionRepository — результаты ASR движков
+type TranscriptionRepository interface {
+	SaveTranscription(t *Transcription) error
+	SaveTranscriptionError(audioFileID int64, engine, modelVersion, errMsg, errorClass string) error
+	RequeueRetryableErrors(engine, modelVersion string, maxAttempts int, unclassified bool) (int64, error)
+	UpdateTranscriptionMetrics(id int64, wer, cer float64) error
+	GetPendingTranscriptions(engine, modelVersion string, limit int) ([]AudioFile, error)
+	GetPendingTranscriptionsByWER(engine, modelVersion string, limit int, refEngine string, minRefWER float64) ([]AudioFile, error)
+	GetTranscriptions(audioFileID int64) ([]Transcription, error)
+	GetTranscriptionsForFiles(ids []int64) (map[int64]map[string]*Transcription, error)
+	GetTranscriptionsForRecalc(audioFileID int64) ([]TranscriptionRecalc, error)
+	TranscriptionStats(total int) (map[string]*EngineStats, error)
+	DeleteTranscriptions(audioFileID int64) error
+	SaveTranscriptionSegments(audioFileID int64, engine, modelVersion string, segments []TranscriptionSegment) error
+	GetTranscriptionSegments(audioFileID int64, engine, modelVersion string) ([]TranscriptionSegment, error)
+	SaveTranscriptionWords(audioFileID int64, engine, modelVersion string, words []TranscriptionWord) error
+	GetTranscriptionWords(audioFileID int64, engine, modelVersion string) ([]TranscriptionWord, error)
+	SaveTranscriptionHypotheses(audioFileID int64, engine, modelVersion string, h *TranscriptionHypotheses) error
+	GetTranscriptionHypotheses(audioFileID int64, engine, modelVersion string) (*TranscriptionHypotheses, error)
+}
+
+// JobRepository — журнал пакетных задач
+type JobRepository interface {
+	CreateJob(j *Job) (int64, error)
+	UpdateJobProgress(j *Job) error
+	FinishJob(j *Job) error
+	ResumeJob(id int64, startedBy string) error
+	MarkInterruptedJobs(staleSec int) (int64, error)
+	GetJob(id int64) (*Job, error)
+	LastJob(jobType, name string) (*Job, error)
+	ListJobs(jobType, name, status string, page, limit int) ([]Job, int64, error)
+}
+
+// SweepRepository — перебор веса LM по сохранённым lattice
+type SweepRepository interface {
+	GetSweepUtterances(engine, modelVersion string, limit int) ([]SweepUtterance, error)
+	SaveSweepResult(r *SweepResult) error
+	MarkBestSweepResult(jobID, id int64) error
+	GetSweepResults(jobID int64) ([]SweepResult, error)
+}
+
+// ReconcileRepository — сверка эталонов на диске и в базе, очередь расхождений
+type ReconcileRepository interface {
+	InsertReconcileConflict(c *ReconcileConflict) (int64, error)
+	GetReconcileConflict(id int64) (*ReconcileConflict, error)
+	GetReconcileConflicts(f ConflictFilter, page, limit int) ([]ReconcileConflict, int64, error)
+	GetReconcileConflictIDs(f ConflictFilter) ([]int64, error)
+	GetStandingConflicts() ([]ReconcileConflict, error)
+	CountPendingConflicts() (map[string]int64, error)
+	ResolveReconcileConflict(id int64, winner, resolvedBy string) (bool, error)
+	SetTranscriptionSource(id int64, text string) error
+	AcceptDiskTranscription(id int64, text string) error
+}
+
+// FingerprintRepository — акустические отпечатки для поиска почти-дубликатов
+type FingerprintRepository interface {
+	SaveFingerprint(audioFileID int64, fp audio.Fingerprint) error
+	GetFingerprints() ([]FileFingerprint, error)
+	GetFilesWithoutFingerprint(limit int, afterID int64) ([]AudioFile, error)
+	CountFilesWithoutFingerprint() (int64, error)
+}
+
+// MigrationRepository — версии схемы
+type MigrationRepository interface {
+	MigrateUp(target int64) ([]Migration, error)
+	MigrateDown(steps int) ([]Migration, error)
+	MigrationStatus() ([]MigrationState, error)
+	PendingMigrations() (int, error)
+}
+
+// Store — всё хранилище целиком
+type Store interface {
+	FileRepository
+	MergeQueueRepository
+	TranscriptionRepository
+	JobRepository
+	SweepRepository
+	ReconcileRepository
+	FingerprintRepository
+	MigrationRepository
+
+	// Segments — репозиторий сегментов pyannote на том же соединении
+	Segments() segment.Store
+	Driver() string
+	Close() error
+}
+
+var _ Store = (*DB)(nil)
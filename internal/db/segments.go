@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IsSegment — виртуальный отрезок длинной записи (FilePath — запись целиком)
+func (af *AudioFile) IsSegment() bool {
+	return af.SegmentStart != nil && af.SegmentEnd != nil
+}
+
+// SegmentKey — ключ файла для проверки повторного импорта: путь, а для
+// виртуального отрезка — путь записи и границы в миллисекундах
+func SegmentKey(path string, start, end *float64) string {
+	if start == nil || end == nil {
+		return path
+	}
+	return fmt.Sprintf("%s#%d-%d", path, msec(*start), msec(*end))
+}
+
+// FileKey — SegmentKey текущего пути и границ файла
+func (af *AudioFile) FileKey() string {
+	return SegmentKey(af.FilePath, af.SegmentStart, af.SegmentEnd)
+}
+
+// KeyPath — путь файла из ключа SegmentKey (без границ отрезка)
+func KeyPath(key string) string {
+	i := strings.LastIndex(key, "#")
+	if i < 0 {
+		return key
+	}
+	from, to, ok := strings.Cut(key[i+1:], "-")
+	if !ok {
+		return key
+	}
+	if _, err := strconv.ParseInt(from, 10, 64); err != nil {
+		return key
+	}
+	if _, err := strconv.ParseInt(to, 10, 64); err != nil {
+		return key
+	}
+	return key[:i]
+}
+
+func msec(sec float64) int64 {
+	return int64(sec*1000 + 0.5)
+}
+
+// UpdateSegmentRange меняет границы виртуального отрезка (обрезка без записи файла);
+// отпечаток старых границ удаляется
+func (db *DB) UpdateSegmentRange(id int64, start, end float64, hash string) error {
+	_, err := db.conn.Exec(`
+		UPDATE audio_files
+		SET segment_start = ?, segment_end = ?, duration_sec = ?, file_hash = ?
+		WHERE id = ? AND segment_start IS NOT NULL`, start, end, end-start, hash, id)
+	if err != nil {
+		return err
+	}
+	return db.dropFingerprint(id)
+}
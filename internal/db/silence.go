@@ -0,0 +1,76 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// Порог has_trailing_silence: Kaldi нужно минимум 100ms тишины в конце
+const minTrailingSilenceMs = 100
+
+// SilenceFilter — какие активные файлы берёт нормализация тишины (пустое поле — без фильтра)
+type SilenceFilter struct {
+	Speaker    string `json:"speaker,omitempty"`
+	Chapter    string `json:"chapter,omitempty"`
+	NoiseLevel string `json:"noise_level,omitempty"`
+	// OutOfRange — только измеренные файлы с тишиной вне [MinMs, MaxMs] и ещё не измеренные
+	OutOfRange bool    `json:"out_of_range,omitempty"`
+	MinMs      float64 `json:"min_ms,omitempty"`
+	MaxMs      float64 `json:"max_ms,omitempty"`
+}
+
+// UpdateSilenceEdges сохраняет тишину в начале и в конце (VAD, ms) и has_trailing_silence
+func (db *DB) UpdateSilenceEdges(id int64, leadingMs, trailingMs float64) error {
+	_, err := db.conn.Exec(`
+		UPDATE audio_files
+		SET leading_silence_ms = ?, trailing_silence_ms = ?, has_trailing_silence = ?
+		WHERE id = ?`, leadingMs, trailingMs, trailingMs >= minTrailingSilenceMs, id)
+	return err
+}
+
+// GetFilesForSilence возвращает файлы по возрастанию id, начиная после afterID (курсор задачи)
+func (db *DB) GetFilesForSilence(f SilenceFilter, limit int, afterID int64) ([]AudioFile, error) {
+	conditions := []string{"active = 1", "id > ?"}
+	args := []interface{}{afterID}
+
+	if f.Speaker != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, f.Speaker)
+	}
+	if f.Chapter != "" {
+		conditions = append(conditions, "chapter_id = ?")
+		args = append(args, f.Chapter)
+	}
+	if f.NoiseLevel != "" {
+		conditions = append(conditions, "noise_level = ?")
+		args = append(args, f.NoiseLevel)
+	}
+	if f.OutOfRange {
+		conditions = append(conditions, `(leading_silence_ms IS NULL OR trailing_silence_ms IS NULL
+			OR leading_silence_ms < ? OR leading_silence_ms > ?
+			OR trailing_silence_ms < ? OR trailing_silence_ms > ?)`)
+		args = append(args, f.MinMs, f.MaxMs, f.MinMs, f.MaxMs)
+	}
+	args = append(args, limit)
+
+	rows, err := db.conn.Query(`
+		SELECT id, file_path, duration_sec, segment_start, segment_end FROM audio_files
+		WHERE `+strings.Join(conditions, " AND ")+`
+		ORDER BY id LIMIT ?`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudioFile
+	for rows.Next() {
+		var af AudioFile
+		var segStart, segEnd sql.NullFloat64
+		if err := rows.Scan(&af.ID, &af.FilePath, &af.DurationSec, &segStart, &segEnd); err != nil {
+			return nil, err
+		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		files = append(files, af)
+	}
+	return files, rows.Err()
+}
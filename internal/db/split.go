@@ -31,14 +31,10 @@ func (d *DB) InsertSplitFile(filePath, userID, chapterID, transcript string, dur
 			file_size,
 			file_hash,
 			audio_metadata,
-			asr_status, 
-			asr_nolm_status, 
-			whisper_local_status, 
-			whisper_openai_status,
 			review_status,
 			split_source_id, 
 			active
-		) VALUES (?, ?, ?, ?, ?, ?, ?, 8000, 1, 16, ?, ?, '{}', 'pending', 'pending', 'pending', 'pending', 'pending', ?, 1)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, 8000, 1, 16, ?, ?, '{}', 'pending', ?, 1)
 	`, filePath, userID, chapterID, chapterInt, fmt.Sprintf("%d", sourceID), transcript, duration, fileSize, fileHash, sourceID)
 	if err != nil {
 		return 0, err
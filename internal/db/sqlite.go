@@ -0,0 +1,75 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Поддерживаемые драйверы (DB_DRIVER)
+const (
+	DriverMySQL  = "mysql"
+	DriverSQLite = "sqlite3"
+)
+
+// NewSQLite открывает (или создаёт) файл SQLite — для локального запуска без MariaDB.
+// WAL + busy_timeout, чтобы воркеры сканера/ASR не падали с "database is locked"
+func NewSQLite(path string) (*DB, error) {
+	dsn := fmt.Sprintf("file:%s?_journal_mode=WAL&_busy_timeout=10000&_foreign_keys=on&_txlock=immediate", path)
+
+	conn, err := sql.Open(DriverSQLite, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// SQLite допускает одного писателя — много соединений только увеличат ожидание
+	conn.SetMaxOpenConns(4)
+	conn.SetMaxIdleConns(4)
+
+	if err := conn.Ping(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &DB{conn: conn, driver: DriverSQLite}, nil
+}
+
+// upsert — хвост INSERT для вставки-или-обновления по уникальному ключу.
+// keys — колонки уникального индекса (нужны только SQLite), set — "col = ..." через запятую.
+// Новое значение колонки внутри set — через db.excluded(col)
+func (db *DB) upsert(keys, set string) string {
+	if db.driver == DriverSQLite {
+		return " ON CONFLICT(" + keys + ") DO UPDATE SET " + set
+	}
+	return " ON DUPLICATE KEY UPDATE " + set
+}
+
+// excluded — значение колонки из вставляемой строки внутри upsert
+func (db *DB) excluded(col string) string {
+	if db.driver == DriverSQLite {
+		return "excluded." + col
+	}
+	return "VALUES(" + col + ")"
+}
+
+// secondsAgo — момент n секунд назад по часам базы (для сравнения с CURRENT_TIMESTAMP колонками)
+func (db *DB) secondsAgo(n int) string {
+	if db.driver == DriverSQLite {
+		return fmt.Sprintf("datetime('now', '-%d seconds')", n)
+	}
+	return fmt.Sprintf("(NOW() - INTERVAL %d SECOND)", n)
+}
+
+// firstInsertID — id первой строки многострочного INSERT:
+// MySQL возвращает id первой вставленной строки, SQLite — последней
+func (db *DB) firstInsertID(res sql.Result, rows int) (int64, error) {
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if db.driver == DriverSQLite {
+		id -= int64(rows - 1)
+	}
+	return id, nil
+}
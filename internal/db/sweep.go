@@ -0,0 +1,103 @@
+package db
+
+import "time"
+
+// SweepResult — WER по корпусу для одной пары (вес LM, word insertion penalty)
+type SweepResult struct {
+	ID           int64     `json:"id"`
+	JobID        int64     `json:"job_id"`
+	Engine       string    `json:"engine"`
+	ModelVersion string    `json:"model_version"`
+	LMScale      float64   `json:"lm_scale"`
+	WIP          float64   `json:"wip"`
+	Files        int       `json:"files"`
+	WordErrors   int       `json:"word_errors"`
+	RefWords     int       `json:"ref_words"`
+	WER          float64   `json:"wer"`
+	Best         bool      `json:"best"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// SweepUtterance — файл с сохранённой lattice и эталоном для перебора
+type SweepUtterance struct {
+	AudioFileID int64
+	LatticePath string
+	Reference   string
+}
+
+// GetSweepUtterances — файлы с сохранённой lattice движка и непустым эталоном
+func (db *DB) GetSweepUtterances(engine, modelVersion string, limit int) ([]SweepUtterance, error) {
+	query := `
+		SELECT t.audio_file_id, t.lattice_path, a.transcription_original
+		FROM transcriptions t
+		JOIN audio_files a ON a.id = t.audio_file_id
+		WHERE t.engine = ? AND t.model_version = ? AND t.status = 'processed'
+		  AND t.lattice_path IS NOT NULL AND t.lattice_path <> ''
+		  AND a.active = 1 AND a.transcription_original IS NOT NULL AND a.transcription_original <> ''
+		ORDER BY t.audio_file_id`
+	args := []interface{}{engine, modelVersion}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []SweepUtterance
+	for rows.Next() {
+		var u SweepUtterance
+		if err := rows.Scan(&u.AudioFileID, &u.LatticePath, &u.Reference); err != nil {
+			return nil, err
+		}
+		list = append(list, u)
+	}
+	return list, rows.Err()
+}
+
+// SaveSweepResult записывает (или перезаписывает при resume) точку перебора
+func (db *DB) SaveSweepResult(r *SweepResult) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO lm_sweep_results
+		(job_id, engine, model_version, lm_scale, wip, files, word_errors, ref_words, wer)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`+
+		db.upsert("job_id, lm_scale, wip", `
+			files = `+db.excluded("files")+`, word_errors = `+db.excluded("word_errors")+`,
+			ref_words = `+db.excluded("ref_words")+`, wer = `+db.excluded("wer")),
+		r.JobID, r.Engine, r.ModelVersion, r.LMScale, r.WIP, r.Files, r.WordErrors, r.RefWords, r.WER)
+	return err
+}
+
+// MarkBestSweepResult помечает лучшую точку перебора задачи
+func (db *DB) MarkBestSweepResult(jobID, id int64) error {
+	_, err := db.conn.Exec(`UPDATE lm_sweep_results SET best = CASE WHEN id = ? THEN 1 ELSE 0 END
+		WHERE job_id = ?`, id, jobID)
+	return err
+}
+
+// GetSweepResults — таблица перебора задачи по возрастанию WER
+func (db *DB) GetSweepResults(jobID int64) ([]SweepResult, error) {
+	rows, err := db.conn.Query(`
+		SELECT id, job_id, engine, model_version, lm_scale, wip, files, word_errors, ref_words, wer, best, created_at
+		FROM lm_sweep_results
+		WHERE job_id = ?
+		ORDER BY wer, lm_scale, wip`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []SweepResult{}
+	for rows.Next() {
+		var r SweepResult
+		if err := rows.Scan(&r.ID, &r.JobID, &r.Engine, &r.ModelVersion, &r.LMScale, &r.WIP,
+			&r.Files, &r.WordErrors, &r.RefWords, &r.WER, &r.Best, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		results = append(results, r)
+	}
+	return results, rows.Err()
+}
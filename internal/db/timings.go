@@ -0,0 +1,136 @@
+package db
+
+// TranscriptionSegment — сегмент распознавания с таймингом (Whisper verbose_json)
+type TranscriptionSegment struct {
+	Seq          int      `json:"seq"`
+	Start        float64  `json:"start"`
+	End          float64  `json:"end"`
+	Text         string   `json:"text"`
+	AvgLogprob   *float64 `json:"avg_logprob,omitempty"`
+	NoSpeechProb *float64 `json:"no_speech_prob,omitempty"`
+}
+
+// SaveTranscriptionSegments заменяет сегменты результата движка для файла
+func (db *DB) SaveTranscriptionSegments(audioFileID int64, engine, modelVersion string, segments []TranscriptionSegment) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM transcription_segments
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?`,
+		audioFileID, engine, modelVersion); err != nil {
+		return err
+	}
+
+	if len(segments) > 0 {
+		stmt, err := tx.Prepare(`
+			INSERT INTO transcription_segments
+			(audio_file_id, engine, model_version, seq, start_sec, end_sec, text, avg_logprob, no_speech_prob)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, s := range segments {
+			if _, err := stmt.Exec(audioFileID, engine, modelVersion, s.Seq, s.Start, s.End, s.Text,
+				s.AvgLogprob, s.NoSpeechProb); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTranscriptionSegments — сегменты результата движка по порядку
+func (db *DB) GetTranscriptionSegments(audioFileID int64, engine, modelVersion string) ([]TranscriptionSegment, error) {
+	rows, err := db.conn.Query(`
+		SELECT seq, start_sec, end_sec, COALESCE(text, ''), avg_logprob, no_speech_prob
+		FROM transcription_segments
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?
+		ORDER BY seq`, audioFileID, engine, modelVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	segments := []TranscriptionSegment{}
+	for rows.Next() {
+		var s TranscriptionSegment
+		if err := rows.Scan(&s.Seq, &s.Start, &s.End, &s.Text, &s.AvgLogprob, &s.NoSpeechProb); err != nil {
+			return nil, err
+		}
+		segments = append(segments, s)
+	}
+	return segments, rows.Err()
+}
+
+// TranscriptionWord — слово результата движка с таймингом (секунды от начала файла)
+type TranscriptionWord struct {
+	Seq        int      `json:"seq"`
+	Word       string   `json:"word"`
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// SaveTranscriptionWords заменяет слова результата движка для файла
+func (db *DB) SaveTranscriptionWords(audioFileID int64, engine, modelVersion string, words []TranscriptionWord) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM transcription_words
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?`,
+		audioFileID, engine, modelVersion); err != nil {
+		return err
+	}
+
+	if len(words) > 0 {
+		stmt, err := tx.Prepare(`
+			INSERT INTO transcription_words
+			(audio_file_id, engine, model_version, seq, word, start_sec, end_sec, confidence)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, w := range words {
+			if _, err := stmt.Exec(audioFileID, engine, modelVersion, w.Seq, w.Word, w.Start, w.End,
+				w.Confidence); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetTranscriptionWords — слова результата движка по порядку
+func (db *DB) GetTranscriptionWords(audioFileID int64, engine, modelVersion string) ([]TranscriptionWord, error) {
+	rows, err := db.conn.Query(`
+		SELECT seq, word, start_sec, end_sec, confidence
+		FROM transcription_words
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?
+		ORDER BY seq`, audioFileID, engine, modelVersion)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	words := []TranscriptionWord{}
+	for rows.Next() {
+		var w TranscriptionWord
+		if err := rows.Scan(&w.Seq, &w.Word, &w.Start, &w.End, &w.Confidence); err != nil {
+			return nil, err
+		}
+		words = append(words, w)
+	}
+	return words, rows.Err()
+}
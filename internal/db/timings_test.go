@@ -0,0 +1,72 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTranscriptionWords(t *testing.T) {
+	database := testDB(t)
+	id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: "/data/a.wav", FileHash: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := 0.9
+
+	tests := []struct {
+		name  string
+		words []TranscriptionWord
+	}{
+		{"first run", []TranscriptionWord{
+			{Seq: 0, Word: "hello", Start: 0.1, End: 0.4, Confidence: &conf},
+			{Seq: 1, Word: "world", Start: 0.5, End: 0.9},
+		}},
+		{"rerun replaces", []TranscriptionWord{{Seq: 0, Word: "hi", Start: 0, End: 0.3}}},
+		{"empty clears", []TranscriptionWord{}},
+	}
+	for _, tt := range tests {
+		if err := database.SaveTranscriptionWords(id, EngineKaldi, "", tt.words); err != nil {
+			t.Fatal(err)
+		}
+		got, err := database.GetTranscriptionWords(id, EngineKaldi, "")
+		if err != nil || !reflect.DeepEqual(got, tt.words) {
+			t.Errorf("%s: got %+v %v, want %+v", tt.name, got, err, tt.words)
+		}
+	}
+
+	// Версии модели не пересекаются
+	if err := database.SaveTranscriptionWords(id, EngineKaldi, "4gram", tests[0].words); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := database.GetTranscriptionWords(id, EngineKaldi, ""); len(got) != 0 {
+		t.Errorf("base model sees profile words: %+v", got)
+	}
+}
+
+func TestTranscriptionSegments(t *testing.T) {
+	database := testDB(t)
+	id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: "/data/a.wav", FileHash: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logprob, noSpeech := -0.25, 0.01
+
+	segments := []TranscriptionSegment{
+		{Seq: 0, Start: 0, End: 1.5, Text: "hello", AvgLogprob: &logprob, NoSpeechProb: &noSpeech},
+		{Seq: 1, Start: 1.5, End: 3, Text: "world"},
+	}
+	if err := database.SaveTranscriptionSegments(id, EngineWhisperOpenAI, "", segments); err != nil {
+		t.Fatal(err)
+	}
+	got, err := database.GetTranscriptionSegments(id, EngineWhisperOpenAI, "")
+	if err != nil || !reflect.DeepEqual(got, segments) {
+		t.Errorf("segments: %+v %v", got, err)
+	}
+
+	if err := database.DeleteTranscriptions(id); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := database.GetTranscriptionSegments(id, EngineWhisperOpenAI, ""); err != nil || len(got) != 0 {
+		t.Errorf("after delete: %+v %v", got, err)
+	}
+}
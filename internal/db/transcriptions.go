@@ -0,0 +1,430 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Имена движков в таблице transcriptions
+const (
+	EngineKaldi         = "kaldi"
+	EngineKaldiNoLM     = "kaldi-nolm"
+	EngineWhisperLocal  = "whisper-local"
+	EngineWhisperOpenAI = "whisper-openai"
+
+	// PrimaryEngine — эталонный движок: по его WER считаются needs_review
+	// и проверяются файлы перед merge
+	PrimaryEngine = EngineKaldi
+)
+
+// Transcription — результат одного движка (и версии модели) для одного файла
+type Transcription struct {
+	ID           int64     `json:"id"`
+	AudioFileID  int64     `json:"audio_file_id"`
+	Engine       string    `json:"engine"`
+	ModelVersion string    `json:"model_version"`
+	Text         string    `json:"text"`
+	WER          float64   `json:"wer"`
+	CER          float64   `json:"cer"`
+	Status       string    `json:"status"`
+	Attempts     int       `json:"attempts"`
+	LastError    string    `json:"last_error,omitempty"`
+	ErrorClass   string    `json:"error_class,omitempty"` // transient / permanent
+	RTF          float64   `json:"rtf"`
+	CreatedAt    time.Time `json:"created_at"`
+
+	// Уверенность Whisper (verbose_json): avg_logprob по длительности, максимальный no_speech_prob
+	AvgLogprob   *float64 `json:"avg_logprob,omitempty"`
+	NoSpeechProb *float64 `json:"no_speech_prob,omitempty"`
+
+	// Уверенность Kaldi (MBR по lattice): средняя по словам
+	Confidence *float64 `json:"confidence,omitempty"`
+}
+
+// TranscriptionHypotheses — извлечённое из lattice Kaldi: сохранённая lattice,
+// N-best и MBR гипотеза (JSON как есть, формат asr.Hypothesis / asr.MBRResult)
+type TranscriptionHypotheses struct {
+	Confidence  *float64        `json:"confidence,omitempty"`
+	LatticePath string          `json:"lattice_path,omitempty"`
+	NBest       json.RawMessage `json:"nbest,omitempty"`
+	MBR         json.RawMessage `json:"mbr,omitempty"`
+}
+
+// TranscriptionKey — ключ в AudioFile.Transcriptions и в статистике:
+// "engine" или "engine@model_version"
+func TranscriptionKey(engine, modelVersion string) string {
+	if modelVersion == "" {
+		return engine
+	}
+	return engine + "@" + modelVersion
+}
+
+func (t *Transcription) Key() string {
+	return TranscriptionKey(t.Engine, t.ModelVersion)
+}
+
+// TranscriptionRecalc — транскрипция + оригинал для пересчёта WER/CER
+type TranscriptionRecalc struct {
+	ID                    int64
+	AudioFileID           int64
+	Engine                string
+	ModelVersion          string
+	TranscriptionOriginal string
+	Text                  string
+}
+
+// EngineStats — статистика по одному движку/версии
+type EngineStats struct {
+	Engine       string  `json:"engine"`
+	ModelVersion string  `json:"model_version"`
+	Pending      int     `json:"pending"`
+	Processed    int     `json:"processed"`
+	Errors       int     `json:"errors"`
+	AvgWER       float64 `json:"avg_wer"`
+	AvgCER       float64 `json:"avg_cer"`
+}
+
+// transcriptionKeyColumns — уникальный ключ transcriptions
+const transcriptionKeyColumns = "audio_file_id, engine, model_version"
+
+// SaveTranscription сохраняет (или перезаписывает) результат движка для файла.
+// Считается попыткой; last_error от прошлых неудачных попыток остаётся для истории
+func (db *DB) SaveTranscription(t *Transcription) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO transcriptions
+		(audio_file_id, engine, model_version, text, wer, cer, status, attempts, rtf, avg_logprob, no_speech_prob)
+		VALUES (?, ?, ?, ?, ?, ?, 'processed', 1, ?, ?, ?)`+
+		db.upsert(transcriptionKeyColumns, `
+			text = `+db.excluded("text")+`, wer = `+db.excluded("wer")+`, cer = `+db.excluded("cer")+`,
+			status = 'processed', attempts = attempts + 1, rtf = `+db.excluded("rtf")+`,
+			avg_logprob = `+db.excluded("avg_logprob")+`, no_speech_prob = `+db.excluded("no_speech_prob")+`,
+			created_at = CURRENT_TIMESTAMP`),
+		t.AudioFileID, t.Engine, t.ModelVersion, t.Text, t.WER, t.CER, t.RTF, t.AvgLogprob, t.NoSpeechProb)
+	return err
+}
+
+// SaveTranscriptionHypotheses записывает lattice, N-best и MBR для уже сохранённого результата
+func (db *DB) SaveTranscriptionHypotheses(audioFileID int64, engine, modelVersion string, h *TranscriptionHypotheses) error {
+	_, err := db.conn.Exec(`UPDATE transcriptions
+		SET confidence = ?, lattice_path = ?, nbest = ?, mbr = ?
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?`,
+		h.Confidence, nullString(h.LatticePath), nullJSON(h.NBest), nullJSON(h.MBR),
+		audioFileID, engine, modelVersion)
+	return err
+}
+
+// GetTranscriptionHypotheses — lattice, N-best и MBR результата движка; nil — результата нет
+func (db *DB) GetTranscriptionHypotheses(audioFileID int64, engine, modelVersion string) (*TranscriptionHypotheses, error) {
+	var h TranscriptionHypotheses
+	var nbest, mbr sql.NullString
+	err := db.conn.QueryRow(`
+		SELECT confidence, COALESCE(lattice_path, ''), nbest, mbr
+		FROM transcriptions
+		WHERE audio_file_id = ? AND engine = ? AND model_version = ?`,
+		audioFileID, engine, modelVersion).Scan(&h.Confidence, &h.LatticePath, &nbest, &mbr)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if nbest.Valid {
+		h.NBest = json.RawMessage(nbest.String)
+	}
+	if mbr.Valid {
+		h.MBR = json.RawMessage(mbr.String)
+	}
+	return &h, nil
+}
+
+func nullJSON(data json.RawMessage) sql.NullString {
+	return sql.NullString{String: string(data), Valid: len(data) > 0}
+}
+
+// SaveTranscriptionError записывает неудачную попытку: status = error, attempts + 1.
+// errorClass — asr.ErrorTransient / asr.ErrorPermanent
+func (db *DB) SaveTranscriptionError(audioFileID int64, engine, modelVersion, errMsg, errorClass string) error {
+	_, err := db.conn.Exec(`
+		INSERT INTO transcriptions (audio_file_id, engine, model_version, status, attempts, last_error, error_class)
+		VALUES (?, ?, ?, 'error', 1, ?, ?)`+
+		db.upsert(transcriptionKeyColumns, `status = 'error', attempts = attempts + 1,
+			last_error = `+db.excluded("last_error")+`, error_class = `+db.excluded("error_class")),
+		audioFileID, engine, modelVersion, errMsg, nullString(errorClass))
+	return err
+}
+
+// RequeueRetryableErrors возвращает в pending ошибки движка класса transient.
+// maxAttempts > 0 — только файлы, у которых попыток меньше; unclassified — также
+// ошибки без класса (записанные до появления классификации)
+func (db *DB) RequeueRetryableErrors(engine, modelVersion string, maxAttempts int, unclassified bool) (int64, error) {
+	query := `UPDATE transcriptions SET status = 'pending'
+		WHERE engine = ? AND model_version = ? AND status = 'error'`
+	args := []interface{}{engine, modelVersion}
+
+	if unclassified {
+		query += " AND (error_class = 'transient' OR error_class IS NULL)"
+	} else {
+		query += " AND error_class = 'transient'"
+	}
+	if maxAttempts > 0 {
+		query += " AND attempts < ?"
+		args = append(args, maxAttempts)
+	}
+
+	res, err := db.conn.Exec(query, args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateTranscriptionMetrics обновляет только WER/CER
+func (db *DB) UpdateTranscriptionMetrics(id int64, wer, cer float64) error {
+	_, err := db.conn.Exec(`UPDATE transcriptions SET wer = ?, cer = ? WHERE id = ?`, wer, cer, id)
+	return err
+}
+
+// GetPendingTranscriptions возвращает активные файлы без готового результата движка
+// (нет строки в transcriptions или status = 'pending')
+func (db *DB) GetPendingTranscriptions(engine, modelVersion string, limit int) ([]AudioFile, error) {
+	query := `
+		SELECT a.id, a.file_path, a.file_hash, COALESCE(a.transcription_original, ''), COALESCE(a.duration_sec, 0),
+		       a.segment_start, a.segment_end
+		FROM audio_files a
+		LEFT JOIN transcriptions t
+		       ON t.audio_file_id = a.id AND t.engine = ? AND t.model_version = ?
+		WHERE a.active = 1 AND (t.id IS NULL OR t.status = 'pending')`
+
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	return db.queryPendingFiles(query, engine, modelVersion)
+}
+
+// GetPendingTranscriptionsByWER — pending файлы, для которых refEngine уже
+// отработал с WER > minRefWER (например OpenAI только там, где ошибся локальный Whisper)
+func (db *DB) GetPendingTranscriptionsByWER(engine, modelVersion string, limit int, refEngine string, minRefWER float64) ([]AudioFile, error) {
+	query := `
+		SELECT a.id, a.file_path, a.file_hash, COALESCE(a.transcription_original, ''), COALESCE(a.duration_sec, 0),
+		       a.segment_start, a.segment_end
+		FROM audio_files a
+		LEFT JOIN transcriptions t
+		       ON t.audio_file_id = a.id AND t.engine = ? AND t.model_version = ?
+		WHERE a.active = 1 AND (t.id IS NULL OR t.status = 'pending')
+		  AND EXISTS (SELECT 1 FROM transcriptions r
+		              WHERE r.audio_file_id = a.id AND r.engine = ?
+		                AND r.status = 'processed' AND r.wer > ?)`
+
+	if limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", limit)
+	}
+
+	return db.queryPendingFiles(query, engine, modelVersion, refEngine, minRefWER)
+}
+
+func (db *DB) queryPendingFiles(query string, args ...interface{}) ([]AudioFile, error) {
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudioFile
+	for rows.Next() {
+		var af AudioFile
+		var segStart, segEnd sql.NullFloat64
+		if err := rows.Scan(&af.ID, &af.FilePath, &af.FileHash, &af.TranscriptionOriginal, &af.DurationSec,
+			&segStart, &segEnd); err != nil {
+			return nil, err
+		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		files = append(files, af)
+	}
+	return files, rows.Err()
+}
+
+const transcriptionColumns = `id, audio_file_id, engine, model_version, COALESCE(text, ''),
+	COALESCE(wer, 0), COALESCE(cer, 0), status, attempts, COALESCE(last_error, ''), COALESCE(error_class, ''),
+	COALESCE(rtf, 0), created_at, avg_logprob, no_speech_prob, confidence`
+
+func scanTranscription(rows *sql.Rows) (*Transcription, error) {
+	var t Transcription
+	err := rows.Scan(&t.ID, &t.AudioFileID, &t.Engine, &t.ModelVersion, &t.Text,
+		&t.WER, &t.CER, &t.Status, &t.Attempts, &t.LastError, &t.ErrorClass, &t.RTF, &t.CreatedAt,
+		&t.AvgLogprob, &t.NoSpeechProb, &t.Confidence)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetTranscriptions возвращает все результаты движков для файла
+func (db *DB) GetTranscriptions(audioFileID int64) ([]Transcription, error) {
+	rows, err := db.conn.Query(`SELECT `+transcriptionColumns+`
+		FROM transcriptions WHERE audio_file_id = ? ORDER BY engine, model_version`, audioFileID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []Transcription
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, *t)
+	}
+	return list, rows.Err()
+}
+
+// GetTranscriptionsForFiles — результаты для списка файлов: id -> key -> транскрипция
+func (db *DB) GetTranscriptionsForFiles(ids []int64) (map[int64]map[string]*Transcription, error) {
+	result := make(map[int64]map[string]*Transcription)
+	if len(ids) == 0 {
+		return result, nil
+	}
+
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM transcriptions WHERE audio_file_id IN (%s)`,
+		transcriptionColumns, strings.Join(placeholders, ","))
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanTranscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		if result[t.AudioFileID] == nil {
+			result[t.AudioFileID] = make(map[string]*Transcription)
+		}
+		result[t.AudioFileID][t.Key()] = t
+	}
+	return result, rows.Err()
+}
+
+// attachTranscriptions заполняет AudioFile.Transcriptions для списка файлов
+func (db *DB) attachTranscriptions(files []AudioFile) error {
+	ids := make([]int64, len(files))
+	for i := range files {
+		ids[i] = files[i].ID
+	}
+
+	byFile, err := db.GetTranscriptionsForFiles(ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		files[i].Transcriptions = byFile[files[i].ID]
+	}
+	return nil
+}
+
+// GetTranscriptionsForRecalc возвращает готовые транскрипции для пересчёта WER/CER.
+// audioFileID = 0 — по всем файлам
+func (db *DB) GetTranscriptionsForRecalc(audioFileID int64) ([]TranscriptionRecalc, error) {
+	query := `
+		SELECT t.id, t.audio_file_id, t.engine, t.model_version,
+		       COALESCE(a.transcription_original, ''), COALESCE(t.text, '')
+		FROM transcriptions t
+		JOIN audio_files a ON a.id = t.audio_file_id
+		WHERE t.status = 'processed'`
+
+	var args []interface{}
+	if audioFileID > 0 {
+		query += " AND t.audio_file_id = ?"
+		args = append(args, audioFileID)
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var list []TranscriptionRecalc
+	for rows.Next() {
+		var r TranscriptionRecalc
+		if err := rows.Scan(&r.ID, &r.AudioFileID, &r.Engine, &r.ModelVersion,
+			&r.TranscriptionOriginal, &r.Text); err != nil {
+			return nil, err
+		}
+		list = append(list, r)
+	}
+	return list, rows.Err()
+}
+
+// TranscriptionStats — статистика по всем движкам/версиям, которые есть в таблице.
+// Pending считается от total: файлы без результата движка тоже pending
+func (db *DB) TranscriptionStats(total int) (map[string]*EngineStats, error) {
+	rows, err := db.conn.Query(`
+		SELECT engine, model_version, status, COUNT(*),
+		       COALESCE(AVG(wer), 0), COALESCE(AVG(cer), 0)
+		FROM transcriptions
+		GROUP BY engine, model_version, status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]*EngineStats)
+	for rows.Next() {
+		var engine, version, status string
+		var count int
+		var avgWER, avgCER float64
+		if err := rows.Scan(&engine, &version, &status, &count, &avgWER, &avgCER); err != nil {
+			return nil, err
+		}
+
+		key := TranscriptionKey(engine, version)
+		s := result[key]
+		if s == nil {
+			s = &EngineStats{Engine: engine, ModelVersion: version}
+			result[key] = s
+		}
+
+		switch status {
+		case "processed":
+			s.Processed = count
+			s.AvgWER = avgWER
+			s.AvgCER = avgCER
+		case "error":
+			s.Errors = count
+		}
+	}
+
+	for _, s := range result {
+		s.Pending = total - s.Processed - s.Errors
+		if s.Pending < 0 {
+			s.Pending = 0
+		}
+	}
+	return result, rows.Err()
+}
+
+// DeleteTranscriptions удаляет все результаты движков для файла (вместе с сегментами и словами)
+func (db *DB) DeleteTranscriptions(audioFileID int64) error {
+	for _, table := range []string{"transcription_segments", "transcription_words"} {
+		if _, err := db.conn.Exec("DELETE FROM "+table+" WHERE audio_file_id = ?", audioFileID); err != nil {
+			return err
+		}
+	}
+	_, err := db.conn.Exec("DELETE FROM transcriptions WHERE audio_file_id = ?", audioFileID)
+	return err
+}
@@ -0,0 +1,221 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDriverSQL(t *testing.T) {
+	tests := []struct {
+		driver                       string
+		upsert, excluded, secondsAgo string
+	}{
+		{DriverSQLite, " ON CONFLICT(a, b) DO UPDATE SET x = 1", "excluded.x", "datetime('now', '-30 seconds')"},
+		{DriverMySQL, " ON DUPLICATE KEY UPDATE x = 1", "VALUES(x)", "(NOW() - INTERVAL 30 SECOND)"},
+	}
+	for _, tt := range tests {
+		d := &DB{driver: tt.driver}
+		if got := d.upsert("a, b", "x = 1"); got != tt.upsert {
+			t.Errorf("%s upsert: %q", tt.driver, got)
+		}
+		if got := d.excluded("x"); got != tt.excluded {
+			t.Errorf("%s excluded: %q", tt.driver, got)
+		}
+		if got := d.secondsAgo(30); got != tt.secondsAgo {
+			t.Errorf("%s secondsAgo: %q", tt.driver, got)
+		}
+	}
+}
+
+func TestSaveTranscription(t *testing.T) {
+	database := testDB(t)
+	id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: "/data/a.wav", FileHash: "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ошибка, затем два успешных результата: одна строка, попытки копятся
+	if err := database.SaveTranscriptionError(id, EngineWhisperLocal, "", "status 503", "transient"); err != nil {
+		t.Fatal(err)
+	}
+	for _, text := range []string{"first", "second"} {
+		if err := database.SaveTranscription(&Transcription{AudioFileID: id, Engine: EngineWhisperLocal, Text: text, WER: 0.5}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := database.GetTranscriptions(id)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("transcriptions: %+v %v", list, err)
+	}
+	if tr := list[0]; tr.Text != "second" || tr.Status != "processed" || tr.Attempts != 3 || tr.LastError != "status 503" {
+		t.Errorf("transcription: %+v", tr)
+	}
+}
+
+func TestRequeueRetryableErrors(t *testing.T) {
+	errs := []struct {
+		class    string
+		attempts int
+	}{
+		{"transient", 1},
+		{"transient", 3},
+		{"permanent", 1},
+		{"", 1}, // до классификации
+	}
+
+	tests := []struct {
+		name         string
+		maxAttempts  int
+		unclassified bool
+		want         int64
+	}{
+		{"transient", 0, false, 2},
+		{"under max attempts", 3, false, 1},
+		{"with unclassified", 0, true, 3},
+		{"unclassified under max attempts", 2, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database := testDB(t)
+			for i, e := range errs {
+				path := fmt.Sprintf("/data/%d.wav", i)
+				id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: path, FileHash: path})
+				if err != nil {
+					t.Fatal(err)
+				}
+				for n := 0; n < e.attempts; n++ {
+					if err := database.SaveTranscriptionError(id, EngineKaldi, "", "failed", e.class); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			n, err := database.RequeueRetryableErrors(EngineKaldi, "", tt.maxAttempts, tt.unclassified)
+			if err != nil || n != tt.want {
+				t.Fatalf("requeued %d, want %d (%v)", n, tt.want, err)
+			}
+			pending, err := database.GetPendingTranscriptions(EngineKaldi, "", 0)
+			if err != nil || int64(len(pending)) != tt.want {
+				t.Errorf("pending %d, want %d (%v)", len(pending), tt.want, err)
+			}
+		})
+	}
+}
+
+func TestGetPendingTranscriptions(t *testing.T) {
+	database := testDB(t)
+
+	ids := make(map[string]int64)
+	for _, name := range []string{"new", "done", "inactive", "bad-ref", "good-ref"} {
+		id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: "/data/" + name + ".wav", FileHash: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = id
+	}
+	save := func(name, engine string, wer float64) {
+		if err := database.SaveTranscription(&Transcription{AudioFileID: ids[name], Engine: engine, WER: wer}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save("done", EngineWhisperOpenAI, 0)
+	save("bad-ref", EngineWhisperLocal, 0.5)
+	save("good-ref", EngineWhisperLocal, 0)
+	save("inactive", EngineWhisperLocal, 0.5)
+	if err := database.DeactivateFiles([]int64{ids["inactive"]}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		query func() ([]AudioFile, error)
+		want  []string
+	}{
+		{"pending", func() ([]AudioFile, error) {
+			return database.GetPendingTranscriptions(EngineWhisperOpenAI, "", 0)
+		}, []string{"new", "bad-ref", "good-ref"}},
+		{"by ref WER", func() ([]AudioFile, error) {
+			return database.GetPendingTranscriptionsByWER(EngineWhisperOpenAI, "", 0, EngineWhisperLocal, 0)
+		}, []string{"bad-ref"}},
+	}
+	for _, tt := range tests {
+		files, err := tt.query()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got := make(map[int64]bool)
+		for _, f := range files {
+			got[f.ID] = true
+		}
+		if len(files) != len(tt.want) {
+			t.Errorf("%s: %d files, want %v", tt.name, len(files), tt.want)
+		}
+		for _, name := range tt.want {
+			if !got[ids[name]] {
+				t.Errorf("%s: %s missing", tt.name, name)
+			}
+		}
+	}
+
+	if files, err := database.GetPendingTranscriptions(EngineWhisperOpenAI, "", 1); err != nil || len(files) != 1 {
+		t.Errorf("limit 1: %d files %v", len(files), err)
+	}
+}
+
+func TestTranscriptionKey(t *testing.T) {
+	tests := []struct {
+		engine, modelVersion, want string
+	}{
+		{EngineKaldi, "", "kaldi"},
+		{EngineKaldi, "4gram", "kaldi@4gram"},
+		{EngineWhisperOpenAI, "", EngineWhisperOpenAI},
+	}
+	for _, tt := range tests {
+		if got := TranscriptionKey(tt.engine, tt.modelVersion); got != tt.want {
+			t.Errorf("TranscriptionKey(%q, %q) = %q, want %q", tt.engine, tt.modelVersion, got, tt.want)
+		}
+	}
+}
+
+func TestTranscriptionsForFiles(t *testing.T) {
+	database := testDB(t)
+
+	var ids []int64
+	for _, path := range []string{"/data/a.wav", "/data/b.wav"} {
+		id, err := database.Insert(&AudioFile{UserID: "1001", ChapterID: "2001", FilePath: path, FileHash: path})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// Один движок с двумя версиями модели — две строки, не перезапись
+	for _, tr := range []Transcription{
+		{AudioFileID: ids[0], Engine: EngineKaldi, Text: "base"},
+		{AudioFileID: ids[0], Engine: EngineKaldi, ModelVersion: "4gram", Text: "profile"},
+		{AudioFileID: ids[0], Engine: EngineWhisperLocal, Text: "whisper"},
+	} {
+		if err := database.SaveTranscription(&tr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byFile, err := database.GetTranscriptionsForFiles(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byFile[ids[0]]
+	if len(got) != 3 || got["kaldi"].Text != "base" || got["kaldi@4gram"].Text != "profile" || got[EngineWhisperLocal].Text != "whisper" {
+		t.Errorf("file a: %+v", got)
+	}
+	if len(byFile[ids[1]]) != 0 {
+		t.Errorf("file b: %+v", byFile[ids[1]])
+	}
+
+	if err := database.DeleteTranscriptions(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := database.GetTranscriptions(ids[0]); err != nil || len(list) != 0 {
+		t.Errorf("after delete: %+v %v", list, err)
+	}
+}
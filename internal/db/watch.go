@@ -0,0 +1,62 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+)
+
+// GetFilesUnderDir возвращает исходные (не merged) файлы, импортированные из каталога dir
+// (source_key, а для старых строк — file_path), с полями, которые сверяет слежение за каталогом.
+// SourceKey заполнен всегда: у старых строк это FileKey
+func (db *DB) GetFilesUnderDir(dir string) ([]AudioFile, error) {
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	// LIKE только сужает выборку: _ и % в путях проверяются ниже по префиксу
+	rows, err := db.conn.Query(`
+		SELECT id, file_path, COALESCE(file_hash, ''), segment_start, segment_end, source_key,
+		       COALESCE(transcription_original, ''), transcription_source, COALESCE(original_edited, 0), active
+		FROM audio_files
+		WHERE COALESCE(source_key, file_path) LIKE ? AND parent_ids IS NULL
+		ORDER BY id`, prefix+"%")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []AudioFile
+	for rows.Next() {
+		var af AudioFile
+		var segStart, segEnd sql.NullFloat64
+		var sourceKey, source sql.NullString
+		if err := rows.Scan(&af.ID, &af.FilePath, &af.FileHash, &segStart, &segEnd, &sourceKey,
+			&af.TranscriptionOriginal, &source, &af.OriginalEdited, &af.Active); err != nil {
+			return nil, err
+		}
+		af.SegmentStart, af.SegmentEnd = floatPtr(segStart), floatPtr(segEnd)
+		af.SourceKey = sourceKey.String
+		if !sourceKey.Valid {
+			af.SourceKey = af.FileKey()
+		}
+		if source.Valid {
+			af.TranscriptionSource = &source.String
+		}
+		if !strings.HasPrefix(af.SourceKey, prefix) {
+			continue
+		}
+		files = append(files, af)
+	}
+	return files, rows.Err()
+}
+
+// UpdateDiskTranscription обновляет эталон, изменившийся на диске; правка оператора
+// (original_edited) не перезаписывается — false, если строка не изменилась
+func (db *DB) UpdateDiskTranscription(id int64, text string) (bool, error) {
+	res, err := db.conn.Exec(`
+		UPDATE audio_files SET transcription_original = ?, transcription_source = ?
+		WHERE id = ? AND COALESCE(original_edited, 0) = 0`, text, text, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
@@ -42,6 +42,14 @@ func WER(reference, hypothesis string) float64 {
 	return float64(d) / float64(len(refWords))
 }
 
+// WordErrors — число ошибок в словах и длина эталона: для WER по корпусу
+// (сумма ошибок / сумма слов, а не среднее WER файлов)
+func WordErrors(reference, hypothesis string) (errs, words int) {
+	refWords := strings.Fields(normalizeText(reference))
+	hypWords := strings.Fields(normalizeText(hypothesis))
+	return levenshteinWords(refWords, hypWords), len(refWords)
+}
+
 // CER - Character Error Rate
 func CER(reference, hypothesis string) float64 {
 	// Нормализуем оба текста
@@ -0,0 +1,103 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Разбиения Common Voice: validated.tsv содержит train/dev/test, поэтому берётся он,
+// а без него — доступные разбиения по отдельности
+var commonVoiceSplits = [][]string{
+	{"validated.tsv"},
+	{"train.tsv", "dev.tsv", "test.tsv"},
+}
+
+// ScanCommonVoice — Mozilla Common Voice: TSV с колонками client_id, path, sentence,
+// аудио в clips/ (обычно mp3 — метаданные через ffprobe). user_id — client_id
+// (обрезанный до 64 символов), chapter_id — имя TSV без расширения
+func ScanCommonVoice(rootDir string, limit int) ([]AudioTask, error) {
+	var tsvFiles []string
+	for _, split := range commonVoiceSplits {
+		for _, name := range split {
+			if path := filepath.Join(rootDir, name); fileExists(path) {
+				tsvFiles = append(tsvFiles, path)
+			}
+		}
+		if len(tsvFiles) > 0 {
+			break
+		}
+	}
+	if len(tsvFiles) == 0 {
+		return nil, fmt.Errorf("%s: no validated.tsv or train/dev/test.tsv", rootDir)
+	}
+
+	clipsDir := filepath.Join(rootDir, "clips")
+	seen := make(map[string]bool)
+	var tasks []AudioTask
+	for _, tsv := range tsvFiles {
+		chapter := strings.TrimSuffix(filepath.Base(tsv), ".tsv")
+		err := readTSV(tsv, func(row map[string]string) bool {
+			path := filepath.Join(clipsDir, row["path"])
+			if row["path"] == "" || seen[path] || !fileExists(path) {
+				return true
+			}
+			seen[path] = true
+
+			speaker := row["client_id"]
+			if speaker == "" {
+				speaker = unknownSpeaker
+			}
+			tasks = append(tasks, AudioTask{
+				UserID:        shortID(speaker),
+				ChapterID:     chapter,
+				WavPath:       path,
+				Transcription: strings.TrimSpace(row["sentence"]),
+			})
+			return limit <= 0 || len(tasks) < limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(tasks) >= limit {
+			break
+		}
+	}
+	return tasks, nil
+}
+
+// readTSV вызывает fn для каждой строки TSV с заголовком; fn возвращает false — остановиться.
+// Кавычки не разбираются: в предложениях Common Voice они встречаются без экранирования
+func readTSV(path string, fn func(row map[string]string) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	if !scanner.Scan() {
+		return scanner.Err()
+	}
+	header := strings.Split(scanner.Text(), "\t")
+
+	for scanner.Scan() {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), "\t")
+		row := make(map[string]string, len(header))
+		for i, name := range header {
+			if i < len(fields) {
+				row[name] = fields[i]
+			}
+		}
+		if !fn(row) {
+			break
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,117 @@
+package scanner
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Имена колонок CSV с заголовком
+var (
+	csvPathColumns       = []string{"path", "file", "filename", "audio", "audio_path", "wav"}
+	csvTranscriptColumns = []string{"transcript", "transcription", "text", "sentence"}
+	csvSpeakerColumns    = []string{"speaker", "speaker_id", "user_id", "client_id"}
+)
+
+// ScanCSV — произвольные *.csv в rootDir: колонки path,transcript[,speaker]. Если первая
+// строка — заголовок (первая колонка называется path, file, audio, ...), колонки ищутся
+// по именам. Относительные пути — от каталога CSV, chapter_id — имя CSV без расширения
+func ScanCSV(rootDir string, limit int) ([]AudioTask, error) {
+	files := globSorted(rootDir, "*.csv")
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%s: no *.csv files", rootDir)
+	}
+
+	var tasks []AudioTask
+	for _, file := range files {
+		chapter := shortID(strings.TrimSuffix(filepath.Base(file), ".csv"))
+		err := readCSV(file, func(path, transcript, speaker string) bool {
+			path = resolvePath(filepath.Dir(file), path)
+			if !fileExists(path) {
+				return true
+			}
+			if speaker == "" {
+				speaker = unknownSpeaker
+			}
+			tasks = append(tasks, AudioTask{
+				UserID:        shortID(speaker),
+				ChapterID:     chapter,
+				WavPath:       path,
+				Transcription: strings.TrimSpace(transcript),
+			})
+			return limit <= 0 || len(tasks) < limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(tasks) >= limit {
+			break
+		}
+	}
+	return tasks, nil
+}
+
+// readCSV вызывает fn для каждой строки с путём; fn возвращает false — остановиться
+func readCSV(path string, fn func(path, transcript, speaker string) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+
+	pathCol, textCol, speakerCol := 0, 1, 2
+	first := true
+	for {
+		rec, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		if first {
+			first = false
+			if len(rec) > 0 && columnIndex(rec, csvPathColumns) == 0 {
+				pathCol = 0
+				textCol = columnIndex(rec, csvTranscriptColumns)
+				speakerCol = columnIndex(rec, csvSpeakerColumns)
+				continue
+			}
+		}
+
+		field := func(i int) string {
+			if i < 0 || i >= len(rec) {
+				return ""
+			}
+			return rec[i]
+		}
+		if field(pathCol) == "" {
+			continue
+		}
+		if !fn(field(pathCol), field(textCol), strings.TrimSpace(field(speakerCol))) {
+			return nil
+		}
+	}
+}
+
+// columnIndex — индекс первой колонки заголовка с одним из имён, -1 — нет такой
+func columnIndex(header []string, names []string) int {
+	for i, h := range header {
+		h = strings.ToLower(strings.TrimSpace(h))
+		for _, name := range names {
+			if h == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
@@ -0,0 +1,143 @@
+package scanner
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Importer разбирает корпус в каталоге rootDir в задачи импорта; limit > 0 — не больше limit задач
+type Importer interface {
+	Scan(rootDir string, limit int) ([]AudioTask, error)
+}
+
+// ImporterFunc — функция как Importer
+type ImporterFunc func(rootDir string, limit int) ([]AudioTask, error)
+
+func (f ImporterFunc) Scan(rootDir string, limit int) ([]AudioTask, error) {
+	return f(rootDir, limit)
+}
+
+// Форматы корпусов для POST /api/scan/start?format=
+const (
+	FormatLibriSpeech = "librispeech" // speaker/chapter/speaker-chapter.trans.txt + .wav
+	FormatKaldi       = "kaldi"       // wav.scp, text, utt2spk, необязательный segments
+	FormatCommonVoice = "commonvoice" // *.tsv (client_id, path, sentence) + clips/
+	FormatNeMo        = "nemo"        // JSONL манифесты (audio_filepath, text, offset, duration)
+	FormatCSV         = "csv"         // *.csv: path,transcript,speaker
+	FormatLJSpeech    = "ljspeech"    // metadata.csv (id|text|normalized) + wavs/
+)
+
+// DefaultFormat — формат, когда format не указан
+const DefaultFormat = FormatLibriSpeech
+
+var importers = map[string]Importer{
+	FormatLibriSpeech: ImporterFunc(ScanLibriSpeech),
+	FormatKaldi:       ImporterFunc(ScanKaldi),
+	FormatCommonVoice: ImporterFunc(ScanCommonVoice),
+	FormatNeMo:        ImporterFunc(ScanNeMo),
+	FormatCSV:         ImporterFunc(ScanCSV),
+	FormatLJSpeech:    ImporterFunc(ScanLJSpeech),
+}
+
+// GetImporter возвращает импортёр формата; пустой format — DefaultFormat
+func GetImporter(format string) (Importer, error) {
+	if format == "" {
+		format = DefaultFormat
+	}
+	imp, ok := importers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (supported: %s)", format, strings.Join(Formats(), ", "))
+	}
+	return imp, nil
+}
+
+// Formats — поддерживаемые форматы по алфавиту
+func Formats() []string {
+	formats := make([]string, 0, len(importers))
+	for name := range importers {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// unknownSpeaker — user_id, когда формат не знает диктора
+const unknownSpeaker = "unknown"
+
+// maxIDLen — длина user_id / chapter_id в audio_files
+const maxIDLen = 64
+
+// shortID обрезает идентификатор до размера колонки
+func shortID(id string) string {
+	if len(id) > maxIDLen {
+		return id[:maxIDLen]
+	}
+	return id
+}
+
+// resolvePath — относительные пути в манифестах считаются от каталога манифеста
+func resolvePath(baseDir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(baseDir, path)
+}
+
+// fileExists — обычный файл (не каталог)
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
+
+// readKeyValues читает файл вида "ключ значение" (text, utt2spk, wav.scp); порядок ключей сохраняется
+func readKeyValues(path string) ([]string, map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	var keys []string
+	values := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		key, value := cutKey(line)
+		if _, dup := values[key]; !dup {
+			keys = append(keys, key)
+		}
+		values[key] = value
+	}
+	return keys, values, scanner.Err()
+}
+
+// cutKey делит строку "ключ значение" по первой серии пробелов или табуляций
+// (Kaldi text и wav.scp бывают с табами)
+func cutKey(line string) (key, value string) {
+	line = strings.TrimSpace(line)
+	i := strings.IndexFunc(line, unicode.IsSpace)
+	if i < 0 {
+		return line, ""
+	}
+	return line[:i], strings.TrimSpace(line[i:])
+}
+
+// globSorted — файлы каталога dir по маскам, по алфавиту
+func globSorted(dir string, patterns ...string) []string {
+	var files []string
+	for _, p := range patterns {
+		matches, _ := filepath.Glob(filepath.Join(dir, p))
+		files = append(files, matches...)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,185 @@
+package scanner
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// corpus создаёт файлы: имя → содержимое
+func corpus(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestImporters(t *testing.T) {
+	cases := []struct {
+		format string
+		files  map[string]string
+		want   []AudioTask // WavPath относительно корпуса
+	}{
+		{
+			format: FormatKaldi,
+			files: map[string]string{
+				"wav.scp":        "rec1 audio/rec1.wav\nrec2 sox audio/rec2.flac -t wav - |\n",
+				"text":           "u1 hello world\nu2 second line\nu3 piped\n",
+				"utt2spk":        "u1 spk1\nu2 spk1\nu3 spk2\n",
+				"segments":       "u1 rec1 0.00 1.50\nu2 rec1 1.50 3.25\nu3 rec2 0 1\n",
+				"audio/rec1.wav": "",
+			},
+			want: []AudioTask{
+				{UserID: "spk1", ChapterID: "rec1", WavPath: "audio/rec1.wav", Transcription: "hello world", Start: 0, Duration: 1.5, TransFile: "text", UttID: "u1"},
+				{UserID: "spk1", ChapterID: "rec1", WavPath: "audio/rec1.wav", Transcription: "second line", Start: 1.5, Duration: 1.75, TransFile: "text", UttID: "u2"},
+			},
+		},
+		{
+			format: FormatCommonVoice,
+			files: map[string]string{
+				"validated.tsv": "client_id\tpath\tsentence\tup_votes\nabc\ta.mp3\t\"Quoted\" text\t2\nabc\tmissing.mp3\tx\t0\n",
+				"train.tsv":     "client_id\tpath\tsentence\nzzz\tb.mp3\tignored\n",
+				"clips/a.mp3":   "",
+				"clips/b.mp3":   "",
+			},
+			want: []AudioTask{
+				{UserID: "abc", ChapterID: "validated", WavPath: "clips/a.mp3", Transcription: `"Quoted" text`},
+			},
+		},
+		{
+			format: FormatNeMo,
+			files: map[string]string{
+				"train.json": `{"audio_filepath": "a.wav", "text": "first", "duration": 1.2, "speaker": "s1"}` + "\n" +
+					`{"audio_filepath": "long.wav", "text": "second", "offset": 2.5, "duration": 1.0}` + "\n",
+				"a.wav":    "",
+				"long.wav": "",
+			},
+			want: []AudioTask{
+				{UserID: "s1", ChapterID: "train", WavPath: "a.wav", Transcription: "first"},
+				{UserID: unknownSpeaker, ChapterID: "train", WavPath: "long.wav", Transcription: "second", Start: 2.5, Duration: 1},
+			},
+		},
+		{
+			format: FormatCSV,
+			files: map[string]string{
+				"a.csv":      "wavs/1.wav,\"one, two\",spk\n",
+				"b.csv":      "text,speaker\n",
+				"c.csv":      "Audio,Speaker,Transcript\nwavs/2.wav,s2,three\n",
+				"wavs/1.wav": "",
+				"wavs/2.wav": "",
+			},
+			want: []AudioTask{
+				{UserID: "spk", ChapterID: "a", WavPath: "wavs/1.wav", Transcription: "one, two"},
+				{UserID: "s2", ChapterID: "c", WavPath: "wavs/2.wav", Transcription: "three"},
+			},
+		},
+		{
+			format: FormatLJSpeech,
+			files: map[string]string{
+				"metadata.csv":        "LJ001-0001|Printing, in 1 sense|Printing, in one sense\nLJ001-0002|\"quoted\"|\n",
+				"wavs/LJ001-0001.wav": "",
+				"wavs/LJ001-0002.wav": "",
+			},
+			want: []AudioTask{
+				{ChapterID: "LJ001", WavPath: "wavs/LJ001-0001.wav", Transcription: "Printing, in one sense"},
+				{ChapterID: "LJ001", WavPath: "wavs/LJ001-0002.wav", Transcription: `"quoted"`},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		dir := corpus(t, c.files)
+		imp, err := GetImporter(c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := imp.Scan(dir, 0)
+		if err != nil {
+			t.Errorf("%s: %v", c.format, err)
+			continue
+		}
+		if len(got) != len(c.want) {
+			t.Errorf("%s: got %d tasks: %+v", c.format, len(got), got)
+			continue
+		}
+		for i, want := range c.want {
+			want.WavPath = filepath.Join(dir, want.WavPath)
+			if want.TransFile != "" {
+				want.TransFile = filepath.Join(dir, want.TransFile)
+			}
+			if want.UserID == "" {
+				want.UserID = filepath.Base(dir)
+			}
+			if got[i] != want {
+				t.Errorf("%s task %d:\n got %+v\nwant %+v", c.format, i, got[i], want)
+			}
+		}
+
+		if limited, _ := imp.Scan(dir, 1); len(limited) != 1 {
+			t.Errorf("%s: limit 1 gave %d tasks", c.format, len(limited))
+		}
+	}
+
+	if _, err := GetImporter("timit"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestWriteTranscript(t *testing.T) {
+	dir := corpus(t, map[string]string{"text": "u1 hello world\nu10 other\n\nu2\tsecond line\n"})
+	path := filepath.Join(dir, "text")
+
+	if err := WriteTranscript(path, "u1", "hello there"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteTranscript(path, "u2", "tab separated"); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if want := "u1 hello there\nu10 other\n\nu2 tab separated\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	if err := WriteTranscript(path, "u3", "x"); err == nil {
+		t.Error("missing utterance accepted")
+	}
+}
+
+func TestWriteTranscriptMultiline(t *testing.T) {
+	dir := corpus(t, map[string]string{
+		"text":          "u1 hello\r\nu2 world\r\n",
+		"1-2.trans.txt": "1-2-0001 hello\n1-2-0002 world\n",
+	})
+	text := "fake line\r\nu9 injected\ttext "
+
+	kaldi := filepath.Join(dir, "text")
+	if err := WriteTranscript(kaldi, "u1", text); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(kaldi)
+	if want := "u1 fake line u9 injected text\r\nu2 world\r\n"; string(data) != want {
+		t.Errorf("got %q, want %q", data, want)
+	}
+	keys, values, err := readKeyValues(kaldi)
+	if err != nil || !reflect.DeepEqual(keys, []string{"u1", "u2"}) || values["u1"] != "fake line u9 injected text" {
+		t.Errorf("kaldi text: %v %v %v", keys, values, err)
+	}
+
+	libri := filepath.Join(dir, "1-2.trans.txt")
+	if err := WriteTranscript(libri, "1-2-0001", text); err != nil {
+		t.Fatal(err)
+	}
+	got, err := parseTransFile(libri)
+	want := map[string]string{"1-2-0001": "fake line u9 injected text", "1-2-0002": "world"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("trans.txt: %v %v", got, err)
+	}
+}
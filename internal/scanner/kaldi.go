@@ -0,0 +1,118 @@
+package scanner
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ScanKaldi — Kaldi data dir: wav.scp (recording → путь), text (utterance → текст), utt2spk.
+// Без segments recording = utterance, chapter_id — имя каталога; с segments каждая
+// строка "utt rec start end" — отрезок записи rec, chapter_id — rec.
+// Строки wav.scp с командой (заканчиваются на "|") не поддерживаются и пропускаются
+func ScanKaldi(rootDir string, limit int) ([]AudioTask, error) {
+	_, recordings, err := readKeyValues(filepath.Join(rootDir, "wav.scp"))
+	if err != nil {
+		return nil, err
+	}
+	textFile := filepath.Join(rootDir, "text")
+	uttIDs, texts, err := readKeyValues(textFile)
+	if err != nil {
+		return nil, err
+	}
+	_, speakers, err := readKeyValues(filepath.Join(rootDir, "utt2spk"))
+	if err != nil {
+		return nil, err
+	}
+
+	var segments map[string]kaldiSegment
+	if segmentsFile := filepath.Join(rootDir, "segments"); fileExists(segmentsFile) {
+		if segments, err = readKaldiSegments(segmentsFile); err != nil {
+			return nil, err
+		}
+	}
+
+	chapter := shortID(filepath.Base(rootDir))
+	var tasks []AudioTask
+	skipped := 0
+	for _, utt := range uttIDs {
+		speaker, ok := speakers[utt]
+		if !ok {
+			skipped++
+			continue
+		}
+
+		rec, seg := utt, kaldiSegment{}
+		if segments != nil {
+			if seg, ok = segments[utt]; !ok {
+				skipped++
+				continue
+			}
+			rec = seg.Recording
+		}
+
+		path, ok := recordings[rec]
+		if !ok || strings.HasSuffix(path, "|") {
+			skipped++
+			continue
+		}
+		path = resolvePath(rootDir, path)
+		if !fileExists(path) {
+			skipped++
+			continue
+		}
+
+		task := AudioTask{
+			UserID:        shortID(speaker),
+			ChapterID:     chapter,
+			WavPath:       path,
+			Transcription: texts[utt],
+			TransFile:     textFile,
+			UttID:         utt,
+		}
+		if segments != nil {
+			task.ChapterID = shortID(rec)
+			task.Start = seg.Start
+			task.Duration = seg.End - seg.Start
+		}
+		tasks = append(tasks, task)
+		if limit > 0 && len(tasks) >= limit {
+			break
+		}
+	}
+
+	if skipped > 0 {
+		log.Printf("Kaldi %s: skipped %d utterances (no speaker, segment or audio file)", rootDir, skipped)
+	}
+	return tasks, nil
+}
+
+type kaldiSegment struct {
+	Recording  string
+	Start, End float64
+}
+
+// readKaldiSegments — segments: "utt rec start end" в секундах; end -1 — до конца записи не поддерживается
+func readKaldiSegments(path string) (map[string]kaldiSegment, error) {
+	uttIDs, lines, err := readKeyValues(path)
+	if err != nil {
+		return nil, err
+	}
+
+	segments := make(map[string]kaldiSegment, len(uttIDs))
+	for _, utt := range uttIDs {
+		fields := strings.Fields(lines[utt])
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: bad line for %s", path, utt)
+		}
+		start, err1 := strconv.ParseFloat(fields[1], 64)
+		end, err2 := strconv.ParseFloat(fields[2], 64)
+		if err1 != nil || err2 != nil || start < 0 || end <= start {
+			return nil, fmt.Errorf("%s: bad range for %s", path, utt)
+		}
+		segments[utt] = kaldiSegment{Recording: fields[0], Start: start, End: end}
+	}
+	return segments, nil
+}
@@ -12,6 +12,16 @@ type AudioTask struct {
 	ChapterID     string
 	WavPath       string
 	Transcription string
+
+	// Отрезок длинной записи (Kaldi segments, NeMo offset): WavPath — запись целиком,
+	// [Start, Start+Duration) импортируется виртуальным отрезком; Duration 0 — весь файл
+	Start    float64
+	Duration float64
+
+	// Строка эталона "utt текст" (LibriSpeech trans.txt, Kaldi text): через неё правка
+	// оператора записывается обратно на диск. Пусто — формат этого не поддерживает
+	TransFile string
+	UttID     string
 }
 
 // Сканирует LibriSpeech структуру
@@ -57,6 +67,8 @@ func ScanLibriSpeech(rootDir string, limit int) ([]AudioTask, error) {
 						ChapterID:     chapterID,
 						WavPath:       wavPath,
 						Transcription: text,
+						TransFile:     transFile,
+						UttID:         id,
 					})
 					count++
 					if limit > 0 && count >= limit {
@@ -85,9 +97,8 @@ func parseTransFile(path string) (map[string]string, error) {
 		if line == "" {
 			continue
 		}
-		parts := strings.SplitN(line, " ", 2)
-		if len(parts) == 2 {
-			result[parts[0]] = parts[1]
+		if key, value := cutKey(line); value != "" {
+			result[key] = value
 		}
 	}
 
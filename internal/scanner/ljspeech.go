@@ -0,0 +1,50 @@
+package scanner
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ScanLJSpeech — LJSpeech: metadata.csv со строками "id|текст|нормализованный текст"
+// (разделитель |, кавычки не экранируются) и wavs/<id>.wav. Берётся нормализованный
+// текст, если он есть. Диктор один — user_id = имя каталога, chapter_id — префикс id (LJ001)
+func ScanLJSpeech(rootDir string, limit int) ([]AudioTask, error) {
+	f, err := os.Open(filepath.Join(rootDir, "metadata.csv"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	speaker := shortID(filepath.Base(rootDir))
+	var tasks []AudioTask
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		fields := strings.Split(strings.TrimSpace(scanner.Text()), "|")
+		if len(fields) < 2 || fields[0] == "" {
+			continue
+		}
+		id, text := fields[0], fields[1]
+		if len(fields) > 2 && strings.TrimSpace(fields[2]) != "" {
+			text = fields[2]
+		}
+
+		path := filepath.Join(rootDir, "wavs", id+".wav")
+		if !fileExists(path) {
+			continue
+		}
+		chapter, _, _ := strings.Cut(id, "-")
+		tasks = append(tasks, AudioTask{
+			UserID:        speaker,
+			ChapterID:     shortID(chapter),
+			WavPath:       path,
+			Transcription: strings.TrimSpace(text),
+		})
+		if limit > 0 && len(tasks) >= limit {
+			break
+		}
+	}
+	return tasks, scanner.Err()
+}
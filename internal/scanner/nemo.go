@@ -0,0 +1,95 @@
+package scanner
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nemoEntry — строка манифеста NeMo
+type nemoEntry struct {
+	AudioFilepath string   `json:"audio_filepath"`
+	Text          string   `json:"text"`
+	Duration      float64  `json:"duration"`
+	Offset        *float64 `json:"offset"`
+	Speaker       string   `json:"speaker"`
+	SpeakerID     string   `json:"speaker_id"`
+}
+
+// ScanNeMo — NeMo манифесты (*.json, *.jsonl в rootDir, одна JSON запись на строку).
+// Относительные audio_filepath — от каталога манифеста; запись с offset — отрезок
+// [offset, offset+duration) файла. chapter_id — имя манифеста без расширения
+func ScanNeMo(rootDir string, limit int) ([]AudioTask, error) {
+	manifests := globSorted(rootDir, "*.json", "*.jsonl")
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("%s: no *.json or *.jsonl manifests", rootDir)
+	}
+
+	var tasks []AudioTask
+	for _, manifest := range manifests {
+		chapter := shortID(strings.TrimSuffix(filepath.Base(manifest), filepath.Ext(manifest)))
+		err := readJSONL(manifest, func(e nemoEntry) bool {
+			path := resolvePath(filepath.Dir(manifest), e.AudioFilepath)
+			if e.AudioFilepath == "" || !fileExists(path) {
+				return true
+			}
+
+			speaker := e.Speaker
+			if speaker == "" {
+				speaker = e.SpeakerID
+			}
+			if speaker == "" {
+				speaker = unknownSpeaker
+			}
+
+			task := AudioTask{
+				UserID:        shortID(speaker),
+				ChapterID:     chapter,
+				WavPath:       path,
+				Transcription: strings.TrimSpace(e.Text),
+			}
+			if e.Offset != nil && e.Duration > 0 {
+				task.Start = *e.Offset
+				task.Duration = e.Duration
+			}
+			tasks = append(tasks, task)
+			return limit <= 0 || len(tasks) < limit
+		})
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(tasks) >= limit {
+			break
+		}
+	}
+	return tasks, nil
+}
+
+// readJSONL вызывает fn для каждой записи; fn возвращает false — остановиться
+func readJSONL(path string, fn func(e nemoEntry) bool) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var e nemoEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		if !fn(e) {
+			break
+		}
+	}
+	return scanner.Err()
+}
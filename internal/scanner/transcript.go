@@ -0,0 +1,60 @@
+package scanner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WriteTranscript заменяет текст строки uttID в файле эталонов "utt текст"
+// (LibriSpeech trans.txt, Kaldi text). Остальные строки не трогаются; файл
+// подменяется целиком через временный, чтобы импорт не прочитал его наполовину.
+// Переводы строк и табы в тексте схлопываются в пробел: строка эталона — одна строка
+func WriteTranscript(path, uttID, text string) error {
+	text = strings.Join(strings.Fields(text), " ")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(string(data), "\n")
+	found := false
+	for i, line := range lines {
+		if key, _ := cutKey(line); key != uttID {
+			continue
+		}
+		eol := ""
+		if strings.HasSuffix(line, "\r\n") {
+			eol = "\r\n"
+		} else if strings.HasSuffix(line, "\n") {
+			eol = "\n"
+		}
+		lines[i] = uttID + " " + text + eol
+		found = true
+	}
+	if !found {
+		return fmt.Errorf("%s: no line for %s", path, uttID)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.WriteString(strings.Join(lines, "")); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), info.Mode().Perm()); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
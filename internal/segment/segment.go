@@ -129,32 +129,29 @@ func (c *Client) Diarize(audioPath string, minSpeakers, maxSpeakers *int) (*Diar
 // Repository - работа с БД
 // ========================================
 
+// Store — операции с audio_segments, которые нужны handlers
+type Store interface {
+	DeleteByAudioFile(audioFileID int64) error
+	InsertSegments(audioFileID int64, segments []PyannoteSegment) error
+	GetByAudioFile(audioFileID int64) ([]Segment, error)
+	UpdateSelection(segmentIDs []int64, selected bool) error
+	UpdateTranscript(segmentID int64, transcript string) error
+	UpdateTranscriptsBatch(transcripts map[int64]string) error
+	GetSelected(audioFileID int64) ([]Segment, error)
+	HasSegments(audioFileID int64) (bool, error)
+	CombineTranscripts(audioFileID int64) (string, error)
+}
+
 type Repository struct {
 	db *sql.DB
 }
 
+var _ Store = (*Repository)(nil)
+
 func NewRepository(db *sql.DB) *Repository {
 	return &Repository{db: db}
 }
 
-func (r *Repository) CreateTable() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS audio_segments (
-		id INT AUTO_INCREMENT PRIMARY KEY,
-		audio_file_id INT NOT NULL,
-		start_time DECIMAL(10,3) NOT NULL,
-		end_time DECIMAL(10,3) NOT NULL,
-		speaker VARCHAR(50),
-		has_overlap TINYINT(1) DEFAULT 0,
-		selected TINYINT(1) DEFAULT 0,
-		transcript TEXT,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		INDEX idx_audio_file (audio_file_id)
-	)`
-	_, err := r.db.Exec(query)
-	return err
-}
-
 func (r *Repository) DeleteByAudioFile(audioFileID int64) error {
 	_, err := r.db.Exec("DELETE FROM audio_segments WHERE audio_file_id = ?", audioFileID)
 	return err
@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+)
+
+// AnalyzeStatus — прогресс пакетного анализа SNR/RMS и дефектов
+type AnalyzeStatus struct {
+	JobID     int64   `json:"job_id,omitempty"`
+	Running   bool    `json:"running"`
+	Total     int64   `json:"total"`
+	Processed int64   `json:"processed"`
+	Errors    int64   `json:"errors"`
+	Percent   float64 `json:"percent"`
+	Elapsed   string  `json:"elapsed"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// analyzeParams — параметры запуска для jobs.params
+type analyzeParams struct {
+	Limit int  `json:"limit"`
+	Force bool `json:"force"`
+}
+
+// AnalyzeService — пакетный пересчёт SNR/RMS (audio.GetStats) и дефектов записи по файлам.
+// Идёт по возрастанию id, курсор задачи — последний обработанный файл
+type AnalyzeService struct {
+	db       db.FileRepository
+	jobs     *JobManager
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewAnalyzeService(database db.FileRepository, jobs *JobManager) *AnalyzeService {
+	s := &AnalyzeService{db: database, jobs: jobs}
+	jobs.RegisterResumer(db.JobTypeAnalyze, "", s.Resume)
+	return s
+}
+
+// Start выбирает файлы и запускает анализ в фоне. Возвращает число файлов в очереди
+func (s *AnalyzeService) Start(limit int, force bool, startedBy string) (int, error) {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return 0, errors.New("Analyze already running")
+	}
+
+	files, err := s.db.GetFilesForAnalyze(limit, force, 0)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+	if len(files) == 0 {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, nil
+	}
+
+	job, err := s.jobs.Begin(db.JobTypeAnalyze, "", analyzeParams{Limit: limit, Force: force}, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+
+	s.launch(job, files)
+	return len(files), nil
+}
+
+// Resume продолжает анализ с файла после курсора
+func (s *AnalyzeService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("Analyze already running")
+	}
+
+	var params analyzeParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	// limit > 0 всегда (handler подставляет 100); < 0 — лимит уже выбран
+	var files []db.AudioFile
+	if limit := job.Remaining(params.Limit); limit > 0 {
+		files, err = s.db.GetFilesForAnalyze(limit, params.Force, job.Cursor())
+		if err != nil {
+			atomic.StoreInt32(&s.running, 0)
+			job.Fail(err.Error())
+			return err
+		}
+	}
+
+	s.launch(job, files)
+	return nil
+}
+
+func (s *AnalyzeService) launch(job *Job, files []db.AudioFile) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	job.AddTotal(len(files))
+	go s.run(job, files)
+}
+
+func (s *AnalyzeService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *AnalyzeService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *AnalyzeService) Status() AnalyzeStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeAnalyze, "")
+		if rec == nil {
+			return AnalyzeStatus{}
+		}
+		return AnalyzeStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+		}
+	}
+
+	t, p, _, e := job.Progress()
+	return AnalyzeStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+	}
+}
+
+func (s *AnalyzeService) run(job *Job, files []db.AudioFile) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	for _, file := range files {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		path, release, err := LocalAudio(&file)
+		if err != nil {
+			log.Printf("Analyze error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+			job.SetCursor(file.ID)
+			continue
+		}
+
+		stats, err := audio.GetStats(path)
+		if err != nil {
+			log.Printf("Analyze error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+		} else if err := s.db.UpdateAudioStats(file.ID, stats); err != nil {
+			log.Printf("Analyze update error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+		} else if defects, err := s.detectDefects(file.ID, path); err != nil {
+			log.Printf("Analyze defects error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: defects: %v", file.ID, err))
+		} else {
+			log.Printf("Analyzed file %d: SNR=%.1f, Noise=%s, Defects=%d", file.ID, stats.SNREstimate, stats.NoiseLevel, defects)
+			job.Processed()
+		}
+		release()
+		job.SetCursor(file.ID)
+	}
+
+	log.Printf("Analyze complete: %d files (job %d)", len(files), job.ID())
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+// detectDefects ищет дефекты записи path файла id и сохраняет их; возвращает число найденных
+func (s *AnalyzeService) detectDefects(id int64, path string) (int, error) {
+	defects, err := audio.DetectDefects(path)
+	if err != nil {
+		return 0, err
+	}
+	return len(defects), s.db.SaveDefects(id, defects)
+}
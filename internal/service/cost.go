@@ -0,0 +1,84 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+)
+
+// CostPolicy — цена платного движка. Стоимость считается по секундам аудио
+// успешно распознанных запросов (API берёт деньги только за них)
+type CostPolicy struct {
+	PricePerMinute float64 // $ за минуту аудио; 0 — бесплатный движок
+	Budget         float64 // $ на задачу по умолчанию (?budget= при старте); 0 — без лимита
+}
+
+func (p CostPolicy) Paid() bool {
+	return p.PricePerMinute > 0
+}
+
+func (p CostPolicy) Cost(audioSec float64) float64 {
+	return audioSec / 60 * p.PricePerMinute
+}
+
+// audioSeconds — сколько секунд аудио уйдёт в API: у виртуального отрезка — его границы,
+// иначе длительность из базы, а без неё — измеренная по path (см. LocalAudio)
+func audioSeconds(file *db.AudioFile, path string) (float64, error) {
+	sec := file.DurationSec
+	if file.IsSegment() {
+		sec = *file.SegmentEnd - *file.SegmentStart
+	} else if sec <= 0 {
+		var err error
+		if sec, err = audio.GetAudioDuration(path); err != nil {
+			return 0, fmt.Errorf("duration: %w", err)
+		}
+	}
+	if sec <= 0 {
+		return 0, fmt.Errorf("duration unknown")
+	}
+	return sec, nil
+}
+
+// reserve резервирует стоимость sec секунд аудио до запроса к платному API.
+// false — бюджет исчерпан, задача останавливается
+func (s *EngineService) reserve(job *Job, sec float64) (float64, bool) {
+	policy := s.engine.Cost
+	if !policy.Paid() {
+		return 0, true
+	}
+
+	cost := policy.Cost(sec)
+	if job.Reserve(cost) {
+		return cost, true
+	}
+
+	if atomic.CompareAndSwapInt32(&s.stopFlag, 0, 1) {
+		_, spent, budget := job.Cost()
+		msg := fmt.Sprintf("budget exceeded: spent $%.4f of $%.4f", spent, budget)
+		log.Printf("⚠ %s: %s, stopping job %d", s.engine.Description, msg, job.ID())
+		job.SetLastError(msg)
+	}
+	return 0, false
+}
+
+// settle снимает резерв и записывает стоимость успешного запроса в журнал задачи
+func (s *EngineService) settle(job *Job, file *db.AudioFile, reserved float64, result *asr.DecodeResult) {
+	policy := s.engine.Cost
+	if !policy.Paid() {
+		return
+	}
+
+	if result == nil || !result.Success {
+		job.Settle(reserved, 0, 0)
+		return
+	}
+	sec := result.Duration
+	if sec <= 0 {
+		sec = file.DurationSec
+	}
+	job.Settle(reserved, sec, policy.Cost(sec))
+}
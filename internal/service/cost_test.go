@@ -0,0 +1,110 @@
+package service
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+
+	"audio-labeler/internal/db"
+)
+
+func TestCostPolicy(t *testing.T) {
+	tests := []struct {
+		policy   CostPolicy
+		audioSec float64
+		paid     bool
+		want     float64
+	}{
+		{CostPolicy{}, 60, false, 0},
+		{CostPolicy{PricePerMinute: 0.006}, 60, true, 0.006},
+		{CostPolicy{PricePerMinute: 0.006}, 90, true, 0.009},
+		{CostPolicy{PricePerMinute: 0.006}, 0, true, 0},
+	}
+	for _, tt := range tests {
+		if tt.policy.Paid() != tt.paid {
+			t.Errorf("%+v: Paid = %v", tt.policy, !tt.paid)
+		}
+		if got := tt.policy.Cost(tt.audioSec); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%+v: Cost(%.0f) = %f, want %f", tt.policy, tt.audioSec, got, tt.want)
+		}
+	}
+}
+
+func TestJobReserve(t *testing.T) {
+	tests := []struct {
+		name     string
+		budget   float64
+		spent    float64 // уже оплачено в прошлых запусках
+		inFlight []float64
+		cost     float64
+		want     bool
+	}{
+		{"no budget", 0, 100, []float64{50}, 10, true},
+		{"fits", 1, 0.5, nil, 0.5, true},
+		{"over budget", 1, 0.9, nil, 0.2, false},
+		{"in flight counts", 1, 0.2, []float64{0.3, 0.3}, 0.3, false},
+		{"in flight fits", 1, 0.2, []float64{0.3}, 0.3, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			job := &Job{rec: db.Job{Budget: tt.budget}, cost: tt.spent}
+			for _, c := range tt.inFlight {
+				if !job.Reserve(c) {
+					t.Fatalf("in-flight reserve %.2f refused", c)
+				}
+			}
+			if got := job.Reserve(tt.cost); got != tt.want {
+				t.Errorf("Reserve(%.2f) = %v, want %v", tt.cost, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJobSettle(t *testing.T) {
+	job := &Job{rec: db.Job{Budget: 1}}
+	if !job.Reserve(0.6) {
+		t.Fatal("reserve refused")
+	}
+	if job.Reserve(0.6) {
+		t.Fatal("reserve in flight ignored")
+	}
+
+	// Неудачный запрос: резерв снимается, платить не за что
+	job.Settle(0.6, 0, 0)
+	if !job.Reserve(0.6) {
+		t.Fatal("reserve not released after failed request")
+	}
+	job.Settle(0.6, 60, 0.6)
+
+	sec, spent, budget := job.Cost()
+	if sec != 60 || spent != 0.6 || budget != 1 {
+		t.Errorf("Cost = %v %v %v", sec, spent, budget)
+	}
+	if job.Reserve(0.6) {
+		t.Error("reserve over budget after settle")
+	}
+}
+
+func TestAudioSeconds(t *testing.T) {
+	start, end := 120.0, 123.5
+	missing := filepath.Join(t.TempDir(), "missing.wav")
+
+	tests := []struct {
+		name    string
+		file    db.AudioFile
+		want    float64
+		wantErr bool
+	}{
+		{"duration from db", db.AudioFile{DurationSec: 4.2}, 4.2, false},
+		// У отрезка считаются его границы, а не длительность из базы
+		{"segment range", db.AudioFile{DurationSec: 3600, SegmentStart: &start, SegmentEnd: &end}, 3.5, false},
+		{"empty segment", db.AudioFile{SegmentStart: &start, SegmentEnd: &start}, 0, true},
+		{"unreadable file", db.AudioFile{}, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := audioSeconds(&tt.file, missing)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("%s: %v %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+)
+
+// Что скан делает с почти-дубликатами уже импортированных файлов (SCAN_DUPLICATES, ?duplicates=)
+const (
+	DuplicatesOff  = "off"  // только сохраняет отпечаток
+	DuplicatesFlag = "flag" // импортирует и помечает duplicate_of / duplicate_score
+	DuplicatesSkip = "skip" // не импортирует
+)
+
+func checkDuplicatesMode(mode string) error {
+	switch mode {
+	case DuplicatesOff, DuplicatesFlag, DuplicatesSkip:
+		return nil
+	}
+	return fmt.Errorf("duplicates must be %s, %s or %s", DuplicatesOff, DuplicatesFlag, DuplicatesSkip)
+}
+
+// FingerprintStatus — прогресс расчёта отпечатков; Skipped — тишина или слишком короткий файл
+type FingerprintStatus struct {
+	JobID     int64   `json:"job_id,omitempty"`
+	Running   bool    `json:"running"`
+	Total     int64   `json:"total"`
+	Processed int64   `json:"processed"`
+	Skipped   int64   `json:"skipped"`
+	Errors    int64   `json:"errors"`
+	Percent   float64 `json:"percent"`
+	Elapsed   string  `json:"elapsed"`
+	LastError string  `json:"last_error,omitempty"`
+	Missing   int64   `json:"missing"` // активных файлов без отпечатка
+}
+
+// fingerprintParams — параметры запуска для jobs.params
+type fingerprintParams struct {
+	Limit int `json:"limit"`
+}
+
+// DuplicateMember — файл группы дубликатов; Similarity — наибольшая похожесть на другой файл группы
+type DuplicateMember struct {
+	ID            int64    `json:"id"`
+	UserID        string   `json:"user_id"`
+	ChapterID     string   `json:"chapter_id"`
+	FilePath      string   `json:"file_path"`
+	SegmentStart  *float64 `json:"segment_start,omitempty"`
+	SegmentEnd    *float64 `json:"segment_end,omitempty"`
+	DurationSec   float64  `json:"duration_sec"`
+	Transcription string   `json:"transcription"`
+	Similarity    float64  `json:"similarity"`
+}
+
+// DuplicatePair — пара файлов группы с похожестью не ниже порога
+type DuplicatePair struct {
+	A          int64   `json:"a"`
+	B          int64   `json:"b"`
+	Similarity float64 `json:"similarity"`
+}
+
+// DuplicateGroup — связные файлы: каждый похож хотя бы на один другой файл группы
+type DuplicateGroup struct {
+	Members       []DuplicateMember `json:"members"`
+	Pairs         []DuplicatePair   `json:"pairs"`
+	MaxSimilarity float64           `json:"max_similarity"`
+	MinSimilarity float64           `json:"min_similarity"`
+	Speakers      int               `json:"speakers"`
+	Chapters      int               `json:"chapters"`
+}
+
+// DuplicateReport — отчёт GET /api/duplicates
+type DuplicateReport struct {
+	Threshold       float64          `json:"threshold"`
+	Files           int              `json:"files"`           // файлов с отпечатком
+	Unfingerprinted int64            `json:"unfingerprinted"` // активных без отпечатка — см. fingerprint/start
+	TotalGroups     int              `json:"total_groups"`
+	DuplicateFiles  int              `json:"duplicate_files"` // файлов во всех группах
+	Groups          []DuplicateGroup `json:"groups"`
+}
+
+// DuplicateService — акустические отпечатки и поиск почти-дубликатов. MD5 находит только
+// побайтовые копии; отпечаток находит ту же запись после перекодирования, ресемплинга,
+// AddSilence/TrimAudio и повторной поставки. Отпечатки новых файлов считает скан,
+// старых — Start
+type DuplicateService struct {
+	db       db.Store
+	jobs     *JobManager
+	cfg      config.DedupConfig
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewDuplicateService(database db.Store, jobs *JobManager, cfg config.DedupConfig) *DuplicateService {
+	s := &DuplicateService{db: database, jobs: jobs, cfg: cfg}
+	jobs.RegisterResumer(db.JobTypeFingerprint, "", s.Resume)
+	return s
+}
+
+// Start считает отпечатки файлов, у которых их нет. Возвращает число файлов в очереди
+func (s *DuplicateService) Start(limit int, startedBy string) (int, error) {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return 0, errors.New("Fingerprinting already running")
+	}
+
+	files, err := s.db.GetFilesWithoutFingerprint(limit, 0)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+	if len(files) == 0 {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, nil
+	}
+
+	job, err := s.jobs.Begin(db.JobTypeFingerprint, "", fingerprintParams{Limit: limit}, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+
+	s.launch(job, files)
+	return len(files), nil
+}
+
+// Resume продолжает расчёт с файла после курсора
+func (s *DuplicateService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("Fingerprinting already running")
+	}
+
+	var params fingerprintParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	var files []db.AudioFile
+	if limit := job.Remaining(params.Limit); limit > 0 {
+		files, err = s.db.GetFilesWithoutFingerprint(limit, job.Cursor())
+		if err != nil {
+			atomic.StoreInt32(&s.running, 0)
+			job.Fail(err.Error())
+			return err
+		}
+	}
+
+	s.launch(job, files)
+	return nil
+}
+
+func (s *DuplicateService) launch(job *Job, files []db.AudioFile) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	job.AddTotal(len(files))
+	go s.run(job, files)
+}
+
+func (s *DuplicateService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *DuplicateService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *DuplicateService) Status() FingerprintStatus {
+	missing, err := s.db.CountFilesWithoutFingerprint()
+	if err != nil {
+		log.Printf("⚠ Fingerprint: count missing: %v", err)
+	}
+
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeFingerprint, "")
+		if rec == nil {
+			return FingerprintStatus{Missing: missing}
+		}
+		return FingerprintStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Skipped:   rec.Skipped,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+			Missing:   missing,
+		}
+	}
+
+	t, p, sk, e := job.Progress()
+	return FingerprintStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Skipped:   sk,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+		Missing:   missing,
+	}
+}
+
+func (s *DuplicateService) run(job *Job, files []db.AudioFile) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	for _, file := range files {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		err := s.fingerprint(&file)
+		switch {
+		case errors.Is(err, audio.ErrNoFingerprint):
+			job.Skipped()
+		case err != nil:
+			log.Printf("Fingerprint error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+		default:
+			job.Processed()
+		}
+		job.SetCursor(file.ID)
+	}
+
+	log.Printf("Fingerprinting complete: %d files (job %d)", len(files), job.ID())
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+func (s *DuplicateService) fingerprint(file *db.AudioFile) error {
+	path, release, err := LocalAudio(file)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	fp, err := audio.FingerprintFile(path)
+	if errors.Is(err, audio.ErrNoFingerprint) {
+		// пустой отпечаток — метка: следующий запуск файл не пересчитывает
+		if err := s.db.SaveFingerprint(file.ID, nil); err != nil {
+			return err
+		}
+		return audio.ErrNoFingerprint
+	}
+	if err != nil {
+		return err
+	}
+	return s.db.SaveFingerprint(file.ID, fp)
+}
+
+// Report группирует почти-дубликаты с похожестью не ниже threshold (<= 0 — DUPLICATE_THRESHOLD).
+// cross: speaker | chapter — только группы с несколькими дикторами / главами (утечка
+// одной записи между частями корпуса); limit — групп в ответе, 0 — все
+func (s *DuplicateService) Report(threshold float64, cross string, limit int) (*DuplicateReport, error) {
+	if threshold <= 0 {
+		threshold = s.cfg.Threshold
+	}
+	if threshold > 1 {
+		return nil, fmt.Errorf("invalid threshold %g", threshold)
+	}
+	if cross != "" && cross != "speaker" && cross != "chapter" {
+		return nil, fmt.Errorf("cross must be speaker or chapter")
+	}
+
+	prints, err := s.db.GetFingerprints()
+	if err != nil {
+		return nil, err
+	}
+	missing, err := s.db.CountFilesWithoutFingerprint()
+	if err != nil {
+		return nil, err
+	}
+
+	index := audio.NewFingerprintIndex()
+	byID := make(map[int64]*db.FileFingerprint, len(prints))
+	for i := range prints {
+		index.Add(prints[i].ID, prints[i].Fingerprint)
+		byID[prints[i].ID] = &prints[i]
+	}
+
+	// Пары выше порога и связные группы (union-find)
+	parent := make(map[int64]int64)
+	var find func(id int64) int64
+	find = func(id int64) int64 {
+		if p, ok := parent[id]; ok && p != id {
+			root := find(p)
+			parent[id] = root
+			return root
+		}
+		parent[id] = id
+		return id
+	}
+
+	var pairs []DuplicatePair
+	for _, p := range prints {
+		for _, m := range index.Search(p.Fingerprint, threshold) {
+			if m.ID <= p.ID {
+				continue // каждая пара один раз
+			}
+			pairs = append(pairs, DuplicatePair{A: p.ID, B: m.ID, Similarity: round3(m.Similarity)})
+			if ra, rb := find(p.ID), find(m.ID); ra != rb {
+				parent[max(ra, rb)] = min(ra, rb)
+			}
+		}
+	}
+
+	groups := make(map[int64]*DuplicateGroup)
+	best := make(map[int64]float64)
+	for _, pair := range pairs {
+		root := find(pair.A)
+		g := groups[root]
+		if g == nil {
+			g = &DuplicateGroup{MinSimilarity: 1}
+			groups[root] = g
+		}
+		g.Pairs = append(g.Pairs, pair)
+		g.MaxSimilarity = max(g.MaxSimilarity, pair.Similarity)
+		g.MinSimilarity = min(g.MinSimilarity, pair.Similarity)
+		best[pair.A] = max(best[pair.A], pair.Similarity)
+		best[pair.B] = max(best[pair.B], pair.Similarity)
+	}
+
+	ids := make([]int64, 0, len(best))
+	for id := range best {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		f := byID[id]
+		g := groups[find(id)]
+		g.Members = append(g.Members, DuplicateMember{
+			ID:            f.ID,
+			UserID:        f.UserID,
+			ChapterID:     f.ChapterID,
+			FilePath:      f.FilePath,
+			SegmentStart:  f.SegmentStart,
+			SegmentEnd:    f.SegmentEnd,
+			DurationSec:   f.DurationSec,
+			Transcription: f.TranscriptionOriginal,
+			Similarity:    best[id],
+		})
+	}
+
+	report := &DuplicateReport{Threshold: threshold, Files: len(prints), Unfingerprinted: missing, Groups: []DuplicateGroup{}}
+	for _, g := range groups {
+		speakers, chapters := make(map[string]bool), make(map[string]bool)
+		for _, m := range g.Members {
+			speakers[m.UserID] = true
+			chapters[m.UserID+"/"+m.ChapterID] = true
+		}
+		g.Speakers, g.Chapters = len(speakers), len(chapters)
+		if (cross == "speaker" && g.Speakers < 2) || (cross == "chapter" && g.Chapters < 2) {
+			continue
+		}
+		report.Groups = append(report.Groups, *g)
+		report.DuplicateFiles += len(g.Members)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if len(a.Members) != len(b.Members) {
+			return len(a.Members) > len(b.Members)
+		}
+		if a.MaxSimilarity != b.MaxSimilarity {
+			return a.MaxSimilarity > b.MaxSimilarity
+		}
+		return a.Members[0].ID < b.Members[0].ID
+	})
+	report.TotalGroups = len(report.Groups)
+	if limit > 0 && len(report.Groups) > limit {
+		report.Groups = report.Groups[:limit]
+	}
+	return report, nil
+}
+
+func round3(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
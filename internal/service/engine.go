@@ -0,0 +1,681 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/metrics"
+)
+
+// EngineStore — где движок берёт pending файлы и куда пишет результат
+type EngineStore interface {
+	Pending(limit int, params map[string]string) ([]db.AudioFile, error)
+	Save(file *db.AudioFile, result *asr.DecodeResult, wer, cer float64) error
+	// SaveError записывает неудачную попытку; errorClass — asr.ErrorTransient / asr.ErrorPermanent
+	// (asr.ErrorFatal сюда не попадает: файл остаётся pending)
+	SaveError(file *db.AudioFile, errMsg, errorClass string) error
+	// RequeueErrors возвращает transient ошибки в pending
+	RequeueErrors(maxAttempts int, unclassified bool) (int64, error)
+}
+
+// Engine — описание ASR движка для реестра
+type Engine struct {
+	Name        string
+	Description string
+	Transcriber asr.Transcriber
+	Store       EngineStore
+	Workers     int         // воркеров по умолчанию
+	BatchSize   int         // >0 — batch режим (Transcriber должен реализовать asr.BatchTranscriber)
+	Retry       RetryPolicy // пусто — из конфига (ASR_RETRY_*)
+	Cost        CostPolicy  // платный API: учёт стоимости и бюджет (только поштучный режим)
+
+	// Profiles — именованные варианты движка (Kaldi: модель, граф, beam'ы, веса),
+	// выбираются при запуске params["profile"]; Profile собирает движок для профиля.
+	// Результаты профиля пишутся с model_version = имя профиля
+	Profiles []string
+	Profile  func(name string) (*Engine, error)
+}
+
+// StartOptions — параметры запуска движка
+type StartOptions struct {
+	Limit     int               `json:"limit"`
+	Workers   int               `json:"workers"`
+	BatchSize int               `json:"batch_size,omitempty"`
+	Params    map[string]string `json:"params,omitempty"` // движко-специфичные (min_wer, forced, profile, ...)
+	StartedBy string            `json:"-"`
+}
+
+type EngineStatus struct {
+	Engine    string  `json:"engine"`
+	Profile   string  `json:"profile,omitempty"`
+	JobID     int64   `json:"job_id,omitempty"`
+	Running   bool    `json:"running"`
+	Total     int64   `json:"total"`
+	Processed int64   `json:"processed"`
+	Errors    int64   `json:"errors"`
+	Percent   float64 `json:"percent"`
+	Rate      float64 `json:"rate"`
+	AvgWER    float64 `json:"avg_wer"`
+	Elapsed   string  `json:"elapsed"`
+	LastError string  `json:"last_error,omitempty"`
+
+	// Платные движки: отправлено аудио, потрачено и лимит задачи в $
+	AudioMinutes float64 `json:"audio_minutes,omitempty"`
+	Cost         float64 `json:"cost,omitempty"`
+	Budget       float64 `json:"budget,omitempty"`
+
+	// Скорость запуска: RTF = время обработки / длительность аудио. Для движков с процессом
+	// на воркер (Kaldi server mode) — RTF, который был бы при процессе на файл
+	// (загрузка модели на каждый файл, по измеренному времени загрузки), и ускорение
+	RTF             float64 `json:"rtf,omitempty"`
+	OneShotRTF      float64 `json:"oneshot_rtf,omitempty"`
+	Speedup         float64 `json:"speedup,omitempty"`
+	DecoderStarts   int     `json:"decoder_starts,omitempty"`
+	DecoderRestarts int     `json:"decoder_restarts,omitempty"`
+}
+
+// EngineService — общий worker loop для любого зарегистрированного движка
+type EngineService struct {
+	engine   *Engine
+	active   *Engine // движок текущего запуска: engine или его профиль
+	jobs     *JobManager
+	running  int32
+	stopFlag int32
+	failed   int32 // задача остановлена ошибкой asr.ErrorFatal
+	job      *Job
+	totalWER float64 // сумма WER за текущий запуск
+	werCount int64
+	audioSec float64 // аудио и время обработки успешных файлов запуска (RTF)
+	procSec  float64
+	workers  []asr.TranscriberWorker
+	profile  string // профиль текущего запуска
+	mu       sync.Mutex
+}
+
+func NewEngineService(engine *Engine, jobs *JobManager) *EngineService {
+	s := &EngineService{engine: engine, jobs: jobs}
+	jobs.RegisterResumer(db.JobTypeASR, engine.Name, s.Resume)
+	return s
+}
+
+func (s *EngineService) Name() string {
+	return s.engine.Name
+}
+
+func (s *EngineService) Description() string {
+	return s.engine.Description
+}
+
+// Profiles — имена профилей, доступных при запуске (params["profile"])
+func (s *EngineService) Profiles() []string {
+	return s.engine.Profiles
+}
+
+// forProfile — движок для params["profile"]; без профиля — основной
+func (s *EngineService) forProfile(params map[string]string) (*Engine, error) {
+	name := params["profile"]
+	if name == "" {
+		return s.engine, nil
+	}
+	if s.engine.Profile == nil {
+		return nil, fmt.Errorf("%s has no profiles", s.engine.Description)
+	}
+	return s.engine.Profile(name)
+}
+
+func (s *EngineService) Start(opts StartOptions) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return fmt.Errorf("%s already running", s.engine.Description)
+	}
+
+	if opts.Workers <= 0 {
+		opts.Workers = s.engine.Workers
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 1
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = s.engine.BatchSize
+	}
+
+	engine, err := s.forProfile(opts.Params)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	if err := engine.Transcriber.Health(); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("%s not available: %v", s.engine.Description, err)
+	}
+
+	var budget float64
+	if s.engine.Cost.Paid() {
+		budget = s.engine.Cost.Budget
+		if v, err := strconv.ParseFloat(opts.Params["budget"], 64); err == nil {
+			budget = v
+		}
+	}
+
+	job, err := s.jobs.BeginBudget(db.JobTypeASR, s.engine.Name, opts, opts.StartedBy, budget)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	s.launch(job, opts, engine)
+	return nil
+}
+
+// Resume продолжает прерванный запуск: Pending и так вернёт только необработанные файлы
+func (s *EngineService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return fmt.Errorf("%s already running", s.engine.Description)
+	}
+
+	var opts StartOptions
+	if err := json.Unmarshal([]byte(rec.Params), &opts); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	engine, err := s.forProfile(opts.Params)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	if err := engine.Transcriber.Health(); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("%s not available: %v", s.engine.Description, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	s.launch(job, opts, engine)
+	return nil
+}
+
+func (s *EngineService) launch(job *Job, opts StartOptions, engine *Engine) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	atomic.StoreInt32(&s.failed, 0)
+	s.mu.Lock()
+	s.job = job
+	s.active = engine
+	s.profile = opts.Params["profile"]
+	s.totalWER = 0
+	s.werCount = 0
+	s.audioSec = 0
+	s.procSec = 0
+	s.workers = nil
+	s.mu.Unlock()
+
+	go s.run(job, opts)
+}
+
+func (s *EngineService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *EngineService) addWER(wer float64) {
+	s.mu.Lock()
+	s.totalWER += wer
+	s.werCount++
+	s.mu.Unlock()
+}
+
+func (s *EngineService) addTiming(result *asr.DecodeResult) {
+	s.mu.Lock()
+	s.audioSec += result.Duration
+	s.procSec += result.ProcessingTime
+	s.mu.Unlock()
+}
+
+// speed — RTF запуска и, для процессов на воркер, оценка RTF процесса на файл
+func (s *EngineService) speed(st *EngineStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.audioSec <= 0 {
+		return
+	}
+	st.RTF = s.procSec / s.audioSec
+
+	var ws asr.WorkerStats
+	for _, w := range s.workers {
+		stats := w.Stats()
+		ws.Starts += stats.Starts
+		ws.Restarts += stats.Restarts
+		ws.StartupTime += stats.StartupTime
+	}
+	st.DecoderStarts = ws.Starts
+	st.DecoderRestarts = ws.Restarts
+	if ws.Starts == 0 || ws.StartupTime <= 0 {
+		return
+	}
+
+	// процесс на файл: та же обработка без разовых загрузок, но загрузка на каждый файл
+	startup := ws.StartupTime.Seconds()
+	perFile := startup / float64(ws.Starts)
+	oneShot := s.procSec - startup + perFile*float64(s.werCount)
+	if oneShot < s.procSec {
+		oneShot = s.procSec
+	}
+	st.OneShotRTF = oneShot / s.audioSec
+	if st.RTF > 0 {
+		st.Speedup = st.OneShotRTF / st.RTF
+	}
+}
+
+// workerTranscriber — Transcriber воркера: свой процесс для WorkerTranscriber.
+// release закрывает его в конце запуска
+func (s *EngineService) workerTranscriber() (tr asr.Transcriber, release func()) {
+	wt, ok := s.active.Transcriber.(asr.WorkerTranscriber)
+	if !ok {
+		return s.active.Transcriber, func() {}
+	}
+
+	w := wt.NewWorker()
+	s.mu.Lock()
+	s.workers = append(s.workers, w)
+	s.mu.Unlock()
+	return w, func() { w.Close() }
+}
+
+func (s *EngineService) avgWER() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.werCount == 0 {
+		return 0
+	}
+	return s.totalWER / float64(s.werCount)
+}
+
+func (s *EngineService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *EngineService) currentProfile() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile
+}
+
+func (s *EngineService) Status() EngineStatus {
+	job := s.currentJob()
+	if job == nil {
+		st := EngineStatus{Engine: s.engine.Name}
+		if rec := s.jobs.Last(db.JobTypeASR, s.engine.Name); rec != nil {
+			st.JobID = rec.ID
+			st.Total = rec.Total
+			st.Processed = rec.Processed
+			st.Errors = rec.Errors
+			st.Percent = recordPercent(rec)
+			st.Elapsed = recordElapsed(rec).Round(time.Second).String()
+			st.LastError = rec.LastError
+			st.AudioMinutes = rec.AudioSeconds / 60
+			st.Cost = rec.Cost
+			st.Budget = rec.Budget
+		}
+		return st
+	}
+
+	t, p, _, e := job.Progress()
+	audioSec, cost, budget := job.Cost()
+	st := EngineStatus{
+		Engine:    s.engine.Name,
+		Profile:   s.currentProfile(),
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Rate:      job.Rate(),
+		AvgWER:    s.avgWER(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+
+		AudioMinutes: audioSec / 60,
+		Cost:         cost,
+		Budget:       budget,
+	}
+	s.speed(&st)
+	return st
+}
+
+func (s *EngineService) run(job *Job, opts StartOptions) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	name := s.engine.Description
+
+	limit := job.Remaining(opts.Limit)
+	if limit < 0 {
+		log.Printf("%s: job %d limit reached", name, job.ID())
+		job.Finish(db.JobCompleted)
+		return
+	}
+
+	files, err := s.active.Store.Pending(limit, opts.Params)
+	if err != nil {
+		log.Printf("%s get pending error: %v", name, err)
+		job.Fail("get pending: " + err.Error())
+		return
+	}
+
+	if len(files) == 0 {
+		log.Printf("%s: no pending files", name)
+		job.Finish(db.JobCompleted)
+		return
+	}
+
+	job.AddTotal(len(files))
+
+	if batcher, ok := s.active.Transcriber.(asr.BatchTranscriber); ok && opts.BatchSize > 0 {
+		log.Printf("%s: processing %d files in batches of %d (job %d)", name, len(files), opts.BatchSize, job.ID())
+		s.runBatches(job, batcher, files, opts.BatchSize)
+	} else {
+		log.Printf("%s: processing %d files with %d workers (job %d)", name, len(files), opts.Workers, job.ID())
+		s.runWorkers(job, files, opts.Workers)
+	}
+
+	_, processed, _, errs := job.Progress()
+	log.Printf("%s complete: processed=%d errors=%d avgWER=%.2f%%",
+		name, processed, errs, s.avgWER()*100)
+
+	var speed EngineStatus
+	s.speed(&speed)
+	if speed.Speedup > 0 {
+		log.Printf("%s: RTF %.3f, process per file ~%.3f (x%.1f), decoder restarts=%d",
+			name, speed.RTF, speed.OneShotRTF, speed.Speedup, speed.DecoderRestarts)
+	}
+	if atomic.LoadInt32(&s.failed) == 1 {
+		job.Finish(db.JobFailed)
+		return
+	}
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+// abort останавливает задачу из-за ошибки, не зависящей от файла (asr.ErrorFatal).
+// Задача завершается со статусом failed, файлы остаются pending
+func (s *EngineService) abort(job *Job, msg string) {
+	if atomic.CompareAndSwapInt32(&s.stopFlag, 0, 1) {
+		atomic.StoreInt32(&s.failed, 1)
+		log.Printf("⚠ %s: %s, stopping job %d", s.engine.Description, msg, job.ID())
+		job.SetLastError(msg)
+	}
+}
+
+func (s *EngineService) runWorkers(job *Job, files []db.AudioFile, workers int) {
+	taskChan := make(chan db.AudioFile, 100)
+	var wg sync.WaitGroup
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go s.worker(&wg, job, taskChan)
+	}
+
+	for _, file := range files {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+		taskChan <- file
+	}
+	close(taskChan)
+
+	wg.Wait()
+}
+
+func (s *EngineService) worker(wg *sync.WaitGroup, job *Job, tasks <-chan db.AudioFile) {
+	defer wg.Done()
+
+	tr, release := s.workerTranscriber()
+	defer release()
+
+	for file := range tasks {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			return
+		}
+		s.transcribeWithRetry(job, tr, &file, 1)
+	}
+}
+
+// transcribeWithRetry — одна задача воркера: transient ошибки повторяются с backoff
+// до Retry.MaxAttempts, permanent сразу пишутся в error, fatal останавливает задачу.
+// Каждая попытка сохраняется (attempts).
+// firstAttempt > 1 — часть попыток уже потрачена (неудачный batch)
+func (s *EngineService) transcribeWithRetry(job *Job, tr asr.Transcriber, file *db.AudioFile, firstAttempt int) {
+	policy := s.engine.Retry
+
+	path, release, err := LocalAudio(file)
+	if err != nil {
+		s.active.Store.SaveError(file, err.Error(), asr.ErrorPermanent)
+		job.Error(err.Error())
+		return
+	}
+	defer release()
+
+	// Без длительности резерв был бы $0 и файл прошёл бы мимо бюджета
+	var sec float64
+	if s.engine.Cost.Paid() {
+		if sec, err = audioSeconds(file, path); err != nil {
+			s.active.Store.SaveError(file, err.Error(), asr.ErrorPermanent)
+			log.Printf("%s skip %s: %v", s.engine.Description, file.FilePath, err)
+			job.Error(err.Error())
+			return
+		}
+	}
+
+	for attempt := firstAttempt; ; attempt++ {
+		reserved, ok := s.reserve(job, sec)
+		if !ok {
+			return
+		}
+		result, err := tr.Transcribe(path)
+		s.settle(job, file, reserved, result)
+		if err == nil && result != nil && result.Success {
+			s.handleResult(job, file, result)
+			return
+		}
+
+		msg, class, retryAfter := asr.Failure(result, err)
+		if class == asr.ErrorFatal {
+			s.abort(job, msg)
+			return
+		}
+		s.active.Store.SaveError(file, msg, class)
+
+		if class == asr.ErrorPermanent || attempt >= policy.MaxAttempts {
+			log.Printf("%s error %s (%s, attempt %d): %s", s.engine.Description, file.FilePath, class, attempt, msg)
+			job.Error(msg)
+			return
+		}
+
+		delay := policy.Backoff(attempt, retryAfter)
+		log.Printf("%s retry %d/%d for ID=%d in %s: %s",
+			s.engine.Description, attempt+1, policy.MaxAttempts, file.ID, delay.Round(time.Millisecond), msg)
+		if !s.wait(delay) {
+			job.Error(msg)
+			return
+		}
+	}
+}
+
+// wait спит d, прерываясь по Stop. false — сервис остановлен
+func (s *EngineService) wait(d time.Duration) bool {
+	deadline := time.Now().Add(d)
+	for time.Now().Before(deadline) {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			return false
+		}
+		step := time.Until(deadline)
+		if step > 200*time.Millisecond {
+			step = 200 * time.Millisecond
+		}
+		time.Sleep(step)
+	}
+	return atomic.LoadInt32(&s.stopFlag) == 0
+}
+
+func (s *EngineService) runBatches(job *Job, batcher asr.BatchTranscriber, files []db.AudioFile, batchSize int) {
+	for i := 0; i < len(files); i += batchSize {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		end := i + batchSize
+		if end > len(files) {
+			end = len(files)
+		}
+
+		s.processBatch(job, batcher, files[i:end])
+	}
+}
+
+// processBatch декодирует пачку. Файлы с transient ошибкой повторяются по одному
+// (через Transcribe) — так один битый WAV не валит повторно всю пачку.
+// Виртуальные отрезки на время пачки вырезаются во временные WAV
+func (s *EngineService) processBatch(job *Job, batcher asr.BatchTranscriber, files []db.AudioFile) {
+	paths := make([]string, 0, len(files))
+	local := make(map[int64]string, len(files))
+	batch := files[:0:0]
+	for i := range files {
+		path, release, err := LocalAudio(&files[i])
+		if err != nil {
+			s.active.Store.SaveError(&files[i], err.Error(), asr.ErrorPermanent)
+			job.Error(err.Error())
+			continue
+		}
+		defer release()
+		paths = append(paths, path)
+		local[files[i].ID] = path
+		batch = append(batch, files[i])
+	}
+	files = batch
+	if len(files) == 0 {
+		return
+	}
+
+	results, err := batcher.TranscribeBatch(paths)
+	if err != nil {
+		log.Printf("%s batch error: %v", s.engine.Description, err)
+	}
+
+	policy := s.engine.Retry
+	var retry []*db.AudioFile
+	var retryAfter time.Duration
+
+	for i := range files {
+		file := &files[i]
+
+		var result *asr.DecodeResult
+		if err == nil {
+			result = results[local[file.ID]]
+		}
+		if result != nil && result.Success {
+			s.handleResult(job, file, result)
+			continue
+		}
+
+		msg, class, ra := asr.Failure(result, err)
+		if class == asr.ErrorFatal {
+			s.abort(job, msg)
+			continue
+		}
+		s.active.Store.SaveError(file, msg, class)
+
+		if class == asr.ErrorPermanent || policy.MaxAttempts <= 1 {
+			job.Error(msg)
+			continue
+		}
+		retry = append(retry, file)
+		if ra > retryAfter {
+			retryAfter = ra
+		}
+	}
+
+	if len(retry) == 0 {
+		return
+	}
+
+	log.Printf("%s: %d files from batch will be retried one by one", s.engine.Description, len(retry))
+	if !s.wait(policy.Backoff(1, retryAfter)) {
+		for range retry {
+			job.Error("stopped before retry")
+		}
+		return
+	}
+	for _, file := range retry {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			job.Error("stopped before retry")
+			continue
+		}
+		s.transcribeWithRetry(job, s.active.Transcriber, file, 2)
+	}
+}
+
+// handleResult считает WER/CER и сохраняет успешный результат
+func (s *EngineService) handleResult(job *Job, file *db.AudioFile, result *asr.DecodeResult) {
+	wer := metrics.WER(file.TranscriptionOriginal, result.Text)
+	cer := metrics.CER(file.TranscriptionOriginal, result.Text)
+
+	if err := s.active.Store.Save(file, result, wer, cer); err != nil {
+		log.Printf("DB update error: %v", err)
+		job.Error("update db: " + err.Error())
+		return
+	}
+
+	s.addWER(wer)
+	s.addTiming(result)
+	job.Processed()
+}
+
+// RetryErrors возвращает в очередь файлы с transient ошибками (см. EngineStore.RequeueErrors);
+// profile — ошибки запусков с этим профилем
+func (s *EngineService) RetryErrors(profile string, maxAttempts int, unclassified bool) (int64, error) {
+	engine, err := s.forProfile(map[string]string{"profile": profile})
+	if err != nil {
+		return 0, err
+	}
+	return engine.Store.RequeueErrors(maxAttempts, unclassified)
+}
+
+// ProcessFile синхронно обрабатывает один файл (кнопка "process" в UI), без повторов
+func (s *EngineService) ProcessFile(file *db.AudioFile) error {
+	path, release, err := LocalAudio(file)
+	if err != nil {
+		s.engine.Store.SaveError(file, err.Error(), asr.ErrorPermanent)
+		return err
+	}
+	defer release()
+
+	result, err := s.engine.Transcriber.Transcribe(path)
+	if err != nil || !result.Success {
+		msg, class, _ := asr.Failure(result, err)
+		if class != asr.ErrorFatal {
+			s.engine.Store.SaveError(file, msg, class)
+		}
+		return errors.New(msg)
+	}
+
+	wer := metrics.WER(file.TranscriptionOriginal, result.Text)
+	cer := metrics.CER(file.TranscriptionOriginal, result.Text)
+
+	log.Printf("%s OK ID=%d WER=%.2f%%", s.engine.Description, file.ID, wer*100)
+
+	return s.engine.Store.Save(file, result, wer, cer)
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/db"
+)
+
+// scriptedTranscriber отвечает по сценарию: ответы попыток по очереди, дальше — последний
+type scriptedTranscriber struct {
+	mu     sync.Mutex
+	script []*asr.DecodeResult
+	calls  int
+}
+
+func (s *scriptedTranscriber) Transcribe(audioPath string) (*asr.DecodeResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.script[min(s.calls, len(s.script)-1)]
+	s.calls++
+	return r, nil
+}
+
+func (s *scriptedTranscriber) Health() error {
+	return nil
+}
+
+// memStore — EngineStore в памяти: pending — файлы без результата
+type memStore struct {
+	mu     sync.Mutex
+	files  []db.AudioFile
+	saved  map[int64]string
+	errors []string // классы сохранённых ошибок
+}
+
+func (m *memStore) Pending(limit int, params map[string]string) ([]db.AudioFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var files []db.AudioFile
+	for _, f := range m.files {
+		if _, ok := m.saved[f.ID]; !ok {
+			files = append(files, f)
+		}
+	}
+	return files, nil
+}
+
+func (m *memStore) Save(file *db.AudioFile, result *asr.DecodeResult, wer, cer float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.saved[file.ID] = result.Text
+	return nil
+}
+
+func (m *memStore) SaveError(file *db.AudioFile, errMsg, errorClass string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errors = append(m.errors, errorClass)
+	return nil
+}
+
+func (m *memStore) RequeueErrors(maxAttempts int, unclassified bool) (int64, error) {
+	return 0, nil
+}
+
+func failed(class string) *asr.DecodeResult {
+	return &asr.DecodeResult{Error: class + " failure", ErrorClass: class}
+}
+
+func TestEngineRetry(t *testing.T) {
+	ok := &asr.DecodeResult{Success: true, Text: "hello world"}
+
+	tests := []struct {
+		name      string
+		script    []*asr.DecodeResult
+		calls     int
+		processed int64
+		errs      int64
+		saved     []string // классы ошибок в store
+		jobStatus string
+	}{
+		{"success", []*asr.DecodeResult{ok}, 1, 1, 0, nil, db.JobCompleted},
+		{"transient then success", []*asr.DecodeResult{failed(asr.ErrorTransient), ok}, 2, 1, 0,
+			[]string{asr.ErrorTransient}, db.JobCompleted},
+		{"transient exhausted", []*asr.DecodeResult{failed(asr.ErrorTransient)}, 3, 0, 1,
+			[]string{asr.ErrorTransient, asr.ErrorTransient, asr.ErrorTransient}, db.JobCompleted},
+		{"permanent", []*asr.DecodeResult{failed(asr.ErrorPermanent), ok}, 1, 0, 1,
+			[]string{asr.ErrorPermanent}, db.JobCompleted},
+		{"fatal stops the job", []*asr.DecodeResult{failed(asr.ErrorFatal), ok}, 1, 0, 0, nil, db.JobFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := &scriptedTranscriber{script: tt.script}
+			store := &memStore{
+				files: []db.AudioFile{{ID: 1, FilePath: "/data/a.wav", TranscriptionOriginal: "hello world"}},
+				saved: make(map[int64]string),
+			}
+			jobs := testJobs(t)
+			s := NewEngineService(&Engine{
+				Name:        "fake",
+				Transcriber: tr,
+				Store:       store,
+				Retry:       RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 2 * time.Millisecond},
+			}, jobs)
+
+			if err := s.Start(StartOptions{Workers: 1}); err != nil {
+				t.Fatal(err)
+			}
+			st := s.Status()
+			for deadline := time.Now().Add(5 * time.Second); st.Running; st = s.Status() {
+				if time.Now().After(deadline) {
+					t.Fatalf("still running: %+v", st)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			if tr.calls != tt.calls || st.Processed != tt.processed || st.Errors != tt.errs {
+				t.Errorf("calls=%d processed=%d errors=%d, want %d %d %d", tr.calls, st.Processed, st.Errors, tt.calls, tt.processed, tt.errs)
+			}
+			if !reflect.DeepEqual(store.errors, tt.saved) {
+				t.Errorf("saved errors %v, want %v", store.errors, tt.saved)
+			}
+			rec, err := jobs.Get(st.JobID)
+			if err != nil || rec.Status != tt.jobStatus {
+				t.Errorf("job: %+v %v, want %s", rec, err, tt.jobStatus)
+			}
+		})
+	}
+}
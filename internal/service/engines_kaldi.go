@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+)
+
+// Kaldi движки: CPU/GPU, с LM и без LM (lm-scale=0)
+func init() {
+	RegisterEngine("kaldi", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		return kaldiEngine(cfg, database, kaldiVariant{
+			name:        "kaldi",
+			description: "Kaldi ASR",
+			table:       db.EngineKaldi,
+		})
+	})
+
+	RegisterEngine("kaldi-nolm", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		return kaldiEngine(cfg, database, kaldiVariant{
+			name:        "kaldi-nolm",
+			description: "Kaldi ASR NoLM",
+			table:       db.EngineKaldiNoLM,
+			noLM:        true,
+		})
+	})
+
+	RegisterEngine("kaldi-gpu", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		return kaldiEngine(cfg, database, kaldiVariant{
+			name:        "kaldi-gpu",
+			description: "Kaldi ASR GPU",
+			table:       db.EngineKaldi,
+			gpu:         true,
+		})
+	})
+
+	RegisterEngine("kaldi-gpu-nolm", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		return kaldiEngine(cfg, database, kaldiVariant{
+			name:        "kaldi-gpu-nolm",
+			description: "Kaldi ASR GPU NoLM",
+			table:       db.EngineKaldiNoLM,
+			noLM:        true,
+			gpu:         true,
+		})
+	})
+}
+
+// kaldiVariant — чем отличаются Kaldi движки
+type kaldiVariant struct {
+	name        string
+	description string
+	table       string // имя движка в transcriptions
+	noLM        bool   // lm-scale=0 поверх любого профиля
+	gpu         bool   // batched-wav-nnet3-cuda, batch по 32; иначе при KALDI_SERVER — процесс на воркер
+}
+
+// kaldiEngine — движок с профилем по умолчанию; именованные профили (KALDI_PROFILES)
+// выбираются при запуске params["profile"] и пишутся с model_version = имя профиля
+func kaldiEngine(cfg *config.Config, database db.Store, v kaldiVariant) (*Engine, error) {
+	if cfg.Kaldi.ModelDir == "" {
+		return nil, nil
+	}
+
+	engine, err := kaldiProfileEngine(cfg, database, v, cfg.Kaldi.Decoding)
+	if err != nil {
+		return nil, err
+	}
+
+	for name := range cfg.Kaldi.Profiles {
+		engine.Profiles = append(engine.Profiles, name)
+	}
+	sort.Strings(engine.Profiles)
+
+	engine.Profile = func(name string) (*Engine, error) {
+		profile, ok := cfg.Kaldi.Profiles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown kaldi profile: %s", name)
+		}
+		return kaldiProfileEngine(cfg, database, v, profile)
+	}
+	return engine, nil
+}
+
+func kaldiProfileEngine(cfg *config.Config, database db.Store, v kaldiVariant, profile config.KaldiProfile) (*Engine, error) {
+	decoder, err := newKaldiDecoder(cfg, profile, v.noLM)
+	if err != nil {
+		return nil, err
+	}
+
+	engine := &Engine{
+		Name:        v.name,
+		Description: v.description,
+		Transcriber: decoder,
+		Store:       TranscriptionStore{DB: database, Engine: v.table, ModelVersion: profile.Name},
+		Workers:     cfg.Workers.ASR,
+	}
+	if v.gpu {
+		engine.Transcriber = asr.NewKaldiGPUDecoder(decoder)
+		engine.Workers = 1
+		engine.BatchSize = 32
+	} else if cfg.Kaldi.Server {
+		engine.Transcriber = asr.NewKaldiServerDecoder(decoder)
+	}
+	return engine, nil
+}
+
+// newKaldiDecoder — декодер по профилю, без LM (lm-scale=0) для NoLM движков,
+// с извлечением из lattice по конфигу
+func newKaldiDecoder(cfg *config.Config, profile config.KaldiProfile, noLM bool) (*asr.KaldiDecoder, error) {
+	lmScale := profile.LMScale
+	if noLM {
+		lmScale = 0
+	}
+
+	decoder, err := asr.NewKaldiDecoderWithParams(asr.KaldiParams{
+		KaldiRoot:        profile.KaldiRoot,
+		ModelDir:         profile.ModelDir,
+		GraphDir:         profile.GraphDir,
+		Beam:             profile.Beam,
+		MaxActive:        profile.MaxActive,
+		LatticeBeam:      profile.LatticeBeam,
+		AcousticScale:    profile.AcousticScale,
+		LMScale:          lmScale,
+		FrameSubsampling: profile.FrameSubsampling,
+	})
+	if err != nil {
+		return nil, err
+	}
+	decoder.SetLatticeOptions(asr.LatticeOptions{
+		Dir:   cfg.Kaldi.LatticeDir,
+		NBest: cfg.Kaldi.NBest,
+		MBR:   cfg.Kaldi.MBR,
+	})
+	return decoder, nil
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"strings"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+)
+
+// Whisper движки: локальный faster-whisper и OpenAI API
+func init() {
+	RegisterEngine("whisper-local", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		if cfg.Whisper.LocalURL == "" {
+			return nil, nil
+		}
+		return &Engine{
+			Name:        "whisper-local",
+			Description: "Whisper Local",
+			Transcriber: asr.NewWhisperLocalClient(cfg.Whisper.LocalURL, cfg.Whisper.Lang),
+			Store:       TranscriptionStore{DB: database, Engine: db.EngineWhisperLocal},
+			Workers:     3,
+		}, nil
+	})
+
+	RegisterEngine("whisper-openai", func(cfg *config.Config, database db.Store) (*Engine, error) {
+		if cfg.Whisper.OpenAIKey == "" {
+			return nil, nil
+		}
+		limits := cfg.Whisper.OpenAI
+		client := asr.NewWhisperOpenAIClient(cfg.Whisper.OpenAIKey, cfg.Whisper.OpenAIModel, cfg.Whisper.Lang).
+			WithBaseURL(cfg.Whisper.OpenAIURL).
+			WithOptions(asr.WhisperOpenAIOptions{
+				ResponseFormat: cfg.Whisper.OpenAIFormat,
+				Timestamps:     splitList(cfg.Whisper.OpenAITimestamps),
+				Prompt:         cfg.Whisper.OpenAIPrompt,
+				Temperature:    cfg.Whisper.OpenAITemperature,
+			}).
+			WithRateLimiter(asr.NewRateLimiter(float64(limits.RequestsPerMin), float64(limits.AudioMinutesPerMin), limits.MaxConcurrent))
+		return &Engine{
+			Name:        "whisper-openai",
+			Description: "Whisper OpenAI",
+			Transcriber: client,
+			Store:       TranscriptionStore{DB: database, Engine: db.EngineWhisperOpenAI, RefEngine: db.EngineWhisperLocal},
+			Workers:     3,
+			Cost:        CostPolicy{PricePerMinute: limits.PricePerMinute, Budget: limits.Budget},
+		}, nil
+	})
+}
+
+// splitList — "segment, word" -> [segment word]
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
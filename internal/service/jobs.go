@@ -0,0 +1,433 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/db"
+)
+
+// jobFlushInterval — как часто прогресс запущенной задачи пишется в jobs;
+// jobStaleAfter — задача running без сброса прогресса дольше этого считается прерванной
+const (
+	jobFlushInterval = 2 * time.Second
+	jobStaleAfter    = 15 * jobFlushInterval
+)
+
+// Resumer перезапускает прерванную задачу с её сохранёнными параметрами
+type Resumer func(rec *db.Job, startedBy string) error
+
+// JobManager — журнал пакетных задач: создаёт записи в jobs, сбрасывает туда
+// прогресс и продолжает прерванные задачи через зарегистрированные Resumer
+type JobManager struct {
+	db db.JobRepository
+
+	mu       sync.RWMutex
+	resumers map[string]Resumer // type или type/name
+	active   map[int64]*Job
+}
+
+// NewJobManager помечает задачи, оставшиеся running после падения, как interrupted.
+// По updated_at, а не по статусу: на общей базе running задачи другого сервера живы.
+// Задачи, упавшие меньше jobStaleAfter назад (быстрый рестарт), помечает reapLoop
+func NewJobManager(database db.JobRepository) *JobManager {
+	m := &JobManager{
+		db:       database,
+		resumers: make(map[string]Resumer),
+		active:   make(map[int64]*Job),
+	}
+
+	m.markInterrupted()
+	go m.reapLoop()
+	return m
+}
+
+func (m *JobManager) markInterrupted() {
+	if n, err := m.db.MarkInterruptedJobs(int(jobStaleAfter / time.Second)); err != nil {
+		log.Printf("⚠ Jobs: mark interrupted: %v", err)
+	} else if n > 0 {
+		log.Printf("⚠ Jobs: %d interrupted by restart, resume via POST /api/jobs/{id}/resume", n)
+	}
+}
+
+func (m *JobManager) reapLoop() {
+	ticker := time.NewTicker(jobStaleAfter)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.markInterrupted()
+	}
+}
+
+func resumerKey(jobType, name string) string {
+	if name == "" {
+		return jobType
+	}
+	return jobType + "/" + name
+}
+
+// RegisterResumer — сервис сообщает, как продолжить свои задачи
+func (m *JobManager) RegisterResumer(jobType, name string, fn Resumer) {
+	m.mu.Lock()
+	m.resumers[resumerKey(jobType, name)] = fn
+	m.mu.Unlock()
+}
+
+// Begin создаёт запись о новой задаче и запускает периодический сброс прогресса
+func (m *JobManager) Begin(jobType, name string, params interface{}, startedBy string) (*Job, error) {
+	return m.BeginBudget(jobType, name, params, startedBy, 0)
+}
+
+// BeginBudget — Begin для платного движка: задача остановится, когда стоимость дойдёт до budget
+func (m *JobManager) BeginBudget(jobType, name string, params interface{}, startedBy string, budget float64) (*Job, error) {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &db.Job{Type: jobType, Name: name, Params: string(data), StartedBy: startedBy, Budget: budget}
+	id, err := m.db.CreateJob(rec)
+	if err != nil {
+		return nil, fmt.Errorf("create job: %w", err)
+	}
+	rec.ID = id
+	rec.Runs = 1
+
+	return m.track(rec), nil
+}
+
+// Continue переводит прерванную задачу в running; счётчики продолжаются с сохранённых
+func (m *JobManager) Continue(rec *db.Job, startedBy string) (*Job, error) {
+	if err := m.db.ResumeJob(rec.ID, startedBy); err != nil {
+		return nil, err
+	}
+	rec.Runs++
+	rec.StartedBy = startedBy
+	// total пересчитается: сделанное + то, что найдёт новый запуск (AddTotal)
+	rec.Total = rec.Processed + rec.Skipped + rec.Errors
+	return m.track(rec), nil
+}
+
+func (m *JobManager) track(rec *db.Job) *Job {
+	j := &Job{
+		m:         m,
+		rec:       *rec,
+		total:     rec.Total,
+		processed: rec.Processed,
+		skipped:   rec.Skipped,
+		errors:    rec.Errors,
+		cursor:    rec.CursorID,
+		base:      rec.Processed,
+		lastError: rec.LastError,
+		audioSec:  rec.AudioSeconds,
+		cost:      rec.Cost,
+		startTime: time.Now(),
+		done:      make(chan struct{}),
+	}
+	j.rec.Status = db.JobRunning
+
+	m.mu.Lock()
+	m.active[j.rec.ID] = j
+	m.mu.Unlock()
+
+	go j.flushLoop()
+	return j
+}
+
+// Resume продолжает задачу по id
+func (m *JobManager) Resume(id int64, startedBy string) error {
+	rec, err := m.db.GetJob(id)
+	if err != nil {
+		return fmt.Errorf("job %d not found", id)
+	}
+	if rec.Status == db.JobRunning || rec.Status == db.JobCompleted {
+		return fmt.Errorf("job %d is %s", id, rec.Status)
+	}
+
+	m.mu.RLock()
+	fn := m.resumers[resumerKey(rec.Type, rec.Name)]
+	m.mu.RUnlock()
+	if fn == nil {
+		return fmt.Errorf("job %d: no runner for %s", id, resumerKey(rec.Type, rec.Name))
+	}
+	return fn(rec, startedBy)
+}
+
+// Get — задача из памяти (если выполняется) или из базы
+func (m *JobManager) Get(id int64) (*db.Job, error) {
+	m.mu.RLock()
+	j := m.active[id]
+	m.mu.RUnlock()
+	if j != nil {
+		snap := j.Snapshot()
+		return &snap, nil
+	}
+	return m.db.GetJob(id)
+}
+
+// List — история задач; для выполняемых подставляются живые счётчики
+func (m *JobManager) List(jobType, name, status string, page, limit int) ([]db.Job, int64, error) {
+	jobs, total, err := m.db.ListJobs(jobType, name, status, page, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for i := range jobs {
+		if j := m.active[jobs[i].ID]; j != nil {
+			jobs[i] = j.Snapshot()
+		}
+	}
+	return jobs, total, nil
+}
+
+// Last — последний запуск задачи (nil если не запускалась)
+func (m *JobManager) Last(jobType, name string) *db.Job {
+	rec, err := m.db.LastJob(jobType, name)
+	if err != nil {
+		log.Printf("⚠ Jobs: last %s: %v", resumerKey(jobType, name), err)
+		return nil
+	}
+	return rec
+}
+
+// Job — выполняемая задача: атомарные счётчики в памяти, периодически пишутся в jobs
+type Job struct {
+	m   *JobManager
+	rec db.Job
+
+	total     int64
+	processed int64
+	skipped   int64
+	errors    int64
+	cursor    int64
+	base      int64 // processed из прошлых запусков (для rate)
+	startTime time.Time
+
+	mu        sync.Mutex
+	lastError string
+	audioSec  float64 // отправлено в платный API (с прошлыми запусками)
+	cost      float64
+	reserved  float64 // стоимость запросов в полёте
+
+	done     chan struct{}
+	finished int32
+}
+
+func (j *Job) ID() int64 {
+	return j.rec.ID
+}
+
+// Params разбирает сохранённые параметры задачи
+func (j *Job) Params(v interface{}) error {
+	return json.Unmarshal([]byte(j.rec.Params), v)
+}
+
+// Done — сколько элементов уже обработано (в т.ч. в прошлых запусках)
+func (j *Job) Done() int64 {
+	return atomic.LoadInt64(&j.processed) + atomic.LoadInt64(&j.skipped) + atomic.LoadInt64(&j.errors)
+}
+
+// Remaining — limit с учётом уже сделанного (0 — без лимита, <0 — лимит исчерпан)
+func (j *Job) Remaining(limit int) int {
+	if limit <= 0 {
+		return 0
+	}
+	left := limit - int(j.Done())
+	if left <= 0 {
+		return -1
+	}
+	return left
+}
+
+// AddTotal — найдено ещё n элементов (при resume прибавляется к сделанному ранее)
+func (j *Job) AddTotal(n int) {
+	atomic.AddInt64(&j.total, int64(n))
+}
+
+func (j *Job) Processed() {
+	atomic.AddInt64(&j.processed, 1)
+}
+
+func (j *Job) Skipped() {
+	atomic.AddInt64(&j.skipped, 1)
+}
+
+// Error — элемент с ошибкой
+func (j *Job) Error(msg string) {
+	atomic.AddInt64(&j.errors, 1)
+	j.SetLastError(msg)
+}
+
+func (j *Job) SetLastError(msg string) {
+	j.mu.Lock()
+	j.lastError = msg
+	j.mu.Unlock()
+}
+
+func (j *Job) LastError() string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.lastError
+}
+
+// Reserve резервирует стоимость запроса к платному API до отправки.
+// false — с учётом запросов в полёте бюджет задачи будет превышен
+func (j *Job) Reserve(cost float64) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.rec.Budget > 0 && j.cost+j.reserved+cost > j.rec.Budget {
+		return false
+	}
+	j.reserved += cost
+	return true
+}
+
+// Settle снимает резерв; если запрос оплачен — добавляет секунды и стоимость в журнал
+func (j *Job) Settle(reserved, audioSec, cost float64) {
+	j.mu.Lock()
+	j.reserved -= reserved
+	j.audioSec += audioSec
+	j.cost += cost
+	j.mu.Unlock()
+}
+
+// Cost — (секунды аудио, потрачено, бюджет)
+func (j *Job) Cost() (float64, float64, float64) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.audioSec, j.cost, j.rec.Budget
+}
+
+// SetCursor — последний обработанный audio_files.id (для задач, идущих по id)
+func (j *Job) SetCursor(id int64) {
+	atomic.StoreInt64(&j.cursor, id)
+}
+
+func (j *Job) Cursor() int64 {
+	return atomic.LoadInt64(&j.cursor)
+}
+
+func (j *Job) Running() bool {
+	return atomic.LoadInt32(&j.finished) == 0
+}
+
+// Progress — (total, processed, skipped, errors)
+func (j *Job) Progress() (int64, int64, int64, int64) {
+	return atomic.LoadInt64(&j.total), atomic.LoadInt64(&j.processed),
+		atomic.LoadInt64(&j.skipped), atomic.LoadInt64(&j.errors)
+}
+
+// Percent — доля обработанных от total
+func (j *Job) Percent() float64 {
+	t := atomic.LoadInt64(&j.total)
+	if t <= 0 {
+		return 0
+	}
+	return float64(j.Done()) / float64(t) * 100
+}
+
+// Rate — обработано в секунду за текущий запуск
+func (j *Job) Rate() float64 {
+	elapsed := time.Since(j.startTime).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&j.processed)-j.base) / elapsed
+}
+
+// Elapsed — длительность текущего запуска
+func (j *Job) Elapsed() time.Duration {
+	return time.Since(j.startTime)
+}
+
+// Snapshot — текущее состояние в виде записи jobs
+func (j *Job) Snapshot() db.Job {
+	j.mu.Lock()
+	rec := j.rec
+	rec.LastError = j.lastError
+	rec.AudioSeconds = j.audioSec
+	rec.Cost = j.cost
+	j.mu.Unlock()
+
+	rec.Total, rec.Processed, rec.Skipped, rec.Errors = j.Progress()
+	rec.CursorID = j.Cursor()
+	return rec
+}
+
+func (j *Job) flushLoop() {
+	ticker := time.NewTicker(jobFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-ticker.C:
+			snap := j.Snapshot()
+			if err := j.m.db.UpdateJobProgress(&snap); err != nil {
+				log.Printf("⚠ Job %d flush: %v", j.rec.ID, err)
+			}
+		}
+	}
+}
+
+// Finish фиксирует итоговый статус (db.JobCompleted, db.JobStopped, db.JobFailed)
+func (j *Job) Finish(status string) {
+	if !atomic.CompareAndSwapInt32(&j.finished, 0, 1) {
+		return
+	}
+	close(j.done)
+
+	now := time.Now()
+	j.mu.Lock()
+	j.rec.Status = status
+	j.rec.FinishedAt = &now
+	j.mu.Unlock()
+
+	snap := j.Snapshot()
+	if err := j.m.db.FinishJob(&snap); err != nil {
+		log.Printf("⚠ Job %d finish: %v", j.rec.ID, err)
+	}
+
+	j.m.mu.Lock()
+	delete(j.m.active, j.rec.ID)
+	j.m.mu.Unlock()
+}
+
+// Fail — задача не смогла выполниться целиком (ошибка до обработки элементов)
+func (j *Job) Fail(msg string) {
+	j.SetLastError(msg)
+	j.Finish(db.JobFailed)
+}
+
+// finishStatus — completed или stopped, если был выставлен stopFlag сервиса
+func finishStatus(stopFlag *int32) string {
+	if atomic.LoadInt32(stopFlag) == 1 {
+		return db.JobStopped
+	}
+	return db.JobCompleted
+}
+
+// recordPercent — прогресс завершённой задачи из журнала
+func recordPercent(rec *db.Job) float64 {
+	if rec.Total <= 0 {
+		return 0
+	}
+	return float64(rec.Processed+rec.Skipped+rec.Errors) / float64(rec.Total) * 100
+}
+
+// recordElapsed — длительность задачи из журнала: от первого старта до завершения
+func recordElapsed(rec *db.Job) time.Duration {
+	end := time.Now()
+	if rec.FinishedAt != nil {
+		end = *rec.FinishedAt
+	} else if rec.UpdatedAt != nil {
+		end = *rec.UpdatedAt
+	}
+	return end.Sub(rec.StartedAt)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"audio-labeler/internal/db"
+)
+
+func TestJobRemaining(t *testing.T) {
+	tests := []struct {
+		name                     string
+		limit                    int
+		processed, skipped, errs int64
+		want                     int
+	}{
+		{"no limit", 0, 10, 0, 0, 0},
+		{"fresh", 100, 0, 0, 0, 100},
+		{"resumed", 100, 40, 5, 5, 50},
+		{"exhausted", 100, 90, 5, 5, -1},
+		{"over", 10, 20, 0, 0, -1},
+	}
+	for _, tt := range tests {
+		job := &Job{processed: tt.processed, skipped: tt.skipped, errors: tt.errs}
+		if got := job.Remaining(tt.limit); got != tt.want {
+			t.Errorf("%s: Remaining(%d) = %d, want %d", tt.name, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestFinishStatus(t *testing.T) {
+	tests := []struct {
+		stopFlag int32
+		want     string
+	}{
+		{0, db.JobCompleted},
+		{1, db.JobStopped},
+	}
+	for _, tt := range tests {
+		if got := finishStatus(&tt.stopFlag); got != tt.want {
+			t.Errorf("finishStatus(%d) = %s, want %s", tt.stopFlag, got, tt.want)
+		}
+	}
+}
+
+// testJobs — журнал задач на пустой SQLite базе
+func testJobs(t *testing.T) *JobManager {
+	t.Helper()
+	database, err := db.NewSQLite(filepath.Join(t.TempDir(), "jobs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	if _, err := database.MigrateUp(0); err != nil {
+		t.Fatal(err)
+	}
+	return NewJobManager(database)
+}
+
+func TestJobResume(t *testing.T) {
+	m := testJobs(t)
+
+	job, err := m.Begin(db.JobTypeScan, "", map[string]int{"limit": 10}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	job.AddTotal(10)
+	for i := 0; i < 4; i++ {
+		job.Processed()
+	}
+	job.Error("broken")
+	job.SetCursor(42)
+	job.Finish(db.JobStopped)
+	job.Finish(db.JobCompleted) // повторный Finish ничего не меняет
+
+	rec, err := m.Get(job.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Status != db.JobStopped || rec.Processed != 4 || rec.Errors != 1 || rec.CursorID != 42 || rec.LastError != "broken" {
+		t.Fatalf("finished job: %+v", rec)
+	}
+
+	// Resume: счётчики и курсор продолжаются, total — сделанное + новое
+	var resumed *Job
+	m.RegisterResumer(db.JobTypeScan, "", func(rec *db.Job, startedBy string) error {
+		var params map[string]int
+		if err := json.Unmarshal([]byte(rec.Params), &params); err != nil || params["limit"] != 10 {
+			return errors.New("params lost: " + rec.Params)
+		}
+		resumed, err = m.Continue(rec, startedBy)
+		return err
+	})
+	if err := m.Resume(job.ID(), "again"); err != nil {
+		t.Fatal(err)
+	}
+	if resumed.Cursor() != 42 || resumed.Remaining(10) != 5 {
+		t.Errorf("resumed: cursor %d, remaining %d", resumed.Cursor(), resumed.Remaining(10))
+	}
+	resumed.AddTotal(5)
+	if total, processed, _, errs := resumed.Progress(); total != 10 || processed != 4 || errs != 1 {
+		t.Errorf("resumed progress: %d %d %d", total, processed, errs)
+	}
+	if err := m.Resume(job.ID(), "again"); err == nil {
+		t.Error("running job resumed twice")
+	}
+	resumed.Finish(db.JobCompleted)
+
+	rec, _ = m.Get(job.ID())
+	if rec.Status != db.JobCompleted || rec.Runs != 2 || rec.StartedBy != "again" {
+		t.Errorf("after resume: %+v", rec)
+	}
+	if err := m.Resume(job.ID(), "again"); err == nil {
+		t.Error("completed job resumed")
+	}
+}
@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+)
+
+// LoudnessStatus — прогресс нормализации громкости; Skipped — тишина (громкость не определена)
+type LoudnessStatus struct {
+	JobID     int64   `json:"job_id,omitempty"`
+	Running   bool    `json:"running"`
+	Total     int64   `json:"total"`
+	Processed int64   `json:"processed"`
+	Skipped   int64   `json:"skipped"`
+	Errors    int64   `json:"errors"`
+	Percent   float64 `json:"percent"`
+	Elapsed   string  `json:"elapsed"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// loudnessParams — параметры запуска для jobs.params
+type loudnessParams struct {
+	Limit      int               `json:"limit"`
+	TargetLUFS float64           `json:"target_lufs"`
+	PeakDB     float64           `json:"peak_db"`
+	Filter     db.LoudnessFilter `json:"filter"`
+}
+
+// LoudnessService — пакетная нормализация громкости к TargetLUFS с потолком true peak.
+// Копия пишется рядом с суффиксом _loud, исходный файл остаётся на диске: в БД сохраняются
+// применённое усиление и путь исходника, так что нормализацию можно откатить (Revert).
+// Повторная нормализация берёт исходник, а не прошлую копию — ограничение пиков не накапливается
+type LoudnessService struct {
+	db       db.FileRepository
+	jobs     *JobManager
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewLoudnessService(database db.FileRepository, jobs *JobManager) *LoudnessService {
+	s := &LoudnessService{db: database, jobs: jobs}
+	jobs.RegisterResumer(db.JobTypeLoudness, "", s.Resume)
+	return s
+}
+
+// Start выбирает файлы по фильтру и запускает нормализацию в фоне. Возвращает число файлов в очереди
+func (s *LoudnessService) Start(limit int, targetLUFS, peakDB float64, filter db.LoudnessFilter, startedBy string) (int, error) {
+	if targetLUFS >= 0 || targetLUFS < -70 || peakDB > 0 {
+		return 0, fmt.Errorf("invalid loudness target %.1f LUFS / %.1f dBTP", targetLUFS, peakDB)
+	}
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return 0, errors.New("Loudness normalization already running")
+	}
+
+	filter.TargetLUFS, filter.PeakDB = targetLUFS, peakDB
+	files, err := s.db.GetFilesForLoudness(filter, limit, 0)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+	if len(files) == 0 {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, nil
+	}
+
+	params := loudnessParams{Limit: limit, TargetLUFS: targetLUFS, PeakDB: peakDB, Filter: filter}
+	job, err := s.jobs.Begin(db.JobTypeLoudness, "", params, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+
+	s.launch(job, files, params)
+	return len(files), nil
+}
+
+// Resume продолжает нормализацию с файла после курсора
+func (s *LoudnessService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("Loudness normalization already running")
+	}
+
+	var params loudnessParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	var files []db.AudioFile
+	if limit := job.Remaining(params.Limit); limit > 0 {
+		files, err = s.db.GetFilesForLoudness(params.Filter, limit, job.Cursor())
+		if err != nil {
+			atomic.StoreInt32(&s.running, 0)
+			job.Fail(err.Error())
+			return err
+		}
+	}
+
+	s.launch(job, files, params)
+	return nil
+}
+
+func (s *LoudnessService) launch(job *Job, files []db.AudioFile, params loudnessParams) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	job.AddTotal(len(files))
+	go s.run(job, files, params)
+}
+
+func (s *LoudnessService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *LoudnessService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *LoudnessService) Status() LoudnessStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeLoudness, "")
+		if rec == nil {
+			return LoudnessStatus{}
+		}
+		return LoudnessStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Skipped:   rec.Skipped,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+		}
+	}
+
+	t, p, sk, e := job.Progress()
+	return LoudnessStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Skipped:   sk,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+	}
+}
+
+func (s *LoudnessService) run(job *Job, files []db.AudioFile, params loudnessParams) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	for _, file := range files {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		err := s.normalize(file, params.TargetLUFS, params.PeakDB)
+		switch {
+		case errors.Is(err, audio.ErrNoLoudness):
+			job.Skipped()
+		case err != nil:
+			log.Printf("Loudness error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+		default:
+			job.Processed()
+		}
+		job.SetCursor(file.ID)
+	}
+
+	log.Printf("Loudness normalization complete: %d files (job %d)", len(files), job.ID())
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+// normalize обрабатывает один файл; виртуальный отрезок сначала вырезается в файл,
+// он и остаётся исходником для отката
+func (s *LoudnessService) normalize(file db.AudioFile, targetLUFS, peakDB float64) error {
+	source := LoudnessSource(file)
+	if file.IsSegment() {
+		var err error
+		if source, err = Materialize(&file); err != nil {
+			return err
+		}
+	}
+	outputPath := LoudnessPath(source)
+	res, err := audio.NormalizeLoudness(source, outputPath, targetLUFS, peakDB)
+	if err != nil {
+		return err
+	}
+
+	hash, err := audio.MD5File(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := s.db.SetLoudnessNormalized(file.ID, outputPath, hash, res.GainDB, source, res.After); err != nil {
+		return err
+	}
+	limited := ""
+	if res.Limited {
+		limited = " (limited)"
+	}
+	log.Printf("Loudness normalized %d: %.1f→%.1f LUFS, gain %+.2f dB, peak %.1f dBTP%s",
+		file.ID, res.Before.Integrated, res.After.Integrated, res.GainDB, res.After.TruePeak, limited)
+	return nil
+}
+
+// LoudnessSource — файл, от которого считается нормализация: исходник прошлой нормализации,
+// если текущий путь — её копия, иначе текущий файл (например, после правки тишины)
+func LoudnessSource(file db.AudioFile) string {
+	if file.LoudnessSourcePath != "" && file.FilePath == LoudnessPath(file.LoudnessSourcePath) {
+		return file.LoudnessSourcePath
+	}
+	return file.FilePath
+}
+
+// LoudnessPath — путь нормализованной копии исходника: суффикс _loud
+func LoudnessPath(source string) string {
+	ext := filepath.Ext(source)
+	return strings.TrimSuffix(source, ext) + "_loud" + ext
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"encoding/json"
 	"fmt"
 	"log"
 	"os"
@@ -15,22 +16,23 @@ import (
 )
 
 type MergeService struct {
-	db        *db.DB
+	db        db.Store
+	jobs      *JobManager
 	outputDir string
 	running   int32
 	stopFlag  int32
-	processed int64
-	errors    int64
-	total     int64
-	startTime time.Time
+	job       *Job
 	mu        sync.Mutex
 }
 
-func NewMergeService(database *db.DB, outputDir string) *MergeService {
-	return &MergeService{
+func NewMergeService(database db.Store, jobs *JobManager, outputDir string) *MergeService {
+	s := &MergeService{
 		db:        database,
+		jobs:      jobs,
 		outputDir: outputDir,
 	}
+	jobs.RegisterResumer(db.JobTypeMerge, "", s.ResumeQueue)
+	return s
 }
 
 type MergeRequest struct {
@@ -48,7 +50,13 @@ type MergeResult struct {
 	ChapterID     string  `json:"chapter_id"`
 }
 
+// mergeQueueParams — параметры запуска очереди для jobs.params
+type mergeQueueParams struct {
+	Limit int `json:"limit"`
+}
+
 type MergeQueueStatus struct {
+	JobID     int64  `json:"job_id,omitempty"`
 	Running   bool   `json:"running"`
 	Total     int64  `json:"total"`
 	Processed int64  `json:"processed"`
@@ -106,21 +114,26 @@ func (s *MergeService) mergeFilesInternal(files []*db.AudioFile, speakerID, outp
 	idStrings := make([]string, len(files))
 
 	for i, f := range files {
+		// Виртуальный отрезок вырезается во временный WAV
+		filePath, release, err := LocalAudio(f)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+
 		// Проверяем тишину в конце
-		silenceInfo, err := audio.DetectTrailingSilence(f.FilePath, 100)
+		silenceInfo, err := audio.DetectTrailingSilence(filePath, 100)
 		if err != nil {
 			log.Printf("Warning: silence check failed for %d: %v", f.ID, err)
 		}
 
-		filePath := f.FilePath
-
 		// Если нет тишины — добавляем
 		if silenceInfo != nil && !silenceInfo.HasTrailingSilence {
 			log.Printf("Adding silence to file %d (no trailing silence)", f.ID)
 
 			// Создаём временный файл с тишиной
-			tmpPath := f.FilePath + ".with_silence.wav"
-			if err := audio.AddTrailingSilence(f.FilePath, tmpPath, 150); err != nil {
+			tmpPath := filePath + ".with_silence.wav"
+			if err := audio.AddTrailingSilence(filePath, tmpPath, 150); err != nil {
 				log.Printf("Warning: failed to add silence to %d: %v", f.ID, err)
 			} else {
 				filePath = tmpPath
@@ -222,65 +235,135 @@ func (s *MergeService) mergeFilesInternal(files []*db.AudioFile, speakerID, outp
 // ========================================
 
 // ProcessMergeQueue обрабатывает очередь merge
-func (s *MergeService) ProcessMergeQueue(limit int) error {
+func (s *MergeService) ProcessMergeQueue(limit int, startedBy string) error {
 	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
 		return fmt.Errorf("merge queue already processing")
 	}
 
-	atomic.StoreInt64(&s.processed, 0)
-	atomic.StoreInt64(&s.errors, 0)
-	atomic.StoreInt32(&s.stopFlag, 0)
-	s.startTime = time.Now()
+	job, err := s.jobs.Begin(db.JobTypeMerge, "", mergeQueueParams{Limit: limit}, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	s.launch(job, limit)
+	return nil
+}
+
+// ResumeQueue продолжает прерванную обработку очереди
+func (s *MergeService) ResumeQueue(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return fmt.Errorf("merge queue already processing")
+	}
+
+	var params mergeQueueParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
 
-	go s.runQueue(limit)
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	s.launch(job, params.Limit)
 	return nil
 }
 
+func (s *MergeService) launch(job *Job, limit int) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	go s.runQueue(job, limit)
+}
+
 func (s *MergeService) StopQueue() {
 	atomic.StoreInt32(&s.stopFlag, 1)
 }
 
+func (s *MergeService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
 func (s *MergeService) QueueStatus() MergeQueueStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeMerge, "")
+		if rec == nil {
+			return MergeQueueStatus{}
+		}
+		return MergeQueueStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Errors:    rec.Errors,
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+		}
+	}
+
+	t, p, _, e := job.Progress()
 	return MergeQueueStatus{
+		JobID:     job.ID(),
 		Running:   atomic.LoadInt32(&s.running) == 1,
-		Total:     atomic.LoadInt64(&s.total),
-		Processed: atomic.LoadInt64(&s.processed),
-		Errors:    atomic.LoadInt64(&s.errors),
-		Elapsed:   time.Since(s.startTime).Round(time.Second).String(),
+		Total:     t,
+		Processed: p,
+		Errors:    e,
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
 	}
 }
 
-func (s *MergeService) runQueue(limit int) {
+func (s *MergeService) runQueue(job *Job, limit int) {
 	defer atomic.StoreInt32(&s.running, 0)
 
+	// Записи, застрявшие в processing после падения, возвращаются в очередь
+	if n, err := s.db.ResetStaleMergeQueue(); err != nil {
+		log.Printf("Merge queue reset error: %v", err)
+	} else if n > 0 {
+		log.Printf("⚠ Merge queue: %d stale processing items returned to pending", n)
+	}
+
+	limit = job.Remaining(limit)
+	if limit < 0 {
+		job.Finish(db.JobCompleted)
+		return
+	}
+
 	items, err := s.db.GetPendingMergeQueue(limit)
 	if err != nil {
 		log.Printf("Merge queue error: %v", err)
+		job.Fail(err.Error())
 		return
 	}
 
 	if len(items) == 0 {
 		log.Println("Merge queue: no pending items")
+		job.Finish(db.JobCompleted)
 		return
 	}
 
-	atomic.StoreInt64(&s.total, int64(len(items)))
-	log.Printf("Merge queue: processing %d items", len(items))
+	job.AddTotal(len(items))
+	log.Printf("Merge queue: processing %d items (job %d)", len(items), job.ID())
 
 	for _, item := range items {
 		if atomic.LoadInt32(&s.stopFlag) == 1 {
 			break
 		}
 
-		s.processQueueItem(item)
+		s.processQueueItem(job, item)
 	}
 
-	log.Printf("Merge queue complete: processed=%d errors=%d",
-		atomic.LoadInt64(&s.processed),
-		atomic.LoadInt64(&s.errors))
+	_, p, _, e := job.Progress()
+	log.Printf("Merge queue complete: processed=%d errors=%d", p, e)
+	job.Finish(finishStatus(&s.stopFlag))
 }
 
-func (s *MergeService) processQueueItem(item db.MergeQueueItem) {
+func (s *MergeService) processQueueItem(job *Job, item db.MergeQueueItem) {
 	// Помечаем как processing
 	s.db.UpdateMergeQueueStatus(item.ID, "processing")
 
@@ -288,7 +371,7 @@ func (s *MergeService) processQueueItem(item db.MergeQueueItem) {
 	ids, err := db.ParseMergeIDs(item.IDsString)
 	if err != nil {
 		s.db.UpdateMergeQueueError(item.ID, err.Error())
-		atomic.AddInt64(&s.errors, 1)
+		job.Error(err.Error())
 		return
 	}
 
@@ -296,7 +379,7 @@ func (s *MergeService) processQueueItem(item db.MergeQueueItem) {
 	_, _, err = s.db.CheckFilesForMerge(ids)
 	if err != nil {
 		s.db.UpdateMergeQueueError(item.ID, err.Error())
-		atomic.AddInt64(&s.errors, 1)
+		job.Error(err.Error())
 		return
 	}
 
@@ -304,13 +387,13 @@ func (s *MergeService) processQueueItem(item db.MergeQueueItem) {
 	result, err := s.MergeFiles(ids, s.outputDir)
 	if err != nil {
 		s.db.UpdateMergeQueueError(item.ID, err.Error())
-		atomic.AddInt64(&s.errors, 1)
+		job.Error(err.Error())
 		return
 	}
 
 	// Успех
 	s.db.UpdateMergeQueueCompleted(item.ID, result.NewID, result.OutputPath, result.Duration, result.Transcription)
-	atomic.AddInt64(&s.processed, 1)
+	job.Processed()
 
 	log.Printf("Merged queue item %d -> file %d (%.2fs)", item.ID, result.NewID, result.Duration)
 }
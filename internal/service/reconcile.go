@@ -0,0 +1,432 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/scanner"
+)
+
+// ReconcileParams — параметры сверки для jobs.params
+type ReconcileParams struct {
+	Format string `json:"format,omitempty"` // формат корпуса, как у скана
+	Dir    string `json:"dir,omitempty"`    // подкаталог DATA_DIR
+	Hashes bool   `json:"hashes"`           // сверять MD5 аудио (читает каждый файл)
+}
+
+// ReconcileStatus — прогресс сверки и нерешённые расхождения по видам
+type ReconcileStatus struct {
+	JobID     int64            `json:"job_id,omitempty"`
+	Running   bool             `json:"running"`
+	Total     int64            `json:"total"`
+	Processed int64            `json:"processed"`
+	Errors    int64            `json:"errors"`
+	Percent   float64          `json:"percent"`
+	Elapsed   string           `json:"elapsed"`
+	LastError string           `json:"last_error,omitempty"`
+	Pending   map[string]int64 `json:"pending"`
+}
+
+// ResolveResult — итог решения по расхождению
+type ResolveResult struct {
+	ID      int64  `json:"id"`
+	Kind    string `json:"kind"`
+	Winner  string `json:"winner"`
+	Written bool   `json:"written,omitempty"` // эталон базы записан в файл эталонов
+	Note    string `json:"note,omitempty"`    // почему эталон базы не записан на диск
+}
+
+// notPersisted — база победила, но формат корпуса не позволяет переписать эталон
+const notPersisted = "not persisted to disk: the corpus format has no writable transcript file"
+
+// ReconcileService сверяет эталоны и аудио на диске с базой. Скан и слежение за каталогом
+// не трогают уже импортированные строки, а правки оператора не попадают в trans.txt:
+// расхождения ставятся в очередь reconcile_conflicts, сторону выбирает оператор (Resolve)
+type ReconcileService struct {
+	db       db.Store
+	jobs     *JobManager
+	dataDir  string
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewReconcileService(database db.Store, jobs *JobManager, dataDir string) *ReconcileService {
+	return &ReconcileService{db: database, jobs: jobs, dataDir: dataDir}
+}
+
+// Start запускает сверку в фоне
+func (s *ReconcileService) Start(params ReconcileParams, startedBy string) error {
+	if _, err := scanner.GetImporter(params.Format); err != nil {
+		return err
+	}
+	if params.Dir != "" && !filepath.IsLocal(params.Dir) {
+		return fmt.Errorf("dir %q must be relative to DATA_DIR", params.Dir)
+	}
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("reconcile already running")
+	}
+
+	job, err := s.jobs.Begin(db.JobTypeReconcile, "", params, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	go s.run(job, params)
+	return nil
+}
+
+func (s *ReconcileService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *ReconcileService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *ReconcileService) Status() ReconcileStatus {
+	pending, err := s.db.CountPendingConflicts()
+	if err != nil {
+		log.Printf("⚠ Reconcile: count conflicts: %v", err)
+	}
+
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeReconcile, "")
+		if rec == nil {
+			return ReconcileStatus{Pending: pending}
+		}
+		return ReconcileStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+			Pending:   pending,
+		}
+	}
+
+	t, p, _, e := job.Progress()
+	return ReconcileStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+		Pending:   pending,
+	}
+}
+
+func (s *ReconcileService) run(job *Job, params ReconcileParams) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	importer, err := scanner.GetImporter(params.Format)
+	if err != nil {
+		job.Fail(err.Error())
+		return
+	}
+	root := filepath.Join(s.dataDir, params.Dir)
+	log.Printf("Reconciling %s (hashes=%v, job %d)", root, params.Hashes, job.ID())
+
+	tasks, err := importer.Scan(root, 0)
+	if err != nil {
+		job.Fail("scan dir: " + err.Error())
+		return
+	}
+	files, err := s.db.GetFilesUnderDir(root)
+	if err != nil {
+		job.Fail("load files: " + err.Error())
+		return
+	}
+	standing, err := s.db.GetStandingConflicts()
+	if err != nil {
+		job.Fail("load conflicts: " + err.Error())
+		return
+	}
+
+	onDisk := make(map[string]scanner.AudioTask, len(tasks))
+	for _, task := range tasks {
+		onDisk[taskKey(task)] = task
+	}
+	known := make(map[int64][]db.ReconcileConflict)
+	for _, c := range standing {
+		known[c.AudioFileID] = append(known[c.AudioFileID], c)
+	}
+
+	var active []db.AudioFile
+	for _, f := range files {
+		if f.Active {
+			active = append(active, f)
+		}
+	}
+	job.AddTotal(len(active))
+
+	hashes := make(map[string]string) // hash длинных записей для виртуальных отрезков
+	found := 0
+	for i := range active {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+		f := &active[i]
+
+		task, ok := onDisk[f.SourceKey]
+		conflicts, err := s.check(f, task, ok, params.Hashes, hashes)
+		if err != nil {
+			job.Error(fmt.Sprintf("file %d: %v", f.ID, err))
+			continue
+		}
+		found += s.enqueue(job.ID(), f.ID, conflicts, known[f.ID])
+		job.Processed()
+	}
+
+	_, _, _, e := job.Progress()
+	log.Printf("✓ Reconcile: %d files, %d new conflicts, %d errors (job %d)", len(active), found, e, job.ID())
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+// check сравнивает строку базы с диском; task — строка импорта с тем же ключом (ok — нашлась)
+func (s *ReconcileService) check(f *db.AudioFile, task scanner.AudioTask, ok, hashes bool,
+	cache map[string]string) ([]db.ReconcileConflict, error) {
+
+	if _, err := os.Stat(f.FilePath); errors.Is(err, fs.ErrNotExist) {
+		return []db.ReconcileConflict{{
+			Kind:   db.ConflictFileMissing,
+			DBText: f.TranscriptionOriginal,
+			DBHash: f.FileHash,
+		}}, nil
+	}
+
+	var conflicts []db.ReconcileConflict
+	if ok {
+		if c := textConflict(f, task); c != nil {
+			conflicts = append(conflicts, *c)
+		} else if f.TranscriptionSource == nil || *f.TranscriptionSource != task.Transcription {
+			// Стороны сошлись: запоминаем, что сейчас на диске
+			if err := s.db.SetTranscriptionSource(f.ID, task.Transcription); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if hashes {
+		hash, err := fileHash(f, cache)
+		if err != nil {
+			return nil, err
+		}
+		if hash != f.FileHash {
+			conflicts = append(conflicts, db.ReconcileConflict{
+				Kind:     db.ConflictHashChanged,
+				DBText:   f.TranscriptionOriginal,
+				DiskHash: hash,
+				DBHash:   f.FileHash,
+			})
+		}
+	}
+	return conflicts, nil
+}
+
+// textConflict — расхождение эталонов или nil. База сравнивается с эталоном прошлой сверки:
+// без него (старые строки) неправленный эталон считается прочитанным с диска,
+// а правленый — расходящимся с неизменившимся диском
+func textConflict(f *db.AudioFile, task scanner.AudioTask) *db.ReconcileConflict {
+	disk, current := task.Transcription, f.TranscriptionOriginal
+	if disk == current {
+		return nil
+	}
+
+	base := current
+	switch {
+	case f.TranscriptionSource != nil:
+		base = *f.TranscriptionSource
+	case f.OriginalEdited:
+		base = disk
+	}
+
+	kind := db.ConflictDBEdited
+	switch diskChanged, dbChanged := disk != base, current != base; {
+	case diskChanged && dbChanged:
+		kind = db.ConflictBothChanged
+	case diskChanged:
+		kind = db.ConflictDiskChanged
+	}
+	return &db.ReconcileConflict{
+		Kind:      kind,
+		DiskText:  disk,
+		DBText:    current,
+		BaseText:  base,
+		DBHash:    f.FileHash,
+		TransFile: task.TransFile,
+		UttID:     task.UttID,
+	}
+}
+
+// enqueue ставит новые расхождения файла в очередь. Такие же нерешённые (или решённые
+// в пользу базы) не дублируются, а нерешённые, которых больше нет, снимаются
+func (s *ReconcileService) enqueue(jobID, fileID int64, conflicts, known []db.ReconcileConflict) int {
+	same := func(a, b db.ReconcileConflict) bool {
+		return a.Kind == b.Kind && a.DiskText == b.DiskText && a.DBText == b.DBText && a.DiskHash == b.DiskHash
+	}
+
+	added := 0
+	for _, c := range conflicts {
+		dup := false
+		for _, k := range known {
+			dup = dup || same(c, k)
+		}
+		if dup {
+			continue
+		}
+		c.JobID, c.AudioFileID = jobID, fileID
+		if _, err := s.db.InsertReconcileConflict(&c); err != nil {
+			log.Printf("⚠ Reconcile: file %d: %v", fileID, err)
+			continue
+		}
+		added++
+	}
+
+	for _, k := range known {
+		if k.Status != db.ConflictPending {
+			continue
+		}
+		stale := true
+		for _, c := range conflicts {
+			stale = stale && !same(c, k)
+		}
+		if stale {
+			if _, err := s.db.ResolveReconcileConflict(k.ID, "", "reconcile"); err != nil {
+				log.Printf("⚠ Reconcile: conflict %d: %v", k.ID, err)
+			}
+		}
+	}
+	return added
+}
+
+// Resolve применяет выбор оператора. Побеждает диск: эталон с диска, новый hash аудио,
+// удалённый файл деактивируется. Побеждает база: эталон записывается в файл эталонов;
+// если формат этого не позволяет, эталон остаётся только в базе (Written=false, Note)
+func (s *ReconcileService) Resolve(id int64, winner, resolvedBy string) (*ResolveResult, error) {
+	if winner != db.WinnerDisk && winner != db.WinnerDB {
+		return nil, fmt.Errorf("winner must be %s or %s", db.WinnerDisk, db.WinnerDB)
+	}
+	c, err := s.db.GetReconcileConflict(id)
+	if err != nil {
+		return nil, fmt.Errorf("conflict %d not found", id)
+	}
+	if c.Status != db.ConflictPending {
+		return nil, fmt.Errorf("conflict %d already resolved", id)
+	}
+	f, err := s.db.GetFileIncludingInactive(c.AudioFileID)
+	if err != nil {
+		return nil, fmt.Errorf("file %d: %w", c.AudioFileID, err)
+	}
+
+	result := &ResolveResult{ID: id, Kind: c.Kind, Winner: winner}
+	switch {
+	case c.Kind == db.ConflictFileMissing:
+		if winner == db.WinnerDisk {
+			err = s.db.DeactivateFiles([]int64{f.ID})
+		}
+
+	case c.Kind == db.ConflictHashChanged:
+		if winner == db.WinnerDisk {
+			err = s.acceptAudio(f)
+		}
+
+	case winner == db.WinnerDisk:
+		if err = s.db.AcceptDiskTranscription(f.ID, c.DiskText); err == nil {
+			recalcWER(s.db, f.ID)
+		}
+
+	case c.TransFile != "":
+		if err = scanner.WriteTranscript(c.TransFile, c.UttID, f.TranscriptionOriginal); err == nil {
+			result.Written = true
+			err = s.db.SetTranscriptionSource(f.ID, f.TranscriptionOriginal)
+		}
+
+	default:
+		result.Note = notPersisted
+		log.Printf("⚠ Reconcile conflict %d (file %d): %s", id, f.ID, notPersisted)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ResolveReconcileConflict(id, winner, resolvedBy); err != nil {
+		return nil, err
+	}
+	log.Printf("Reconcile conflict %d (%s, file %d): %s wins", id, c.Kind, f.ID, winner)
+	return result, nil
+}
+
+// ResolveMany — массовое решение; ошибки по отдельным расхождениям не прерывают остальные
+func (s *ReconcileService) ResolveMany(ids []int64, winner, resolvedBy string) ([]ResolveResult, []string) {
+	results := []ResolveResult{}
+	var errs []string
+	for _, id := range ids {
+		r, err := s.Resolve(id, winner, resolvedBy)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		results = append(results, *r)
+	}
+	return results, errs
+}
+
+// acceptAudio — аудио на диске принимается как есть: новый hash (и длительность файла)
+func (s *ReconcileService) acceptAudio(f *db.AudioFile) error {
+	hash, err := fileHash(f, nil)
+	if err != nil {
+		return err
+	}
+	if f.IsSegment() {
+		return s.db.UpdateSegmentRange(f.ID, *f.SegmentStart, *f.SegmentEnd, hash)
+	}
+	duration, err := audio.GetAudioDuration(f.FilePath)
+	if err != nil {
+		return err
+	}
+	return s.db.UpdateFilePath(f.ID, f.FilePath, duration, hash)
+}
+
+// fileHash — MD5 аудио строки; для виртуального отрезка — SegmentHash записи.
+// cache (может быть nil) хранит hash записей
+func fileHash(f *db.AudioFile, cache map[string]string) (string, error) {
+	hash, ok := cache[f.FilePath]
+	if !ok {
+		var err error
+		if hash, err = audio.MD5File(f.FilePath); err != nil {
+			return "", err
+		}
+		if cache != nil && f.IsSegment() {
+			cache[f.FilePath] = hash
+		}
+	}
+	if f.IsSegment() {
+		return audio.SegmentHash(hash, *f.SegmentStart, *f.SegmentEnd), nil
+	}
+	return hash, nil
+}
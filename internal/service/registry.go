@@ -0,0 +1,124 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+)
+
+// EngineFactory создаёт движок из конфига.
+// Возвращает nil, nil если движок не настроен (например нет модели или ключа)
+type EngineFactory func(cfg *config.Config, database db.Store) (*Engine, error)
+
+type namedFactory struct {
+	name    string
+	factory EngineFactory
+}
+
+var (
+	engineFactories   []namedFactory
+	engineFactoriesMu sync.Mutex
+)
+
+// RegisterEngine регистрирует фабрику движка. Вызывается из init() файла движка,
+// чтобы добавить новый ASR достаточно одного файла с реализацией.
+func RegisterEngine(name string, factory EngineFactory) {
+	engineFactoriesMu.Lock()
+	defer engineFactoriesMu.Unlock()
+
+	for _, f := range engineFactories {
+		if f.name == name {
+			panic("service: engine registered twice: " + name)
+		}
+	}
+	engineFactories = append(engineFactories, namedFactory{name: name, factory: factory})
+}
+
+// Registry — набор запущенных (сконфигурированных) движков
+type Registry struct {
+	jobs     *JobManager
+	mu       sync.RWMutex
+	services map[string]*EngineService
+	order    []string
+}
+
+func NewRegistry(jobs *JobManager) *Registry {
+	return &Registry{jobs: jobs, services: make(map[string]*EngineService)}
+}
+
+// BuildEngines создаёт все зарегистрированные движки, для которых есть конфиг
+func BuildEngines(cfg *config.Config, database db.Store, jobs *JobManager) *Registry {
+	engineFactoriesMu.Lock()
+	factories := append([]namedFactory(nil), engineFactories...)
+	engineFactoriesMu.Unlock()
+
+	reg := NewRegistry(jobs)
+	for _, f := range factories {
+		engine, err := f.factory(cfg, database)
+		if err != nil {
+			log.Printf("⚠ Engine %s error: %v", f.name, err)
+			continue
+		}
+		if engine == nil {
+			continue
+		}
+		if engine.Name == "" {
+			engine.Name = f.name
+		}
+		if engine.Retry.MaxAttempts == 0 {
+			engine.Retry = RetryPolicyFromConfig(cfg.Retry)
+		}
+		if err := reg.Register(engine); err != nil {
+			log.Printf("⚠ Engine %s error: %v", f.name, err)
+			continue
+		}
+		log.Printf("✓ Engine %s: %s (workers=%d, batch=%d)", engine.Name, engine.Description, engine.Workers, engine.BatchSize)
+	}
+	return reg
+}
+
+func (r *Registry) Register(engine *Engine) error {
+	if engine.Transcriber == nil || engine.Store == nil {
+		return fmt.Errorf("engine %s: transcriber and store required", engine.Name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.services[engine.Name]; ok {
+		return fmt.Errorf("engine %s already registered", engine.Name)
+	}
+	if engine.Description == "" {
+		engine.Description = engine.Name
+	}
+	r.services[engine.Name] = NewEngineService(engine, r.jobs)
+	r.order = append(r.order, engine.Name)
+	return nil
+}
+
+// Get возвращает сервис движка или nil если движок не настроен
+func (r *Registry) Get(name string) *EngineService {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.services[name]
+}
+
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]string(nil), r.order...)
+}
+
+func (r *Registry) All() []*EngineService {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]*EngineService, 0, len(r.order))
+	for _, name := range r.order {
+		all = append(all, r.services[name])
+	}
+	return all
+}
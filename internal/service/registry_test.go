@@ -0,0 +1,42 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry(testJobs(t))
+	tr := &scriptedTranscriber{}
+	store := &memStore{}
+
+	tests := []struct {
+		name    string
+		engine  *Engine
+		wantErr bool
+	}{
+		{"kaldi", &Engine{Name: "kaldi", Description: "Kaldi", Transcriber: tr, Store: store}, false},
+		{"whisper", &Engine{Name: "whisper", Transcriber: tr, Store: store}, false},
+		{"duplicate", &Engine{Name: "kaldi", Transcriber: tr, Store: store}, true},
+		{"no transcriber", &Engine{Name: "broken", Store: store}, true},
+		{"no store", &Engine{Name: "broken", Transcriber: tr}, true},
+	}
+	for _, tt := range tests {
+		if err := reg.Register(tt.engine); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Register error = %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+
+	if names := reg.Names(); !reflect.DeepEqual(names, []string{"kaldi", "whisper"}) {
+		t.Errorf("Names = %v", names)
+	}
+	if all := reg.All(); len(all) != 2 || all[0].Name() != "kaldi" || all[1].Name() != "whisper" {
+		t.Errorf("All = %v", all)
+	}
+	if s := reg.Get("whisper"); s == nil || s.Description() != "whisper" {
+		t.Errorf("Get(whisper) = %v, description defaults to name", s)
+	}
+	if reg.Get("broken") != nil {
+		t.Error("invalid engine registered")
+	}
+}
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"math/rand/v2"
+	"time"
+
+	"audio-labeler/internal/config"
+)
+
+// RetryPolicy — повторы transient ошибок движка внутри одного запуска
+type RetryPolicy struct {
+	MaxAttempts int // включая первую попытку; 1 — без повторов
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+}
+
+func RetryPolicyFromConfig(cfg config.RetryConfig) RetryPolicy {
+	p := RetryPolicy{
+		MaxAttempts: cfg.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.BaseDelayMs) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.MaxDelayMs) * time.Millisecond,
+	}
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	if p.MaxDelay < p.BaseDelay {
+		p.MaxDelay = p.BaseDelay
+	}
+	return p
+}
+
+// Backoff — пауза после неудачной попытки attempt (с 1): base·2^(attempt-1), не больше MaxDelay,
+// случайно в [d/2, d], чтобы воркеры не били в API одновременно.
+// Если сервер попросил паузу (Retry-After) — не меньше неё
+func (p RetryPolicy) Backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < attempt && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	if d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if d > 0 {
+		d = d/2 + rand.N(d/2+1)
+	}
+	if retryAfter > d {
+		d = retryAfter
+	}
+	return d
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"audio-labeler/internal/config"
+)
+
+func TestBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	tests := []struct {
+		name       string
+		attempt    int
+		retryAfter time.Duration
+		min, max   time.Duration
+	}{
+		{"first", 1, 0, 50 * time.Millisecond, 100 * time.Millisecond},
+		{"doubles", 3, 0, 200 * time.Millisecond, 400 * time.Millisecond},
+		{"capped", 10, 0, 500 * time.Millisecond, time.Second},
+		{"retry-after wins", 1, 5 * time.Second, 5 * time.Second, 5 * time.Second},
+		{"short retry-after ignored", 3, time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if d := policy.Backoff(tt.attempt, tt.retryAfter); d < tt.min || d > tt.max {
+					t.Fatalf("Backoff(%d, %s) = %s, want [%s, %s]", tt.attempt, tt.retryAfter, d, tt.min, tt.max)
+				}
+			}
+		})
+	}
+
+	if d := (RetryPolicy{MaxAttempts: 3}).Backoff(2, 0); d != 0 {
+		t.Errorf("zero delays: %s", d)
+	}
+}
+
+func TestRetryPolicyFromConfig(t *testing.T) {
+	tests := []struct {
+		cfg  config.RetryConfig
+		want RetryPolicy
+	}{
+		{config.RetryConfig{MaxAttempts: 3, BaseDelayMs: 1000, MaxDelayMs: 30000},
+			RetryPolicy{MaxAttempts: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second}},
+		{config.RetryConfig{MaxAttempts: 0, BaseDelayMs: 500, MaxDelayMs: 100},
+			RetryPolicy{MaxAttempts: 1, BaseDelay: 500 * time.Millisecond, MaxDelay: 500 * time.Millisecond}},
+	}
+	for _, tt := range tests {
+		if got := RetryPolicyFromConfig(tt.cfg); got != tt.want {
+			t.Errorf("RetryPolicyFromConfig(%+v) = %+v, want %+v", tt.cfg, got, tt.want)
+		}
+	}
+}
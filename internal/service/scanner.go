@@ -1,19 +1,24 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"math"
+	"path/filepath"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"audio-labeler/internal/audio"
+	"audio-labeler/internal/config"
 	"audio-labeler/internal/db"
 	"audio-labeler/internal/scanner"
 )
 
 type ScanStatus struct {
+	JobID     int64   `json:"job_id,omitempty"`
 	Running   bool    `json:"running"`
 	Total     int64   `json:"total"`
 	Processed int64   `json:"processed"`
@@ -25,31 +30,76 @@ type ScanStatus struct {
 	LastError string  `json:"last_error,omitempty"`
 }
 
+// scanParams — параметры запуска, сохраняются в jobs.params для resume.
+// Format — формат корпуса (scanner.Formats), Dir — подкаталог DATA_DIR ("" — весь DATA_DIR),
+// Duplicates — что делать с почти-дубликатами (DuplicatesOff, DuplicatesFlag, DuplicatesSkip)
+type scanParams struct {
+	Limit      int    `json:"limit"`
+	Workers    int    `json:"workers"`
+	Format     string `json:"format,omitempty"`
+	Dir        string `json:"dir,omitempty"`
+	Duplicates string `json:"duplicates,omitempty"`
+}
+
 type Scanner struct {
-	db             *db.DB
+	db             db.Store
+	jobs           *JobManager
 	dataDir        string
 	defaultWorkers int
+	dedup          config.DedupConfig
 	running        int32
 	stopFlag       int32
-	processed      int64
-	skipped        int64
-	errors         int64
-	total          int64
-	startTime      time.Time
-	lastError      string
+	job            *Job
 	existingPaths  map[string]bool
 	mu             sync.Mutex
+
+	// Отпечатки файлов базы и импортированных в этом запуске. Поиск и резерв места
+	// в индексе — под dupMu, чтобы две копии в одном скане не прошли мимо друг друга;
+	// файлы скана лежат в индексе под отрицательными ключами dupSlots до своего Insert
+	dupMode  string
+	dupIndex *audio.FingerprintIndex
+	dupSlots map[int64]*dupSlot
+	dupMu    sync.Mutex
 }
 
-func NewScanner(db *db.DB, dataDir string, defaultWorkers int) *Scanner {
-	return &Scanner{
-		db:             db,
+// dupSlot — файл этого скана в индексе дубликатов; ready закрывается после Insert,
+// id = 0 — файл не добавлен (ошибка или skip)
+type dupSlot struct {
+	id    int64
+	ready chan struct{}
+}
+
+func NewScanner(database db.Store, jobs *JobManager, dataDir string, defaultWorkers int, dedup config.DedupConfig) *Scanner {
+	s := &Scanner{
+		db:             database,
+		jobs:           jobs,
 		dataDir:        dataDir,
 		defaultWorkers: defaultWorkers,
+		dedup:          dedup,
+	}
+	if err := checkDuplicatesMode(dedup.ScanMode); err != nil {
+		log.Printf("⚠ SCAN_DUPLICATES: %v, using %s", err, DuplicatesOff)
+		s.dedup.ScanMode = DuplicatesOff
 	}
+	jobs.RegisterResumer(db.JobTypeScan, "", s.Resume)
+	return s
 }
 
-func (s *Scanner) Start(limit, workers int) error {
+// Start запускает импорт корпуса формата format из подкаталога dir в DATA_DIR;
+// duplicates "" — режим из SCAN_DUPLICATES
+func (s *Scanner) Start(limit, workers int, format, dir, duplicates, startedBy string) error {
+	if _, err := scanner.GetImporter(format); err != nil {
+		return err
+	}
+	if duplicates == "" {
+		duplicates = s.dedup.ScanMode
+	}
+	if err := checkDuplicatesMode(duplicates); err != nil {
+		return err
+	}
+	if dir != "" && !filepath.IsLocal(dir) {
+		return fmt.Errorf("dir %q must be relative to DATA_DIR", dir)
+	}
 	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
 		return errors.New("scan already running")
 	}
@@ -58,91 +108,164 @@ func (s *Scanner) Start(limit, workers int) error {
 		workers = s.defaultWorkers
 	}
 
-	atomic.StoreInt64(&s.processed, 0)
-	atomic.StoreInt64(&s.skipped, 0)
-	atomic.StoreInt64(&s.errors, 0)
-	atomic.StoreInt32(&s.stopFlag, 0)
-	s.lastError = ""
-	s.startTime = time.Now()
+	params := scanParams{Limit: limit, Workers: workers, Format: format, Dir: dir, Duplicates: duplicates}
+	job, err := s.jobs.Begin(db.JobTypeScan, "", params, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
 
-	go s.run(limit, workers)
+	s.launch(job, params, false)
 	return nil
 }
 
-func (s *Scanner) Stop() {
-	atomic.StoreInt32(&s.stopFlag, 1)
+// Resume продолжает прерванный скан: файлы, уже попавшие в базу, не пересчитываются
+func (s *Scanner) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("scan already running")
+	}
+
+	var params scanParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	s.launch(job, params, true)
+	return nil
 }
 
-func (s *Scanner) setLastError(err string) {
+func (s *Scanner) launch(job *Job, params scanParams, resumed bool) {
+	atomic.StoreInt32(&s.stopFlag, 0)
 	s.mu.Lock()
-	s.lastError = err
+	s.job = job
 	s.mu.Unlock()
+
+	go s.run(job, params, resumed)
 }
 
-func (s *Scanner) Status() ScanStatus {
-	p := atomic.LoadInt64(&s.processed)
-	sk := atomic.LoadInt64(&s.skipped)
-	e := atomic.LoadInt64(&s.errors)
-	t := atomic.LoadInt64(&s.total)
+func (s *Scanner) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
 
-	var pct, rate float64
-	elapsed := time.Since(s.startTime)
+// tryLock занимает сканер без запуска задачи (проход слежения за каталогом):
+// пока он занят, Start отвечает "scan already running"
+func (s *Scanner) tryLock() bool {
+	return atomic.CompareAndSwapInt32(&s.running, 0, 1)
+}
 
-	if t > 0 {
-		pct = float64(p+sk+e) / float64(t) * 100
-	}
-	if elapsed.Seconds() > 0 {
-		rate = float64(p) / elapsed.Seconds()
-	}
+func (s *Scanner) unlock() {
+	atomic.StoreInt32(&s.running, 0)
+}
 
+func (s *Scanner) currentJob() *Job {
 	s.mu.Lock()
-	lastErr := s.lastError
-	s.mu.Unlock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *Scanner) Status() ScanStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeScan, "")
+		if rec == nil {
+			return ScanStatus{}
+		}
+		return ScanStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Skipped:   rec.Skipped,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+		}
+	}
 
+	t, p, sk, e := job.Progress()
 	return ScanStatus{
+		JobID:     job.ID(),
 		Running:   atomic.LoadInt32(&s.running) == 1,
 		Total:     t,
 		Processed: p,
 		Skipped:   sk,
 		Errors:    e,
-		Percent:   pct,
-		Rate:      rate,
-		Elapsed:   elapsed.Round(time.Second).String(),
-		LastError: lastErr,
+		Percent:   job.Percent(),
+		Rate:      job.Rate(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
 	}
 }
 
-func (s *Scanner) run(limit, workers int) {
+func (s *Scanner) run(job *Job, params scanParams, resumed bool) {
 	defer atomic.StoreInt32(&s.running, 0)
 
-	log.Printf("Scanning %s with limit=%d workers=%d", s.dataDir, limit, workers)
+	importer, err := scanner.GetImporter(params.Format)
+	if err != nil {
+		job.Fail(err.Error())
+		return
+	}
+	root := filepath.Join(s.dataDir, params.Dir)
+	format := params.Format
+	if format == "" {
+		format = scanner.DefaultFormat
+	}
+	log.Printf("Scanning %s (%s) with limit=%d workers=%d (job %d)", root, format, params.Limit, params.Workers, job.ID())
 
 	// Загружаем все существующие пути из базы
 	log.Println("Loading existing file paths from database...")
 	existingPaths, err := s.db.GetAllFilePaths()
 	if err != nil {
-		s.setLastError("load paths: " + err.Error())
 		log.Printf("Load paths error: %v", err)
+		job.Fail("load paths: " + err.Error())
 		return
 	}
 	s.existingPaths = existingPaths
 	log.Printf("Found %d existing files in database", len(existingPaths))
 
-	tasks, err := scanner.ScanLibriSpeech(s.dataDir, limit)
+	mode := params.Duplicates
+	if mode == "" {
+		mode = DuplicatesOff
+	}
+	if err := s.prepareDuplicates(mode); err != nil {
+		log.Printf("Load fingerprints error: %v", err)
+		job.Fail("load fingerprints: " + err.Error())
+		return
+	}
+
+	tasks, err := importer.Scan(root, params.Limit)
 	if err != nil {
-		s.setLastError("scan dir: " + err.Error())
 		log.Printf("Scan error: %v", err)
+		job.Fail("scan dir: " + err.Error())
 		return
 	}
-	atomic.StoreInt64(&s.total, int64(len(tasks)))
+
+	if resumed {
+		// Всё, что уже в базе, учтено прошлым запуском
+		remaining := tasks[:0]
+		for _, task := range tasks {
+			if !existingPaths[taskKey(task)] {
+				remaining = append(remaining, task)
+			}
+		}
+		tasks = remaining
+	}
+	job.AddTotal(len(tasks))
 	log.Printf("Found %d tasks in directory", len(tasks))
 
 	taskChan := make(chan scanner.AudioTask, 100)
 	var wg sync.WaitGroup
 
-	for i := 0; i < workers; i++ {
+	for i := 0; i < params.Workers; i++ {
 		wg.Add(1)
-		go s.worker(&wg, taskChan)
+		go s.worker(&wg, job, taskChan)
 	}
 
 	for _, task := range tasks {
@@ -154,13 +277,13 @@ func (s *Scanner) run(limit, workers int) {
 	close(taskChan)
 
 	wg.Wait()
-	log.Printf("Scan complete: processed=%d skipped=%d errors=%d",
-		atomic.LoadInt64(&s.processed),
-		atomic.LoadInt64(&s.skipped),
-		atomic.LoadInt64(&s.errors))
+
+	_, p, sk, e := job.Progress()
+	log.Printf("Scan complete: processed=%d skipped=%d errors=%d", p, sk, e)
+	job.Finish(finishStatus(&s.stopFlag))
 }
 
-func (s *Scanner) worker(wg *sync.WaitGroup, tasks <-chan scanner.AudioTask) {
+func (s *Scanner) worker(wg *sync.WaitGroup, job *Job, tasks <-chan scanner.AudioTask) {
 	defer wg.Done()
 
 	for task := range tasks {
@@ -169,101 +292,220 @@ func (s *Scanner) worker(wg *sync.WaitGroup, tasks <-chan scanner.AudioTask) {
 		}
 
 		// Быстрая проверка по пути — без чтения файла
-		if s.existingPaths[task.WavPath] {
-			atomic.AddInt64(&s.skipped, 1)
+		if s.existingPaths[taskKey(task)] {
+			job.Skipped()
 			continue
 		}
 
-		// Hash
-		hash, err := audio.MD5File(task.WavPath)
-		if err != nil {
-			s.setLastError("hash: " + err.Error())
-			log.Printf("Hash error %s: %v", task.WavPath, err)
-			atomic.AddInt64(&s.errors, 1)
-			continue
-		}
+		s.importTask(job, task)
+	}
+}
 
-		// Check duplicate
-		exists, _ := s.db.ExistsByHash(hash)
-		if exists {
-			atomic.AddInt64(&s.skipped, 1)
-			continue
-		}
+// importTask импортирует одну задачу: hash, метаданные, статистика, дефекты.
+// Счётчики задачи job обновляются здесь; true — файл добавлен в базу
+func (s *Scanner) importTask(job *Job, task scanner.AudioTask) bool {
+	// Hash; у отрезка — hash записи и границы
+	hash, err := s.taskHash(task)
+	if err != nil {
+		log.Printf("Hash error %s: %v", task.WavPath, err)
+		job.Error("hash: " + err.Error())
+		return false
+	}
 
-		// Metadata via ffprobe
-		meta, err := audio.GetMetadata(task.WavPath)
-		if err != nil {
-			s.setLastError("metadata: " + err.Error())
-			log.Printf("Metadata error %s: %v", task.WavPath, err)
-			atomic.AddInt64(&s.errors, 1)
-			continue
-		}
+	// Check duplicate
+	exists, _ := s.db.ExistsByHash(hash)
+	if exists {
+		job.Skipped()
+		return false
+	}
 
-		// Stats via sox (SNR, RMS)
-		stats, err := audio.GetStats(task.WavPath)
-		if err != nil {
-			s.setLastError("stats: " + err.Error())
-			log.Printf("Stats error %s: %v", task.WavPath, err)
-			atomic.AddInt64(&s.errors, 1)
-			continue
-		}
+	// Metadata via ffprobe
+	meta, err := audio.GetMetadata(task.WavPath)
+	if err != nil {
+		log.Printf("Metadata error %s: %v", task.WavPath, err)
+		job.Error("metadata: " + err.Error())
+		return false
+	}
+
+	af := &db.AudioFile{
+		UserID:                task.UserID,
+		ChapterID:             task.ChapterID,
+		FilePath:              task.WavPath,
+		FileHash:              hash,
+		DurationSec:           meta.DurationSec,
+		SampleRate:            meta.SampleRate,
+		Channels:              meta.Channels,
+		BitDepth:              meta.BitDepth,
+		FileSize:              meta.FileSize,
+		AudioMetadata:         meta.ToJSON(),
+		TranscriptionOriginal: task.Transcription,
+		SourceKey:             taskKey(task),
+	}
+	if task.Duration > 0 {
+		// Виртуальный отрезок: своего файла нет, метаданные формата — от записи
+		start, end := task.Start, task.Start+task.Duration
+		af.SegmentStart, af.SegmentEnd = &start, &end
+		af.DurationSec = task.Duration
+		af.FileSize = 0
+	}
 
-		// Защита от NaN/Inf
-		snrDB := stats.SNREstimate
-		snrSox := stats.SNRSox
-		snrWada := stats.SNRWada
-		rmsDB := stats.RMSLevDB
+	path, release, err := LocalAudio(af)
+	if err != nil {
+		log.Printf("Segment error %s: %v", task.WavPath, err)
+		job.Error("segment: " + err.Error())
+		return false
+	}
 
-		if math.IsNaN(snrDB) || math.IsInf(snrDB, 0) || snrDB > 999 || snrDB < -999 {
-			snrDB = 0
-		}
-		if math.IsNaN(snrSox) || math.IsInf(snrSox, 0) || snrSox > 999 || snrSox < -999 {
-			snrSox = 0
+	// Акустический отпечаток; тишина и ошибка чтения не мешают импорту.
+	// У тишины сохраняется пустой отпечаток — метка, что считать нечего
+	fp, fpErr := audio.FingerprintFile(path)
+	savePrint := fpErr == nil || errors.Is(fpErr, audio.ErrNoFingerprint)
+	if !savePrint {
+		log.Printf("Fingerprint error %s: %v", task.WavPath, fpErr)
+	}
+
+	// Stats via sox (SNR, RMS)
+	stats, err := audio.GetStats(path)
+	if err != nil {
+		release()
+		log.Printf("Stats error %s: %v", task.WavPath, err)
+		job.Error("stats: " + err.Error())
+		return false
+	}
+
+	af.SNRDB, af.SNRSox, af.SNRWada = finiteDB(stats.SNREstimate), finiteDB(stats.SNRSox), finiteDB(stats.SNRWada)
+	af.NoiseLevel, af.RMSDB = stats.NoiseLevel, finiteDB(stats.RMSLevDB)
+	if l := stats.Loudness; l != nil {
+		af.LoudnessLUFS, af.LoudnessRange, af.TruePeakDB = &l.Integrated, &l.Range, &l.TruePeak
+	}
+
+	// Почти-дубликат уже импортированного файла
+	var slot *dupSlot
+	if s.dupMode != DuplicatesOff && fp != nil {
+		var matches []audio.FingerprintMatch
+		matches, slot = s.reserveDuplicate(fp)
+		defer close(slot.ready)
+
+		if best, ok := s.duplicateOf(matches); ok {
+			if s.dupMode == DuplicatesSkip {
+				release()
+				log.Printf("Duplicate of %d (%.3f), skipped: %s", best.ID, best.Similarity, taskKey(task))
+				job.Skipped()
+				return false
+			}
+			af.DuplicateOf, af.DuplicateScore = &best.ID, &best.Similarity
 		}
-		if math.IsNaN(snrWada) || math.IsInf(snrWada, 0) || snrWada > 999 || snrWada < -999 {
-			snrWada = 0
+	}
+
+	id, err := s.db.Insert(af)
+	if err == nil {
+		if slot != nil {
+			slot.id = id
 		}
-		if math.IsNaN(rmsDB) || math.IsInf(rmsDB, 0) || rmsDB > 999 || rmsDB < -999 {
-			rmsDB = 0
+		if savePrint {
+			if err := s.db.SaveFingerprint(id, fp); err != nil {
+				log.Printf("Fingerprint save error %s: %v", task.WavPath, err)
+			}
 		}
+	}
+	if err != nil {
+		log.Printf("Insert error %s: %v", task.WavPath, err)
+		job.Error("insert: " + err.Error())
+		release()
+		return false
+	}
 
-		af := &db.AudioFile{
-			UserID:                task.UserID,
-			ChapterID:             task.ChapterID,
-			FilePath:              task.WavPath,
-			FileHash:              hash,
-			DurationSec:           meta.DurationSec,
-			SNRDB:                 stats.SNREstimate,
-			SNRSox:                stats.SNRSox,
-			SNRWada:               stats.SNRWada,
-			NoiseLevel:            stats.NoiseLevel,
-			RMSDB:                 stats.RMSLevDB,
-			SampleRate:            meta.SampleRate,
-			Channels:              meta.Channels,
-			BitDepth:              meta.BitDepth,
-			FileSize:              meta.FileSize,
-			AudioMetadata:         meta.ToJSON(),
-			TranscriptionOriginal: task.Transcription,
-		}
+	// Дефекты записи; ошибка не мешает импорту — файл проверит analyze
+	if defects, err := audio.DetectDefects(path); err != nil {
+		log.Printf("Defects error %s: %v", task.WavPath, err)
+	} else if err := s.db.SaveDefects(id, defects); err != nil {
+		log.Printf("Defects save error %s: %v", task.WavPath, err)
+	}
+	release()
 
-		_, err = s.db.Insert(af)
-		if err != nil {
-			log.Printf("=============== \n Insert error: %v | SNR: sox=%.2f spectral=%.2f vad=%.2f wada=%.2f estimate=%.2f rms=%.2f | file=%s",
-				err,
-				stats.SNRSox,
-				stats.SNRSpectral,
-				stats.SNRVad,
-				stats.SNRWada,
-				stats.SNREstimate,
-				stats.RMSLevDB,
-				task.WavPath,
-			)
-			s.setLastError("insert: " + err.Error())
-			log.Printf("Insert error: %v", err)
-			atomic.AddInt64(&s.errors, 1)
-			continue
+	job.Processed()
+	return true
+}
+
+// prepareDuplicates задаёт режим поиска дубликатов и загружает отпечатки базы в индекс
+func (s *Scanner) prepareDuplicates(mode string) error {
+	s.dupMode = mode
+	s.dupIndex = nil
+	s.dupSlots = make(map[int64]*dupSlot)
+	if mode == DuplicatesOff {
+		return nil
+	}
+
+	prints, err := s.db.GetFingerprints()
+	if err != nil {
+		return err
+	}
+	index := audio.NewFingerprintIndex()
+	for _, p := range prints {
+		index.Add(p.ID, p.Fingerprint)
+	}
+	s.dupIndex = index
+	log.Printf("Duplicates: %s, %d fingerprints (threshold %.2f)", mode, index.Len(), s.dedup.Threshold)
+	return nil
+}
+
+// reserveDuplicate ищет похожие файлы и занимает место в индексе под новый файл
+func (s *Scanner) reserveDuplicate(fp audio.Fingerprint) ([]audio.FingerprintMatch, *dupSlot) {
+	s.dupMu.Lock()
+	defer s.dupMu.Unlock()
+
+	matches := s.dupIndex.Search(fp, s.dedup.Threshold)
+	slot := &dupSlot{ready: make(chan struct{})}
+	key := -int64(len(s.dupSlots) + 1)
+	s.dupSlots[key] = slot
+	s.dupIndex.Add(key, fp)
+	return matches, slot
+}
+
+// duplicateOf — самый похожий файл из matches с id в базе. Файл этого же скана
+// ждёт своего Insert: он зарезервирован раньше, поэтому ожидание не зацикливается
+func (s *Scanner) duplicateOf(matches []audio.FingerprintMatch) (audio.FingerprintMatch, bool) {
+	for _, m := range matches {
+		if m.ID < 0 {
+			s.dupMu.Lock()
+			slot := s.dupSlots[m.ID]
+			s.dupMu.Unlock()
+			<-slot.ready
+			if m.ID = slot.id; m.ID == 0 {
+				continue
+			}
 		}
+		return m, true
+	}
+	return audio.FingerprintMatch{}, false
+}
+
+// finiteDB — значение в дБ для базы: NaN/Inf и выход за ±999 (тишина, битый WAV) — 0
+func finiteDB(v float64) float64 {
+	if math.IsNaN(v) || math.IsInf(v, 0) || v > 999 || v < -999 {
+		return 0
+	}
+	return v
+}
 
-		atomic.AddInt64(&s.processed, 1)
+// taskKey — ключ задачи в existingPaths (см. db.GetAllFilePaths)
+func taskKey(task scanner.AudioTask) string {
+	if task.Duration <= 0 {
+		return task.WavPath
+	}
+	start, end := task.Start, task.Start+task.Duration
+	return db.SegmentKey(task.WavPath, &start, &end)
+}
+
+// taskHash — md5 файла; у виртуального отрезка — audio.SegmentHash от hash записи
+// (audio.RecordingHash: на одну запись приходится много отрезков)
+func (s *Scanner) taskHash(task scanner.AudioTask) (string, error) {
+	if task.Duration <= 0 {
+		return audio.MD5File(task.WavPath)
+	}
+	recHash, err := audio.RecordingHash(task.WavPath)
+	if err != nil {
+		return "", err
 	}
+	return audio.SegmentHash(recHash, task.Start, task.Start+task.Duration), nil
 }
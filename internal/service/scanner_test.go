@@ -0,0 +1,26 @@
+package service
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFiniteDB(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{12.5, 12.5},
+		{-40, -40},
+		{999, 999},
+		{1000, 0},
+		{-1e6, 0},
+		{math.NaN(), 0},
+		{math.Inf(1), 0},
+		{math.Inf(-1), 0},
+	}
+	for _, tt := range tests {
+		if got := finiteDB(tt.in); got != tt.want {
+			t.Errorf("finiteDB(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
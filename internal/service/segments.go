@@ -0,0 +1,61 @@
+package service
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+)
+
+// LocalAudio — путь к аудио файла для чтения: обычный файл как есть, виртуальный отрезок
+// вырезается из записи во временный WAV. release удаляет временный файл (для обычного — no-op)
+func LocalAudio(f *db.AudioFile) (string, func(), error) {
+	if !f.IsSegment() {
+		return f.FilePath, func() {}, nil
+	}
+
+	tmp, err := os.CreateTemp("", fmt.Sprintf("segment_%d_*.wav", f.ID))
+	if err != nil {
+		return "", nil, err
+	}
+	tmp.Close()
+	path := tmp.Name()
+	release := func() { os.Remove(path) }
+
+	if err := audio.CutAudio(f.FilePath, path, *f.SegmentStart, *f.SegmentEnd-*f.SegmentStart, 0, 0); err != nil {
+		release()
+		return "", nil, fmt.Errorf("segment %d: %w", f.ID, err)
+	}
+	return path, release, nil
+}
+
+// EditPath — от какого пути строятся имена правленых копий (_norm, _loud, _silence):
+// у обычного файла — FilePath, у виртуального отрезка — segments_wav/<запись>_<start>-<end>.wav
+// рядом с записью (границы в миллисекундах)
+func EditPath(f *db.AudioFile) string {
+	if !f.IsSegment() {
+		return f.FilePath
+	}
+	base := strings.TrimSuffix(filepath.Base(f.FilePath), filepath.Ext(f.FilePath))
+	name := fmt.Sprintf("%s_%d-%d.wav", base, int64(*f.SegmentStart*1000+0.5), int64(*f.SegmentEnd*1000+0.5))
+	return filepath.Join(filepath.Dir(f.FilePath), "segments_wav", name)
+}
+
+// Materialize вырезает виртуальный отрезок в EditPath (перед правкой, которая пишет файл);
+// обычный файл возвращается как есть. Запись в базе не меняется
+func Materialize(f *db.AudioFile) (string, error) {
+	if !f.IsSegment() {
+		return f.FilePath, nil
+	}
+	path := EditPath(f)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := audio.CutAudio(f.FilePath, path, *f.SegmentStart, *f.SegmentEnd-*f.SegmentStart, 0, 0); err != nil {
+		return "", fmt.Errorf("segment %d: %w", f.ID, err)
+	}
+	return path, nil
+}
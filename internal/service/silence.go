@@ -0,0 +1,244 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/audio"
+	"audio-labeler/internal/db"
+)
+
+// SilenceStatus — прогресс нормализации тишины; Skipped — файлы, уже попавшие в диапазон
+type SilenceStatus struct {
+	JobID     int64   `json:"job_id,omitempty"`
+	Running   bool    `json:"running"`
+	Total     int64   `json:"total"`
+	Processed int64   `json:"processed"`
+	Skipped   int64   `json:"skipped"`
+	Errors    int64   `json:"errors"`
+	Percent   float64 `json:"percent"`
+	Elapsed   string  `json:"elapsed"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// silenceParams — параметры запуска для jobs.params
+type silenceParams struct {
+	Limit  int              `json:"limit"`
+	MinMs  float64          `json:"min_ms"`
+	MaxMs  float64          `json:"max_ms"`
+	Filter db.SilenceFilter `json:"filter"`
+}
+
+// SilenceService — пакетная нормализация тишины в начале и в конце файла к [MinMs, MaxMs].
+// Результат пишется рядом с суффиксом _norm, оригинал не перезаписывается; в БД
+// обновляются путь, длительность и hash
+type SilenceService struct {
+	db       db.FileRepository
+	jobs     *JobManager
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewSilenceService(database db.FileRepository, jobs *JobManager) *SilenceService {
+	s := &SilenceService{db: database, jobs: jobs}
+	jobs.RegisterResumer(db.JobTypeSilence, "", s.Resume)
+	return s
+}
+
+// Start выбирает файлы по фильтру и запускает нормализацию в фоне. Возвращает число файлов в очереди
+func (s *SilenceService) Start(limit int, minMs, maxMs float64, filter db.SilenceFilter, startedBy string) (int, error) {
+	if minMs < 0 || maxMs < minMs {
+		return 0, fmt.Errorf("invalid silence range %.0f-%.0f ms", minMs, maxMs)
+	}
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return 0, errors.New("Silence normalization already running")
+	}
+
+	filter.MinMs, filter.MaxMs = minMs, maxMs
+	files, err := s.db.GetFilesForSilence(filter, limit, 0)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+	if len(files) == 0 {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, nil
+	}
+
+	params := silenceParams{Limit: limit, MinMs: minMs, MaxMs: maxMs, Filter: filter}
+	job, err := s.jobs.Begin(db.JobTypeSilence, "", params, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+
+	s.launch(job, files, params)
+	return len(files), nil
+}
+
+// Resume продолжает нормализацию с файла после курсора
+func (s *SilenceService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("Silence normalization already running")
+	}
+
+	var params silenceParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	var files []db.AudioFile
+	if limit := job.Remaining(params.Limit); limit > 0 {
+		files, err = s.db.GetFilesForSilence(params.Filter, limit, job.Cursor())
+		if err != nil {
+			atomic.StoreInt32(&s.running, 0)
+			job.Fail(err.Error())
+			return err
+		}
+	}
+
+	s.launch(job, files, params)
+	return nil
+}
+
+func (s *SilenceService) launch(job *Job, files []db.AudioFile, params silenceParams) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	job.AddTotal(len(files))
+	go s.run(job, files, params)
+}
+
+func (s *SilenceService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *SilenceService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *SilenceService) Status() SilenceStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeSilence, "")
+		if rec == nil {
+			return SilenceStatus{}
+		}
+		return SilenceStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Skipped:   rec.Skipped,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+		}
+	}
+
+	t, p, sk, e := job.Progress()
+	return SilenceStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Skipped:   sk,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+	}
+}
+
+func (s *SilenceService) run(job *Job, files []db.AudioFile, params silenceParams) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	for _, file := range files {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		changed, err := s.normalize(file, params.MinMs, params.MaxMs)
+		switch {
+		case err != nil:
+			log.Printf("Silence error for %d: %v", file.ID, err)
+			job.Error(fmt.Sprintf("file %d: %v", file.ID, err))
+		case changed:
+			job.Processed()
+		default:
+			job.Skipped()
+		}
+		job.SetCursor(file.ID)
+	}
+
+	log.Printf("Silence normalization complete: %d files (job %d)", len(files), job.ID())
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+// normalize обрабатывает один файл; false — тишина уже в диапазоне, файл не менялся.
+// Виртуальный отрезок при изменении становится файлом в segments_wav
+func (s *SilenceService) normalize(file db.AudioFile, minMs, maxMs float64) (bool, error) {
+	input, release, err := LocalAudio(&file)
+	if err != nil {
+		return false, err
+	}
+	defer release()
+
+	outputPath := NormalizedPath(EditPath(&file))
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+		return false, err
+	}
+	res, err := audio.NormalizeSilence(input, outputPath, minMs, maxMs)
+	if err != nil {
+		return false, err
+	}
+
+	if res.Changed {
+		hash, err := audio.MD5File(outputPath)
+		if err != nil {
+			return false, err
+		}
+		if err := s.db.UpdateFilePath(file.ID, outputPath, res.Duration, hash); err != nil {
+			return false, err
+		}
+		log.Printf("Silence normalized %d: lead %.0f→%.0fms, trail %.0f→%.0fms",
+			file.ID, res.LeadingBefore, res.LeadingAfter, res.TrailingBefore, res.TrailingAfter)
+	}
+
+	if err := s.db.UpdateSilenceEdges(file.ID, res.LeadingAfter, res.TrailingAfter); err != nil {
+		return false, err
+	}
+	return res.Changed, nil
+}
+
+// NormalizedPath — путь результата: суффиксы прошлых правок тишины (_sil, _trimmed, _norm)
+// заменяются на _norm, так что повторный запуск не плодит файлы
+func NormalizedPath(path string) string {
+	ext := filepath.Ext(path)
+	base := strings.TrimSuffix(path, ext)
+	for _, suffix := range []string{"_norm", "_trimmed", "_sil"} {
+		base = strings.TrimSuffix(base, suffix)
+	}
+	return base + "_norm" + ext
+}
@@ -0,0 +1,150 @@
+package service
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"audio-labeler/internal/asr"
+	"audio-labeler/internal/db"
+)
+
+// TranscriptionStore — EngineStore поверх таблицы transcriptions.
+// Engine — имя в таблице: GPU варианты пишут под тем же именем, что и CPU
+type TranscriptionStore struct {
+	DB           db.TranscriptionRepository
+	Engine       string
+	ModelVersion string
+
+	// RefEngine — если задан, по умолчанию берутся только файлы, где RefEngine
+	// уже отработал с WER > min_wer; forced=1 берёт все pending без фильтра
+	RefEngine string
+}
+
+func (s TranscriptionStore) Pending(limit int, params map[string]string) ([]db.AudioFile, error) {
+	forced := params["forced"] == "1" || params["forced"] == "true"
+	if s.RefEngine == "" || forced {
+		return s.DB.GetPendingTranscriptions(s.Engine, s.ModelVersion, limit)
+	}
+	minRefWER, _ := strconv.ParseFloat(params["min_wer"], 64)
+	return s.DB.GetPendingTranscriptionsByWER(s.Engine, s.ModelVersion, limit, s.RefEngine, minRefWER)
+}
+
+func (s TranscriptionStore) Save(file *db.AudioFile, result *asr.DecodeResult, wer, cer float64) error {
+	avgLogprob, noSpeech := segmentQuality(result.Segments)
+	err := s.DB.SaveTranscription(&db.Transcription{
+		AudioFileID:  file.ID,
+		Engine:       s.Engine,
+		ModelVersion: s.ModelVersion,
+		Text:         result.Text,
+		WER:          wer,
+		CER:          cer,
+		RTF:          result.RTF,
+		AvgLogprob:   avgLogprob,
+		NoSpeechProb: noSpeech,
+	})
+	if err != nil {
+		return err
+	}
+
+	hyps, err := latticeHypotheses(result)
+	if err != nil {
+		return err
+	}
+	if hyps != nil {
+		if err := s.DB.SaveTranscriptionHypotheses(file.ID, s.Engine, s.ModelVersion, hyps); err != nil {
+			return err
+		}
+	}
+
+	if result.Words != nil {
+		words := make([]db.TranscriptionWord, len(result.Words))
+		for i, w := range result.Words {
+			words[i] = db.TranscriptionWord{Seq: i, Word: w.Word, Start: w.Start, End: w.End, Confidence: w.Confidence}
+		}
+		if err := s.DB.SaveTranscriptionWords(file.ID, s.Engine, s.ModelVersion, words); err != nil {
+			return err
+		}
+	}
+
+	if result.Segments == nil {
+		return nil
+	}
+	segments := make([]db.TranscriptionSegment, len(result.Segments))
+	for i, seg := range result.Segments {
+		segments[i] = db.TranscriptionSegment{
+			Seq:          i,
+			Start:        seg.Start,
+			End:          seg.End,
+			Text:         strings.TrimSpace(seg.Text),
+			AvgLogprob:   seg.AvgLogprob,
+			NoSpeechProb: seg.NoSpeechProb,
+		}
+	}
+	return s.DB.SaveTranscriptionSegments(file.ID, s.Engine, s.ModelVersion, segments)
+}
+
+// segmentQuality — сводка по файлу: avg_logprob, взвешенный по длительности сегментов,
+// и максимальный no_speech_prob (хотя бы один "пустой" сегмент — повод проверить файл)
+func segmentQuality(segments []asr.Segment) (avgLogprob, noSpeech *float64) {
+	var sum, weight, maxNoSpeech float64
+	var hasLogprob, hasNoSpeech bool
+
+	for _, seg := range segments {
+		if seg.AvgLogprob != nil {
+			w := seg.End - seg.Start
+			if w <= 0 {
+				w = 0.01
+			}
+			sum += *seg.AvgLogprob * w
+			weight += w
+			hasLogprob = true
+		}
+		if seg.NoSpeechProb != nil && (!hasNoSpeech || *seg.NoSpeechProb > maxNoSpeech) {
+			maxNoSpeech = *seg.NoSpeechProb
+			hasNoSpeech = true
+		}
+	}
+
+	if hasLogprob {
+		v := sum / weight
+		avgLogprob = &v
+	}
+	if hasNoSpeech {
+		noSpeech = &maxNoSpeech
+	}
+	return avgLogprob, noSpeech
+}
+
+// latticeHypotheses — lattice, N-best и MBR результата Kaldi; nil — извлечение выключено
+func latticeHypotheses(result *asr.DecodeResult) (*db.TranscriptionHypotheses, error) {
+	if result.Lattice == "" && result.NBest == nil && result.MBR == nil {
+		return nil, nil
+	}
+
+	hyps := &db.TranscriptionHypotheses{LatticePath: result.Lattice}
+	if result.NBest != nil {
+		data, err := json.Marshal(result.NBest)
+		if err != nil {
+			return nil, err
+		}
+		hyps.NBest = data
+	}
+	if result.MBR != nil {
+		data, err := json.Marshal(result.MBR)
+		if err != nil {
+			return nil, err
+		}
+		hyps.MBR = data
+		hyps.Confidence = &result.MBR.Confidence
+	}
+	return hyps, nil
+}
+
+func (s TranscriptionStore) SaveError(file *db.AudioFile, errMsg, errorClass string) error {
+	return s.DB.SaveTranscriptionError(file.ID, s.Engine, s.ModelVersion, errMsg, errorClass)
+}
+
+func (s TranscriptionStore) RequeueErrors(maxAttempts int, unclassified bool) (int64, error) {
+	return s.DB.RequeueRetryableErrors(s.Engine, s.ModelVersion, maxAttempts, unclassified)
+}
@@ -0,0 +1,335 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/metrics"
+)
+
+// Сетка по умолчанию — как в Kaldi local/score.sh (LMWT 7..17 при acwt 0.1 ~ lm-scale 0.7..1.7)
+var (
+	DefaultSweepLMScales = []float64{0.5, 0.6, 0.7, 0.8, 0.9, 1.0, 1.1, 1.2, 1.3, 1.4, 1.5, 1.7}
+	DefaultSweepWIPs     = []float64{0, 0.5, 1.0}
+)
+
+// SweepParams — параметры перебора для jobs.params
+type SweepParams struct {
+	Engine   string    `json:"engine"`  // kaldi | kaldi-nolm: чьи lattice перебирать
+	Profile  string    `json:"profile"` // профиль декодирования (model_version), пусто — по умолчанию
+	LMScales []float64 `json:"lm_scales"`
+	WIPs     []float64 `json:"wips"`
+	Limit    int       `json:"limit"` // файлов в корпусе, 0 — все с lattice и эталоном
+}
+
+// SweepStatus — прогресс перебора и лучшая точка
+type SweepStatus struct {
+	JobID     int64           `json:"job_id,omitempty"`
+	Running   bool            `json:"running"`
+	Total     int64           `json:"total"`
+	Processed int64           `json:"processed"`
+	Errors    int64           `json:"errors"`
+	Percent   float64         `json:"percent"`
+	Elapsed   string          `json:"elapsed"`
+	LastError string          `json:"last_error,omitempty"`
+	Best      *db.SweepResult `json:"best,omitempty"`
+}
+
+// sweepPoint — одна точка сетки
+type sweepPoint struct {
+	lmScale float64
+	wip     float64
+}
+
+// SweepService — подбор веса LM и word insertion penalty по сохранённым lattice
+// (KALDI_LATTICE_DIR): без повторного декодирования каждая точка сетки — только
+// lattice-scale | lattice-add-penalty | lattice-best-path и WER по корпусу.
+// Точка сетки — элемент задачи; при resume посчитанные точки пропускаются
+type SweepService struct {
+	db       db.Store
+	jobs     *JobManager
+	cfg      *config.Config
+	running  int32
+	stopFlag int32
+	job      *Job
+	mu       sync.Mutex
+}
+
+func NewSweepService(database db.Store, jobs *JobManager, cfg *config.Config) *SweepService {
+	s := &SweepService{db: database, jobs: jobs, cfg: cfg}
+	jobs.RegisterResumer(db.JobTypeSweep, "", s.Resume)
+	return s
+}
+
+// Start проверяет параметры и корпус и запускает перебор в фоне. Возвращает число файлов
+func (s *SweepService) Start(params SweepParams, startedBy string) (int, error) {
+	params = sweepDefaults(params)
+	if err := s.check(params); err != nil {
+		return 0, err
+	}
+
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return 0, errors.New("Sweep already running")
+	}
+
+	utts, err := s.db.GetSweepUtterances(params.Engine, params.Profile, params.Limit)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+	if len(utts) == 0 {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, nil
+	}
+
+	job, err := s.jobs.Begin(db.JobTypeSweep, "", params, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return 0, err
+	}
+
+	s.launch(job, params, utts, sweepGrid(params, nil))
+	return len(utts), nil
+}
+
+// Resume досчитывает точки сетки, которых ещё нет в lm_sweep_results
+func (s *SweepService) Resume(rec *db.Job, startedBy string) error {
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("Sweep already running")
+	}
+
+	var params SweepParams
+	if err := json.Unmarshal([]byte(rec.Params), &params); err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return fmt.Errorf("job %d params: %w", rec.ID, err)
+	}
+
+	job, err := s.jobs.Continue(rec, startedBy)
+	if err != nil {
+		atomic.StoreInt32(&s.running, 0)
+		return err
+	}
+
+	fail := func(err error) error {
+		atomic.StoreInt32(&s.running, 0)
+		job.Fail(err.Error())
+		return err
+	}
+
+	done, err := s.db.GetSweepResults(rec.ID)
+	if err != nil {
+		return fail(err)
+	}
+	utts, err := s.db.GetSweepUtterances(params.Engine, params.Profile, params.Limit)
+	if err != nil {
+		return fail(err)
+	}
+
+	s.launch(job, params, utts, sweepGrid(params, done))
+	return nil
+}
+
+func (s *SweepService) launch(job *Job, params SweepParams, utts []db.SweepUtterance, grid []sweepPoint) {
+	atomic.StoreInt32(&s.stopFlag, 0)
+	s.mu.Lock()
+	s.job = job
+	s.mu.Unlock()
+
+	job.AddTotal(len(grid))
+	go s.run(job, params, utts, grid)
+}
+
+func (s *SweepService) Stop() {
+	atomic.StoreInt32(&s.stopFlag, 1)
+}
+
+func (s *SweepService) currentJob() *Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.job
+}
+
+func (s *SweepService) Status() SweepStatus {
+	job := s.currentJob()
+	if job == nil {
+		rec := s.jobs.Last(db.JobTypeSweep, "")
+		if rec == nil {
+			return SweepStatus{}
+		}
+		return SweepStatus{
+			JobID:     rec.ID,
+			Total:     rec.Total,
+			Processed: rec.Processed,
+			Errors:    rec.Errors,
+			Percent:   recordPercent(rec),
+			Elapsed:   recordElapsed(rec).Round(time.Second).String(),
+			LastError: rec.LastError,
+			Best:      s.best(rec.ID),
+		}
+	}
+
+	t, p, _, e := job.Progress()
+	return SweepStatus{
+		JobID:     job.ID(),
+		Running:   atomic.LoadInt32(&s.running) == 1,
+		Total:     t,
+		Processed: p,
+		Errors:    e,
+		Percent:   job.Percent(),
+		Elapsed:   job.Elapsed().Round(time.Second).String(),
+		LastError: job.LastError(),
+		Best:      s.best(job.ID()),
+	}
+}
+
+// Results — таблица перебора задачи; jobID = 0 — последней
+func (s *SweepService) Results(jobID int64) (int64, []db.SweepResult, error) {
+	if jobID == 0 {
+		if job := s.currentJob(); job != nil {
+			jobID = job.ID()
+		} else if rec := s.jobs.Last(db.JobTypeSweep, ""); rec != nil {
+			jobID = rec.ID
+		} else {
+			return 0, []db.SweepResult{}, nil
+		}
+	}
+	results, err := s.db.GetSweepResults(jobID)
+	return jobID, results, err
+}
+
+// best — точка с минимальным WER из уже посчитанных
+func (s *SweepService) best(jobID int64) *db.SweepResult {
+	results, err := s.db.GetSweepResults(jobID)
+	if err != nil || len(results) == 0 {
+		return nil
+	}
+	return &results[0]
+}
+
+func (s *SweepService) check(params SweepParams) error {
+	if params.Engine != db.EngineKaldi && params.Engine != db.EngineKaldiNoLM {
+		return fmt.Errorf("sweep: engine must be %s or %s", db.EngineKaldi, db.EngineKaldiNoLM)
+	}
+	if s.cfg.Kaldi.ModelDir == "" {
+		return errors.New("sweep: Kaldi is not configured")
+	}
+	if params.Profile != "" {
+		if _, ok := s.cfg.Kaldi.Profiles[params.Profile]; !ok {
+			return fmt.Errorf("unknown kaldi profile: %s", params.Profile)
+		}
+	}
+	return nil
+}
+
+func (s *SweepService) run(job *Job, params SweepParams, utts []db.SweepUtterance, grid []sweepPoint) {
+	defer atomic.StoreInt32(&s.running, 0)
+
+	profile := s.cfg.Kaldi.Decoding
+	if params.Profile != "" {
+		profile = s.cfg.Kaldi.Profiles[params.Profile]
+	}
+	decoder, err := newKaldiDecoder(s.cfg, profile, false)
+	if err != nil {
+		log.Printf("⚠ Sweep: %v", err)
+		job.Fail(err.Error())
+		return
+	}
+
+	lattices := make(map[string]string, len(utts))
+	refs := make(map[string]string, len(utts))
+	for _, u := range utts {
+		uttID := strconv.FormatInt(u.AudioFileID, 10)
+		lattices[uttID] = u.LatticePath
+		refs[uttID] = u.Reference
+	}
+	log.Printf("Sweep: %d files, %d grid points (engine=%s, profile=%q, job %d)",
+		len(utts), len(grid), params.Engine, params.Profile, job.ID())
+
+	for _, p := range grid {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+
+		texts, err := decoder.Rescore(lattices, p.lmScale, p.wip)
+		if err != nil {
+			log.Printf("⚠ Sweep lm_scale=%g wip=%g: %v", p.lmScale, p.wip, err)
+			job.Error(fmt.Sprintf("lm_scale=%g wip=%g: %v", p.lmScale, p.wip, err))
+			continue
+		}
+
+		r := &db.SweepResult{
+			JobID:        job.ID(),
+			Engine:       params.Engine,
+			ModelVersion: params.Profile,
+			LMScale:      p.lmScale,
+			WIP:          p.wip,
+			Files:        len(refs),
+		}
+		for uttID, ref := range refs {
+			errs, words := metrics.WordErrors(ref, texts[uttID])
+			r.WordErrors += errs
+			r.RefWords += words
+		}
+		if r.RefWords > 0 {
+			r.WER = float64(r.WordErrors) / float64(r.RefWords)
+		}
+
+		if err := s.db.SaveSweepResult(r); err != nil {
+			log.Printf("⚠ Sweep save lm_scale=%g wip=%g: %v", p.lmScale, p.wip, err)
+			job.Error(fmt.Sprintf("lm_scale=%g wip=%g: %v", p.lmScale, p.wip, err))
+			continue
+		}
+		log.Printf("%%WER %.2f [ %d / %d ] lm_scale=%g wip=%g", r.WER*100, r.WordErrors, r.RefWords, p.lmScale, p.wip)
+		job.Processed()
+	}
+
+	if best := s.best(job.ID()); best != nil {
+		if err := s.db.MarkBestSweepResult(job.ID(), best.ID); err != nil {
+			log.Printf("⚠ Sweep mark best: %v", err)
+		}
+		log.Printf("✓ Sweep best: %%WER %.2f lm_scale=%g wip=%g (job %d)",
+			best.WER*100, best.LMScale, best.WIP, job.ID())
+	}
+	job.Finish(finishStatus(&s.stopFlag))
+}
+
+func sweepDefaults(params SweepParams) SweepParams {
+	if params.Engine == "" {
+		params.Engine = db.EngineKaldi
+	}
+	if len(params.LMScales) == 0 {
+		params.LMScales = DefaultSweepLMScales
+	}
+	if len(params.WIPs) == 0 {
+		params.WIPs = DefaultSweepWIPs
+	}
+	return params
+}
+
+// sweepGrid — точки lm_scales × wips, кроме уже посчитанных
+func sweepGrid(params SweepParams, done []db.SweepResult) []sweepPoint {
+	seen := make(map[sweepPoint]bool, len(done))
+	for _, r := range done {
+		seen[sweepPoint{r.LMScale, r.WIP}] = true
+	}
+
+	var grid []sweepPoint
+	for _, lm := range params.LMScales {
+		for _, wip := range params.WIPs {
+			p := sweepPoint{lm, wip}
+			if !seen[p] {
+				seen[p] = true
+				grid = append(grid, p)
+			}
+		}
+	}
+	return grid
+}
@@ -0,0 +1,42 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"audio-labeler/internal/db"
+)
+
+func TestSweepDefaults(t *testing.T) {
+	p := sweepDefaults(SweepParams{})
+	if p.Engine != db.EngineKaldi || !reflect.DeepEqual(p.LMScales, DefaultSweepLMScales) || !reflect.DeepEqual(p.WIPs, DefaultSweepWIPs) {
+		t.Errorf("defaults: %+v", p)
+	}
+
+	given := SweepParams{Engine: db.EngineKaldiNoLM, LMScales: []float64{0.7}, WIPs: []float64{1}}
+	if p := sweepDefaults(given); !reflect.DeepEqual(p, given) {
+		t.Errorf("explicit params overridden: %+v", p)
+	}
+}
+
+func TestSweepGrid(t *testing.T) {
+	params := SweepParams{LMScales: []float64{0.5, 1}, WIPs: []float64{0, 0.5}}
+
+	tests := []struct {
+		name   string
+		params SweepParams
+		done   []db.SweepResult
+		want   []sweepPoint
+	}{
+		{"full grid", params, nil, []sweepPoint{{0.5, 0}, {0.5, 0.5}, {1, 0}, {1, 0.5}}},
+		{"resume skips done", params, []db.SweepResult{{LMScale: 0.5, WIP: 0}, {LMScale: 1, WIP: 0.5}},
+			[]sweepPoint{{0.5, 0.5}, {1, 0}}},
+		{"all done", params, []db.SweepResult{{LMScale: 0.5, WIP: 0}, {LMScale: 0.5, WIP: 0.5}, {LMScale: 1, WIP: 0}, {LMScale: 1, WIP: 0.5}}, nil},
+		{"duplicates in params", SweepParams{LMScales: []float64{1, 1}, WIPs: []float64{0}}, nil, []sweepPoint{{1, 0}}},
+	}
+	for _, tt := range tests {
+		if got := sweepGrid(tt.params, tt.done); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"audio-labeler/internal/config"
+	"audio-labeler/internal/db"
+	"audio-labeler/internal/metrics"
+	"audio-labeler/internal/scanner"
+)
+
+// WatchPass — итог прохода слежения за каталогом
+type WatchPass struct {
+	JobID       int64     `json:"job_id,omitempty"`
+	At          time.Time `json:"at"`
+	Changed     bool      `json:"changed"`     // индекс каталога изменился, файлы сверялись
+	Added       int       `json:"added"`       // новые файлы
+	Updated     int       `json:"updated"`     // эталон изменился на диске и обновлён
+	Kept        int       `json:"kept"`        // эталон изменился на диске, но его правил оператор
+	Deactivated int       `json:"deactivated"` // WAV удалён с диска
+	Errors      int       `json:"errors"`
+	Queued      []string  `json:"queued,omitempty"` // движки, запущенные для новых файлов
+}
+
+type WatchStatus struct {
+	Watching    bool       `json:"watching"`
+	Root        string     `json:"root"`
+	Format      string     `json:"format"`
+	IntervalSec int        `json:"interval_sec"`
+	AutoEngines []string   `json:"auto_engines,omitempty"`
+	Passes      int64      `json:"passes"`
+	LastPass    *WatchPass `json:"last_pass,omitempty"`
+	LastChange  *WatchPass `json:"last_change,omitempty"` // последний проход с изменениями
+	LastError   string     `json:"last_error,omitempty"`
+}
+
+// watchParams — параметры прохода, сохраняются в jobs.params
+type watchParams struct {
+	Format string `json:"format,omitempty"`
+	Dir    string `json:"dir,omitempty"`
+}
+
+// WatchService следит за DATA_DIR: каждые IntervalSec строит индекс mtime каталогов и
+// не-аудио файлов (trans.txt, манифесты). Появление и удаление WAV меняет mtime каталога,
+// правка trans.txt — mtime файла; если индекс изменился, корпус сверяется с базой:
+// новые файлы импортируются, изменённый эталон обновляется, файлы с удалённым WAV
+// деактивируются. Проход с изменениями пишется в журнал задач (type=watch)
+type WatchService struct {
+	db      db.Store
+	jobs    *JobManager
+	scanner *Scanner
+	engines *Registry
+	dataDir string
+	cfg     config.WatchConfig
+
+	running  int32 // цикл слежения запущен
+	stopFlag int32
+	stop     chan struct{}
+
+	passMu sync.Mutex // один проход за раз
+	index  map[string]stamp
+
+	mu     sync.Mutex
+	status WatchStatus
+}
+
+func NewWatchService(database db.Store, jobs *JobManager, scanner *Scanner, engines *Registry,
+	dataDir string, cfg config.WatchConfig) *WatchService {
+	if cfg.IntervalSec <= 0 {
+		cfg.IntervalSec = 300
+	}
+	return &WatchService{
+		db:      database,
+		jobs:    jobs,
+		scanner: scanner,
+		engines: engines,
+		dataDir: dataDir,
+		cfg:     cfg,
+	}
+}
+
+func (s *WatchService) root() string {
+	return filepath.Join(s.dataDir, s.cfg.Dir)
+}
+
+func (s *WatchService) format() string {
+	if s.cfg.Format == "" {
+		return scanner.DefaultFormat
+	}
+	return s.cfg.Format
+}
+
+// Start запускает слежение; intervalSec <= 0 — интервал из настроек
+func (s *WatchService) Start(intervalSec int, startedBy string) error {
+	if _, err := scanner.GetImporter(s.cfg.Format); err != nil {
+		return err
+	}
+	if s.cfg.Dir != "" && !filepath.IsLocal(s.cfg.Dir) {
+		return fmt.Errorf("WATCH_DIR %q must be relative to DATA_DIR", s.cfg.Dir)
+	}
+	if !atomic.CompareAndSwapInt32(&s.running, 0, 1) {
+		return errors.New("watch already running")
+	}
+	if intervalSec <= 0 {
+		intervalSec = s.cfg.IntervalSec
+	}
+
+	atomic.StoreInt32(&s.stopFlag, 0)
+	stop := make(chan struct{})
+	s.mu.Lock()
+	s.stop = stop
+	s.status.IntervalSec = intervalSec
+	s.mu.Unlock()
+
+	log.Printf("Watching %s (%s) every %ds", s.root(), s.format(), intervalSec)
+	go s.loop(time.Duration(intervalSec)*time.Second, stop, startedBy)
+	return nil
+}
+
+// Stop останавливает слежение; идущий проход прерывается между файлами
+func (s *WatchService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if atomic.LoadInt32(&s.running) == 1 && atomic.CompareAndSwapInt32(&s.stopFlag, 0, 1) {
+		close(s.stop)
+	}
+}
+
+func (s *WatchService) loop(interval time.Duration, stop <-chan struct{}, startedBy string) {
+	defer func() {
+		s.mu.Lock()
+		atomic.StoreInt32(&s.stopFlag, 0) // ручной проход без слежения не должен видеть старый Stop
+		atomic.StoreInt32(&s.running, 0)
+		s.mu.Unlock()
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.Pass(false, startedBy); err != nil {
+			log.Printf("⚠ Watch: %v", err)
+		}
+		select {
+		case <-stop:
+			log.Printf("Watch stopped")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *WatchService) Status() WatchStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.status
+	st.Watching = atomic.LoadInt32(&s.running) == 1
+	st.Root = s.root()
+	st.Format = s.format()
+	if st.IntervalSec == 0 {
+		st.IntervalSec = s.cfg.IntervalSec
+	}
+	st.AutoEngines = s.cfg.AutoEngines
+	return st
+}
+
+// Pass — один проход: сверка с базой, если индекс каталога изменился (force — всегда).
+// Пока идёт скан, проход откладывается с ошибкой
+func (s *WatchService) Pass(force bool, startedBy string) (*WatchPass, error) {
+	s.passMu.Lock()
+	defer s.passMu.Unlock()
+
+	pass := &WatchPass{At: time.Now()}
+	index, err := indexDir(s.root())
+	if err == nil && (force || s.index == nil || !maps.Equal(index, s.index)) {
+		if !s.scanner.tryLock() {
+			err = errors.New("scan is running, pass postponed")
+		} else {
+			pass.Changed = true
+			err = s.sync(pass, startedBy)
+			s.scanner.unlock()
+		}
+	}
+	if err == nil {
+		s.index = index
+	}
+
+	s.mu.Lock()
+	s.status.Passes++
+	s.status.LastPass = pass
+	if pass.Added+pass.Updated+pass.Kept+pass.Deactivated+pass.Errors > 0 {
+		s.status.LastChange = pass
+	}
+	s.status.LastError = ""
+	if err != nil {
+		s.status.LastError = err.Error()
+	}
+	s.mu.Unlock()
+	return pass, err
+}
+
+// sync сверяет корпус на диске с базой
+func (s *WatchService) sync(pass *WatchPass, startedBy string) error {
+	importer, err := scanner.GetImporter(s.cfg.Format)
+	if err != nil {
+		return err
+	}
+	root := s.root()
+	tasks, err := importer.Scan(root, 0)
+	if err != nil {
+		return fmt.Errorf("scan dir: %w", err)
+	}
+	files, err := s.db.GetFilesUnderDir(root)
+	if err != nil {
+		return fmt.Errorf("load files: %w", err)
+	}
+
+	known := make(map[string]*db.AudioFile, len(files))
+	for i := range files {
+		known[files[i].SourceKey] = &files[i]
+	}
+
+	var added []scanner.AudioTask
+	var changed []*db.AudioFile
+	onDisk := make(map[string]bool, len(tasks))
+	for _, task := range tasks {
+		key := taskKey(task)
+		onDisk[key] = true
+		f, ok := known[key]
+		switch {
+		case !ok:
+			added = append(added, task)
+		case f.Active && f.TranscriptionOriginal != task.Transcription:
+			f.TranscriptionOriginal = task.Transcription
+			changed = append(changed, f)
+		}
+	}
+
+	// WAV удалён: ключа нет среди задач и исходного файла нет на диске
+	var gone []int64
+	for _, f := range files {
+		if !f.Active || onDisk[f.SourceKey] {
+			continue
+		}
+		if _, err := os.Stat(db.KeyPath(f.SourceKey)); errors.Is(err, fs.ErrNotExist) {
+			gone = append(gone, f.ID)
+		}
+	}
+
+	if len(added)+len(changed)+len(gone) == 0 {
+		return nil
+	}
+
+	params := watchParams{Format: s.cfg.Format, Dir: s.cfg.Dir}
+	job, err := s.jobs.Begin(db.JobTypeWatch, "", params, startedBy)
+	if err != nil {
+		return err
+	}
+	pass.JobID = job.ID()
+	job.AddTotal(len(added) + len(changed) + len(gone))
+
+	if len(added) > 0 {
+		if err := s.scanner.prepareDuplicates(s.scanner.dedup.ScanMode); err != nil {
+			job.Fail("load fingerprints: " + err.Error())
+			return err
+		}
+	}
+	pass.Added = s.importTasks(job, added)
+
+	for _, f := range changed {
+		updated, err := s.db.UpdateDiskTranscription(f.ID, f.TranscriptionOriginal)
+		switch {
+		case err != nil:
+			job.Error(fmt.Sprintf("file %d: %v", f.ID, err))
+		case updated:
+			recalcWER(s.db, f.ID)
+			pass.Updated++
+			job.Processed()
+		default:
+			pass.Kept++
+			job.Skipped()
+		}
+	}
+
+	if err := s.db.DeactivateFiles(gone); err != nil {
+		for range gone {
+			job.Error("deactivate: " + err.Error())
+		}
+	} else {
+		pass.Deactivated = len(gone)
+		for range gone {
+			job.Processed()
+		}
+	}
+
+	_, _, _, e := job.Progress()
+	pass.Errors = int(e)
+	job.Finish(finishStatus(&s.stopFlag))
+	log.Printf("Watch pass (job %d): added=%d updated=%d kept=%d deactivated=%d errors=%d",
+		job.ID(), pass.Added, pass.Updated, pass.Kept, pass.Deactivated, pass.Errors)
+
+	if pass.Added > 0 {
+		pass.Queued = s.autoQueue(startedBy)
+	}
+	return nil
+}
+
+// importTasks импортирует новые файлы воркерами сканера; возвращает число добавленных
+func (s *WatchService) importTasks(job *Job, tasks []scanner.AudioTask) int {
+	workers := max(s.scanner.defaultWorkers, 1)
+	taskChan := make(chan scanner.AudioTask)
+	var wg sync.WaitGroup
+	var added int64
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for task := range taskChan {
+				if s.scanner.importTask(job, task) {
+					atomic.AddInt64(&added, 1)
+				}
+			}
+		}()
+	}
+	for _, task := range tasks {
+		if atomic.LoadInt32(&s.stopFlag) == 1 {
+			break
+		}
+		taskChan <- task
+	}
+	close(taskChan)
+	wg.Wait()
+	return int(added)
+}
+
+// recalcWER пересчитывает WER/CER движков по новому эталону
+func recalcWER(database db.TranscriptionRepository, id int64) {
+	list, err := database.GetTranscriptionsForRecalc(id)
+	if err != nil {
+		log.Printf("Recalc error ID=%d: %v", id, err)
+		return
+	}
+	for _, t := range list {
+		if t.Text == "" {
+			continue
+		}
+		wer := metrics.WER(t.TranscriptionOriginal, t.Text)
+		cer := metrics.CER(t.TranscriptionOriginal, t.Text)
+		if err := database.UpdateTranscriptionMetrics(t.ID, wer, cer); err != nil {
+			log.Printf("Recalc error ID=%d %s: %v", id, t.Engine, err)
+		}
+	}
+}
+
+// autoQueue запускает движки WATCH_AUTO_ENGINES: они берут все pending файлы, в том числе новые.
+// Уже работающий движок не трогается — новые файлы попадут в его следующий запуск
+func (s *WatchService) autoQueue(startedBy string) []string {
+	var queued []string
+	for _, name := range s.cfg.AutoEngines {
+		engine := s.engines.Get(name)
+		if engine == nil {
+			log.Printf("⚠ Watch: unknown engine %q in WATCH_AUTO_ENGINES", name)
+			continue
+		}
+		if err := engine.Start(StartOptions{StartedBy: startedBy}); err != nil {
+			log.Printf("⚠ Watch: %s not started: %v", name, err)
+			continue
+		}
+		queued = append(queued, name)
+	}
+	return queued
+}
+
+// audioExts — файлы, которые индекс не отслеживает: их появление и удаление видно по mtime каталога
+var audioExts = map[string]bool{".wav": true, ".flac": true, ".mp3": true, ".ogg": true, ".opus": true, ".m4a": true}
+
+// stamp — mtime и размер записи индекса
+type stamp struct {
+	mod  time.Time
+	size int64
+}
+
+// indexDir — mtime каталогов и не-аудио файлов (trans.txt, манифесты) в дереве root
+func indexDir(root string) (map[string]stamp, error) {
+	index := make(map[string]stamp)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && audioExts[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		index[path] = stamp{mod: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	return index, err
+}
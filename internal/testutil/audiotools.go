@@ -0,0 +1,359 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Заглушки ffprobe, sox и ffmpeg: ровно те вызовы и форматы вывода, что разбирает internal/audio
+func init() {
+	tools["ffprobe"] = fakeFFprobe
+	tools["sox"] = fakeSox
+	tools["ffmpeg"] = fakeFFmpeg
+}
+
+// fakeFFprobe: "-show_entries format=duration" — длительность, иначе JSON -show_format -show_streams
+func fakeFFprobe(args []string) int {
+	if len(args) == 0 {
+		logf("ffprobe: no input file")
+		return 1
+	}
+	path := args[len(args)-1]
+	a, err := ReadWAV(path)
+	if err != nil {
+		logf("%s: Invalid data found when processing input", path)
+		return 1
+	}
+
+	for _, arg := range args {
+		if arg == "-show_entries" {
+			fmt.Printf("%.6f\n", a.Duration())
+			return 0
+		}
+	}
+
+	probe := map[string]interface{}{
+		"streams": []map[string]interface{}{{
+			"codec_name":      "pcm_s16le",
+			"sample_rate":     strconv.Itoa(a.Rate),
+			"channels":        1,
+			"bits_per_sample": 16,
+		}},
+		"format": map[string]interface{}{
+			"format_name": "wav",
+			"duration":    fmt.Sprintf("%.6f", a.Duration()),
+		},
+	}
+	out, _ := json.MarshalIndent(probe, "", "    ")
+	fmt.Println(string(out))
+	return 0
+}
+
+var soxEffects = map[string]bool{
+	"stat": true, "stats": true, "pad": true, "trim": true, "reverse": true, "silence": true,
+}
+
+// fakeSox: "sox [-r R -c C -b B] in... out|-n [effect args...]"; несколько входов — склейка
+func fakeSox(args []string) int {
+	rate := 16000
+	var files, effects []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if soxEffects[arg] {
+			effects = args[i:]
+			break
+		}
+		switch arg {
+		case "-r", "-c", "-b":
+			if i+1 < len(args) {
+				if arg == "-r" {
+					rate, _ = strconv.Atoi(args[i+1])
+				}
+				i++
+			}
+		default:
+			files = append(files, arg)
+		}
+	}
+	if len(files) < 2 {
+		logf("sox FAIL sox: Not enough input filenames specified")
+		return 1
+	}
+
+	output := files[len(files)-1]
+	a := &Audio{Rate: rate}
+	for _, in := range files[:len(files)-1] {
+		if in == "-n" {
+			// нулевой вход: длину задаёт trim
+			if len(effects) >= 3 && effects[0] == "trim" {
+				sec, _ := strconv.ParseFloat(effects[2], 64)
+				a.Samples = append(a.Samples, make([]float64, int(math.Round(sec*float64(rate))))...)
+				effects = effects[3:]
+			}
+			continue
+		}
+		b, err := ReadWAV(in)
+		if err != nil {
+			logf("sox FAIL formats: can't open input file `%s': %v", in, err)
+			return 2
+		}
+		if len(a.Samples) == 0 {
+			a.Rate = b.Rate
+		}
+		a.Append(b)
+	}
+
+	for len(effects) > 0 {
+		name := effects[0]
+		effects = effects[1:]
+		arg := func(i int) float64 {
+			if i >= len(effects) {
+				return 0
+			}
+			v, _ := strconv.ParseFloat(strings.TrimSuffix(effects[i], "%"), 64)
+			return v
+		}
+
+		switch name {
+		case "reverse":
+			a = reverseAudio(a)
+		case "silence":
+			// silence 1 <dur> <thr>%: срезать тишину в начале
+			thr := arg(2) / 100
+			effects = effects[min(3, len(effects)):]
+			start := 0
+			for start < len(a.Samples) && math.Abs(a.Samples[start]) <= thr {
+				start++
+			}
+			a = a.Cut(float64(start)/float64(a.Rate), 0)
+		case "trim":
+			start, dur := arg(0), 0.0
+			n := 1
+			if len(effects) > 1 && !soxEffects[effects[1]] {
+				dur = arg(1)
+				n = 2
+			}
+			effects = effects[min(n, len(effects)):]
+			a = a.Cut(start, dur)
+		case "pad":
+			before, after := arg(0), arg(1)
+			effects = effects[min(2, len(effects)):]
+			a = fromBuffer(a.buffer().Pad(before, after))
+		case "stat":
+			printSoxStat(a)
+		case "stats":
+			printSoxStats(a)
+		}
+	}
+
+	if output == "-n" {
+		return 0
+	}
+	if err := WriteWAV(output, a); err != nil {
+		logf("sox FAIL formats: can't open output file `%s': %v", output, err)
+		return 2
+	}
+	return 0
+}
+
+func reverseAudio(a *Audio) *Audio {
+	out := &Audio{Rate: a.Rate, Samples: make([]float64, len(a.Samples))}
+	for i, s := range a.Samples {
+		out.Samples[len(a.Samples)-1-i] = s
+	}
+	return out
+}
+
+// levels — пик, RMS, DC и RMS по окнам 50ms (max/min), как в sox stats
+type levels struct {
+	min, max, peak, rms, dc float64
+	rmsPk, rmsTr            float64
+	n                       int
+}
+
+func measure(a *Audio) levels {
+	x := a.Samples
+	l := levels{n: len(x)}
+	if l.n == 0 {
+		return l
+	}
+
+	var sum, sumSq float64
+	for _, v := range x {
+		sum += v
+		sumSq += v * v
+		l.min = math.Min(l.min, v)
+		l.max = math.Max(l.max, v)
+	}
+	l.dc = sum / float64(l.n)
+	l.rms = math.Sqrt(sumSq / float64(l.n))
+	l.peak = math.Max(-l.min, l.max)
+
+	win := a.Rate / 20
+	if win < 1 {
+		win = 1
+	}
+	l.rmsTr = math.Inf(1)
+	for i := 0; i+win <= l.n; i += win {
+		var s float64
+		for _, v := range x[i : i+win] {
+			s += v * v
+		}
+		r := math.Sqrt(s / float64(win))
+		l.rmsPk = math.Max(l.rmsPk, r)
+		l.rmsTr = math.Min(l.rmsTr, r)
+	}
+	if math.IsInf(l.rmsTr, 1) {
+		l.rmsPk, l.rmsTr = l.rms, l.rms
+	}
+	return l
+}
+
+func dB(v float64) float64 {
+	return 20 * math.Log10(v)
+}
+
+func printSoxStat(a *Audio) {
+	l := measure(a)
+	logf("Samples read: %13d", l.n)
+	logf("Length (seconds): %10.6f", a.Duration())
+	logf("Maximum amplitude: %9.6f", l.max)
+	logf("Minimum amplitude: %9.6f", l.min)
+	logf("Midline amplitude: %9.6f", (l.max+l.min)/2)
+	logf("RMS     amplitude: %9.6f", l.rms)
+}
+
+func printSoxStats(a *Audio) {
+	l := measure(a)
+	crest := 0.0
+	if l.rms > 0 {
+		crest = l.peak / l.rms
+	}
+	samples := strconv.Itoa(l.n)
+	if l.n >= 1000 {
+		samples = fmt.Sprintf("%.1fk", float64(l.n)/1000)
+	}
+
+	logf("DC offset  %9.6f", l.dc)
+	logf("Min level  %9.6f", l.min)
+	logf("Max level  %9.6f", l.max)
+	logf("Pk lev dB  %9.2f", dB(l.peak))
+	logf("RMS lev dB %9.2f", dB(l.rms))
+	logf("RMS Pk dB  %9.2f", dB(l.rmsPk))
+	logf("RMS Tr dB  %9.2f", dB(l.rmsTr))
+	logf("Crest factor %7.2f", crest)
+	logf("Flat factor %8.2f", 0.0)
+	logf("Pk count %11d", 2)
+	logf("Bit-depth %10s", "16/16")
+	logf("Num samples %8s", samples)
+	logf("Length s %11.3f", a.Duration())
+	logf("Scale max  %9.6f", 1.0)
+	logf("Window s   %9.3f", 0.05)
+}
+
+// fakeFFmpeg: "-ss S -i in [-ss S] [-t D] [-af silencedetect=...|astats] [-ar R] out|-f null -"
+func fakeFFmpeg(args []string) int {
+	var input, filter, output string
+	var ss, t float64
+	rate := 0
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		next := ""
+		if i+1 < len(args) {
+			next = args[i+1]
+		}
+		switch arg {
+		case "-y", "-n":
+		case "-i":
+			input = next
+			i++
+		case "-ss":
+			ss, _ = strconv.ParseFloat(next, 64)
+			i++
+		case "-t":
+			t, _ = strconv.ParseFloat(next, 64)
+			i++
+		case "-af":
+			filter = next
+			i++
+		case "-ar":
+			rate, _ = strconv.Atoi(next)
+			i++
+		case "-c:a", "-ac", "-f":
+			i++
+		default:
+			output = arg
+		}
+	}
+
+	logf("ffmpeg version n0.0-fake Copyright (c) 2000-2024 the FFmpeg developers")
+	a, err := ReadWAV(input)
+	if err != nil {
+		logf("%s: Invalid data found when processing input", input)
+		return 1
+	}
+	a = a.Cut(ss, t).Resample(rate)
+
+	switch {
+	case strings.HasPrefix(filter, "silencedetect"):
+		silenceDetect(a, filter)
+	case strings.HasPrefix(filter, "astats"):
+		l := measure(a)
+		for _, section := range []string{"Channel: 1", "Overall"} {
+			logf("[Parsed_astats_0 @ 0x5580] %s", section)
+			logf("[Parsed_astats_0 @ 0x5580] DC offset: %f", l.dc)
+			logf("[Parsed_astats_0 @ 0x5580] Peak level dB: %f", dB(l.peak))
+			logf("[Parsed_astats_0 @ 0x5580] RMS level dB: %f", dB(l.rms))
+		}
+	}
+
+	if output == "" || output == "-" {
+		return 0
+	}
+	if err := WriteWAV(output, a); err != nil {
+		logf("%s: %v", output, err)
+		return 1
+	}
+	return 0
+}
+
+// silenceDetect — участки ниже noise (dB) не короче d секунд; тишина до конца файла
+// закрывается на EOF, как в новых версиях ffmpeg
+func silenceDetect(a *Audio, filter string) {
+	noise, minDur := -60.0, 2.0
+	for _, opt := range strings.Split(strings.TrimPrefix(filter, "silencedetect="), ":") {
+		key, val, _ := strings.Cut(opt, "=")
+		switch key {
+		case "noise", "n":
+			noise, _ = strconv.ParseFloat(strings.TrimSuffix(val, "dB"), 64)
+		case "d", "duration":
+			minDur, _ = strconv.ParseFloat(val, 64)
+		}
+	}
+	thr := math.Pow(10, noise/20)
+
+	x := a.Samples
+	rate := float64(a.Rate)
+	start := -1
+	flush := func(end int) {
+		if start >= 0 && float64(end-start)/rate >= minDur {
+			logf("[silencedetect @ 0x5581] silence_start: %g", float64(start)/rate)
+			logf("[silencedetect @ 0x5581] silence_end: %g | silence_duration: %g",
+				float64(end)/rate, float64(end-start)/rate)
+		}
+		start = -1
+	}
+	for i, v := range x {
+		if math.Abs(v) < thr {
+			if start < 0 {
+				start = i
+			}
+		} else {
+			flush(i)
+		}
+	}
+	flush(len(x))
+}
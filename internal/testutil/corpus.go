@@ -0,0 +1,64 @@
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Utterance — файл корпуса: эталон в trans.txt и то, что на самом деле "произнесено"
+type Utterance struct {
+	Speaker string
+	Chapter string
+	Text    string // эталон (trans.txt)
+	Spoken  string // что звучит в аудио; пусто — как Text
+}
+
+// ID — имя файла LibriSpeech: <speaker>-<chapter>-<NNNN>
+func (u Utterance) ID(index int) string {
+	return fmt.Sprintf("%s-%s-%04d", u.Speaker, u.Chapter, index)
+}
+
+// Corpus пишет в dir структуру LibriSpeech: <speaker>/<chapter>/<speaker>-<chapter>.trans.txt
+// и <id>.wav (моно, rate Hz). Возвращает пути wav в порядке utts
+func Corpus(t testing.TB, dir string, rate int, utts ...Utterance) []string {
+	t.Helper()
+
+	type chapterKey struct{ speaker, chapter string }
+	trans := make(map[chapterKey][]string)
+	counts := make(map[chapterKey]int)
+	paths := make([]string, len(utts))
+
+	for i, u := range utts {
+		key := chapterKey{u.Speaker, u.Chapter}
+		id := u.ID(counts[key])
+		counts[key]++
+
+		chapterDir := filepath.Join(dir, u.Speaker, u.Chapter)
+		if err := os.MkdirAll(chapterDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		spoken := u.Spoken
+		if spoken == "" {
+			spoken = u.Text
+		}
+		paths[i] = filepath.Join(chapterDir, id+".wav")
+		if err := WriteWAV(paths[i], Speech(spoken, rate)); err != nil {
+			t.Fatal(err)
+		}
+		trans[key] = append(trans[key], id+" "+u.Text)
+	}
+
+	for key, lines := range trans {
+		sort.Strings(lines)
+		path := filepath.Join(dir, key.speaker, key.chapter, key.speaker+"-"+key.chapter+".trans.txt")
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,710 @@
+package testutil
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Kaldi — поддельная установка Kaldi: KALDI_ROOT с заглушками бинарников и модель
+// с графом. Декодер распознаёт синтезированную речь (см. Speech): слова не из
+// словаря становятся <UNK>. Lattice — text archive с линейным путём: lattice-scale,
+// lattice-add-penalty, lattice-copy, lattice-best-path и int2sym.pl работают с ним
+// как настоящие (scp со смещениями, ark:-, "ark,t:-")
+type Kaldi struct {
+	Root     string // KALDI_ROOT
+	ModelDir string // KALDI_MODEL_DIR: model/final.mdl, graph/{HCLG.fst,words.txt}, conf/online.conf
+}
+
+// KaldiOptions — поведение заглушек
+type KaldiOptions struct {
+	Vocabulary []string      // слова графа (words.txt); остальные распознаются как <UNK>
+	LoadDelay  time.Duration // "загрузка модели" декодером до чтения wav
+	CrashOn    string        // декодер падает (один раз) на utterance с этим словом
+}
+
+const kaldiConfigName = "fake.json"
+
+// kaldiBinaries — путь заглушки от KALDI_ROOT -> имя в tools
+var kaldiBinaries = map[string]string{
+	"src/online2bin/online2-wav-nnet3-latgen-faster": "online2-wav-nnet3-latgen-faster",
+	"src/latbin/lattice-scale":                       "lattice-scale",
+	"src/latbin/lattice-add-penalty":                 "lattice-add-penalty",
+	"src/latbin/lattice-copy":                        "lattice-copy",
+	"src/latbin/lattice-best-path":                   "lattice-best-path",
+	"egs/wsj/s5/utils/int2sym.pl":                    "int2sym.pl",
+}
+
+func init() {
+	tools["online2-wav-nnet3-latgen-faster"] = fakeOnline2Decoder
+	tools["lattice-scale"] = fakeLatticeScale
+	tools["lattice-add-penalty"] = fakeLatticeAddPenalty
+	tools["lattice-copy"] = fakeLatticeCopy
+	tools["lattice-best-path"] = fakeLatticeBestPath
+	tools["int2sym.pl"] = fakeInt2Sym
+}
+
+// NewKaldi ставит заглушки во временный каталог теста
+func NewKaldi(t testing.TB, opts KaldiOptions) *Kaldi {
+	t.Helper()
+	dir := t.TempDir()
+	k := &Kaldi{Root: filepath.Join(dir, "kaldi"), ModelDir: filepath.Join(dir, "model")}
+
+	for path, name := range kaldiBinaries {
+		installTool(t, filepath.Join(k.Root, path), name, k.Root)
+	}
+
+	cfg, err := json.Marshal(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	words := "<eps> 0\n<UNK> 1\n"
+	for i, w := range opts.Vocabulary {
+		words += fmt.Sprintf("%s %d\n", w, i+2)
+	}
+	files := map[string]string{
+		filepath.Join(k.Root, kaldiConfigName):        string(cfg),
+		filepath.Join(k.ModelDir, "model/final.mdl"):  "fake nnet3 model\n",
+		filepath.Join(k.ModelDir, "graph/HCLG.fst"):   "fake graph\n",
+		filepath.Join(k.ModelDir, "graph/words.txt"):  words,
+		filepath.Join(k.ModelDir, "conf/online.conf"): "--feature-type=mfcc\n",
+	}
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return k
+}
+
+// Env — переменные окружения конфига (config.Load) для этой установки
+func (k *Kaldi) Env() map[string]string {
+	return map[string]string{
+		"KALDI_ROOT":      k.Root,
+		"KALDI_MODEL_DIR": k.ModelDir,
+	}
+}
+
+func loadKaldiOptions() KaldiOptions {
+	var opts KaldiOptions
+	data, err := os.ReadFile(filepath.Join(os.Getenv(envDir), kaldiConfigName))
+	if err == nil {
+		json.Unmarshal(data, &opts)
+	}
+	return opts
+}
+
+// kaldiArgs делит аргументы на --опции и позиционные
+func kaldiArgs(args []string) (map[string]string, []string) {
+	opts := make(map[string]string)
+	var pos []string
+	for _, arg := range args {
+		if strings.HasPrefix(arg, "--") {
+			key, val, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+			opts[key] = val
+			continue
+		}
+		pos = append(pos, arg)
+	}
+	return opts, pos
+}
+
+func kaldiLog(level, tool, format string, args ...interface{}) {
+	logf("%s (%s[5.5.1068~1-fake]:main():%s.cc:1) %s", level, tool, tool, fmt.Sprintf(format, args...))
+}
+
+// ============================================================
+// Декодер
+// ============================================================
+
+// fakeOnline2Decoder: model fst spk2utt-rspecifier wav-rspecifier lattice-wspecifier.
+// Текст — в stderr строкой "uttID слова", lattice — text archive с flush после utterance
+func fakeOnline2Decoder(args []string) int {
+	const tool = "online2-wav-nnet3-latgen-faster"
+	opts, pos := kaldiArgs(args)
+	if len(pos) != 5 {
+		logf("Usage: %s [options] <nnet3-in> <fst-in> <spk2utt-rspecifier> <wav-rspecifier> <lattice-wspecifier>", tool)
+		return 1
+	}
+	fake := loadKaldiOptions()
+
+	for _, f := range []string{opts["config"], pos[0], pos[1]} {
+		if _, err := os.Stat(f); err != nil {
+			kaldiLog("ERROR", tool, "Failed to open file %s", f)
+			return 1
+		}
+	}
+	symbols, err := readSymbols(opts["word-symbol-table"])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Could not read symbol table from file %s", opts["word-symbol-table"])
+		return 1
+	}
+	time.Sleep(fake.LoadDelay)
+
+	spk2utt, err := openRspecifier(pos[2])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error opening table %s: %v", pos[2], err)
+		return 1
+	}
+	wavs, err := newWavTable(pos[3])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error opening table %s: %v", pos[3], err)
+		return 1
+	}
+	lat, err := openWspecifier(pos[4])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error opening table %s: %v", pos[4], err)
+		return 1
+	}
+	defer lat.Close()
+
+	decoded, failed := 0, 0
+	lines := bufio.NewScanner(spk2utt)
+	for lines.Scan() {
+		fields := strings.Fields(lines.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		for _, utt := range fields[1:] {
+			a, err := wavs.get(utt)
+			if err != nil {
+				kaldiLog("WARNING", tool, "Did not find audio for utterance %s", utt)
+				failed++
+				continue
+			}
+
+			words := recognize(a, symbols)
+			if fake.CrashOn != "" && containsWord(words, fake.CrashOn) && crashOnce() {
+				kaldiLog("ERROR", tool, "fake decoder crash on utterance %s", utt)
+				return 134
+			}
+
+			writeLatticeEntry(lat, utt, linearLattice(words, symbols, a.Duration()))
+			if err := lat.Flush(); err != nil {
+				kaldiLog("ERROR", tool, "write lattice: %v", err)
+				return 1
+			}
+			logf("%s %s", utt, strings.Join(words, " "))
+			decoded++
+		}
+	}
+
+	kaldiLog("LOG", tool, "Decoded %d utterances, %d with errors.", decoded, failed)
+	return 0
+}
+
+// recognize — слова аудио; не из словаря — <UNK>
+func recognize(a *Audio, symbols map[string]int) []string {
+	var words []string
+	for _, w := range a.Words() {
+		if _, ok := symbols[w.Word]; ok {
+			words = append(words, w.Word)
+		} else {
+			words = append(words, "<UNK>")
+		}
+	}
+	return words
+}
+
+func containsWord(words []string, word string) bool {
+	for _, w := range words {
+		if w == word {
+			return true
+		}
+	}
+	return false
+}
+
+// crashOnce — true только при первом вызове в этой установке
+func crashOnce() bool {
+	f, err := os.OpenFile(filepath.Join(os.Getenv(envDir), "crashed"), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return false
+	}
+	f.Close()
+	return true
+}
+
+// linearLattice — дуги "src dst word graph,acoustic,transition-ids" и финальное состояние
+func linearLattice(words []string, symbols map[string]int, duration float64) []string {
+	frames := int(duration * 100 / 3)
+	per := 1
+	if len(words) > 0 && frames/len(words) > 1 {
+		per = frames / len(words)
+	}
+
+	lines := make([]string, 0, len(words)+1)
+	for i, w := range words {
+		id, ok := symbols[w]
+		if !ok {
+			id = 1
+		}
+		tids := strings.TrimSuffix(strings.Repeat("1_", per), "_")
+		lines = append(lines, fmt.Sprintf("%d %d %d %g,%g,%s", i, i+1, id, 2.5, 10.0*float64(per), tids))
+	}
+	lines = append(lines, fmt.Sprintf("%d 0,0,", len(words)))
+	return lines
+}
+
+// wavTable — wav-rspecifier: scp (utt -> путь) или sorted archive "uttID RIFF..." (server mode)
+type wavTable struct {
+	scp     map[string]string
+	ark     *bufio.Reader
+	pending string // прочитанный вперёд ключ archive
+	audio   *Audio
+}
+
+func newWavTable(spec string) (*wavTable, error) {
+	kind, _, _ := strings.Cut(spec, ":")
+	r, err := openRspecifier(spec)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(kind, "ark") {
+		return &wavTable{ark: bufio.NewReader(r)}, nil
+	}
+
+	t := &wavTable{scp: make(map[string]string)}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 {
+			t.scp[fields[0]] = fields[1]
+		}
+	}
+	return t, nil
+}
+
+func (t *wavTable) get(utt string) (*Audio, error) {
+	if t.scp != nil {
+		path, ok := t.scp[utt]
+		if !ok {
+			return nil, errors.New("not in scp")
+		}
+		return ReadWAV(path)
+	}
+
+	// sorted archive: читаем до нужного ключа, больший ключ оставляем на следующий раз
+	for {
+		if t.pending == "" {
+			key, a, err := readWavEntry(t.ark)
+			if err != nil {
+				return nil, err
+			}
+			t.pending, t.audio = key, a
+		}
+		switch {
+		case t.pending == utt:
+			t.pending = ""
+			return t.audio, nil
+		case t.pending > utt:
+			return nil, errors.New("not in archive")
+		}
+		t.pending = ""
+	}
+}
+
+// readWavEntry — "key " и WAV целиком по размеру RIFF
+func readWavEntry(r *bufio.Reader) (string, *Audio, error) {
+	key, err := r.ReadString(' ')
+	if err != nil {
+		return "", nil, err
+	}
+	key = strings.TrimSpace(key)
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return "", nil, err
+	}
+	size := int(uint32(header[4]) | uint32(header[5])<<8 | uint32(header[6])<<16 | uint32(header[7])<<24)
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return "", nil, err
+	}
+	a, err := DecodeWAV(append(header, body...))
+	return key, a, err
+}
+
+// ============================================================
+// Таблицы Kaldi: rspecifier / wspecifier
+// ============================================================
+
+// openRspecifier: "ark:file", "ark:-", "ark:команда |", "scp:..."; опции (s,cs,t) игнорируются
+func openRspecifier(spec string) (io.Reader, error) {
+	_, target, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("bad rspecifier %q", spec)
+	}
+	target = strings.TrimSpace(target)
+
+	switch {
+	case target == "-":
+		return os.Stdin, nil
+	case strings.HasSuffix(target, "|"):
+		out, err := exec.Command("sh", "-c", strings.TrimSuffix(target, "|")).Output()
+		if err != nil {
+			return nil, err
+		}
+		return strings.NewReader(string(out)), nil
+	default:
+		return os.Open(target)
+	}
+}
+
+// tableWriter — text archive в файл или stdout; ark,scp — ещё и scp со смещениями
+type tableWriter struct {
+	*bufio.Writer
+	file   *os.File
+	scp    *os.File
+	arkDir string
+	arkPos int64
+	name   string
+}
+
+func openWspecifier(spec string) (*tableWriter, error) {
+	kind, target, ok := strings.Cut(spec, ":")
+	if !ok {
+		return nil, fmt.Errorf("bad wspecifier %q", spec)
+	}
+
+	w := &tableWriter{}
+	if target == "-" {
+		w.Writer = bufio.NewWriter(os.Stdout)
+		return w, nil
+	}
+
+	arkPath := target
+	if strings.Contains(kind, "scp") {
+		var scpPath string
+		arkPath, scpPath, _ = strings.Cut(target, ",")
+		scp, err := os.Create(scpPath)
+		if err != nil {
+			return nil, err
+		}
+		w.scp = scp
+	}
+	f, err := os.Create(arkPath)
+	if err != nil {
+		return nil, err
+	}
+	w.file, w.name = f, arkPath
+	w.Writer = bufio.NewWriter(f)
+	return w, nil
+}
+
+func (w *tableWriter) Close() error {
+	err := w.Flush()
+	if w.file != nil {
+		w.file.Close()
+	}
+	if w.scp != nil {
+		w.scp.Close()
+	}
+	return err
+}
+
+// writeLatticeEntry — "key \n<строки>\n\n"; для ark,scp — "key ark:смещение" в scp
+func writeLatticeEntry(w *tableWriter, key string, lines []string) {
+	entry := key + " \n" + strings.Join(lines, "\n") + "\n\n"
+	if w.scp != nil {
+		fmt.Fprintf(w.scp, "%s %s:%d\n", key, w.name, w.arkPos+int64(len(key)+1))
+	}
+	w.WriteString(entry)
+	w.arkPos += int64(len(entry))
+}
+
+type latticeEntry struct {
+	key   string
+	lines []string
+}
+
+// readLattices — text archive (ark) или scp "key file:offset"
+func readLattices(spec string) ([]latticeEntry, error) {
+	kind, _, _ := strings.Cut(spec, ":")
+	r, err := openRspecifier(spec)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(kind, "scp") {
+		return parseLatticeArchive(r)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var entries []latticeEntry
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		lines, err := readLatticeAt(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fields[0], err)
+		}
+		entries = append(entries, latticeEntry{key: fields[0], lines: lines})
+	}
+	return entries, nil
+}
+
+// readLatticeAt — lattice по "file:offset" (смещение сразу после ключа)
+func readLatticeAt(rx string) ([]string, error) {
+	path, offset := rx, int64(0)
+	if i := strings.LastIndex(rx, ":"); i > 0 {
+		if n, err := strconv.ParseInt(rx[i+1:], 10, 64); err == nil {
+			path, offset = rx[:i], n
+		}
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, scanner.Err()
+}
+
+func parseLatticeArchive(r io.Reader) ([]latticeEntry, error) {
+	var entries []latticeEntry
+	var cur *latticeEntry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+			if cur != nil {
+				entries = append(entries, *cur)
+				cur = nil
+			}
+		case cur == nil:
+			cur = &latticeEntry{key: strings.Fields(line)[0]}
+		default:
+			cur.lines = append(cur.lines, line)
+		}
+	}
+	if cur != nil {
+		entries = append(entries, *cur)
+	}
+	return entries, scanner.Err()
+}
+
+// mapArcs применяет fn к весам дуг "graph,acoustic" (финальные состояния — без изменений)
+func mapArcs(lines []string, fn func(word int, graph, acoustic float64) (float64, float64)) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			out[i] = line
+			continue
+		}
+		word, _ := strconv.Atoi(fields[2])
+		w := strings.SplitN(fields[3], ",", 3)
+		graph, _ := strconv.ParseFloat(w[0], 64)
+		acoustic, _ := strconv.ParseFloat(w[1], 64)
+		graph, acoustic = fn(word, graph, acoustic)
+		out[i] = fmt.Sprintf("%s %s %s %g,%g,%s", fields[0], fields[1], fields[2], graph, acoustic, w[2])
+	}
+	return out
+}
+
+// copyLattices — общий каркас lattice-scale / lattice-add-penalty / lattice-copy
+func copyLattices(tool string, args []string, fn func(word int, graph, acoustic float64) (float64, float64)) int {
+	_, pos := kaldiArgs(args)
+	if len(pos) != 2 {
+		logf("Usage: %s [options] <lattice-rspecifier> <lattice-wspecifier>", tool)
+		return 1
+	}
+	entries, err := readLattices(pos[0])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error reading lattices %s: %v", pos[0], err)
+		return 1
+	}
+	w, err := openWspecifier(pos[1])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error opening table %s: %v", pos[1], err)
+		return 1
+	}
+	for _, e := range entries {
+		lines := e.lines
+		if fn != nil {
+			lines = mapArcs(lines, fn)
+		}
+		writeLatticeEntry(w, e.key, lines)
+	}
+	if err := w.Close(); err != nil {
+		return 1
+	}
+	kaldiLog("LOG", tool, "Done %d lattices.", len(entries))
+	return 0
+}
+
+func fakeLatticeScale(args []string) int {
+	opts, _ := kaldiArgs(args)
+	lm := floatOption(opts, "lm-scale", 1)
+	ac := floatOption(opts, "acoustic-scale", 1)
+	return copyLattices("lattice-scale", args, func(_ int, graph, acoustic float64) (float64, float64) {
+		return graph * lm, acoustic * ac
+	})
+}
+
+func fakeLatticeAddPenalty(args []string) int {
+	opts, _ := kaldiArgs(args)
+	wip := floatOption(opts, "word-ins-penalty", 0)
+	return copyLattices("lattice-add-penalty", args, func(word int, graph, acoustic float64) (float64, float64) {
+		if word != 0 {
+			graph += wip
+		}
+		return graph, acoustic
+	})
+}
+
+func fakeLatticeCopy(args []string) int {
+	return copyLattices("lattice-copy", args, nil)
+}
+
+// fakeLatticeBestPath: "key id id ..." в text archive; путь линейный — слова дуг по порядку
+func fakeLatticeBestPath(args []string) int {
+	const tool = "lattice-best-path"
+	_, pos := kaldiArgs(args)
+	if len(pos) < 2 {
+		logf("Usage: %s [options] <lattice-rspecifier> [<transcriptions-wspecifier> [<alignments-wspecifier>]]", tool)
+		return 1
+	}
+	entries, err := readLattices(pos[0])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error reading lattices %s: %v", pos[0], err)
+		return 1
+	}
+	w, err := openWspecifier(pos[1])
+	if err != nil {
+		kaldiLog("ERROR", tool, "Error opening table %s: %v", pos[1], err)
+		return 1
+	}
+	for _, e := range entries {
+		ids := []string{e.key}
+		for _, line := range e.lines {
+			if fields := strings.Fields(line); len(fields) == 4 && fields[2] != "0" {
+				ids = append(ids, fields[2])
+			}
+		}
+		fmt.Fprintln(w, strings.Join(ids, " "))
+	}
+	if err := w.Close(); err != nil {
+		return 1
+	}
+	kaldiLog("LOG", tool, "Done %d lattices, failed for 0", len(entries))
+	return 0
+}
+
+// fakeInt2Sym: int2sym.pl -f 2- words.txt — stdin в stdout с заменой id на слова
+func fakeInt2Sym(args []string) int {
+	from, path := 1, ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-f" && i+1 < len(args) {
+			from, _ = strconv.Atoi(strings.TrimSuffix(args[i+1], "-"))
+			i++
+			continue
+		}
+		path = args[i]
+	}
+	symbols, err := readSymbols(path)
+	if err != nil {
+		logf("int2sym.pl: cannot open %s", path)
+		return 1
+	}
+	words := make(map[string]string, len(symbols))
+	for w, id := range symbols {
+		words[strconv.Itoa(id)] = w
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		for i := from - 1; i < len(fields); i++ {
+			w, ok := words[fields[i]]
+			if !ok {
+				logf("int2sym.pl: undefined symbol %s (in position %d)", fields[i], i+1)
+				return 1
+			}
+			fields[i] = w
+		}
+		fmt.Fprintln(out, strings.Join(fields, " "))
+	}
+	return 0
+}
+
+// readSymbols — words.txt: "слово id"
+func readSymbols(path string) (map[string]int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	symbols := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if id, err := strconv.Atoi(fields[1]); err == nil {
+			symbols[fields[0]] = id
+		}
+	}
+	return symbols, nil
+}
+
+func floatOption(opts map[string]string, key string, def float64) float64 {
+	if v, err := strconv.ParseFloat(opts[key], 64); err == nil {
+		return v
+	}
+	return def
+}
+
+// Vocabulary — отсортированные слова текстов без повторов
+func Vocabulary(texts ...string) []string {
+	seen := make(map[string]bool)
+	var words []string
+	for _, text := range texts {
+		for _, w := range strings.Fields(text) {
+			if !seen[w] {
+				seen[w] = true
+				words = append(words, w)
+			}
+		}
+	}
+	sort.Strings(words)
+	return words
+}
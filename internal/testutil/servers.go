@@ -0,0 +1,273 @@
+package testutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"audio-labeler/internal/segment"
+)
+
+// Server — httptest сервер заглушки: считает запросы распознавания и умеет отвечать ошибкой
+type Server struct {
+	*httptest.Server
+	requests int64
+
+	mu       sync.Mutex
+	failures []int // статусы следующих ответов
+}
+
+// Requests — сколько запросов распознавания/диаризации пришло (включая отвеченные ошибкой)
+func (s *Server) Requests() int64 {
+	return atomic.LoadInt64(&s.requests)
+}
+
+// FailNext — следующие n запросов получат status (429, 503, ...)
+func (s *Server) FailNext(n, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := 0; i < n; i++ {
+		s.failures = append(s.failures, status)
+	}
+}
+
+// fail отвечает запланированной ошибкой; false — ошибок в очереди нет
+func (s *Server) fail(w http.ResponseWriter) bool {
+	atomic.AddInt64(&s.requests, 1)
+
+	s.mu.Lock()
+	if len(s.failures) == 0 {
+		s.mu.Unlock()
+		return false
+	}
+	status := s.failures[0]
+	s.failures = s.failures[1:]
+	s.mu.Unlock()
+
+	if status == http.StatusTooManyRequests {
+		w.Header().Set("Retry-After", "0")
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error": map[string]string{"message": http.StatusText(status)},
+	})
+	return true
+}
+
+func newServer(t testing.TB, mux *http.ServeMux) *Server {
+	t.Helper()
+	s := &Server{}
+	s.Server = httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+// NewWhisperLocal — faster-whisper сервер: POST /transcribe (multipart "audio"), GET /health
+func NewWhisperLocal(t testing.TB) *Server {
+	mux := http.NewServeMux()
+	s := newServer(t, mux)
+
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /transcribe", func(w http.ResponseWriter, r *http.Request) {
+		if s.fail(w) {
+			return
+		}
+		a, err := uploadedAudio(r, "audio")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+			return
+		}
+
+		words := whisperWords(a, true)
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"text":     a.Text(),
+			"language": r.FormValue("language"),
+			"duration": a.Duration(),
+			"segments": []map[string]interface{}{whisperSegment(a, words)},
+		})
+	})
+	return s
+}
+
+// NewOpenAI — OpenAI API: POST /audio/transcriptions (json, verbose_json), GET /models.
+// Ключ key обязателен в Authorization: Bearer, иначе 401
+func NewOpenAI(t testing.TB, key string) *Server {
+	mux := http.NewServeMux()
+	s := newServer(t, mux)
+
+	authorized := func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Header.Get("Authorization") != "Bearer "+key {
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{
+				"error": map[string]string{"message": "Incorrect API key provided", "type": "invalid_request_error"},
+			})
+			return false
+		}
+		return true
+	}
+
+	mux.HandleFunc("GET /models", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) {
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"object": "list",
+			"data":   []map[string]string{{"id": "whisper-1", "object": "model"}},
+		})
+	})
+	mux.HandleFunc("POST /audio/transcriptions", func(w http.ResponseWriter, r *http.Request) {
+		if !authorized(w, r) || s.fail(w) {
+			return
+		}
+		a, err := uploadedAudio(r, "file")
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]string{"message": err.Error()},
+			})
+			return
+		}
+
+		switch format := r.FormValue("response_format"); format {
+		case "", "json":
+			writeJSON(w, http.StatusOK, map[string]string{"text": a.Text()})
+		case "verbose_json":
+			words := whisperWords(a, false)
+			resp := map[string]interface{}{
+				"task":     "transcribe",
+				"language": r.FormValue("language"),
+				"duration": a.Duration(),
+				"text":     a.Text(),
+				"segments": []map[string]interface{}{whisperSegment(a, nil)},
+			}
+			for _, g := range r.MultipartForm.Value["timestamp_granularities[]"] {
+				if g == "word" {
+					resp["words"] = words
+				}
+			}
+			writeJSON(w, http.StatusOK, resp)
+		default:
+			writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+				"error": map[string]string{"message": "unsupported response_format: " + format},
+			})
+		}
+	})
+	return s
+}
+
+// NewPyannote — сервер диаризации: POST /diarize {"audio_path"}, GET /health.
+// Сегмент — распознанные слова без пауз длиннее 0.25s, спикеры чередуются по сегментам
+func NewPyannote(t testing.TB) *Server {
+	mux := http.NewServeMux()
+	s := newServer(t, mux)
+
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	mux.HandleFunc("POST /diarize", func(w http.ResponseWriter, r *http.Request) {
+		if s.fail(w) {
+			return
+		}
+		var req segment.DiarizeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+			return
+		}
+		a, err := ReadWAV(req.AudioPath)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"detail": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, diarize(a))
+	})
+	return s
+}
+
+func diarize(a *Audio) segment.DiarizeResponse {
+	const maxPause = 0.25
+
+	resp := segment.DiarizeResponse{
+		Segments:     []segment.PyannoteSegment{},
+		Overlaps:     []segment.Overlap{},
+		SpeakerStats: make(map[string]segment.SpeakerStat),
+		Duration:     a.Duration(),
+	}
+	words := a.Words()
+	for i, w := range words {
+		n := len(resp.Segments)
+		if i > 0 && w.Start-words[i-1].End <= maxPause {
+			resp.Segments[n-1].End = w.End
+			continue
+		}
+		resp.Segments = append(resp.Segments, segment.PyannoteSegment{
+			Start:   w.Start,
+			End:     w.End,
+			Speaker: fmt.Sprintf("SPEAKER_%02d", n%2),
+		})
+	}
+
+	for _, seg := range resp.Segments {
+		st := resp.SpeakerStats[seg.Speaker]
+		st.Duration += seg.End - seg.Start
+		st.Segments++
+		resp.SpeakerStats[seg.Speaker] = st
+	}
+	resp.NumSpeakers = len(resp.SpeakerStats)
+	return resp
+}
+
+func uploadedAudio(r *http.Request, field string) (*Audio, error) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		return nil, err
+	}
+	f, _, err := r.FormFile(field)
+	if err != nil {
+		return nil, fmt.Errorf("field %q: %w", field, err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeWAV(data)
+}
+
+// whisperWords — слова с таймингом; probability есть только у faster-whisper
+func whisperWords(a *Audio, probability bool) []map[string]interface{} {
+	recognized := a.Words()
+	words := make([]map[string]interface{}, len(recognized))
+	for i, w := range recognized {
+		word := map[string]interface{}{"word": " " + w.Word, "start": w.Start, "end": w.End}
+		if probability {
+			word["probability"] = 0.95
+		}
+		words[i] = word
+	}
+	return words
+}
+
+func whisperSegment(a *Audio, words []map[string]interface{}) map[string]interface{} {
+	seg := map[string]interface{}{
+		"id":             0,
+		"start":          0.0,
+		"end":            a.Duration(),
+		"text":           " " + a.Text(),
+		"avg_logprob":    -0.15,
+		"no_speech_prob": 0.01,
+	}
+	if words != nil {
+		seg["words"] = words
+	}
+	return seg
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
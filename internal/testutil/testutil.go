@@ -0,0 +1,74 @@
+// Package testutil — заглушки внешних зависимостей для end-to-end тестов:
+// бинарники Kaldi, ffprobe/sox/ffmpeg и HTTP серверы Whisper, OpenAI и pyannote.
+//
+// Бинарники — sh скрипты, которые запускают тестовый бинарник с переменной
+// AUDIO_LABELER_FAKE_TOOL; тест подключает это через TestMain:
+//
+//	func TestMain(m *testing.M) { testutil.Main(m) }
+package testutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	envTool = "AUDIO_LABELER_FAKE_TOOL"
+	envDir  = "AUDIO_LABELER_FAKE_DIR" // каталог установки: конфиг заглушек Kaldi
+)
+
+// tools — реализации заглушек: аргументы командной строки -> код выхода
+var tools = map[string]func(args []string) int{}
+
+// Main — TestMain пакета с e2e тестами: внутри заглушки выполняет её, иначе тесты
+func Main(m *testing.M) {
+	if name := os.Getenv(envTool); name != "" {
+		tool, ok := tools[name]
+		if !ok {
+			fmt.Fprintf(os.Stderr, "%s: unknown fake tool\n", name)
+			os.Exit(127)
+		}
+		os.Exit(tool(os.Args[1:]))
+	}
+	os.Exit(m.Run())
+}
+
+// AudioTools ставит заглушки ffprobe, sox и ffmpeg в начало PATH на время теста
+func AudioTools(t testing.TB) {
+	t.Helper()
+	bin := t.TempDir()
+	for _, name := range []string{"ffprobe", "sox", "ffmpeg"} {
+		installTool(t, filepath.Join(bin, name), name, "")
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+// installTool пишет sh скрипт path, запускающий заглушку name
+func installTool(t testing.TB, path, name, dir string) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("test executable: %v", err)
+	}
+
+	script := fmt.Sprintf("#!/bin/sh\nexport %s=%s %s=%s\nexec %s \"$@\"\n",
+		envTool, shellQuote(name), envDir, shellQuote(dir), shellQuote(exe))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// logf — вывод заглушки в stderr в формате Kaldi/sox
+func logf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
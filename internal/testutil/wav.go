@@ -0,0 +1,222 @@
+package testutil
+
+import (
+	"bytes"
+	"math"
+	"strings"
+
+	"audio-labeler/internal/audio/wav"
+)
+
+// Синтезируемая речь: каждая буква — тон charSec своей частоты (см. charFreq), между словами
+// пауза gapSec с шумом (короче 50ms, которые silencedetect в audio считает тишиной).
+// Текст восстанавливается из самих отсчётов (Words), поэтому переживает cut, pad, concat
+// и ресемплинг — и в заглушках, и в internal/audio
+const (
+	charSec    = 0.04
+	gapSec     = 0.04
+	leadSec    = 0.1
+	fadeSec    = 0.005
+	toneAmp    = 0.3
+	noiseLevel = 20.0 / 32768 // шум в паузах (~ -64 dBFS)
+
+	speechThreshold = 0.05  // |x| выше — речь
+	minPauseSec     = 0.01  // пауза внутри слова короче (переходы через ноль)
+	charEdgeSec     = 0.005 // края буквы не анализируются
+)
+
+// charAlphabet — буквы, которые умеет "произносить" Speech; частоты 500..3100 Hz
+// укладываются в полосу 8 kHz после ресемплинга
+const charAlphabet = "abcdefghijklmnopqrstuvwxyz'"
+
+func charFreq(i int) float64 {
+	return 500 + 100*float64(i)
+}
+
+// Word — слово с таймингом в секундах
+type Word struct {
+	Word  string
+	Start float64
+	End   float64
+}
+
+// Audio — моно, отсчёты в [-1, 1)
+type Audio struct {
+	Rate    int
+	Samples []float64
+}
+
+// Speech синтезирует фразу, без тишины в конце. Символы не из charAlphabet пропускаются
+func Speech(text string, rate int) *Audio {
+	a := &Audio{Rate: rate}
+	a.appendSilence(leadSec)
+	for i, w := range strings.Fields(strings.ToLower(text)) {
+		if i > 0 {
+			a.appendSilence(gapSec)
+		}
+		a.appendWord(w)
+	}
+	return a
+}
+
+func (a *Audio) appendSilence(sec float64) {
+	n := int(math.Round(sec * float64(a.Rate)))
+	for i := 0; i < n; i++ {
+		// детерминированный шум: у настоящих записей тишина не нулевая
+		k := len(a.Samples)
+		a.Samples = append(a.Samples, noiseLevel*float64((k*7919)%41-20)/20)
+	}
+}
+
+func (a *Audio) appendWord(word string) {
+	per := int(math.Round(charSec * float64(a.Rate)))
+	var freqs []float64
+	for _, c := range word {
+		if i := strings.IndexRune(charAlphabet, c); i >= 0 {
+			freqs = append(freqs, charFreq(i))
+		}
+	}
+
+	n := per * len(freqs)
+	fade := int(fadeSec * float64(a.Rate))
+	phase := 0.0
+	for i := 0; i < n; i++ {
+		env := 1.0
+		if i < fade {
+			env = float64(i) / float64(fade)
+		} else if n-i < fade {
+			env = float64(n-i) / float64(fade)
+		}
+		// фаза непрерывна между буквами: без щелчков на стыках
+		phase += 2 * math.Pi * freqs[i/per] / float64(a.Rate)
+		a.Samples = append(a.Samples, toneAmp*env*math.Sin(phase))
+	}
+}
+
+// Words распознаёт слова: участки выше speechThreshold, буквы — по самой сильной частоте
+// в каждом интервале charSec
+func (a *Audio) Words() []Word {
+	var words []Word
+	rate := float64(a.Rate)
+	minPause := int(minPauseSec * rate)
+
+	for i := 0; i < len(a.Samples); {
+		if math.Abs(a.Samples[i]) < speechThreshold {
+			i++
+			continue
+		}
+		start, end := i, i+1
+		for j := i; j < len(a.Samples) && j-end < minPause; j++ {
+			if math.Abs(a.Samples[j]) >= speechThreshold {
+				end = j + 1
+			}
+		}
+		i = end + minPause
+
+		if w := a.decodeWord(start, end); w != "" {
+			words = append(words, Word{Word: w, Start: float64(start) / rate, End: float64(end) / rate})
+		}
+	}
+	return words
+}
+
+func (a *Audio) decodeWord(start, end int) string {
+	rate := float64(a.Rate)
+	chars := int(math.Round(float64(end-start) / rate / charSec))
+	edge := int(charEdgeSec * rate)
+
+	var word strings.Builder
+	for c := 0; c < chars; c++ {
+		from := start + int(float64(c)*charSec*rate) + edge
+		to := min(start+int(float64(c+1)*charSec*rate)-edge, len(a.Samples))
+		if to <= from {
+			break
+		}
+		best, bestPower := 0, -1.0
+		for k := range charAlphabet {
+			if p := goertzel(a.Samples[from:to], charFreq(k), rate); p > bestPower {
+				best, bestPower = k, p
+			}
+		}
+		word.WriteByte(charAlphabet[best])
+	}
+	return word.String()
+}
+
+// goertzel — мощность частоты freq в x
+func goertzel(x []float64, freq, rate float64) float64 {
+	coeff := 2 * math.Cos(2*math.Pi*freq/rate)
+	var s1, s2 float64
+	for _, v := range x {
+		s1, s2 = v+coeff*s1-s2, s1
+	}
+	return s1*s1 + s2*s2 - coeff*s1*s2
+}
+
+// Text — распознанные слова через пробел
+func (a *Audio) Text() string {
+	words := a.Words()
+	out := make([]string, len(words))
+	for i, w := range words {
+		out[i] = w.Word
+	}
+	return strings.Join(out, " ")
+}
+
+func (a *Audio) Duration() float64 {
+	if a.Rate == 0 {
+		return 0
+	}
+	return float64(len(a.Samples)) / float64(a.Rate)
+}
+
+func (a *Audio) buffer() *wav.Buffer {
+	return &wav.Buffer{Format: wav.PCM16(a.Rate, 1), Samples: a.Samples}
+}
+
+func fromBuffer(b *wav.Buffer) *Audio {
+	b = b.Mono()
+	return &Audio{Rate: b.Format.SampleRate, Samples: b.Samples}
+}
+
+// Cut — фрагмент [start, start+dur); dur <= 0 — до конца
+func (a *Audio) Cut(start, dur float64) *Audio {
+	return fromBuffer(a.buffer().Trim(start, dur))
+}
+
+// Append дописывает b в конец (частота b приводится к частоте a)
+func (a *Audio) Append(b *Audio) {
+	if a.Rate == 0 {
+		a.Rate = b.Rate
+	}
+	a.Samples = append(a.Samples, b.Resample(a.Rate).Samples...)
+}
+
+func (a *Audio) Resample(rate int) *Audio {
+	if rate <= 0 || rate == a.Rate {
+		return a
+	}
+	return &Audio{Rate: rate, Samples: wav.Resample(a.Samples, a.Rate, rate)}
+}
+
+// WriteWAV пишет моно PCM 16 bit
+func WriteWAV(path string, a *Audio) error {
+	return wav.WriteFile(path, a.buffer())
+}
+
+func ReadWAV(path string) (*Audio, error) {
+	b, err := wav.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return fromBuffer(b), nil
+}
+
+// DecodeWAV разбирает WAV любого формата; каналы сводятся в моно
+func DecodeWAV(data []byte) (*Audio, error) {
+	b, err := wav.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return fromBuffer(b), nil
+}
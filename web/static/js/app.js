@@ -6,6 +6,11 @@ let allSpeakers = [];
 let selectedSpeaker = '';
 let audioCacheBust = {}; // {fileId: timestamp} - для антикэша после trim
 
+// tr — результат движка из file.transcriptions ({} если движок не запускался)
+function tr(file, engine) {
+    return (file.transcriptions && file.transcriptions[engine]) || {};
+}
+
 // ============================================================
 // STATS
 // ============================================================
@@ -17,22 +22,29 @@ async function loadStats() {
             document.getElementById('stat-total').textContent = data.data.total || 0;
             document.getElementById('stat-verified').textContent = data.data.verified || 0;
             document.getElementById('stat-needs-review').textContent = data.data.needs_review || 0;
-            document.getElementById('stat-pending').textContent = data.data.pending || 0;
-            document.getElementById('stat-pending-nolm').textContent = data.data.pending_nolm || 0;
-            document.getElementById('stat-pending-whisper-local').textContent = data.data.pending_whisper_local || 0;
-            document.getElementById('stat-pending-whisper-openai').textContent = data.data.pending_whisper_openai || 0;
+            // По движкам: если движок ещё ничего не обработал — все файлы pending
+            const engines = data.data.engines || {};
+            const total = data.data.total || 0;
+            const pending = (e) => engines[e] ? engines[e].pending : total;
+            const processed = (e) => engines[e]?.processed || 0;
+            const avgWER = (e) => engines[e]?.avg_wer ? (engines[e].avg_wer * 100).toFixed(2) + '%' : '-';
+
+            document.getElementById('stat-pending').textContent = pending('kaldi');
+            document.getElementById('stat-pending-nolm').textContent = pending('kaldi-nolm');
+            document.getElementById('stat-pending-whisper-local').textContent = pending('whisper-local');
+            document.getElementById('stat-pending-whisper-openai').textContent = pending('whisper-openai');
 
             // WER
-            document.getElementById('stat-kaldi-wer').textContent = data.data.kaldi_wer ? (data.data.kaldi_wer * 100).toFixed(2) + '%' : '-';
-            document.getElementById('stat-kaldi-nolm-wer').textContent = data.data.kaldi_nolm_wer ? (data.data.kaldi_nolm_wer * 100).toFixed(2) + '%' : '-';
-            document.getElementById('stat-whisper-local-wer').textContent = data.data.whisper_local_wer ? (data.data.whisper_local_wer * 100).toFixed(2) + '%' : '-';
-            document.getElementById('stat-whisper-openai-wer').textContent = data.data.whisper_openai_wer ? (data.data.whisper_openai_wer * 100).toFixed(2) + '%' : '-';
+            document.getElementById('stat-kaldi-wer').textContent = avgWER('kaldi');
+            document.getElementById('stat-kaldi-nolm-wer').textContent = avgWER('kaldi-nolm');
+            document.getElementById('stat-whisper-local-wer').textContent = avgWER('whisper-local');
+            document.getElementById('stat-whisper-openai-wer').textContent = avgWER('whisper-openai');
 
             // Processed counts
-            document.getElementById('stat-kaldi-processed').textContent = data.data.processed || 0;
-            document.getElementById('stat-nolm-processed').textContent = data.data.processed_nolm || 0;
-            document.getElementById('stat-wlocal-processed').textContent = data.data.processed_whisper_local || 0;
-            document.getElementById('stat-wopenai-processed').textContent = data.data.processed_whisper_openai || 0;
+            document.getElementById('stat-kaldi-processed').textContent = processed('kaldi');
+            document.getElementById('stat-nolm-processed').textContent = processed('kaldi-nolm');
+            document.getElementById('stat-wlocal-processed').textContent = processed('whisper-local');
+            document.getElementById('stat-wopenai-processed').textContent = processed('whisper-openai');
         }
     } catch (e) {
         console.error('Failed to load stats:', e);
@@ -43,31 +55,57 @@ async function loadStats() {
 // PROCESSING - добавлен kaldi-nolm
 // ============================================================
 
+// engineName — имя движка в /api/engines/{name} для значения process-target
+function engineName(target) {
+    return target === 'whisper-openai-forced' ? 'whisper-openai' : target;
+}
+
+// engineProfiles — профили декодирования движков (Kaldi), из /api/engines
+let engineProfiles = {};
+
+async function loadProfiles() {
+    try {
+        const res = await fetch(`${API_BASE}/api/engines`);
+        const data = await res.json();
+        if (!data.success) return;
+        engineProfiles = {};
+        (data.data || []).forEach(e => { engineProfiles[e.name] = e.profiles || []; });
+        updateProfileSelect();
+    } catch (e) {
+        console.error('Failed to load profiles:', e);
+    }
+}
+
+// updateProfileSelect — выбор профиля только для движков, у которых они есть
+function updateProfileSelect() {
+    const select = document.getElementById('process-profile');
+    if (!select) return;
+    const profiles = engineProfiles[document.getElementById('process-target').value] || [];
+    select.innerHTML = '<option value="">default</option>' +
+        profiles.map(p => `<option value="${p}">${p}</option>`).join('');
+    select.classList.toggle('hidden', profiles.length === 0);
+}
+
 async function startProcessing() {
     const limit = document.getElementById('process-limit').value;
     const target = document.getElementById('process-target').value;
+    const profile = document.getElementById('process-profile')?.value;
 
     let url = '';
     switch (target) {
-        case 'kaldi':
-            url = `${API_BASE}/api/asr/start?limit=${limit}&workers=5`;
-            break;
-        case 'kaldi-nolm':
-            url = `${API_BASE}/api/asr-nolm/start?limit=${limit}&workers=5`;
-            break;
-        case 'whisper-local':
-            url = `${API_BASE}/api/whisper-local/start?limit=${limit}&workers=3`;
+        case 'analyze':
+            const force = document.getElementById('analyze-force')?.checked ? '1' : '0';
+            url = `${API_BASE}/api/analyze/start?limit=${limit}&force=${force}`;
             break;
         case 'whisper-openai':
-            url = `${API_BASE}/api/whisper-openai/start?limit=${limit}&workers=3&min_wer=0`;
+            url = `${API_BASE}/api/engines/whisper-openai/start?limit=${limit}&min_wer=0`;
             break;
         case 'whisper-openai-forced':
-            url = `${API_BASE}/api/whisper-openai/start-forced?limit=${limit}&workers=3`;
-            break;
-        case 'analyze':
-            const force = document.getElementById('analyze-force')?.checked ? '1' : '0';
-            url = `${API_BASE}/api/analyze/start?limit=${limit}&force=${force}`;
+            url = `${API_BASE}/api/engines/whisper-openai/start?limit=${limit}&forced=1`;
             break;
+        default:
+            url = `${API_BASE}/api/engines/${target}/start?limit=${limit}`;
+            if (profile) url += `&profile=${encodeURIComponent(profile)}`;
     }
 
     try {
@@ -87,22 +125,9 @@ async function startProcessing() {
 async function stopProcessing() {
     const target = document.getElementById('process-target').value;
 
-    let url = '';
-    switch (target) {
-        case 'kaldi':
-            url = `${API_BASE}/api/asr/stop`;
-            break;
-        case 'kaldi-nolm':
-            url = `${API_BASE}/api/asr-nolm/stop`;
-            break;
-        case 'whisper-local':
-            url = `${API_BASE}/api/whisper-local/stop`;
-            break;
-        case 'whisper-openai':
-        case 'whisper-openai-forced':
-            url = `${API_BASE}/api/whisper-openai/stop`;
-            break;
-    }
+    const url = target === 'analyze'
+        ? `${API_BASE}/api/analyze/stop`
+        : `${API_BASE}/api/engines/${engineName(target)}/stop`;
 
     try {
         const res = await fetch(url, { method: 'POST' });
@@ -116,25 +141,9 @@ async function stopProcessing() {
 async function refreshStatus() {
     const target = document.getElementById('process-target').value;
 
-    let url = '';
-    switch (target) {
-        case 'kaldi':
-            url = `${API_BASE}/api/asr/status`;
-            break;
-        case 'kaldi-nolm':
-            url = `${API_BASE}/api/asr-nolm/status`;
-            break;
-        case 'whisper-local':
-            url = `${API_BASE}/api/whisper-local/status`;
-            break;
-        case 'whisper-openai':
-        case 'whisper-openai-forced':
-            url = `${API_BASE}/api/whisper-openai/status`;
-            break;
-        case 'analyze':
-            url = `${API_BASE}/api/analyze/status`;
-            break;
-    }
+    const url = target === 'analyze'
+        ? `${API_BASE}/api/analyze/status`
+        : `${API_BASE}/api/engines/${engineName(target)}/status`;
 
     if (!url) {
         showProcessStatus('No status endpoint for this target');
@@ -147,10 +156,11 @@ async function refreshStatus() {
         if (data.success && data.data) {
             const s = data.data;
             if (s.running) {
-                showProcessStatus(`Running: ${s.processed}/${s.total} (${s.percent?.toFixed(1)}%)`);
+                const profile = s.profile ? ` [${s.profile}]` : '';
+                showProcessStatus(`Running${profile}: ${s.processed}/${s.total} (${s.percent?.toFixed(1)}%)${formatCost(s)}${formatSpeed(s)}`);
                 setTimeout(refreshStatus, 2000);
             } else {
-                showProcessStatus(`Idle. Last: ${s.processed || 0} processed`);
+                showProcessStatus(`Idle. Last: ${s.processed || 0} processed${formatCost(s)}`);
                 loadFiles();
                 loadStats();
             }
@@ -161,6 +171,32 @@ async function refreshStatus() {
 }
 
 
+// formatCost — стоимость платного движка (whisper-openai) для строки статуса
+function formatCost(s) {
+    if (!s.cost && !s.budget) return '';
+    const budget = s.budget ? ` of $${s.budget.toFixed(2)}` : '';
+    return `, ${(s.audio_minutes || 0).toFixed(1)} min, $${(s.cost || 0).toFixed(4)}${budget}`;
+}
+
+// formatSpeed — RTF запуска; для Kaldi server mode — ускорение против процесса на файл
+function formatSpeed(s) {
+    if (!s.rtf) return '';
+    let text = `, RTF ${s.rtf.toFixed(3)}`;
+    if (s.speedup) text += ` (x${s.speedup.toFixed(1)} vs ${s.oneshot_rtf.toFixed(3)} per-file)`;
+    if (s.decoder_restarts) text += `, ${s.decoder_restarts} decoder restarts`;
+    return text;
+}
+
+// formatConfidence — avg_logprob / no_speech_prob (Whisper verbose_json), confidence (Kaldi MBR)
+function formatConfidence(t) {
+    if (t.avg_logprob == null && t.no_speech_prob == null && t.confidence == null) return '';
+    const parts = [];
+    if (t.confidence != null) parts.push(`conf: ${(t.confidence * 100).toFixed(0)}%`);
+    if (t.avg_logprob != null) parts.push(`logprob: ${t.avg_logprob.toFixed(2)}`);
+    if (t.no_speech_prob != null) parts.push(`no speech: ${(t.no_speech_prob * 100).toFixed(0)}%`);
+    return ' | ' + parts.join(' | ');
+}
+
 function showProcessStatus(msg, isError = false) {
     const el = document.getElementById('process-status');
     el.textContent = msg;
@@ -216,6 +252,8 @@ function resetFilters() {
     document.getElementById('filter-text').value = '';
     document.getElementById('filter-chapter').value = '';
     document.getElementById('filter-noise').value = '';
+    document.getElementById('filter-defect').value = '';
+    document.getElementById('filter-confidence').value = '';
     clearSpeaker();
     currentPage = 1;
     loadFiles();
@@ -276,7 +314,7 @@ async function showDetail(id) {
             content.innerHTML = `
                 <div class="space-y-4">
                     <div class="bg-gray-50 p-3 rounded">
-                        <audio controls class="w-full">
+                        <audio id="detail-audio" controls class="w-full">
                             <source src="${getAudioUrl(file.id)}" type="audio/wav">
                         </audio>
                     </div>
@@ -315,33 +353,35 @@ async function showDetail(id) {
                         <div class="bg-blue-50 p-3 rounded">
                             <div class="flex justify-between items-center mb-1">
                                 <span class="font-semibold text-blue-700">Kaldi ASR</span>
-                                <span class="text-sm">WER: ${((file.wer || 0) * 100).toFixed(2)}% | CER: ${((file.cer || 0) * 100).toFixed(2)}%</span>
+                                <span class="text-sm">WER: ${((tr(file, 'kaldi').wer || 0) * 100).toFixed(2)}% | CER: ${((tr(file, 'kaldi').cer || 0) * 100).toFixed(2)}%${formatConfidence(tr(file, 'kaldi'))}</span>
                             </div>
-                            <div class="text-gray-800 text-base">${file.transcription_asr || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-text-kaldi" class="text-gray-800 text-base">${tr(file, 'kaldi').text || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-nbest-kaldi"></div>
                         </div>
 
                         <div class="bg-indigo-50 p-3 rounded">
                             <div class="flex justify-between items-center mb-1">
                                 <span class="font-semibold text-indigo-700">Kaldi NoLM</span>
-                                <span class="text-sm">WER: ${((file.wer_nolm || 0) * 100).toFixed(2)}% | CER: ${((file.cer_nolm || 0) * 100).toFixed(2)}%</span>
+                                <span class="text-sm">WER: ${((tr(file, 'kaldi-nolm').wer || 0) * 100).toFixed(2)}% | CER: ${((tr(file, 'kaldi-nolm').cer || 0) * 100).toFixed(2)}%${formatConfidence(tr(file, 'kaldi-nolm'))}</span>
                             </div>
-                            <div class="text-gray-800 text-base">${file.transcription_asr_nolm || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-text-kaldi-nolm" class="text-gray-800 text-base">${tr(file, 'kaldi-nolm').text || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-nbest-kaldi-nolm"></div>
                         </div>
 
                         <div class="bg-green-50 p-3 rounded">
                             <div class="flex justify-between items-center mb-1">
                                 <span class="font-semibold text-green-700">Whisper Local</span>
-                                <span class="text-sm">WER: ${((file.wer_whisper_local || 0) * 100).toFixed(2)}% | CER: ${((file.cer_whisper_local || 0) * 100).toFixed(2)}%</span>
+                                <span class="text-sm">WER: ${((tr(file, 'whisper-local').wer || 0) * 100).toFixed(2)}% | CER: ${((tr(file, 'whisper-local').cer || 0) * 100).toFixed(2)}%</span>
                             </div>
-                            <div class="text-gray-800 text-base">${file.transcription_whisper_local || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-text-whisper-local" class="text-gray-800 text-base">${tr(file, 'whisper-local').text || '<span class="text-gray-400">Not processed</span>'}</div>
                         </div>
 
                         <div class="bg-purple-50 p-3 rounded">
                             <div class="flex justify-between items-center mb-1">
                                 <span class="font-semibold text-purple-700">Whisper OpenAI</span>
-                                <span class="text-sm">WER: ${((file.wer_whisper_openai || 0) * 100).toFixed(2)}% | CER: ${((file.cer_whisper_openai || 0) * 100).toFixed(2)}%</span>
+                                <span class="text-sm">WER: ${((tr(file, 'whisper-openai').wer || 0) * 100).toFixed(2)}% | CER: ${((tr(file, 'whisper-openai').cer || 0) * 100).toFixed(2)}%${formatConfidence(tr(file, 'whisper-openai'))}</span>
                             </div>
-                            <div class="text-gray-800 text-base">${file.transcription_whisper_openai || '<span class="text-gray-400">Not processed</span>'}</div>
+                            <div id="tr-text-whisper-openai" class="text-gray-800 text-base">${tr(file, 'whisper-openai').text || '<span class="text-gray-400">Not processed</span>'}</div>
                         </div>
                     </div>
 
@@ -361,6 +401,7 @@ async function showDetail(id) {
 
                     <div class="text-xs text-gray-500 break-all">
                         <span class="font-semibold">Path:</span> ${file.file_path}
+                        ${file.segment_start != null ? `<span class="ml-2 font-semibold">Segment:</span> ${file.segment_start.toFixed(2)}–${file.segment_end.toFixed(2)}s` : ''}
                     </div>
                 </div>
             `;
@@ -369,6 +410,8 @@ async function showDetail(id) {
 
             // Init segments
             initSegments(file.id);
+            initWords(file);
+            initHypotheses(file);
         }
     } catch (e) {
         console.error('Failed to load file details:', e);
@@ -489,6 +532,14 @@ function clearSpeaker() {
 let loadedFiles = []; // хранит загруженные файлы
 let currentSort = { field: 'id', order: 'desc' };
 
+// sortValue — значение поля для сортировки; "wer:<engine>" — WER движка
+function sortValue(file, field) {
+    if (field.startsWith('wer:')) {
+        return tr(file, field.slice(4)).wer;
+    }
+    return file[field];
+}
+
 function sortFiles(field) {
     // Переключаем порядок если тот же field
     if (currentSort.field === field) {
@@ -499,8 +550,8 @@ function sortFiles(field) {
     }
 
     const sorted = [...loadedFiles].sort((a, b) => {
-        let valA = a[field];
-        let valB = b[field];
+        let valA = sortValue(a, field);
+        let valB = sortValue(b, field);
 
         // Для строк
         if (typeof valA === 'string') {
@@ -646,6 +697,16 @@ document.getElementById('filter-noise')?.addEventListener('change', function ()
     loadFiles();
 });
 
+document.getElementById('filter-defect')?.addEventListener('change', function () {
+    currentPage = 1;
+    loadFiles();
+});
+
+document.getElementById('filter-confidence')?.addEventListener('change', function () {
+    currentPage = 1;
+    loadFiles();
+});
+
 document.getElementById('filter-text')?.addEventListener('keypress', function (e) {
     if (e.key === 'Enter') {
         currentPage = 1;
@@ -659,3 +720,5 @@ document.getElementById('filter-chapter')?.addEventListener('keypress', function
         loadFiles();
     }
 });
+
+document.getElementById('process-target')?.addEventListener('change', updateProfileSelect);
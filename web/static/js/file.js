@@ -43,14 +43,14 @@ async function loadFiles() {
         if (filter === 'errors') url += '&wer_op=gt&wer_value=0';
         if (filter === 'high_wer') url += '&wer_op=gt&wer_value=10';
         if (filter === 'very_high_wer') url += '&wer_op=gt&wer_value=20';
-        if (filter === 'pending_asr') url += '&asr_status=pending';
-        if (filter === 'pending_nolm') url += '&asr_nolm_status=pending';
-        if (filter === 'pending_whisper') url += '&whisper_local_status=pending';
-        if (filter === 'pending_openai') url += '&whisper_openai_status=pending';
-        if (filter === 'processed_asr') url += '&asr_status=processed';
-        if (filter === 'processed_nolm') url += '&asr_nolm_status=processed';
-        if (filter === 'processed_whisper') url += '&whisper_local_status=processed';
-        if (filter === 'processed_openai') url += '&whisper_openai_status=processed';
+        if (filter === 'pending_asr') url += '&status_kaldi=pending';
+        if (filter === 'pending_nolm') url += '&status_kaldi-nolm=pending';
+        if (filter === 'pending_whisper') url += '&status_whisper-local=pending';
+        if (filter === 'pending_openai') url += '&status_whisper-openai=pending';
+        if (filter === 'processed_asr') url += '&status_kaldi=processed';
+        if (filter === 'processed_nolm') url += '&status_kaldi-nolm=processed';
+        if (filter === 'processed_whisper') url += '&status_whisper-local=processed';
+        if (filter === 'processed_openai') url += '&status_whisper-openai=processed';
 
         // Custom filters
         if (werOp && werValue) {
@@ -75,6 +75,21 @@ async function loadFiles() {
             url += `&noise_level=${filterNoise}`;
         }
 
+        // Defects filter: тип[:минимальная серьёзность]
+        const filterDefect = document.getElementById('filter-defect')?.value;
+        if (filterDefect) {
+            const [defect, severity] = filterDefect.split(':');
+            url += `&defect=${defect}`;
+            if (severity) url += `&defect_severity=${severity}`;
+        }
+
+        // Kaldi confidence: порог и/или сначала наименее уверенные
+        const filterConfidence = document.getElementById('filter-confidence')?.value;
+        if (filterConfidence) {
+            url += '&sort=confidence';
+            if (filterConfidence !== 'sort') url += `&conf_max=${filterConfidence}`;
+        }
+
         // Chapter filter
         const filterChapter = document.getElementById('filter-chapter')?.value?.trim();
         if (filterChapter) {
@@ -229,7 +244,7 @@ function renderFiles(files) {
 
     fileList.innerHTML = files.map(file => {
         // Определяем класс карточки по статусу
-        const wer = file.wer || 0;
+        const wer = tr(file, 'kaldi').wer || 0;
         const isVerified = file.operator_verified;
         const highWER = wer > 0.15;
         const needsReview = highWER && !isVerified;
@@ -265,12 +280,14 @@ function renderFiles(files) {
                 ${file.snr_wada > 0 ? `<span class="text-gray-600"><span class="font-semibold">WADA:</span> ${file.snr_wada.toFixed(1)}</span>` : ''}
                 ${file.snr_spectral > 0 ? `<span class="text-gray-600"><span class="font-semibold">Spec:</span> ${file.snr_spectral.toFixed(1)}</span>` : ''}
                 ${file.rms_db ? `<span class="text-gray-600"><span class="font-semibold">RMS:</span> ${file.rms_db.toFixed(1)}dB</span>` : ''}
+                ${renderLoudness(file)}
             </div>
+            ${renderDefects(file)}
             <div class="flex flex-wrap items-center gap-6 mb-2 text-sm leading-relaxed">
-                <span><span class="font-semibold text-gray-600">ASR:</span> ${getStatusBadge(file.asr_status)}</span>
-                <span><span class="font-semibold text-gray-600">NoLM:</span> ${getStatusBadge(file.asr_nolm_status)}</span>
-                <span><span class="font-semibold text-gray-600">W.L:</span> ${getStatusBadge(file.whisper_local_status)}</span>
-                <span><span class="font-semibold text-gray-600">W.O:</span> ${getStatusBadge(file.whisper_openai_status)}</span>
+                <span><span class="font-semibold text-gray-600">ASR:</span> ${getStatusBadge(tr(file, 'kaldi').status || 'pending')}</span>
+                <span><span class="font-semibold text-gray-600">NoLM:</span> ${getStatusBadge(tr(file, 'kaldi-nolm').status || 'pending')}</span>
+                <span><span class="font-semibold text-gray-600">W.L:</span> ${getStatusBadge(tr(file, 'whisper-local').status || 'pending')}</span>
+                <span><span class="font-semibold text-gray-600">W.O:</span> ${getStatusBadge(tr(file, 'whisper-openai').status || 'pending')}</span>
             </div>
             
             <audio controls preload="none" class="w-full mb-2">
@@ -285,26 +302,26 @@ function renderFiles(files) {
                 
                 <div>
                     <span class="font-semibold text-blue-600">Kaldi:</span>
-                    <span class="text-base">${file.asr_status === 'processed' ? highlightDiff(file.transcription_original, file.transcription_asr) : ''}</span>
-                    <span class="text-gray-400 ml-1 text-xs">${file.asr_status === 'processed' ? formatMetric(file.wer, 'WER') : 'WER:-'}</span>
+                    <span class="text-base">${tr(file, 'kaldi').status === 'processed' ? highlightDiff(file.transcription_original, tr(file, 'kaldi').text) : ''}</span>
+                    <span class="text-gray-400 ml-1 text-xs">${tr(file, 'kaldi').status === 'processed' ? formatMetric(tr(file, 'kaldi').wer, 'WER') : 'WER:-'}</span>
                 </div>
 
                 <div>
                     <span class="font-semibold text-indigo-600">NoLM:</span>
-                    <span class="text-base">${file.asr_nolm_status === 'processed' ? highlightDiff(file.transcription_original, file.transcription_asr_nolm) : ''}</span>
-                    <span class="text-gray-400 ml-1 text-xs">${file.asr_nolm_status === 'processed' ? formatMetric(file.wer_nolm, 'WER') : 'WER:-'}</span>
+                    <span class="text-base">${tr(file, 'kaldi-nolm').status === 'processed' ? highlightDiff(file.transcription_original, tr(file, 'kaldi-nolm').text) : ''}</span>
+                    <span class="text-gray-400 ml-1 text-xs">${tr(file, 'kaldi-nolm').status === 'processed' ? formatMetric(tr(file, 'kaldi-nolm').wer, 'WER') : 'WER:-'}</span>
                 </div>
                 
                 <div>
                     <span class="font-semibold text-green-600">W.Local:</span>
-                    <span class="text-base">${file.whisper_local_status === 'processed' ? highlightDiff(file.transcription_original, file.transcription_whisper_local) : ''}</span>
-                    <span class="text-gray-400 ml-1 text-xs">${file.whisper_local_status === 'processed' ? formatMetric(file.wer_whisper_local, 'WER') : 'WER:-'}</span>
+                    <span class="text-base">${tr(file, 'whisper-local').status === 'processed' ? highlightDiff(file.transcription_original, tr(file, 'whisper-local').text) : ''}</span>
+                    <span class="text-gray-400 ml-1 text-xs">${tr(file, 'whisper-local').status === 'processed' ? formatMetric(tr(file, 'whisper-local').wer, 'WER') : 'WER:-'}</span>
                 </div>
                 
                 <div>
                     <span class="font-semibold text-purple-600">W.OpenAI:</span>
-                    <span class="text-base">${file.whisper_openai_status === 'processed' ? highlightDiff(file.transcription_original, file.transcription_whisper_openai) : ''}</span>
-                    <span class="text-gray-400 ml-1 text-xs">${file.whisper_openai_status === 'processed' ? formatMetric(file.wer_whisper_openai, 'WER') : 'WER:-'}</span>
+                    <span class="text-base">${tr(file, 'whisper-openai').status === 'processed' ? highlightDiff(file.transcription_original, tr(file, 'whisper-openai').text) : ''}</span>
+                    <span class="text-gray-400 ml-1 text-xs">${tr(file, 'whisper-openai').status === 'processed' ? formatMetric(tr(file, 'whisper-openai').wer, 'WER') : 'WER:-'}</span>
                 </div>
             </div>
 
@@ -329,6 +346,50 @@ function renderFiles(files) {
     `}).join('');
 }
 
+// Дефекты записи: по бейджу на тип, в подсказке — участки и детали
+const DEFECT_LABELS = {
+    clipping: 'Clipping',
+    dc_offset: 'DC offset',
+    dropout: 'Dropout',
+    hum: 'Hum',
+    narrowband: 'Narrowband',
+};
+const SEVERITY_CLASSES = {
+    low: 'bg-yellow-100 text-yellow-700',
+    medium: 'bg-orange-100 text-orange-700',
+    high: 'bg-red-100 text-red-700',
+};
+const SEVERITY_ORDER = ['low', 'medium', 'high'];
+
+function renderDefects(file) {
+    if (!file.defects || file.defects.length === 0) return '';
+
+    const byType = {};
+    for (const d of file.defects) {
+        (byType[d.type] = byType[d.type] || []).push(d);
+    }
+
+    const badges = Object.entries(byType).map(([type, list]) => {
+        const worst = list.reduce((a, d) => SEVERITY_ORDER.indexOf(d.severity) > SEVERITY_ORDER.indexOf(a) ? d.severity : a, 'low');
+        const title = list.map(d => `${d.start.toFixed(2)}-${d.end.toFixed(2)}s ${d.severity}: ${d.detail || ''}`).join('\n');
+        const count = list.length > 1 ? ` ×${list.length}` : '';
+        return `<span class="px-2 py-0.5 rounded ${SEVERITY_CLASSES[worst] || ''}" title="${title}">${DEFECT_LABELS[type] || type}${count}</span>`;
+    }).join('');
+
+    return `<div class="flex flex-wrap items-center gap-2 mb-2 text-sm leading-relaxed">
+                <span class="font-semibold text-gray-600">Defects:</span> ${badges}
+            </div>`;
+}
+
+function renderLoudness(file) {
+    if (file.loudness_lufs === null || file.loudness_lufs === undefined) return '';
+    const peak = file.true_peak_db !== null && file.true_peak_db !== undefined ? ` / ${file.true_peak_db.toFixed(1)} dBTP` : '';
+    const gain = file.loudness_gain_db !== null && file.loudness_gain_db !== undefined
+        ? ` <span class="text-xs text-blue-600" title="Loudness-normalized copy, gain applied">(${file.loudness_gain_db > 0 ? '+' : ''}${file.loudness_gain_db.toFixed(1)} dB)</span>`
+        : '';
+    return `<span class="text-gray-600"><span class="font-semibold">LUFS:</span> ${file.loudness_lufs.toFixed(1)}${peak}${gain}</span>`;
+}
+
 function formatMetric(value, label) {
     if (value === undefined || value === null) {
         return `${label}:-`;
@@ -0,0 +1,59 @@
+/**
+ * Hypotheses — N-best и MBR уверенности Kaldi под карточкой движка:
+ * альтернативы, совпадающие с оригиналом, подсказывают опечатку в эталоне
+ */
+const HYPOTHESIS_ENGINES = ['kaldi', 'kaldi-nolm'];
+
+async function initHypotheses(file) {
+    const engines = HYPOTHESIS_ENGINES.filter(engine => tr(file, engine).status === 'processed');
+    for (const engine of engines) {
+        const el = document.getElementById(`tr-nbest-${engine}`);
+        if (!el) continue;
+
+        const hyps = await loadHypotheses(file.id, engine);
+        if (!hyps || (!hyps.nbest?.length && !hyps.mbr)) continue;
+        el.innerHTML = renderHypotheses(hyps, file.transcription_original || '');
+    }
+}
+
+async function loadHypotheses(fileId, engine) {
+    try {
+        const res = await fetch(`${API_BASE}/api/files/${fileId}/hypotheses?engine=${encodeURIComponent(engine)}`);
+        const data = await res.json();
+        return data.success ? data.data : null;
+    } catch (e) {
+        console.error('Failed to load hypotheses:', e);
+        return null;
+    }
+}
+
+function renderHypotheses(hyps, original) {
+    const norm = s => s.toLowerCase().replace(/\s+/g, ' ').trim();
+    const ref = norm(original);
+
+    let html = '<details class="mt-2 text-sm"><summary class="cursor-pointer text-gray-600">';
+    html += hyps.nbest?.length ? `N-best (${hyps.nbest.length})` : 'MBR';
+    html += '</summary><div class="mt-1 space-y-1">';
+
+    (hyps.nbest || []).forEach((h, i) => {
+        const match = ref && norm(h.text) === ref;
+        const cost = (h.lm_cost + h.acoustic_cost).toFixed(2);
+        html += `<div class="flex gap-2${match ? ' text-green-700 font-semibold' : ''}">
+            <span class="text-gray-400 w-5 text-right">${i + 1}</span>
+            <span class="flex-1">${escapeWord(h.text) || '<span class="text-gray-400">∅</span>'}</span>
+            <span class="text-gray-400" title="LM + acoustic cost">${cost}</span>
+        </div>`;
+    });
+
+    if (hyps.mbr) {
+        const words = (hyps.mbr.words || []).map(w => {
+            const low = w.confidence < LOW_CONFIDENCE;
+            return `<span class="${low ? 'underline decoration-red-400 decoration-wavy' : ''}"
+                title="${(w.confidence * 100).toFixed(0)}%">${escapeWord(w.word)}</span>`;
+        }).join(' ');
+        html += `<div class="border-t pt-1 mt-1"><span class="text-gray-500">MBR
+            (risk ${hyps.mbr.bayes_risk.toFixed(2)}, conf ${(hyps.mbr.confidence * 100).toFixed(0)}%):</span> ${words}</div>`;
+    }
+
+    return html + '</div></details>';
+}
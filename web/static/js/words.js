@@ -0,0 +1,63 @@
+/**
+ * Words — слова движков с таймингом: подсветка при воспроизведении,
+ * клик по слову — перемотка, низкая уверенность подчёркнута
+ */
+const WORD_ENGINES = ['kaldi', 'kaldi-nolm', 'whisper-local', 'whisper-openai'];
+const LOW_CONFIDENCE = 0.5;
+
+async function initWords(file) {
+    const audio = document.getElementById('detail-audio');
+    if (!audio) return;
+
+    const engines = WORD_ENGINES.filter(engine => tr(file, engine).status === 'processed');
+    const loaded = await Promise.all(engines.map(engine => loadWords(file.id, engine)));
+
+    const spans = [];
+    engines.forEach((engine, i) => {
+        const words = loaded[i];
+        const el = document.getElementById(`tr-text-${engine}`);
+        if (!el || !words.length) return;
+
+        el.innerHTML = words.map(renderWord).join(' ');
+        el.querySelectorAll('.word').forEach(span => {
+            span.addEventListener('click', () => {
+                audio.currentTime = parseFloat(span.dataset.start);
+                audio.play();
+            });
+            spans.push(span);
+        });
+    });
+
+    if (!spans.length) return;
+
+    audio.addEventListener('timeupdate', () => {
+        const t = audio.currentTime;
+        spans.forEach(span => {
+            const active = t >= parseFloat(span.dataset.start) && t < parseFloat(span.dataset.end);
+            span.classList.toggle('bg-yellow-200', active);
+        });
+    });
+}
+
+async function loadWords(fileId, engine) {
+    try {
+        const res = await fetch(`${API_BASE}/api/files/${fileId}/words?engine=${encodeURIComponent(engine)}`);
+        const data = await res.json();
+        return data.success && data.data.words ? data.data.words : [];
+    } catch (e) {
+        console.error('Failed to load words:', e);
+        return [];
+    }
+}
+
+function renderWord(w) {
+    const low = w.confidence != null && w.confidence < LOW_CONFIDENCE;
+    const conf = w.confidence != null ? ` (${(w.confidence * 100).toFixed(0)}%)` : '';
+    const title = `${w.start.toFixed(2)}–${w.end.toFixed(2)}s${conf}`;
+    return `<span class="word cursor-pointer rounded${low ? ' underline decoration-red-400 decoration-wavy' : ''}"
+        data-start="${w.start}" data-end="${w.end}" title="${title}">${escapeWord(w.word)}</span>`;
+}
+
+function escapeWord(s) {
+    return s.replace(/[&<>"]/g, c => ({ '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;' }[c]));
+}